@@ -0,0 +1,24 @@
+-- Message Attachments, Priorities and Expiry Schema
+-- Run this in Supabase SQL Editor after SCHEMA_MESSAGES.sql
+
+-- 1. Priority and expiry on broadcast_messages
+ALTER TABLE broadcast_messages ADD COLUMN IF NOT EXISTS priority VARCHAR(20) NOT NULL DEFAULT 'normal'; -- 'normal', 'urgent', 'pinned'
+ALTER TABLE broadcast_messages ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ; -- NULL = never expires
+
+-- 2. Create message_attachments table (files live in the backend's ATTACHMENTS_DIR)
+CREATE TABLE IF NOT EXISTS message_attachments (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  admin_id UUID NOT NULL REFERENCES admins(id) ON DELETE CASCADE,
+  message_id UUID REFERENCES broadcast_messages(id) ON DELETE CASCADE, -- NULL until the message is sent
+  file_name VARCHAR(255) NOT NULL,
+  content_type VARCHAR(100) NOT NULL,
+  size_bytes BIGINT NOT NULL,
+  storage_key VARCHAR(64) NOT NULL UNIQUE,
+  sha256 CHAR(64) NOT NULL,
+  created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- 3. Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_broadcast_messages_expires_at ON broadcast_messages(expires_at) WHERE expires_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_message_attachments_message_id ON message_attachments(message_id);
+CREATE INDEX IF NOT EXISTS idx_message_attachments_admin_id ON message_attachments(admin_id);
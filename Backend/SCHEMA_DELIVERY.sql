@@ -0,0 +1,38 @@
+-- Broadcast Delivery Queue Schema
+-- Run this in Supabase SQL Editor after SCHEMA_MESSAGES.sql
+
+-- 1. Idempotency key so retried sends don't create duplicate messages. Keys
+-- are per admin: one admin's key never matches another admin's message.
+ALTER TABLE broadcast_messages ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(255);
+ALTER TABLE broadcast_messages DROP CONSTRAINT IF EXISTS broadcast_messages_idempotency_key_key;
+ALTER TABLE broadcast_messages DROP CONSTRAINT IF EXISTS broadcast_messages_admin_idempotency_key;
+ALTER TABLE broadcast_messages ADD CONSTRAINT broadcast_messages_admin_idempotency_key
+  UNIQUE (admin_id, idempotency_key);
+
+-- 2. Create broadcast_jobs table (one delivery job per broadcast message)
+CREATE TABLE IF NOT EXISTS broadcast_jobs (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  message_id UUID NOT NULL UNIQUE REFERENCES broadcast_messages(id) ON DELETE CASCADE,
+  status VARCHAR(20) NOT NULL DEFAULT 'queued', -- 'queued', 'running', 'completed', 'failed'
+  audience JSONB NOT NULL DEFAULT '{}'::jsonb, -- Who the message is for, resolved when the job runs
+  total_recipients INT DEFAULT 0,
+  delivered_recipients INT DEFAULT 0,
+  attempts INT DEFAULT 0,
+  last_error TEXT,
+  next_attempt_at TIMESTAMPTZ, -- A failed run is retried from this time; NULL = now
+  notified_at TIMESTAMPTZ, -- Pushes sent; a re-run doesn't send them again
+  created_at TIMESTAMP DEFAULT NOW(),
+  updated_at TIMESTAMP DEFAULT NOW(),
+  completed_at TIMESTAMP
+);
+
+-- Databases created before failed runs were retried
+ALTER TABLE broadcast_jobs ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ;
+ALTER TABLE broadcast_jobs ADD COLUMN IF NOT EXISTS notified_at TIMESTAMPTZ;
+
+-- 3. Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_broadcast_jobs_status ON broadcast_jobs(status);
+CREATE INDEX IF NOT EXISTS idx_broadcast_jobs_message_id ON broadcast_jobs(message_id);
+
+-- 4. Targeted audience selector stored with each message for auditing
+ALTER TABLE broadcast_messages ADD COLUMN IF NOT EXISTS audience JSONB;
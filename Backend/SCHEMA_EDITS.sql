@@ -0,0 +1,19 @@
+-- Message Editing and Recall Schema
+-- Run this in Supabase SQL Editor after SCHEMA_MESSAGES.sql
+
+-- 1. Edit and recall markers on broadcast_messages
+ALTER TABLE broadcast_messages ADD COLUMN IF NOT EXISTS edited_at TIMESTAMPTZ; -- NULL = never edited
+ALTER TABLE broadcast_messages ADD COLUMN IF NOT EXISTS recalled_at TIMESTAMPTZ; -- NULL = visible to recipients
+
+-- 2. Create message_edits table (previous versions of edited messages)
+CREATE TABLE IF NOT EXISTS message_edits (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  message_id UUID NOT NULL REFERENCES broadcast_messages(id) ON DELETE CASCADE,
+  admin_id UUID NOT NULL REFERENCES admins(id) ON DELETE CASCADE,
+  previous_title VARCHAR(255) NOT NULL,
+  previous_message TEXT NOT NULL,
+  edited_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- 3. Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_message_edits_message_id ON message_edits(message_id, edited_at DESC);
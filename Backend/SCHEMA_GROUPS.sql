@@ -10,12 +10,16 @@ CREATE TABLE IF NOT EXISTS groups (
   location_lon FLOAT,
   threshold_meters FLOAT DEFAULT 100.0,
   status VARCHAR(50) DEFAULT 'inactive', -- 'active', 'closed', 'inactive'
+  group_only BOOLEAN DEFAULT false, -- Scope of the open window; false = all students may submit
   window_start_time TIMESTAMP,
   window_end_time TIMESTAMP,
   created_at TIMESTAMP DEFAULT NOW(),
   updated_at TIMESTAMP DEFAULT NOW()
 );
 
+-- Databases created before group_only was stored
+ALTER TABLE groups ADD COLUMN IF NOT EXISTS group_only BOOLEAN DEFAULT false;
+
 -- 2. Create group_students junction table (many-to-many)
 CREATE TABLE IF NOT EXISTS group_students (
   id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
@@ -46,10 +50,11 @@ CREATE INDEX IF NOT EXISTS idx_group_students_student_id ON group_students(stude
 CREATE INDEX IF NOT EXISTS idx_group_attendance_group_id ON group_attendance(group_id);
 CREATE INDEX IF NOT EXISTS idx_group_attendance_student_id ON group_attendance(student_id);
 
--- 5. Disable RLS for development (enable policies for production)
-ALTER TABLE groups DISABLE ROW LEVEL SECURITY;
-ALTER TABLE group_students DISABLE ROW LEVEL SECURITY;
-ALTER TABLE group_attendance DISABLE ROW LEVEL SECURITY;
+-- 5. Enable RLS; only the backend's service key can reach these tables
+-- (SCHEMA_RLS.sql covers every table)
+ALTER TABLE groups ENABLE ROW LEVEL SECURITY;
+ALTER TABLE group_students ENABLE ROW LEVEL SECURITY;
+ALTER TABLE group_attendance ENABLE ROW LEVEL SECURITY;
 
 -- 6. Create function to update updated_at timestamp
 CREATE OR REPLACE FUNCTION update_updated_at_column()
@@ -0,0 +1,52 @@
+-- Paginated Inbox Schema
+-- Run this in Supabase SQL Editor after SCHEMA_ATTACHMENTS.sql
+
+-- 1. Denormalize message priority onto message_recipients so the inbox can be
+--    sorted and paginated with a keyset cursor on a single table
+--    (0 = pinned, 1 = urgent, 2 = normal)
+ALTER TABLE message_recipients ADD COLUMN IF NOT EXISTS priority_rank SMALLINT NOT NULL DEFAULT 2;
+
+CREATE OR REPLACE FUNCTION message_priority_rank(priority VARCHAR)
+RETURNS SMALLINT AS $$
+  SELECT CASE priority WHEN 'pinned' THEN 0 WHEN 'urgent' THEN 1 ELSE 2 END::SMALLINT;
+$$ LANGUAGE sql IMMUTABLE;
+
+-- 2. Keep priority_rank in sync with broadcast_messages.priority
+CREATE OR REPLACE FUNCTION set_recipient_priority_rank()
+RETURNS TRIGGER AS $$
+BEGIN
+    SELECT message_priority_rank(priority) INTO NEW.priority_rank
+    FROM broadcast_messages WHERE id = NEW.message_id;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS set_message_recipients_priority_rank ON message_recipients;
+CREATE TRIGGER set_message_recipients_priority_rank
+    BEFORE INSERT ON message_recipients
+    FOR EACH ROW EXECUTE FUNCTION set_recipient_priority_rank();
+
+CREATE OR REPLACE FUNCTION propagate_message_priority()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE message_recipients SET priority_rank = message_priority_rank(NEW.priority)
+    WHERE message_id = NEW.id;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+DROP TRIGGER IF EXISTS propagate_broadcast_messages_priority ON broadcast_messages;
+CREATE TRIGGER propagate_broadcast_messages_priority
+    AFTER UPDATE OF priority ON broadcast_messages
+    FOR EACH ROW WHEN (OLD.priority IS DISTINCT FROM NEW.priority)
+    EXECUTE FUNCTION propagate_message_priority();
+
+-- 3. Backfill existing rows
+UPDATE message_recipients mr SET priority_rank = message_priority_rank(bm.priority)
+FROM broadcast_messages bm WHERE bm.id = mr.message_id;
+
+-- 4. Create indexes for performance (matches the inbox sort order)
+CREATE INDEX IF NOT EXISTS idx_message_recipients_inbox
+  ON message_recipients(student_id, priority_rank, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_message_recipients_unread
+  ON message_recipients(student_id) WHERE is_read = false;
@@ -45,8 +45,9 @@ CREATE INDEX IF NOT EXISTS idx_message_recipients_message_id ON message_recipien
 CREATE INDEX IF NOT EXISTS idx_message_recipients_student_id ON message_recipients(student_id);
 CREATE INDEX IF NOT EXISTS idx_message_recipients_is_read ON message_recipients(is_read);
 
--- 5. Enable Row Level Security (optional, for Supabase)
--- ALTER TABLE fcm_tokens ENABLE ROW LEVEL SECURITY;
--- ALTER TABLE broadcast_messages ENABLE ROW LEVEL SECURITY;
--- ALTER TABLE message_recipients ENABLE ROW LEVEL SECURITY;
+-- 5. Enable RLS; only the backend's service key can reach these tables
+-- (SCHEMA_RLS.sql covers every table)
+ALTER TABLE fcm_tokens ENABLE ROW LEVEL SECURITY;
+ALTER TABLE broadcast_messages ENABLE ROW LEVEL SECURITY;
+ALTER TABLE message_recipients ENABLE ROW LEVEL SECURITY;
 
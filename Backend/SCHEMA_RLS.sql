@@ -0,0 +1,60 @@
+-- Row Level Security
+-- Run this in Supabase SQL Editor after the other SCHEMA_*.sql files.
+-- Set SUPABASE_SERVICE_KEY for the backend first: with RLS on, the anon key
+-- can no longer read or write anything.
+--
+-- The Go backend is the database's only client. It calls Supabase with the
+-- service-role key, which bypasses RLS; the apps only talk to the backend.
+-- So every table gets RLS with no policies for the anon and authenticated
+-- roles, which means requests made with the anon key see no rows and can't
+-- write. Their table privileges are revoked too, so a policy added later by
+-- mistake still doesn't open a table. Safe to re-run.
+
+-- 1. Drop the permissive policies earlier setup guides suggested
+DROP POLICY IF EXISTS "Allow public read on admins" ON admins;
+DROP POLICY IF EXISTS "Allow public read on students" ON students;
+
+-- 2. Enable RLS on every table
+ALTER TABLE admins ENABLE ROW LEVEL SECURITY;
+ALTER TABLE students ENABLE ROW LEVEL SECURITY;
+ALTER TABLE groups ENABLE ROW LEVEL SECURITY;
+ALTER TABLE group_students ENABLE ROW LEVEL SECURITY;
+ALTER TABLE group_attendance ENABLE ROW LEVEL SECURITY;
+ALTER TABLE fcm_tokens ENABLE ROW LEVEL SECURITY;
+ALTER TABLE broadcast_messages ENABLE ROW LEVEL SECURITY;
+ALTER TABLE message_recipients ENABLE ROW LEVEL SECURITY;
+ALTER TABLE broadcast_jobs ENABLE ROW LEVEL SECURITY;
+ALTER TABLE message_attachments ENABLE ROW LEVEL SECURITY;
+ALTER TABLE message_edits ENABLE ROW LEVEL SECURITY;
+ALTER TABLE message_templates ENABLE ROW LEVEL SECURITY;
+ALTER TABLE message_threads ENABLE ROW LEVEL SECURITY;
+ALTER TABLE thread_messages ENABLE ROW LEVEL SECURITY;
+ALTER TABLE scheduled_messages ENABLE ROW LEVEL SECURITY;
+ALTER TABLE scheduled_windows ENABLE ROW LEVEL SECURITY;
+
+-- 3. Take direct access away from the client roles, now and for new tables
+REVOKE ALL ON ALL TABLES IN SCHEMA public FROM anon, authenticated;
+REVOKE ALL ON ALL SEQUENCES IN SCHEMA public FROM anon, authenticated;
+REVOKE EXECUTE ON ALL FUNCTIONS IN SCHEMA public FROM anon, authenticated;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE ALL ON TABLES FROM anon, authenticated;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE ALL ON SEQUENCES FROM anon, authenticated;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public REVOKE EXECUTE ON FUNCTIONS FROM anon, authenticated;
+
+-- 4. The service role keeps full access
+GRANT ALL ON ALL TABLES IN SCHEMA public TO service_role;
+GRANT ALL ON ALL SEQUENCES IN SCHEMA public TO service_role;
+
+-- 5. Verify: every table should show rls_enabled = true and no policies
+SELECT
+    t.tablename,
+    t.rowsecurity AS rls_enabled,
+    COUNT(p.policyname) AS policies
+FROM pg_tables t
+LEFT JOIN pg_policies p ON p.schemaname = t.schemaname AND p.tablename = t.tablename
+WHERE t.schemaname = 'public'
+GROUP BY t.tablename, t.rowsecurity
+ORDER BY t.tablename;
+
+-- To check from outside, this should now return [] or a permission error:
+-- curl "https://YOUR_PROJECT_ID.supabase.co/rest/v1/students" \
+--   -H "apikey: YOUR_ANON_KEY" -H "Authorization: Bearer YOUR_ANON_KEY"
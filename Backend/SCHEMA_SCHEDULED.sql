@@ -0,0 +1,26 @@
+-- Scheduled Broadcast Messages Schema
+-- Run this in Supabase SQL Editor after SCHEMA_MESSAGES.sql
+
+-- 1. Create scheduled_messages table
+CREATE TABLE IF NOT EXISTS scheduled_messages (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  admin_id UUID NOT NULL REFERENCES admins(id) ON DELETE CASCADE,
+  group_id UUID REFERENCES groups(id) ON DELETE CASCADE, -- NULL = all students
+  title VARCHAR(255) NOT NULL,
+  message TEXT NOT NULL,
+  sent_to_all BOOLEAN DEFAULT false,
+  send_at TIMESTAMPTZ NOT NULL, -- Next delivery time
+  recurrence VARCHAR(100), -- NULL = one-off, e.g. 'every monday 08:00'
+  status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'sent', 'cancelled'
+  send_count INT DEFAULT 0,
+  last_sent_at TIMESTAMPTZ,
+  last_message_id UUID REFERENCES broadcast_messages(id) ON DELETE SET NULL,
+  created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- 2. Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_scheduled_messages_due ON scheduled_messages(status, send_at);
+CREATE INDEX IF NOT EXISTS idx_scheduled_messages_admin_id ON scheduled_messages(admin_id);
+
+-- 3. Targeted audience selector (overrides group_id/sent_to_all when set)
+ALTER TABLE scheduled_messages ADD COLUMN IF NOT EXISTS audience JSONB;
@@ -0,0 +1,26 @@
+-- Message Templates Schema
+-- Run this in Supabase SQL Editor after SCHEMA_MESSAGES.sql and SCHEMA_DELIVERY.sql
+
+-- 1. Create message_templates table
+-- Placeholders: {student_name}, {student_id}, {group}, {end_time}, {attendance_percent}
+CREATE TABLE IF NOT EXISTS message_templates (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  admin_id UUID NOT NULL REFERENCES admins(id) ON DELETE CASCADE,
+  name VARCHAR(100) NOT NULL,
+  title VARCHAR(255) NOT NULL,
+  body TEXT NOT NULL,
+  shared BOOLEAN DEFAULT false, -- Visible to every admin
+  created_at TIMESTAMPTZ DEFAULT NOW(),
+  updated_at TIMESTAMPTZ DEFAULT NOW(),
+  UNIQUE(admin_id, name)
+);
+
+-- 2. Personalized broadcasts: placeholders are rendered per recipient at delivery
+ALTER TABLE broadcast_messages ADD COLUMN IF NOT EXISTS personalized BOOLEAN DEFAULT false;
+ALTER TABLE broadcast_messages ADD COLUMN IF NOT EXISTS template_group_id UUID REFERENCES groups(id) ON DELETE SET NULL;
+ALTER TABLE message_recipients ADD COLUMN IF NOT EXISTS rendered_title VARCHAR(255);
+ALTER TABLE message_recipients ADD COLUMN IF NOT EXISTS rendered_message TEXT;
+
+-- 3. Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_message_templates_admin_id ON message_templates(admin_id);
+CREATE INDEX IF NOT EXISTS idx_message_templates_shared ON message_templates(shared) WHERE shared = true;
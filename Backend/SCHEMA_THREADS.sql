@@ -0,0 +1,37 @@
+-- Two-way Messaging (Student Replies + Admin Inbox) Schema
+-- Run this in Supabase SQL Editor after SCHEMA_MESSAGES.sql
+
+-- 1. Create message_threads table (one conversation between a student and an admin)
+CREATE TABLE IF NOT EXISTS message_threads (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  student_id UUID NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+  admin_id UUID NOT NULL REFERENCES admins(id) ON DELETE CASCADE,
+  group_id UUID REFERENCES groups(id) ON DELETE SET NULL,
+  broadcast_message_id UUID REFERENCES broadcast_messages(id) ON DELETE SET NULL, -- NULL = opened by the student
+  subject VARCHAR(255) NOT NULL,
+  last_message_at TIMESTAMPTZ DEFAULT NOW(),
+  student_deleted BOOLEAN DEFAULT false, -- Hidden for the student only
+  admin_deleted BOOLEAN DEFAULT false, -- Hidden for the admin only
+  created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- 2. Create thread_messages table
+CREATE TABLE IF NOT EXISTS thread_messages (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  thread_id UUID NOT NULL REFERENCES message_threads(id) ON DELETE CASCADE,
+  sender_type VARCHAR(20) NOT NULL, -- 'student' or 'admin'
+  sender_id UUID NOT NULL, -- References students(id) or admins(id)
+  body TEXT NOT NULL,
+  is_read BOOLEAN DEFAULT false,
+  read_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- 3. Create indexes for performance
+-- One reply thread per student per broadcast
+CREATE UNIQUE INDEX IF NOT EXISTS idx_message_threads_broadcast_student
+  ON message_threads(broadcast_message_id, student_id) WHERE broadcast_message_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_message_threads_admin_id ON message_threads(admin_id, last_message_at DESC);
+CREATE INDEX IF NOT EXISTS idx_message_threads_student_id ON message_threads(student_id, last_message_at DESC);
+CREATE INDEX IF NOT EXISTS idx_thread_messages_thread_id ON thread_messages(thread_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_thread_messages_unread ON thread_messages(thread_id, sender_type) WHERE is_read = false;
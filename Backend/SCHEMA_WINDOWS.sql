@@ -0,0 +1,21 @@
+-- Scheduled Attendance Windows Schema
+-- Run this in Supabase SQL Editor after SCHEMA_GROUPS.sql and SCHEMA_SCHEDULED.sql
+
+-- 1. Create scheduled_windows table
+CREATE TABLE IF NOT EXISTS scheduled_windows (
+  id UUID DEFAULT gen_random_uuid() PRIMARY KEY,
+  admin_id UUID NOT NULL REFERENCES admins(id) ON DELETE CASCADE,
+  group_id UUID NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+  group_only BOOLEAN DEFAULT false, -- false = all students may submit
+  opens_at TIMESTAMPTZ NOT NULL, -- Next opening time
+  recurrence VARCHAR(100), -- NULL = one-off, e.g. 'every monday 09:00'
+  status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'opened', 'cancelled'
+  open_count INT DEFAULT 0,
+  last_opened_at TIMESTAMPTZ,
+  created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- 2. Create indexes for performance
+CREATE INDEX IF NOT EXISTS idx_scheduled_windows_due ON scheduled_windows(status, opens_at);
+CREATE INDEX IF NOT EXISTS idx_scheduled_windows_admin_id ON scheduled_windows(admin_id);
+CREATE INDEX IF NOT EXISTS idx_scheduled_windows_group_id ON scheduled_windows(group_id);
@@ -0,0 +1,537 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"attendance-system/apierror"
+)
+
+// The /api/v1 surface is resource-oriented, takes and returns JSON only and
+// reports errors in a typed envelope:
+//
+//	{"data": ...}
+//	{"error": {"code": "not_found", "message": "...", "request_id": "..."}}
+//
+// Each v1 route adapts the request onto the handler that also serves the
+// legacy /api/<verb> route, so both surfaces share one implementation and
+// the legacy routes keep their exact behaviour. Legacy bodies that don't
+// follow v1's conventions (snake_case fields, a pagination object on
+// paginated lists) are converted to v1 types listed in v1Responses.
+
+const apiV1Prefix = "/api/v1/"
+
+// APIError is the body of the v1 error envelope; codes are listed in the
+// apierror package
+type APIError struct {
+	Code      apierror.Code `json:"code"`
+	Message   string        `json:"message"`
+	RequestID string        `json:"request_id,omitempty"`
+}
+
+func isV1Request(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, apiV1Prefix)
+}
+
+// writeAPIError writes the v1 error envelope
+func writeAPIError(w http.ResponseWriter, r *http.Request, apiErr *apierror.Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(map[string]APIError{
+		"error": {Code: apiErr.Code, Message: apiErr.Message, RequestID: requestID(r)},
+	})
+}
+
+// legacyInput is how a legacy handler reads its parameters
+type legacyInput int
+
+const (
+	inputForm legacyInput = iota // r.FormValue / URL query
+	inputJSON                    // JSON request body
+	inputRaw                     // Body passed through untouched (multipart uploads)
+)
+
+// v1Route maps a v1 resource route onto a legacy handler. Path parameters
+// are named after the legacy field they fill in.
+type v1Route struct {
+	method  string
+	pattern string
+	handler http.HandlerFunc
+	input   legacyInput
+	stream  bool // Success responses are files or event streams, not JSON
+}
+
+var v1Routes = []v1Route{
+	// Sessions
+	{http.MethodPost, "/api/v1/sessions/admin", adminLoginHandler, inputForm, false},
+	{http.MethodPost, "/api/v1/sessions/student", studentLoginHandler, inputForm, false},
+
+	// Groups and their attendance windows
+	{http.MethodGet, "/api/v1/groups", getMyGroupsHandler, inputForm, false},
+	{http.MethodPost, "/api/v1/groups", createGroupHandler, inputForm, false},
+	{http.MethodDelete, "/api/v1/groups/{group_id}", deleteGroupHandler, inputForm, false},
+	{http.MethodPut, "/api/v1/groups/{group_id}/name", updateSessionNameHandler, inputForm, false},
+	{http.MethodGet, "/api/v1/groups/{group_id}/location", getAdminLocationHandler, inputForm, false},
+	{http.MethodPut, "/api/v1/groups/{group_id}/location", setCenterHandler, inputForm, false},
+	{http.MethodGet, "/api/v1/groups/{group_id}/students", getGroupStudentsHandler, inputForm, false},
+	{http.MethodPost, "/api/v1/groups/{group_id}/students", addStudentsToGroupHandler, inputForm, false},
+	{http.MethodPost, "/api/v1/groups/{group_id}/windows", startWindowHandler, inputForm, false},
+	{http.MethodGet, "/api/v1/groups/{group_id}/windows/current", getWindowStatusHandler, inputForm, false},
+	{http.MethodDelete, "/api/v1/groups/{group_id}/windows/current", closeWindowHandler, inputForm, false},
+	{http.MethodGet, "/api/v1/groups/{group_id}/windows/current/summary", getWindowSummaryHandler, inputForm, false},
+	{http.MethodGet, "/api/v1/groups/{group_id}/windows/scheduled", getScheduledWindowsHandler, inputForm, false},
+	{http.MethodPost, "/api/v1/groups/{group_id}/windows/scheduled", scheduleWindowHandler, inputJSON, false},
+	{http.MethodDelete, "/api/v1/groups/{group_id}/windows/scheduled/{scheduled_id}", cancelScheduledWindowHandler, inputJSON, false},
+	{http.MethodGet, "/api/v1/groups/{group_id}/attendance", getAllStudentLocationsHandler, inputForm, false},
+	{http.MethodPost, "/api/v1/groups/{group_id}/attendance", submitAttendanceHandler, inputForm, false},
+	{http.MethodGet, "/api/v1/groups/{group_id}/attendance/export", downloadCSVHandler, inputForm, true},
+
+	// Students
+	{http.MethodGet, "/api/v1/students", getAllStudentsHandler, inputForm, false},
+	{http.MethodPost, "/api/v1/students", addStudentHandler, inputJSON, false},
+	{http.MethodGet, "/api/v1/students/{student_id}/attendance", getStudentAttendanceHistoryHandler, inputForm, false},
+	{http.MethodGet, "/api/v1/students/{student_id}/dashboard", getStudentDashboardHandler, inputForm, false},
+	{http.MethodGet, "/api/v1/students/{student_id}/windows/current", getWindowStatusHandler, inputForm, false},
+	{http.MethodGet, "/api/v1/students/{student_id}/messages", getMessagesHandler, inputForm, false},
+	{http.MethodGet, "/api/v1/students/{student_id}/messages/unread-count", getUnreadCountHandler, inputForm, false},
+	{http.MethodPost, "/api/v1/students/{student_id}/messages/{message_id}/read", markMessageReadHandler, inputJSON, false},
+	{http.MethodDelete, "/api/v1/students/{student_id}/messages/{message_id}", deleteMessageHandler, inputForm, false},
+	{http.MethodPost, "/api/v1/students/{student_id}/messages/{message_id}/replies", replyMessageHandler, inputJSON, false},
+	{http.MethodGet, "/api/v1/students/{student_id}/threads", getStudentThreadsHandler, inputForm, false},
+	{http.MethodPost, "/api/v1/devices", saveFCMTokenHandler, inputJSON, false},
+
+	// Broadcast messages
+	{http.MethodGet, "/api/v1/messages", getSentMessagesHandler, inputForm, false},
+	{http.MethodPost, "/api/v1/messages", sendBroadcastMessageHandler, inputJSON, false},
+	{http.MethodPatch, "/api/v1/messages/{message_id}", editMessageHandler, inputJSON, false},
+	{http.MethodDelete, "/api/v1/messages/{message_id}", adminDeleteMessageHandler, inputForm, false},
+	{http.MethodGet, "/api/v1/messages/{message_id}/status", getBroadcastStatusHandler, inputForm, false},
+	{http.MethodGet, "/api/v1/messages/{message_id}/edits", getMessageEditsHandler, inputForm, false},
+	{http.MethodGet, "/api/v1/messages/{message_id}/receipts", getMessageReceiptsHandler, inputForm, false},
+	{http.MethodGet, "/api/v1/messages/{message_id}/receipts/unread/export", exportUnreadHandler, inputForm, true},
+	{http.MethodPost, "/api/v1/messages/{message_id}/reminders", renotifyUnreadHandler, inputJSON, false},
+	{http.MethodPost, "/api/v1/messages/{message_id}/recall", recallMessageHandler, inputJSON, false},
+	{http.MethodPost, "/api/v1/attachments", uploadAttachmentHandler, inputRaw, false},
+	{http.MethodGet, "/api/v1/attachments/{attachment_id}", downloadAttachmentHandler, inputForm, true},
+	{http.MethodGet, "/api/v1/scheduled-messages", getScheduledMessagesHandler, inputForm, false},
+	{http.MethodPost, "/api/v1/scheduled-messages", scheduleMessageHandler, inputJSON, false},
+	{http.MethodDelete, "/api/v1/scheduled-messages/{scheduled_id}", cancelScheduledMessageHandler, inputJSON, false},
+
+	// Templates
+	{http.MethodGet, "/api/v1/templates", getTemplatesHandler, inputForm, false},
+	{http.MethodPost, "/api/v1/templates", createTemplateHandler, inputJSON, false},
+	{http.MethodPost, "/api/v1/templates/preview", previewTemplateHandler, inputJSON, false},
+	{http.MethodPut, "/api/v1/templates/{template_id}", updateTemplateHandler, inputJSON, false},
+	{http.MethodDelete, "/api/v1/templates/{template_id}", deleteTemplateHandler, inputForm, false},
+
+	// Conversations
+	{http.MethodGet, "/api/v1/admins/{admin_id}/inbox", getAdminInboxHandler, inputForm, false},
+	{http.MethodPost, "/api/v1/threads", openThreadHandler, inputJSON, false},
+	{http.MethodGet, "/api/v1/threads/{thread_id}", getThreadHandler, inputForm, false},
+	{http.MethodDelete, "/api/v1/threads/{thread_id}", deleteThreadHandler, inputForm, false},
+	{http.MethodPost, "/api/v1/threads/{thread_id}/messages", replyThreadHandler, inputJSON, false},
+	{http.MethodPost, "/api/v1/threads/{thread_id}/read", markThreadReadHandler, inputJSON, false},
+
+	// Real-time events
+	{http.MethodGet, "/api/v1/events", eventsHandler, inputForm, true},
+}
+
+// AttendanceRecord is one student's submission to a window
+type AttendanceRecord struct {
+	StudentID   string  `json:"student_id"`
+	StudentName string  `json:"student_name"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	Distance    float64 `json:"distance"`
+	SubmittedAt string  `json:"submitted_at"`
+	Status      string  `json:"status"`
+}
+
+// Pagination describes where a page of a list sits. Offset-paged lists
+// set page, limit and total_pages; cursor-paged lists set next_cursor.
+type Pagination struct {
+	Page       int     `json:"page,omitempty"`
+	Limit      int     `json:"limit,omitempty"`
+	TotalPages int     `json:"total_pages,omitempty"`
+	HasMore    bool    `json:"has_more"`
+	NextCursor *string `json:"next_cursor,omitempty"`
+}
+
+// v1Response converts a route's legacy success body into its v1 data
+type v1Response struct {
+	fields  []apiField // The data object, for the OpenAPI document
+	convert func(legacy []byte) (interface{}, error)
+}
+
+var paginationField = req("pagination", ref("Pagination"))
+
+// v1Responses lists the routes whose legacy body is converted, by
+// "METHOD pattern"; the rest return the legacy body as is
+var v1Responses = map[string]v1Response{
+	"GET /api/v1/groups/{group_id}/attendance": {
+		fields:  []apiField{req("students", arrayOf(ref("AttendanceRecord"))), countField},
+		convert: convertAttendance,
+	},
+	"GET /api/v1/students": {
+		fields:  []apiField{req("students", arrayOf(anyObject())), countField, paginationField},
+		convert: convertPage("students"),
+	},
+	"GET /api/v1/messages": {
+		fields:  []apiField{req("messages", arrayOf(anyObject())), countField, paginationField},
+		convert: convertPage("messages"),
+	},
+	"GET /api/v1/students/{student_id}/messages": {
+		fields:  []apiField{req("messages", arrayOf(anyObject())), countField, paginationField},
+		convert: convertPage("messages"),
+	},
+}
+
+// convertAttendance turns get-all-student-locations' StudentLocations
+// into AttendanceRecords
+func convertAttendance(legacy []byte) (interface{}, error) {
+	var body struct {
+		Students []StudentLocation `json:"students"`
+	}
+	if err := json.Unmarshal(legacy, &body); err != nil {
+		return nil, err
+	}
+	records := make([]AttendanceRecord, 0, len(body.Students))
+	for _, loc := range body.Students {
+		records = append(records, AttendanceRecord{
+			StudentID:   loc.StudentID,
+			StudentName: loc.StudentName,
+			Latitude:    loc.Latitude,
+			Longitude:   loc.Longitude,
+			Distance:    loc.Distance,
+			SubmittedAt: loc.Timestamp,
+			Status:      loc.Status,
+		})
+	}
+	return map[string]interface{}{"students": records, "count": len(records)}, nil
+}
+
+// convertPage moves a legacy list's paging fields (page, limit, hasMore,
+// totalPages or has_more, next_cursor) into a Pagination; the items under
+// listKey are returned as they are
+func convertPage(listKey string) func(legacy []byte) (interface{}, error) {
+	return func(legacy []byte) (interface{}, error) {
+		var items map[string]json.RawMessage
+		if err := json.Unmarshal(legacy, &items); err != nil {
+			return nil, err
+		}
+		var body struct {
+			Count      int     `json:"count"`
+			Page       int     `json:"page"`
+			Limit      int     `json:"limit"`
+			TotalPages int     `json:"totalPages"`
+			HasMore    bool    `json:"hasMore"`
+			HasMoreV1  bool    `json:"has_more"`
+			NextCursor *string `json:"next_cursor"`
+		}
+		if err := json.Unmarshal(legacy, &body); err != nil {
+			return nil, err
+		}
+		list := items[listKey]
+		if list == nil {
+			list = json.RawMessage("[]")
+		}
+		return map[string]interface{}{
+			listKey: list,
+			"count": body.Count,
+			"pagination": Pagination{
+				Page:       body.Page,
+				Limit:      body.Limit,
+				TotalPages: body.TotalPages,
+				HasMore:    body.HasMore || body.HasMoreV1,
+				NextCursor: body.NextCursor,
+			},
+		}, nil
+	}
+}
+
+// registerV1Routes adds the /api/v1 surface to the router
+func registerV1Routes(router *Router) {
+	for _, route := range v1Routes {
+		router.Handle(route.method, route.pattern, adaptLegacy(route))
+	}
+}
+
+// pathParams lists the {name} segments of a pattern
+func pathParams(pattern string) []string {
+	var names []string
+	for _, segment := range strings.Split(pattern, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			names = append(names, segment[1:len(segment)-1])
+		}
+	}
+	return names
+}
+
+// formValue renders a JSON value the way a form field would carry it;
+// arrays become comma-separated lists
+func formValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case bool:
+		return strconv.FormatBool(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case []interface{}:
+		parts := make([]string, 0, len(value))
+		for _, item := range value {
+			parts = append(parts, formValue(item))
+		}
+		return strings.Join(parts, ",")
+	}
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
+
+// readJSONBody decodes an optional JSON object body
+func readJSONBody(r *http.Request) (map[string]interface{}, int, string) {
+	fields := map[string]interface{}{}
+	if r.Body == nil || r.ContentLength == 0 {
+		return fields, 0, ""
+	}
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, http.StatusBadRequest, "Failed to read request body"
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return fields, 0, ""
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		return nil, http.StatusUnsupportedMediaType, "Request body must be application/json"
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, http.StatusBadRequest, "Request body must be a JSON object"
+	}
+	return fields, 0, ""
+}
+
+// adaptLegacy builds the v1 handler for a route: it turns path parameters
+// and the JSON body into whatever the legacy handler reads, then rewraps
+// the legacy response in the v1 envelope
+func adaptLegacy(route v1Route) http.HandlerFunc {
+	params := pathParams(route.pattern)
+	convert := v1Responses[route.method+" "+route.pattern].convert
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		legacy := r.Clone(r.Context())
+		query := r.URL.Query()
+
+		if route.input == inputRaw {
+			for _, name := range params {
+				query.Set(name, r.PathValue(name))
+			}
+		} else {
+			fields, status, message := readJSONBody(r)
+			if status != 0 {
+				writeAPIError(w, r, apierror.New(status, message))
+				return
+			}
+			for _, name := range params {
+				fields[name] = r.PathValue(name)
+			}
+
+			switch route.input {
+			case inputJSON:
+				// Query parameters fill in fields the body doesn't set, so
+				// e.g. DELETE ...?admin_id=x works without a body
+				for key := range query {
+					if _, ok := fields[key]; !ok {
+						fields[key] = query.Get(key)
+					}
+				}
+				body, _ := json.Marshal(fields)
+				legacy.Body = io.NopCloser(bytes.NewReader(body))
+				legacy.ContentLength = int64(len(body))
+				legacy.Header.Set("Content-Type", "application/json")
+
+			case inputForm:
+				if r.Method == http.MethodGet || r.Method == http.MethodDelete {
+					for key, value := range fields {
+						query.Set(key, formValue(value))
+					}
+					legacy.Body = http.NoBody
+					legacy.ContentLength = 0
+				} else {
+					form := url.Values{}
+					for key, value := range fields {
+						form.Set(key, formValue(value))
+					}
+					body := form.Encode()
+					legacy.Body = io.NopCloser(strings.NewReader(body))
+					legacy.ContentLength = int64(len(body))
+					legacy.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				}
+			}
+		}
+		legacy.URL.RawQuery = query.Encode()
+		legacy.RequestURI = legacy.URL.RequestURI()
+
+		if route.stream {
+			sw := &streamWriter{ResponseWriter: w}
+			route.handler(sw, legacy)
+			if sw.failed {
+				writeAPIError(w, r, legacyError(sw.buf.Bytes(), sw.status))
+			}
+			return
+		}
+
+		rec := &captureWriter{header: http.Header{}}
+		route.handler(rec, legacy)
+		writeV1Response(w, r, rec, convert)
+	}
+}
+
+// legacyError recovers the error a handler wrote. Handlers answer with
+// respondError, which sees the v1 path and writes the envelope already;
+// anything else is treated as {"error": "..."} JSON or plain text.
+func legacyError(body []byte, status int) *apierror.Error {
+	var payload struct {
+		Error   json.RawMessage `json:"error"`
+		Code    apierror.Code   `json:"code"`
+		Message string          `json:"message"`
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		var envelope APIError
+		if json.Unmarshal(payload.Error, &envelope) == nil && envelope.Code != "" {
+			return &apierror.Error{Status: status, Code: envelope.Code, Message: envelope.Message}
+		}
+		apiErr := apierror.New(status, http.StatusText(status))
+		if payload.Code != "" {
+			apiErr.Code = payload.Code
+		}
+		var message string
+		if json.Unmarshal(payload.Error, &message) == nil && message != "" {
+			apiErr.Message = message
+		} else if payload.Message != "" {
+			apiErr.Message = payload.Message
+		}
+		return apiErr
+	}
+	if text := strings.TrimSpace(string(body)); text != "" {
+		return apierror.New(status, text)
+	}
+	return apierror.New(status, http.StatusText(status))
+}
+
+// writeV1Response wraps a captured legacy response: errors become the typed
+// envelope, JSON bodies are returned under "data", converted by convert
+// when the route has a v1 type of its own
+func writeV1Response(w http.ResponseWriter, r *http.Request, rec *captureWriter, convert func([]byte) (interface{}, error)) {
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	for key, values := range rec.header {
+		if key == "Content-Length" || key == "Content-Type" {
+			continue
+		}
+		w.Header()[key] = values
+	}
+
+	if status >= 400 {
+		writeAPIError(w, r, legacyError(rec.buf.Bytes(), status))
+		return
+	}
+
+	var data interface{}
+	if rec.buf.Len() > 0 {
+		var err error
+		if convert != nil {
+			data, err = convert(rec.buf.Bytes())
+		} else {
+			err = json.Unmarshal(rec.buf.Bytes(), &data)
+		}
+		if err != nil {
+			respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Internal server error", fmt.Errorf("handler returned an unexpected body: %w", err)))
+			return
+		}
+	}
+	// The envelope already says whether the call succeeded
+	if object, ok := data.(map[string]interface{}); ok {
+		delete(object, "success")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+// captureWriter buffers a legacy handler's response for rewrapping
+type captureWriter struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+func (c *captureWriter) Header() http.Header { return c.header }
+
+func (c *captureWriter) WriteHeader(status int) {
+	if c.status == 0 {
+		c.status = status
+	}
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	return c.buf.Write(b)
+}
+
+// streamWriter passes successful responses (files, CSV, SSE) straight
+// through but holds back error responses so they can be rewrapped
+type streamWriter struct {
+	http.ResponseWriter
+	status int
+	failed bool
+	buf    bytes.Buffer
+}
+
+func (s *streamWriter) WriteHeader(status int) {
+	if s.status != 0 {
+		return
+	}
+	s.status = status
+	if status >= 400 {
+		s.failed = true
+		return
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *streamWriter) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.WriteHeader(http.StatusOK)
+	}
+	if s.failed {
+		return s.buf.Write(b)
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *streamWriter) Flush() {
+	if s.failed {
+		return
+	}
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (s *streamWriter) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
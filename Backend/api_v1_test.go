@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"attendance-system/apierror"
+)
+
+func TestAdaptLegacy(t *testing.T) {
+	// A legacy handler reading form values and failing the old way
+	legacy := func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("group_id") == "missing" {
+			http.Error(w, "Group not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success":  true,
+			"group_id": r.FormValue("group_id"),
+			"name":     r.FormValue("name"),
+			"tags":     r.FormValue("tags"),
+		})
+	}
+	router := NewRouter()
+	router.Handle(http.MethodPatch, "/api/v1/groups/{group_id}", adaptLegacy(v1Route{http.MethodPatch, "/api/v1/groups/{group_id}", legacy, inputForm, false}))
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/groups/g1", strings.NewReader(`{"name":"CS101","tags":["a","b"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var ok struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &ok)
+	want := map[string]interface{}{"group_id": "g1", "name": "CS101", "tags": "a,b"}
+	for key, value := range want {
+		if ok.Data[key] != value {
+			t.Errorf("data[%s] = %v, want %v", key, ok.Data[key], value)
+		}
+	}
+	if _, found := ok.Data["success"]; found {
+		t.Error("envelope still carries the legacy success flag")
+	}
+
+	tests := []struct {
+		name, path, contentType, body string
+		status                        int
+		code                          apierror.Code
+	}{
+		{"legacy error", "/api/v1/groups/missing", "application/json", `{}`, http.StatusNotFound, apierror.NotFound},
+		{"not JSON", "/api/v1/groups/g1", "text/plain", `name=x`, http.StatusUnsupportedMediaType, apierror.UnsupportedMediaType},
+		{"bad JSON", "/api/v1/groups/g1", "application/json", `[1]`, http.StatusBadRequest, apierror.InvalidRequest},
+		{"unknown route", "/api/v1/nothing", "application/json", `{}`, http.StatusNotFound, apierror.NotFound},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPatch, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		var envelope struct {
+			Error APIError `json:"error"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &envelope)
+		if rec.Code != tt.status || envelope.Error.Code != tt.code {
+			t.Errorf("%s: got %d %q, want %d %q", tt.name, rec.Code, envelope.Error.Code, tt.status, tt.code)
+		}
+	}
+}
+
+func TestV1RoutesAreUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for _, route := range v1Routes {
+		key := route.method + " " + route.pattern
+		if seen[key] {
+			t.Errorf("%s registered twice", key)
+		}
+		seen[key] = true
+		if !strings.HasPrefix(route.pattern, apiV1Prefix) {
+			t.Errorf("%s is outside %s", key, apiV1Prefix)
+		}
+	}
+}
+
+func TestV1ConvertsLegacyBodies(t *testing.T) {
+	useSupabase(t, http.NotFoundHandler())
+	group := useGroup(t, "g1")
+	group.StudentLocations["ST001"] = StudentLocation{StudentID: "ST001", StudentName: "Ann",
+		Latitude: 12.5, Longitude: 77.5, Distance: 8, Timestamp: "2026-01-01 09:00:00", Status: "Present"}
+
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/groups/g1/attendance", nil))
+	want := `{"data":{"count":1,"students":[{"student_id":"ST001","student_name":"Ann","latitude":12.5,` +
+		`"longitude":77.5,"distance":8,"submitted_at":"2026-01-01 09:00:00","status":"Present"}]}}`
+	if got := strings.TrimSpace(rec.Body.String()); got != want {
+		t.Errorf("attendance:\n got %s\nwant %s", got, want)
+	}
+
+	paged := map[string]string{
+		"offset": `{"students":[{"id":"u1"}],"count":1,"page":2,"limit":1,"hasMore":true,"totalPages":3}`,
+		"cursor": `{"students":[],"count":0,"has_more":false,"next_cursor":null}`,
+	}
+	wantPaged := map[string]string{
+		"offset": `{"count":1,"pagination":{"page":2,"limit":1,"total_pages":3,"has_more":true},"students":[{"id":"u1"}]}`,
+		"cursor": `{"count":0,"pagination":{"has_more":false},"students":[]}`,
+	}
+	for name, legacy := range paged {
+		data, err := convertPage("students")([]byte(legacy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := json.Marshal(data); string(got) != wantPaged[name] {
+			t.Errorf("%s page:\n got %s\nwant %s", name, got, wantPaged[name])
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Package apierror is the error model of the HTTP API. Every failed request
+// is answered with a stable, machine-readable code, the HTTP status that
+// goes with it and a message that is safe to show to users. The underlying
+// cause (a database error, an upstream response body) is kept for logging
+// and never sent to clients.
+package apierror
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Code is a stable error identifier; clients may switch on it
+type Code string
+
+// Codes that follow from the HTTP status
+const (
+	InvalidRequest       Code = "invalid_request"
+	Unauthorized         Code = "unauthorized"
+	Forbidden            Code = "forbidden"
+	NotFound             Code = "not_found"
+	MethodNotAllowed     Code = "method_not_allowed"
+	Conflict             Code = "conflict"
+	PayloadTooLarge      Code = "payload_too_large"
+	UnsupportedMediaType Code = "unsupported_media_type"
+	RateLimited          Code = "rate_limited"
+	Internal             Code = "internal_error"
+	Unavailable          Code = "unavailable"
+)
+
+// Codes for specific conditions clients handle differently
+const (
+	WindowClosed      Code = "window_closed"      // No attendance window is open
+	AlreadySubmitted  Code = "already_submitted"  // Attendance was already recorded this window
+	NotGroupMember    Code = "not_group_member"   // The window is restricted to group members
+	PersistenceFailed Code = "persistence_failed" // The database didn't store the change; nothing was applied
+)
+
+// Codes lists every code, in documentation order
+var Codes = []Code{
+	InvalidRequest, Unauthorized, Forbidden, NotFound, MethodNotAllowed, Conflict,
+	PayloadTooLarge, UnsupportedMediaType, RateLimited, Internal, Unavailable,
+	WindowClosed, AlreadySubmitted, NotGroupMember, PersistenceFailed,
+}
+
+// Error is an API error
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Cause   error // Logged, never sent
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return e.Message + ": " + e.Cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// WithCode returns a copy of e with a more specific code
+func (e *Error) WithCode(code Code) *Error {
+	copied := *e
+	copied.Code = code
+	return &copied
+}
+
+// New returns an error with the code that goes with status
+func New(status int, message string) *Error {
+	return &Error{Status: status, Code: CodeForStatus(status), Message: message}
+}
+
+// Wrap is New with a cause to log
+func Wrap(status int, message string, cause error) *Error {
+	return &Error{Status: status, Code: CodeForStatus(status), Message: message, Cause: cause}
+}
+
+// Persistence reports a database write that failed, so the change it was
+// part of was not applied
+func Persistence(message string, cause error) *Error {
+	return Wrap(http.StatusInternalServerError, message, cause).WithCode(PersistenceFailed)
+}
+
+// From returns err as an *Error; anything else becomes a generic 500 that
+// keeps err as its cause
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return Wrap(http.StatusInternalServerError, "Internal server error", err)
+}
+
+// CodeForStatus maps an HTTP status to its generic code
+func CodeForStatus(status int) Code {
+	switch status {
+	case http.StatusUnauthorized:
+		return Unauthorized
+	case http.StatusForbidden:
+		return Forbidden
+	case http.StatusNotFound:
+		return NotFound
+	case http.StatusMethodNotAllowed:
+		return MethodNotAllowed
+	case http.StatusConflict:
+		return Conflict
+	case http.StatusRequestEntityTooLarge:
+		return PayloadTooLarge
+	case http.StatusUnsupportedMediaType:
+		return UnsupportedMediaType
+	case http.StatusTooManyRequests:
+		return RateLimited
+	case http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return Unavailable
+	}
+	if status >= 500 {
+		return Internal
+	}
+	return InvalidRequest
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"attendance-system/apierror"
+)
+
+// Message priorities; urgent and pinned messages are listed first
+const (
+	PriorityNormal = "normal"
+	PriorityUrgent = "urgent"
+	PriorityPinned = "pinned"
+)
+
+const (
+	maxAttachmentSize     = 10 << 20 // 10 MB
+	maxAttachmentsPerPost = 5
+)
+
+// Content types accepted for upload, detected from the file contents
+var allowedAttachmentTypes = map[string]bool{
+	"application/pdf":           true,
+	"image/png":                 true,
+	"image/jpeg":                true,
+	"image/gif":                 true,
+	"image/webp":                true,
+	"text/plain; charset=utf-8": true,
+}
+
+// validPriority reports whether p is a known priority level
+func validPriority(p string) bool {
+	return p == PriorityNormal || p == PriorityUrgent || p == PriorityPinned
+}
+
+// Attachment is an uploaded file, linked to a broadcast once it is sent
+type Attachment struct {
+	ID          string  `json:"id"`
+	AdminID     string  `json:"admin_id"`
+	MessageID   *string `json:"message_id"`
+	FileName    string  `json:"file_name"`
+	ContentType string  `json:"content_type"`
+	SizeBytes   int64   `json:"size_bytes"`
+	StorageKey  string  `json:"storage_key"`
+	SHA256      string  `json:"sha256"`
+	CreatedAt   string  `json:"created_at"`
+}
+
+// blobPath maps a storage key to its file in the attachments directory
+func blobPath(key string) string {
+	return filepath.Join(config.AttachmentsDir, key[:2], key)
+}
+
+// saveBlob writes an upload to the local blob store under a random key
+func saveBlob(r io.Reader) (key string, size int64, sum string, err error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", 0, "", err
+	}
+	key = hex.EncodeToString(raw)
+
+	path := blobPath(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", 0, "", err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err = io.Copy(io.MultiWriter(file, hash), r)
+	if err != nil {
+		os.Remove(path)
+		return "", 0, "", err
+	}
+	return key, size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// attachmentFileName strips any path and quoting from a client file name
+func attachmentFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r == '"' || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "." || name == "/" || name == "" {
+		return "attachment"
+	}
+	return name
+}
+
+// checkAttachments verifies the attachments belong to the admin and aren't
+// already linked to another message
+func checkAttachments(ctx context.Context, adminID string, attachmentIDs []string) error {
+	if len(attachmentIDs) == 0 {
+		return nil
+	}
+	if len(attachmentIDs) > maxAttachmentsPerPost {
+		return fmt.Errorf("at most %d attachments per message", maxAttachmentsPerPost)
+	}
+	var found []Attachment
+	checkURL := from("message_attachments").In("id", attachmentIDs).Eq("admin_id", adminID).Is("message_id", IsNull).
+		Select("id").URL()
+	if err := supabaseGetJSON(ctx, checkURL, &found); err != nil {
+		return err
+	}
+	if len(found) != len(attachmentIDs) {
+		return errors.New("unknown or already used attachment")
+	}
+	return nil
+}
+
+// linkAttachments attaches uploaded files to a stored broadcast
+func linkAttachments(ctx context.Context, adminID, messageID string, attachmentIDs []string) error {
+	if len(attachmentIDs) == 0 {
+		return nil
+	}
+	linkURL := from("message_attachments").In("id", attachmentIDs).Eq("admin_id", adminID).Is("message_id", IsNull).URL()
+	return supabasePatch(ctx, linkURL, map[string]interface{}{"message_id": messageID})
+}
+
+// Handler: POST /api/upload-attachment (multipart: admin_id, file)
+// Stores a file to be referenced by attachment_ids in a broadcast
+func uploadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentSize+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		writeError(w, r, http.StatusBadRequest, fmt.Sprintf("Invalid upload (max %d MB)", maxAttachmentSize>>20))
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	adminID := r.FormValue("admin_id")
+	file, header, err := r.FormFile("file")
+	if adminID == "" || err != nil {
+		writeError(w, r, http.StatusBadRequest, "admin_id and file are required")
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxAttachmentSize {
+		writeError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("File exceeds %d MB", maxAttachmentSize>>20))
+		return
+	}
+
+	var admins []struct {
+		ID string `json:"id"`
+	}
+	if err := supabaseGetJSON(r.Context(), from("admins").Eq("id", adminID).Select("id").URL(), &admins); err != nil || len(admins) == 0 {
+		writeError(w, r, http.StatusBadRequest, "Invalid admin_id")
+		return
+	}
+
+	// Trust the bytes, not the client's Content-Type
+	sniff := make([]byte, 512)
+	n, _ := io.ReadFull(file, sniff)
+	contentType := http.DetectContentType(sniff[:n])
+	if !allowedAttachmentTypes[contentType] {
+		writeError(w, r, http.StatusUnsupportedMediaType, "Unsupported file type: "+contentType)
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to read upload")
+		return
+	}
+
+	key, size, sum, err := saveBlob(file)
+	if err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to store file", err))
+		return
+	}
+
+	fileName := attachmentFileName(header.Filename)
+	attachmentID, _, err := insertReturning(r.Context(), "message_attachments", map[string]interface{}{
+		"admin_id":     adminID,
+		"file_name":    fileName,
+		"content_type": contentType,
+		"size_bytes":   size,
+		"storage_key":  key,
+		"sha256":       sum,
+	})
+	if err != nil {
+		os.Remove(blobPath(key))
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to save attachment", err))
+		return
+	}
+
+	slog.InfoContext(r.Context(), "attachment uploaded", "admin_id", adminID, "attachment_id", attachmentID, "content_type", contentType, "bytes", size)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":       true,
+		"attachment_id": attachmentID,
+		"file_name":     fileName,
+		"content_type":  contentType,
+		"size_bytes":    size,
+	})
+}
+
+// Handler: GET /api/download-attachment?attachment_id=xxx&student_id=xxx (or &admin_id=xxx)
+// Students may download attachments of unexpired messages they received;
+// admins may download their own uploads
+func downloadAttachmentHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	attachmentID := query.Get("attachment_id")
+	adminID := query.Get("admin_id")
+	studentID := query.Get("student_id")
+	if attachmentID == "" || (adminID == "") == (studentID == "") {
+		writeError(w, r, http.StatusBadRequest, "attachment_id and one of admin_id or student_id are required")
+		return
+	}
+
+	var attachments []struct {
+		Attachment
+		BroadcastMessages *struct {
+			ExpiresAt  *string `json:"expires_at"`
+			RecalledAt *string `json:"recalled_at"`
+		} `json:"broadcast_messages"`
+	}
+	attachmentURL := from("message_attachments").Eq("id", attachmentID).
+		Select("*,broadcast_messages(expires_at,recalled_at)").URL()
+	if err := supabaseGetJSON(r.Context(), attachmentURL, &attachments); err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Database error", err))
+		return
+	}
+	if len(attachments) == 0 {
+		writeError(w, r, http.StatusNotFound, "Attachment not found")
+		return
+	}
+	attachment := attachments[0]
+
+	allowed := adminID != "" && attachment.AdminID == adminID
+	if studentID != "" && attachment.MessageID != nil {
+		expired := false
+		if msg := attachment.BroadcastMessages; msg != nil {
+			if msg.ExpiresAt != nil {
+				if expiresAt, err := time.Parse(time.RFC3339, *msg.ExpiresAt); err == nil && time.Now().After(expiresAt) {
+					expired = true
+				}
+			}
+			expired = expired || msg.RecalledAt != nil
+		}
+
+		studentUUID := getStudentUUIDByID(r.Context(), studentID)
+		if !expired && studentUUID != "" {
+			var recipients []struct {
+				MessageID string `json:"message_id"`
+			}
+			recipientURL := from("message_recipients").Eq("message_id", *attachment.MessageID).Eq("student_id", studentUUID).
+				Select("message_id").URL()
+			allowed = supabaseGetJSON(r.Context(), recipientURL, &recipients) == nil && len(recipients) > 0
+		}
+	}
+	if !allowed {
+		// Same answer as a missing attachment, so IDs can't be probed
+		writeError(w, r, http.StatusNotFound, "Attachment not found")
+		return
+	}
+
+	file, err := os.Open(blobPath(attachment.StorageKey))
+	if err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusNotFound, "Attachment not found", err))
+		return
+	}
+	defer file.Close()
+
+	modTime := time.Time{}
+	if info, err := file.Stat(); err == nil {
+		modTime = info.ModTime()
+	}
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", attachment.FileName))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(w, r, attachment.FileName, modTime, file)
+}
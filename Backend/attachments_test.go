@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAttachmentFileName(t *testing.T) {
+	tests := map[string]string{
+		"notes.pdf":            "notes.pdf",
+		"../../etc/passwd":     "passwd",
+		`C:\Users\a\photo.png`: "photo.png",
+		"say \"hi\".txt":       "say _hi_.txt",
+		"":                     "attachment",
+		"/":                    "attachment",
+	}
+	for in, want := range tests {
+		if got := attachmentFileName(in); got != want {
+			t.Errorf("attachmentFileName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSaveBlob(t *testing.T) {
+	saved := config
+	config.AttachmentsDir = t.TempDir()
+	t.Cleanup(func() { config = saved })
+
+	const content = "hello attachment"
+	key, size, sum, err := saveBlob(strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sha256.Sum256([]byte(content))
+	if size != int64(len(content)) || sum != hex.EncodeToString(want[:]) {
+		t.Errorf("got size %d sum %s", size, sum)
+	}
+	data, err := os.ReadFile(blobPath(key))
+	if err != nil || string(data) != content {
+		t.Errorf("stored blob = %q, %v", data, err)
+	}
+}
+
+func TestValidPriority(t *testing.T) {
+	for _, p := range []string{PriorityNormal, PriorityUrgent, PriorityPinned} {
+		if !validPriority(p) {
+			t.Errorf("%q rejected", p)
+		}
+	}
+	if validPriority("high") {
+		t.Error(`"high" accepted`)
+	}
+}
+
+func TestDownloadAttachmentAccess(t *testing.T) {
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	tests := []struct {
+		name    string
+		query   string
+		message map[string]interface{}
+		want    int
+	}{
+		{"owning admin", "admin_id=admin-1", map[string]interface{}{}, http.StatusOK},
+		{"another admin", "admin_id=admin-2", map[string]interface{}{}, http.StatusNotFound},
+		{"recipient", "student_id=ST001", map[string]interface{}{"expires_at": future}, http.StatusOK},
+		{"not a recipient", "student_id=ST002", map[string]interface{}{}, http.StatusNotFound},
+		{"expired message", "student_id=ST001", map[string]interface{}{"expires_at": past}, http.StatusNotFound},
+		{"recalled message", "student_id=ST001", map[string]interface{}{"recalled_at": past}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSupabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/rest/v1/message_attachments":
+					json.NewEncoder(w).Encode([]map[string]interface{}{{
+						"id": "a1", "admin_id": "admin-1", "message_id": "m1", "file_name": "notes.txt",
+						"content_type": "text/plain", "storage_key": "abcdef", "broadcast_messages": tt.message,
+					}})
+				case "/rest/v1/students":
+					id := strings.Trim(strings.TrimSuffix(strings.TrimPrefix(r.URL.Query().Get("student_id"), "in.("), ")"), `"`)
+					json.NewEncoder(w).Encode([]map[string]string{{"id": "uuid-" + id, "student_id": id}})
+				case "/rest/v1/message_recipients":
+					// Only ST001 received m1
+					if r.URL.Query().Get("message_id") == "eq.m1" && r.URL.Query().Get("student_id") == "eq.uuid-ST001" {
+						json.NewEncoder(w).Encode([]map[string]string{{"message_id": "m1"}})
+						return
+					}
+					w.Write([]byte("[]"))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			config.AttachmentsDir = t.TempDir()
+			if err := os.MkdirAll(filepath.Dir(blobPath("abcdef")), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(blobPath("abcdef"), []byte("lecture notes"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			downloadAttachmentHandler(rec, httptest.NewRequest(http.MethodGet, "/api/download-attachment?attachment_id=a1&"+tt.query, nil))
+			if rec.Code != tt.want {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.want == http.StatusOK && rec.Body.String() != "lecture notes" {
+				t.Errorf("downloaded %q", rec.Body)
+			}
+			if tt.want == http.StatusNotFound && strings.Contains(rec.Body.String(), "lecture notes") {
+				t.Error("attachment served without access")
+			}
+		})
+	}
+}
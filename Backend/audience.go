@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// BroadcastAudience selects who a broadcast is for. Selectors are combined
+// as a union; SendToAll overrides everything else. It is resolved when the
+// delivery job runs and stored with the message for auditing.
+type BroadcastAudience struct {
+	SendToAll bool   `json:"send_to_all"`
+	GroupID   string `json:"group_id,omitempty"` // Legacy single-group selector
+
+	GroupIDs               []string `json:"group_ids,omitempty"`
+	StudentIDs             []string `json:"student_ids,omitempty"`              // Roll numbers, e.g. ST001
+	AbsentFromGroupID      string   `json:"absent_from_group_id,omitempty"`     // Not marked Present in this session
+	BelowAttendancePercent *float64 `json:"below_attendance_percent,omitempty"` // Attendance rate under N%
+	UnreadMessageID        string   `json:"unread_message_id,omitempty"`        // Haven't read this message
+}
+
+// IsEmpty reports whether no selector is set
+func (a BroadcastAudience) IsEmpty() bool {
+	return !a.SendToAll && a.GroupID == "" && len(a.GroupIDs) == 0 && len(a.StudentIDs) == 0 &&
+		a.AbsentFromGroupID == "" && a.BelowAttendancePercent == nil && a.UnreadMessageID == ""
+}
+
+// Validate checks selector values before a broadcast is queued
+func (a BroadcastAudience) Validate() error {
+	if a.IsEmpty() {
+		return errors.New("audience is empty")
+	}
+	if p := a.BelowAttendancePercent; p != nil && (*p <= 0 || *p > 100) {
+		return errors.New("below_attendance_percent must be between 0 and 100")
+	}
+	return nil
+}
+
+// SingleGroupID returns the group the audience is limited to, if it is
+// exactly one group; used to fill broadcast_messages.group_id
+func (a BroadcastAudience) SingleGroupID() string {
+	if a.SendToAll || len(a.StudentIDs) > 0 || a.AbsentFromGroupID != "" ||
+		a.BelowAttendancePercent != nil || a.UnreadMessageID != "" {
+		return ""
+	}
+	groups := append([]string{}, a.GroupIDs...)
+	if a.GroupID != "" {
+		groups = append(groups, a.GroupID)
+	}
+	if len(groups) == 1 {
+		return groups[0]
+	}
+	return ""
+}
+
+const studentLookupChunkSize = 100
+
+type audienceStudent struct {
+	ID          string `json:"id"`
+	StudentID   string `json:"student_id"`
+	StudentName string `json:"student_name,omitempty"`
+}
+
+// audienceSet collects resolved students, de-duplicated by UUID
+type audienceSet struct {
+	order    []string
+	students map[string]audienceStudent
+}
+
+func newAudienceSet() *audienceSet {
+	return &audienceSet{students: make(map[string]audienceStudent)}
+}
+
+func (s *audienceSet) add(student audienceStudent) {
+	if student.ID == "" {
+		return
+	}
+	if _, exists := s.students[student.ID]; !exists {
+		s.order = append(s.order, student.ID)
+		s.students[student.ID] = student
+	}
+}
+
+// resolveBroadcastRecipients returns the roll numbers and UUIDs of the audience
+func resolveBroadcastRecipients(ctx context.Context, audience BroadcastAudience) ([]string, []string, error) {
+	set := newAudienceSet()
+
+	if audience.SendToAll || audience.IsEmpty() {
+		var students []audienceStudent
+		if err := supabaseGetJSON(ctx, from("students").Select("id,student_id").URL(), &students); err != nil {
+			return nil, nil, err
+		}
+		for _, s := range students {
+			set.add(s)
+		}
+		return set.result()
+	}
+
+	groupIDs := append([]string{}, audience.GroupIDs...)
+	if audience.GroupID != "" {
+		groupIDs = append(groupIDs, audience.GroupID)
+	}
+	if len(groupIDs) > 0 {
+		if err := addGroupMembers(ctx, set, groupIDs); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Chunked so long lists (e.g. reminder audiences) keep URLs short
+	for start := 0; start < len(audience.StudentIDs); start += studentLookupChunkSize {
+		end := start + studentLookupChunkSize
+		if end > len(audience.StudentIDs) {
+			end = len(audience.StudentIDs)
+		}
+		var students []audienceStudent
+		studentsURL := from("students").In("student_id", audience.StudentIDs[start:end]).Select("id,student_id").URL()
+		if err := supabaseGetJSON(ctx, studentsURL, &students); err != nil {
+			return nil, nil, err
+		}
+		for _, s := range students {
+			set.add(s)
+		}
+	}
+
+	if audience.AbsentFromGroupID != "" {
+		if err := addAbsentStudents(ctx, set, audience.AbsentFromGroupID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if audience.BelowAttendancePercent != nil {
+		rates, err := attendanceRates(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, rate := range rates {
+			if rate.Percent < *audience.BelowAttendancePercent {
+				set.add(rate.Student)
+			}
+		}
+	}
+
+	if audience.UnreadMessageID != "" {
+		var rows []struct {
+			Students audienceStudent `json:"students"`
+		}
+		unreadURL := from("message_recipients").Eq("message_id", audience.UnreadMessageID).Eq("is_read", "false").
+			Select("students(id,student_id)").URL()
+		if err := supabaseGetJSON(ctx, unreadURL, &rows); err != nil {
+			return nil, nil, err
+		}
+		for _, row := range rows {
+			set.add(row.Students)
+		}
+	}
+
+	return set.result()
+}
+
+func (s *audienceSet) result() ([]string, []string, error) {
+	studentIDs := make([]string, 0, len(s.order))
+	studentUUIDs := make([]string, 0, len(s.order))
+	for _, id := range s.order {
+		studentIDs = append(studentIDs, s.students[id].StudentID)
+		studentUUIDs = append(studentUUIDs, id)
+	}
+	return studentIDs, studentUUIDs, nil
+}
+
+func addGroupMembers(ctx context.Context, set *audienceSet, groupIDs []string) error {
+	var rows []struct {
+		Students audienceStudent `json:"students"`
+	}
+	membersURL := from("group_students").In("group_id", groupIDs).Select("students(id,student_id)").URL()
+	if err := supabaseGetJSON(ctx, membersURL, &rows); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		set.add(row.Students)
+	}
+	return nil
+}
+
+// addAbsentStudents adds group members without a Present record for the
+// session, plus anyone who submitted from outside the threshold
+func addAbsentStudents(ctx context.Context, set *audienceSet, groupID string) error {
+	var attendance []struct {
+		Status   string          `json:"status"`
+		Students audienceStudent `json:"students"`
+	}
+	attendanceURL := from("group_attendance").Eq("group_id", groupID).Select("status,students(id,student_id)").URL()
+	if err := supabaseGetJSON(ctx, attendanceURL, &attendance); err != nil {
+		return err
+	}
+
+	present := make(map[string]bool)
+	for _, record := range attendance {
+		if record.Status == "Present" {
+			present[record.Students.ID] = true
+		} else {
+			set.add(record.Students)
+		}
+	}
+
+	var members []struct {
+		Students audienceStudent `json:"students"`
+	}
+	membersURL := from("group_students").Eq("group_id", groupID).Select("students(id,student_id)").URL()
+	if err := supabaseGetJSON(ctx, membersURL, &members); err != nil {
+		return err
+	}
+	for _, member := range members {
+		if !present[member.Students.ID] {
+			set.add(member.Students)
+		}
+	}
+	return nil
+}
+
+// AttendanceRate is a student's share of sessions attended
+type AttendanceRate struct {
+	Student  audienceStudent
+	Sessions int
+	Present  int
+	Percent  float64
+}
+
+// attendanceRates computes attendance per student: Present records divided
+// by the sessions they were expected at (member groups that have been run).
+// Only attendance in a student's own groups counts, so a student who joined
+// other groups' open windows can't raise their rate. Students with no
+// sessions yet are left out.
+func attendanceRates(ctx context.Context) (map[string]*AttendanceRate, error) {
+	type membership struct {
+		GroupID  string          `json:"group_id"`
+		Students audienceStudent `json:"students"`
+	}
+	var memberships []membership
+	err := supabaseGetPages(func() *Query {
+		return from("group_students").Select("id,group_id,students(id,student_id),groups!inner(status)").
+			Filter("groups.status", OpNeq, "inactive")
+	}, func(url string) (int, error) {
+		var page []membership
+		if err := supabaseGetJSON(ctx, url, &page); err != nil {
+			return 0, err
+		}
+		memberships = append(memberships, page...)
+		return len(page), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rates := make(map[string]*AttendanceRate)
+	members := make(map[string]bool) // group_id/student UUID
+	for _, m := range memberships {
+		if m.Students.ID == "" {
+			continue
+		}
+		rate, exists := rates[m.Students.ID]
+		if !exists {
+			rate = &AttendanceRate{Student: m.Students}
+			rates[m.Students.ID] = rate
+		}
+		rate.Sessions++
+		members[m.GroupID+"/"+m.Students.ID] = true
+	}
+
+	type attendance struct {
+		GroupID   string `json:"group_id"`
+		StudentID string `json:"student_id"`
+	}
+	err = supabaseGetPages(func() *Query {
+		return from("group_attendance").Eq("status", "Present").Select("id,group_id,student_id,groups!inner(status)").
+			Filter("groups.status", OpNeq, "inactive")
+	}, func(url string) (int, error) {
+		var page []attendance
+		if err := supabaseGetJSON(ctx, url, &page); err != nil {
+			return 0, err
+		}
+		for _, a := range page {
+			if members[a.GroupID+"/"+a.StudentID] {
+				rates[a.StudentID].Present++
+			}
+		}
+		return len(page), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rate := range rates {
+		rate.Percent = float64(rate.Present) * 100 / float64(rate.Sessions)
+	}
+	return rates, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBroadcastAudienceValidate(t *testing.T) {
+	percent := func(p float64) *float64 { return &p }
+	tests := []struct {
+		name     string
+		audience BroadcastAudience
+		valid    bool
+	}{
+		{"empty", BroadcastAudience{}, false},
+		{"everyone", BroadcastAudience{SendToAll: true}, true},
+		{"students", BroadcastAudience{StudentIDs: []string{"ST001"}}, true},
+		{"below 75%", BroadcastAudience{BelowAttendancePercent: percent(75)}, true},
+		{"below 0%", BroadcastAudience{BelowAttendancePercent: percent(0)}, false},
+		{"below 101%", BroadcastAudience{BelowAttendancePercent: percent(101)}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.audience.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%s: Validate() = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestBroadcastAudienceSingleGroupID(t *testing.T) {
+	tests := []struct {
+		audience BroadcastAudience
+		want     string
+	}{
+		{BroadcastAudience{GroupID: "g1"}, "g1"},
+		{BroadcastAudience{GroupIDs: []string{"g1"}}, "g1"},
+		{BroadcastAudience{GroupIDs: []string{"g1", "g2"}}, ""},
+		{BroadcastAudience{GroupID: "g1", GroupIDs: []string{"g2"}}, ""},
+		{BroadcastAudience{GroupID: "g1", StudentIDs: []string{"ST001"}}, ""},
+		{BroadcastAudience{GroupID: "g1", SendToAll: true}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.audience.SingleGroupID(); got != tt.want {
+			t.Errorf("%+v: SingleGroupID() = %q, want %q", tt.audience, got, tt.want)
+		}
+	}
+}
+
+func TestAudienceSetDeduplicates(t *testing.T) {
+	set := newAudienceSet()
+	set.add(audienceStudent{ID: "u2", StudentID: "ST002"})
+	set.add(audienceStudent{ID: "u1", StudentID: "ST001"})
+	set.add(audienceStudent{ID: "u2", StudentID: "ST002"}) // In two selected groups
+	set.add(audienceStudent{StudentID: "ST404"})           // Unknown roll number
+
+	studentIDs, studentUUIDs, _ := set.result()
+	if !slices.Equal(studentIDs, []string{"ST002", "ST001"}) || !slices.Equal(studentUUIDs, []string{"u2", "u1"}) {
+		t.Errorf("got %q / %q, want each student once in the order added", studentIDs, studentUUIDs)
+	}
+}
+
+func TestResolveStudentIDsInChunks(t *testing.T) {
+	var mu sync.Mutex
+	var lookups []int
+	useSupabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids := strings.Split(strings.TrimSuffix(strings.TrimPrefix(r.URL.Query().Get("student_id"), "in.("), ")"), ",")
+		mu.Lock()
+		lookups = append(lookups, len(ids))
+		mu.Unlock()
+		students := make([]audienceStudent, len(ids))
+		for i, id := range ids {
+			id = strings.Trim(id, `"`)
+			students[i] = audienceStudent{ID: "uuid-" + id, StudentID: id}
+		}
+		json.NewEncoder(w).Encode(students)
+	}))
+
+	studentIDs := make([]string, 2*studentLookupChunkSize+5)
+	for i := range studentIDs {
+		studentIDs[i] = fmt.Sprintf("ST%03d", i)
+	}
+	ids, uuids, err := resolveBroadcastRecipients(context.Background(), BroadcastAudience{StudentIDs: studentIDs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(lookups, []int{studentLookupChunkSize, studentLookupChunkSize, 5}) {
+		t.Errorf("lookup sizes %v, want chunks of %d", lookups, studentLookupChunkSize)
+	}
+	if !slices.Equal(ids, studentIDs) || len(uuids) != len(studentIDs) {
+		t.Errorf("resolved %d students and %d UUIDs, want %d", len(ids), len(uuids), len(studentIDs))
+	}
+}
+
+func TestAttendanceRates(t *testing.T) {
+	saved := supabasePageSize
+	t.Cleanup(func() { supabasePageSize = saved })
+	supabasePageSize = 2
+
+	member := func(group, student string) map[string]interface{} {
+		return map[string]interface{}{"group_id": group, "students": audienceStudent{ID: student, StudentID: "ST-" + student}}
+	}
+	present := func(group, student string) map[string]interface{} {
+		return map[string]interface{}{"group_id": group, "student_id": student}
+	}
+	tables := map[string][]map[string]interface{}{
+		"group_students": {member("g1", "u1"), member("g2", "u1"), member("g1", "u2"), member("g1", "u3")},
+		// u2 skipped g1 but joined g3's open window; u3 wasn't expected in g2
+		"group_attendance": {present("g1", "u1"), present("g3", "u2"), present("g1", "u3"), present("g2", "u3")},
+	}
+	var mu sync.Mutex
+	reads := make(map[string]int)
+	useSupabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		table := strings.TrimPrefix(r.URL.Path, "/rest/v1/")
+		query := r.URL.Query()
+		if query.Get("groups.status") != "neq.inactive" || query.Get("order") != "id.asc" {
+			t.Errorf("%s read with %v, want active groups ordered by id", table, query)
+		}
+		mu.Lock()
+		reads[table]++
+		mu.Unlock()
+		var offset, limit int
+		fmt.Sscan(query.Get("offset"), &offset)
+		fmt.Sscan(query.Get("limit"), &limit)
+		rows := tables[table][min(offset, len(tables[table])):]
+		json.NewEncoder(w).Encode(rows[:min(limit, len(rows))])
+	}))
+
+	rates, err := attendanceRates(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for student, want := range map[string]float64{"u1": 50, "u2": 0, "u3": 100} {
+		if rate := rates[student]; rate == nil || rate.Percent != want {
+			t.Errorf("%s: got %+v, want %g%%", student, rate, want)
+		}
+	}
+	// Two full pages and an empty one for each table
+	if reads["group_students"] != 3 || reads["group_attendance"] != 3 {
+		t.Errorf("read pages %v, want 3 of each table", reads)
+	}
+}
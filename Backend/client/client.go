@@ -0,0 +1,186 @@
+// Package client is a typed Go client for the backend's /api/v1 API.
+//
+// The methods in client_gen.go are generated from ../openapi.json; this file
+// holds the transport they share: the {"data": ...} envelope, typed errors
+// and request signing.
+package client
+
+//go:generate go run ../genclient -spec ../openapi.json -out client_gen.go
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls the API at BaseURL, e.g. http://localhost:8080
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+
+	// SigningSecret signs requests; set it when the server runs with
+	// API_SIGNING_SECRET
+	SigningSecret string
+}
+
+// New returns a client for the server at baseURL
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimRight(baseURL, "/"), HTTPClient: http.DefaultClient}
+}
+
+// Error is an error response from the API
+type Error struct {
+	StatusCode int    `json:"-"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	RequestID  string `json:"request_id,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.RequestID != "" {
+		return fmt.Sprintf("%d %s: %s [%s]", e.StatusCode, e.Code, e.Message, e.RequestID)
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// do sends an optional JSON body and decodes the "data" member of the
+// response into out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	var payload []byte
+	contentType := ""
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+		contentType = "application/json"
+	}
+	resp, err := c.send(ctx, method, path, query, contentType, payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeData(resp.Body, out)
+}
+
+// upload sends fields and one file as multipart/form-data
+func (c *Client) upload(ctx context.Context, method, path string, fields map[string]string, fileField, fileName string, file io.Reader, out interface{}) error {
+	var buf bytes.Buffer
+	form := multipart.NewWriter(&buf)
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+	if file != nil {
+		part, err := form.CreateFormFile(fileField, fileName)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, file); err != nil {
+			return err
+		}
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	resp, err := c.send(ctx, method, path, nil, form.FormDataContentType(), buf.Bytes())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeData(resp.Body, out)
+}
+
+// stream returns the body of a file download or event stream; the caller
+// closes it
+func (c *Client) stream(ctx context.Context, method, path string, query url.Values) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, method, path, query, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// send performs a request and turns error statuses into *Error
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, contentType string, body []byte) (*http.Response, error) {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.SigningSecret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Timestamp", timestamp)
+		req.Header.Set("X-Signature", sign(c.SigningSecret, method, path, query.Encode(), timestamp, body))
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		var envelope struct {
+			Error *Error `json:"error"`
+		}
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if json.Unmarshal(raw, &envelope) == nil && envelope.Error != nil {
+			envelope.Error.StatusCode = resp.StatusCode
+			return nil, envelope.Error
+		}
+		apiErr.Code = "unknown"
+		apiErr.Message = strings.TrimSpace(string(raw))
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return nil, apiErr
+	}
+	return resp, nil
+}
+
+func decodeData(body io.Reader, out interface{}) error {
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(body).Decode(&envelope); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	if out == nil || len(envelope.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
+
+// sign matches the server's signRequest:
+// hex(HMAC-SHA256(secret, METHOD \n PATH \n QUERY \n TIMESTAMP \n hex(SHA256(body))))
+// where QUERY is the url.Values encoding of the query parameters
+func sign(secret, method, path, query, timestamp string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", method, path, query, timestamp, hex.EncodeToString(bodyHash[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
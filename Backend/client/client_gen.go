@@ -0,0 +1,1660 @@
+// Code generated by genclient from openapi.json; DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// GetAdminInbox calls GET /api/v1/admins/{admin_id}/inbox: List an admin's conversations.
+func (c *Client) GetAdminInbox(ctx context.Context, params GetAdminInboxParams) (*GetAdminInboxResponse, error) {
+	path := "/api/v1/admins/" + url.PathEscape(params.AdminID) + "/inbox"
+	query := url.Values{}
+	if params.MessageID != "" {
+		query.Set("message_id", params.MessageID)
+	}
+	var out GetAdminInboxResponse
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UploadAttachment calls POST /api/v1/attachments: Upload a file to attach to a broadcast.
+func (c *Client) UploadAttachment(ctx context.Context, params UploadAttachmentParams) (*UploadAttachmentResponse, error) {
+	path := "/api/v1/attachments"
+	fields := map[string]string{}
+	if params.AdminID != "" {
+		fields["admin_id"] = params.AdminID
+	}
+	var out UploadAttachmentResponse
+	if err := c.upload(ctx, http.MethodPost, path, fields, "file", params.FileName, params.File, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DownloadAttachment calls GET /api/v1/attachments/{attachment_id}: Download an attachment.
+func (c *Client) DownloadAttachment(ctx context.Context, params DownloadAttachmentParams) (io.ReadCloser, error) {
+	path := "/api/v1/attachments/" + url.PathEscape(params.AttachmentID)
+	query := url.Values{}
+	if params.AdminID != "" {
+		query.Set("admin_id", params.AdminID)
+	}
+	if params.StudentID != "" {
+		query.Set("student_id", params.StudentID)
+	}
+	return c.stream(ctx, http.MethodGet, path, query)
+}
+
+// SaveFCMToken calls POST /api/v1/devices: Register a device for push notifications.
+func (c *Client) SaveFCMToken(ctx context.Context, params SaveFCMTokenParams) (*SaveFCMTokenResponse, error) {
+	path := "/api/v1/devices"
+	var out SaveFCMTokenResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Events calls GET /api/v1/events: Server-sent event stream for a student, or for an admin and a group they own.
+func (c *Client) Events(ctx context.Context, params EventsParams) (io.ReadCloser, error) {
+	path := "/api/v1/events"
+	query := url.Values{}
+	if params.GroupID != "" {
+		query.Set("group_id", params.GroupID)
+	}
+	if params.StudentID != "" {
+		query.Set("student_id", params.StudentID)
+	}
+	if params.AdminID != "" {
+		query.Set("admin_id", params.AdminID)
+	}
+	return c.stream(ctx, http.MethodGet, path, query)
+}
+
+// GetMyGroups calls GET /api/v1/groups: List an admin's groups.
+func (c *Client) GetMyGroups(ctx context.Context, params GetMyGroupsParams) (*GetMyGroupsResponse, error) {
+	path := "/api/v1/groups"
+	query := url.Values{}
+	query.Set("admin_id", params.AdminID)
+	var out GetMyGroupsResponse
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateGroup calls POST /api/v1/groups: Create a group.
+func (c *Client) CreateGroup(ctx context.Context, params CreateGroupParams) (*CreateGroupResponse, error) {
+	path := "/api/v1/groups"
+	var out CreateGroupResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteGroup calls DELETE /api/v1/groups/{group_id}: Delete a group.
+func (c *Client) DeleteGroup(ctx context.Context, params DeleteGroupParams) (*DeleteGroupResponse, error) {
+	path := "/api/v1/groups/" + url.PathEscape(params.GroupID)
+	var out DeleteGroupResponse
+	if err := c.do(ctx, http.MethodDelete, path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetAllStudentLocations calls GET /api/v1/groups/{group_id}/attendance: List the submissions of a group's window.
+func (c *Client) GetAllStudentLocations(ctx context.Context, params GetAllStudentLocationsParams) (*GetAllStudentLocationsResponse, error) {
+	path := "/api/v1/groups/" + url.PathEscape(params.GroupID) + "/attendance"
+	var out GetAllStudentLocationsResponse
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SubmitAttendance calls POST /api/v1/groups/{group_id}/attendance: Submit a student's location to the open window.
+func (c *Client) SubmitAttendance(ctx context.Context, params SubmitAttendanceParams) (*SubmitAttendanceResponse, error) {
+	path := "/api/v1/groups/" + url.PathEscape(params.GroupID) + "/attendance"
+	var out SubmitAttendanceResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DownloadCSV calls GET /api/v1/groups/{group_id}/attendance/export: Download the window's attendance sheet.
+func (c *Client) DownloadCSV(ctx context.Context, params DownloadCSVParams) (io.ReadCloser, error) {
+	path := "/api/v1/groups/" + url.PathEscape(params.GroupID) + "/attendance/export"
+	return c.stream(ctx, http.MethodGet, path, nil)
+}
+
+// GetAdminLocation calls GET /api/v1/groups/{group_id}/location: Get a group's attendance location.
+func (c *Client) GetAdminLocation(ctx context.Context, params GetAdminLocationParams) (*GetAdminLocationResponse, error) {
+	path := "/api/v1/groups/" + url.PathEscape(params.GroupID) + "/location"
+	var out GetAdminLocationResponse
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetCenter calls PUT /api/v1/groups/{group_id}/location: Set a group's attendance location and radius.
+func (c *Client) SetCenter(ctx context.Context, params SetCenterParams) (*SetCenterResponse, error) {
+	path := "/api/v1/groups/" + url.PathEscape(params.GroupID) + "/location"
+	var out SetCenterResponse
+	if err := c.do(ctx, http.MethodPut, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateSessionName calls PUT /api/v1/groups/{group_id}/name: Rename a group.
+func (c *Client) UpdateSessionName(ctx context.Context, params UpdateSessionNameParams) (*UpdateSessionNameResponse, error) {
+	path := "/api/v1/groups/" + url.PathEscape(params.GroupID) + "/name"
+	var out UpdateSessionNameResponse
+	if err := c.do(ctx, http.MethodPut, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetGroupStudents calls GET /api/v1/groups/{group_id}/students: List a group's students.
+func (c *Client) GetGroupStudents(ctx context.Context, params GetGroupStudentsParams) (*GetGroupStudentsResponse, error) {
+	path := "/api/v1/groups/" + url.PathEscape(params.GroupID) + "/students"
+	var out GetGroupStudentsResponse
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AddStudentsToGroup calls POST /api/v1/groups/{group_id}/students: Add students to a group.
+func (c *Client) AddStudentsToGroup(ctx context.Context, params AddStudentsToGroupParams) (*AddStudentsToGroupResponse, error) {
+	path := "/api/v1/groups/" + url.PathEscape(params.GroupID) + "/students"
+	var out AddStudentsToGroupResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StartWindow calls POST /api/v1/groups/{group_id}/windows: Open an attendance window.
+func (c *Client) StartWindow(ctx context.Context, params StartWindowParams) (*StartWindowResponse, error) {
+	path := "/api/v1/groups/" + url.PathEscape(params.GroupID) + "/windows"
+	var out StartWindowResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CloseWindow calls DELETE /api/v1/groups/{group_id}/windows/current: Close the open attendance window.
+func (c *Client) CloseWindow(ctx context.Context, params CloseWindowParams) (*CloseWindowResponse, error) {
+	path := "/api/v1/groups/" + url.PathEscape(params.GroupID) + "/windows/current"
+	var out CloseWindowResponse
+	if err := c.do(ctx, http.MethodDelete, path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetWindowStatus calls GET /api/v1/groups/{group_id}/windows/current: Get the state of a group's window, or a student's best open window.
+func (c *Client) GetWindowStatus(ctx context.Context, params GetWindowStatusParams) (*GetWindowStatusResponse, error) {
+	path := "/api/v1/groups/" + url.PathEscape(params.GroupID) + "/windows/current"
+	query := url.Values{}
+	if params.StudentID != "" {
+		query.Set("student_id", params.StudentID)
+	}
+	var out GetWindowStatusResponse
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetWindowSummary calls GET /api/v1/groups/{group_id}/windows/current/summary: Summarize a group's window for the live view.
+func (c *Client) GetWindowSummary(ctx context.Context, params GetWindowSummaryParams) (*GetWindowSummaryResponse, error) {
+	path := "/api/v1/groups/" + url.PathEscape(params.GroupID) + "/windows/current/summary"
+	query := url.Values{}
+	if params.BucketSeconds != 0 {
+		query.Set("bucket_seconds", strconv.Itoa(params.BucketSeconds))
+	}
+	var out GetWindowSummaryResponse
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetScheduledWindows calls GET /api/v1/groups/{group_id}/windows/scheduled: List an admin's scheduled windows.
+func (c *Client) GetScheduledWindows(ctx context.Context, params GetScheduledWindowsParams) (*GetScheduledWindowsResponse, error) {
+	path := "/api/v1/groups/" + url.PathEscape(params.GroupID) + "/windows/scheduled"
+	query := url.Values{}
+	query.Set("admin_id", params.AdminID)
+	var out GetScheduledWindowsResponse
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ScheduleWindow calls POST /api/v1/groups/{group_id}/windows/scheduled: Schedule a window to open later, optionally recurring.
+func (c *Client) ScheduleWindow(ctx context.Context, params ScheduleWindowParams) (*ScheduleWindowResponse, error) {
+	path := "/api/v1/groups/" + url.PathEscape(params.GroupID) + "/windows/scheduled"
+	var out ScheduleWindowResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CancelScheduledWindow calls DELETE /api/v1/groups/{group_id}/windows/scheduled/{scheduled_id}: Cancel a scheduled window.
+func (c *Client) CancelScheduledWindow(ctx context.Context, params CancelScheduledWindowParams) (*CancelScheduledWindowResponse, error) {
+	path := "/api/v1/groups/" + url.PathEscape(params.GroupID) + "/windows/scheduled/" + url.PathEscape(params.ScheduledID)
+	query := url.Values{}
+	query.Set("admin_id", params.AdminID)
+	var out CancelScheduledWindowResponse
+	if err := c.do(ctx, http.MethodDelete, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSentMessages calls GET /api/v1/messages: List an admin's sent broadcasts.
+func (c *Client) GetSentMessages(ctx context.Context, params GetSentMessagesParams) (*GetSentMessagesResponse, error) {
+	path := "/api/v1/messages"
+	query := url.Values{}
+	query.Set("admin_id", params.AdminID)
+	if params.Page != 0 {
+		query.Set("page", strconv.Itoa(params.Page))
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	var out GetSentMessagesResponse
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SendBroadcastMessage calls POST /api/v1/messages: Queue a broadcast message.
+func (c *Client) SendBroadcastMessage(ctx context.Context, params SendBroadcastMessageParams) (*SendBroadcastMessageResponse, error) {
+	path := "/api/v1/messages"
+	var out SendBroadcastMessageResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AdminDeleteMessage calls DELETE /api/v1/messages/{message_id}: Delete a sent broadcast.
+func (c *Client) AdminDeleteMessage(ctx context.Context, params AdminDeleteMessageParams) (*AdminDeleteMessageResponse, error) {
+	path := "/api/v1/messages/" + url.PathEscape(params.MessageID)
+	query := url.Values{}
+	query.Set("admin_id", params.AdminID)
+	var out AdminDeleteMessageResponse
+	if err := c.do(ctx, http.MethodDelete, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// EditMessage calls PATCH /api/v1/messages/{message_id}: Edit a sent broadcast.
+func (c *Client) EditMessage(ctx context.Context, params EditMessageParams) (*EditMessageResponse, error) {
+	path := "/api/v1/messages/" + url.PathEscape(params.MessageID)
+	var out EditMessageResponse
+	if err := c.do(ctx, http.MethodPatch, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetMessageEdits calls GET /api/v1/messages/{message_id}/edits: List a broadcast's edit history.
+func (c *Client) GetMessageEdits(ctx context.Context, params GetMessageEditsParams) (*GetMessageEditsResponse, error) {
+	path := "/api/v1/messages/" + url.PathEscape(params.MessageID) + "/edits"
+	query := url.Values{}
+	query.Set("admin_id", params.AdminID)
+	var out GetMessageEditsResponse
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RecallMessage calls POST /api/v1/messages/{message_id}/recall: Recall a broadcast from students' inboxes.
+func (c *Client) RecallMessage(ctx context.Context, params RecallMessageParams) (*RecallMessageResponse, error) {
+	path := "/api/v1/messages/" + url.PathEscape(params.MessageID) + "/recall"
+	var out RecallMessageResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetMessageReceipts calls GET /api/v1/messages/{message_id}/receipts: List who has read a broadcast.
+func (c *Client) GetMessageReceipts(ctx context.Context, params GetMessageReceiptsParams) (*GetMessageReceiptsResponse, error) {
+	path := "/api/v1/messages/" + url.PathEscape(params.MessageID) + "/receipts"
+	query := url.Values{}
+	query.Set("admin_id", params.AdminID)
+	var out GetMessageReceiptsResponse
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExportUnread calls GET /api/v1/messages/{message_id}/receipts/unread/export: Download the students who haven't read a broadcast.
+func (c *Client) ExportUnread(ctx context.Context, params ExportUnreadParams) (io.ReadCloser, error) {
+	path := "/api/v1/messages/" + url.PathEscape(params.MessageID) + "/receipts/unread/export"
+	query := url.Values{}
+	query.Set("admin_id", params.AdminID)
+	return c.stream(ctx, http.MethodGet, path, query)
+}
+
+// RenotifyUnread calls POST /api/v1/messages/{message_id}/reminders: Push a reminder to students who haven't read a broadcast.
+func (c *Client) RenotifyUnread(ctx context.Context, params RenotifyUnreadParams) (*RenotifyUnreadResponse, error) {
+	path := "/api/v1/messages/" + url.PathEscape(params.MessageID) + "/reminders"
+	var out RenotifyUnreadResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetBroadcastStatus calls GET /api/v1/messages/{message_id}/status: Get a broadcast's delivery progress.
+func (c *Client) GetBroadcastStatus(ctx context.Context, params GetBroadcastStatusParams) (*GetBroadcastStatusResponse, error) {
+	path := "/api/v1/messages/" + url.PathEscape(params.MessageID) + "/status"
+	query := url.Values{}
+	if params.JobID != "" {
+		query.Set("job_id", params.JobID)
+	}
+	var out GetBroadcastStatusResponse
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetScheduledMessages calls GET /api/v1/scheduled-messages: List an admin's scheduled broadcasts.
+func (c *Client) GetScheduledMessages(ctx context.Context, params GetScheduledMessagesParams) (*GetScheduledMessagesResponse, error) {
+	path := "/api/v1/scheduled-messages"
+	query := url.Values{}
+	query.Set("admin_id", params.AdminID)
+	var out GetScheduledMessagesResponse
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ScheduleMessage calls POST /api/v1/scheduled-messages: Schedule a broadcast, optionally recurring.
+func (c *Client) ScheduleMessage(ctx context.Context, params ScheduleMessageParams) (*ScheduleMessageResponse, error) {
+	path := "/api/v1/scheduled-messages"
+	var out ScheduleMessageResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CancelScheduledMessage calls DELETE /api/v1/scheduled-messages/{scheduled_id}: Cancel a scheduled broadcast.
+func (c *Client) CancelScheduledMessage(ctx context.Context, params CancelScheduledMessageParams) (*CancelScheduledMessageResponse, error) {
+	path := "/api/v1/scheduled-messages/" + url.PathEscape(params.ScheduledID)
+	query := url.Values{}
+	query.Set("admin_id", params.AdminID)
+	var out CancelScheduledMessageResponse
+	if err := c.do(ctx, http.MethodDelete, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AdminLogin calls POST /api/v1/sessions/admin: Log in as an admin.
+func (c *Client) AdminLogin(ctx context.Context, params AdminLoginParams) (*AdminLoginResponse, error) {
+	path := "/api/v1/sessions/admin"
+	var out AdminLoginResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StudentLogin calls POST /api/v1/sessions/student: Log in as a student by roll number and name.
+func (c *Client) StudentLogin(ctx context.Context, params StudentLoginParams) (*StudentLoginResponse, error) {
+	path := "/api/v1/sessions/student"
+	var out StudentLoginResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetAllStudents calls GET /api/v1/students: List students, paginated.
+func (c *Client) GetAllStudents(ctx context.Context, params GetAllStudentsParams) (*GetAllStudentsResponse, error) {
+	path := "/api/v1/students"
+	query := url.Values{}
+	if params.Page != 0 {
+		query.Set("page", strconv.Itoa(params.Page))
+	}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.View != "" {
+		query.Set("view", params.View)
+	}
+	var out GetAllStudentsResponse
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AddStudent calls POST /api/v1/students: Register a student.
+func (c *Client) AddStudent(ctx context.Context, params AddStudentParams) (*AddStudentResponse, error) {
+	path := "/api/v1/students"
+	var out AddStudentResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetStudentAttendanceHistory calls GET /api/v1/students/{student_id}/attendance: List a student's attendance records.
+func (c *Client) GetStudentAttendanceHistory(ctx context.Context, params GetStudentAttendanceHistoryParams) (*GetStudentAttendanceHistoryResponse, error) {
+	path := "/api/v1/students/" + url.PathEscape(params.StudentID) + "/attendance"
+	var out GetStudentAttendanceHistoryResponse
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetStudentDashboard calls GET /api/v1/students/{student_id}/dashboard: Everything the student home screen shows.
+func (c *Client) GetStudentDashboard(ctx context.Context, params GetStudentDashboardParams) (*GetStudentDashboardResponse, error) {
+	path := "/api/v1/students/" + url.PathEscape(params.StudentID) + "/dashboard"
+	var out GetStudentDashboardResponse
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetMessages calls GET /api/v1/students/{student_id}/messages: List a student's broadcast inbox.
+func (c *Client) GetMessages(ctx context.Context, params GetMessagesParams) (*GetMessagesResponse, error) {
+	path := "/api/v1/students/" + url.PathEscape(params.StudentID) + "/messages"
+	query := url.Values{}
+	if params.Limit != 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	if params.IsRead != nil {
+		query.Set("is_read", strconv.FormatBool(*params.IsRead))
+	}
+	if params.GroupID != "" {
+		query.Set("group_id", params.GroupID)
+	}
+	if params.Cursor != "" {
+		query.Set("cursor", params.Cursor)
+	}
+	var out GetMessagesResponse
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUnreadCount calls GET /api/v1/students/{student_id}/messages/unread-count: Count a student's unread broadcasts.
+func (c *Client) GetUnreadCount(ctx context.Context, params GetUnreadCountParams) (*GetUnreadCountResponse, error) {
+	path := "/api/v1/students/" + url.PathEscape(params.StudentID) + "/messages/unread-count"
+	var out GetUnreadCountResponse
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteMessage calls DELETE /api/v1/students/{student_id}/messages/{message_id}: Remove a broadcast from a student's inbox.
+func (c *Client) DeleteMessage(ctx context.Context, params DeleteMessageParams) (*DeleteMessageResponse, error) {
+	path := "/api/v1/students/" + url.PathEscape(params.StudentID) + "/messages/" + url.PathEscape(params.MessageID)
+	var out DeleteMessageResponse
+	if err := c.do(ctx, http.MethodDelete, path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// MarkMessageRead calls POST /api/v1/students/{student_id}/messages/{message_id}/read: Mark a broadcast read.
+func (c *Client) MarkMessageRead(ctx context.Context, params MarkMessageReadParams) (*MarkMessageReadResponse, error) {
+	path := "/api/v1/students/" + url.PathEscape(params.StudentID) + "/messages/" + url.PathEscape(params.MessageID) + "/read"
+	var out MarkMessageReadResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReplyMessage calls POST /api/v1/students/{student_id}/messages/{message_id}/replies: Reply privately to a broadcast.
+func (c *Client) ReplyMessage(ctx context.Context, params ReplyMessageParams) (*ReplyMessageResponse, error) {
+	path := "/api/v1/students/" + url.PathEscape(params.StudentID) + "/messages/" + url.PathEscape(params.MessageID) + "/replies"
+	var out ReplyMessageResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetStudentThreads calls GET /api/v1/students/{student_id}/threads: List a student's conversations.
+func (c *Client) GetStudentThreads(ctx context.Context, params GetStudentThreadsParams) (*GetStudentThreadsResponse, error) {
+	path := "/api/v1/students/" + url.PathEscape(params.StudentID) + "/threads"
+	var out GetStudentThreadsResponse
+	if err := c.do(ctx, http.MethodGet, path, nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetStudentWindowStatus calls GET /api/v1/students/{student_id}/windows/current: Get the state of a group's window, or a student's best open window.
+func (c *Client) GetStudentWindowStatus(ctx context.Context, params GetStudentWindowStatusParams) (*GetStudentWindowStatusResponse, error) {
+	path := "/api/v1/students/" + url.PathEscape(params.StudentID) + "/windows/current"
+	query := url.Values{}
+	if params.GroupID != "" {
+		query.Set("group_id", params.GroupID)
+	}
+	var out GetStudentWindowStatusResponse
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetTemplates calls GET /api/v1/templates: List the templates an admin can use.
+func (c *Client) GetTemplates(ctx context.Context, params GetTemplatesParams) (*GetTemplatesResponse, error) {
+	path := "/api/v1/templates"
+	query := url.Values{}
+	query.Set("admin_id", params.AdminID)
+	var out GetTemplatesResponse
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateTemplate calls POST /api/v1/templates: Create a message template.
+func (c *Client) CreateTemplate(ctx context.Context, params CreateTemplateParams) (*CreateTemplateResponse, error) {
+	path := "/api/v1/templates"
+	var out CreateTemplateResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PreviewTemplate calls POST /api/v1/templates/preview: Render a template for one student.
+func (c *Client) PreviewTemplate(ctx context.Context, params PreviewTemplateParams) (*PreviewTemplateResponse, error) {
+	path := "/api/v1/templates/preview"
+	var out PreviewTemplateResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteTemplate calls DELETE /api/v1/templates/{template_id}: Delete a message template.
+func (c *Client) DeleteTemplate(ctx context.Context, params DeleteTemplateParams) (*DeleteTemplateResponse, error) {
+	path := "/api/v1/templates/" + url.PathEscape(params.TemplateID)
+	query := url.Values{}
+	query.Set("admin_id", params.AdminID)
+	var out DeleteTemplateResponse
+	if err := c.do(ctx, http.MethodDelete, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateTemplate calls PUT /api/v1/templates/{template_id}: Update a message template.
+func (c *Client) UpdateTemplate(ctx context.Context, params UpdateTemplateParams) (*UpdateTemplateResponse, error) {
+	path := "/api/v1/templates/" + url.PathEscape(params.TemplateID)
+	var out UpdateTemplateResponse
+	if err := c.do(ctx, http.MethodPut, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// OpenThread calls POST /api/v1/threads: Start a conversation with a group's admin.
+func (c *Client) OpenThread(ctx context.Context, params OpenThreadParams) (*OpenThreadResponse, error) {
+	path := "/api/v1/threads"
+	var out OpenThreadResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteThread calls DELETE /api/v1/threads/{thread_id}: Hide a conversation from one participant.
+func (c *Client) DeleteThread(ctx context.Context, params DeleteThreadParams) (*DeleteThreadResponse, error) {
+	path := "/api/v1/threads/" + url.PathEscape(params.ThreadID)
+	query := url.Values{}
+	if params.AdminID != "" {
+		query.Set("admin_id", params.AdminID)
+	}
+	if params.StudentID != "" {
+		query.Set("student_id", params.StudentID)
+	}
+	var out DeleteThreadResponse
+	if err := c.do(ctx, http.MethodDelete, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetThread calls GET /api/v1/threads/{thread_id}: Get a conversation with its messages.
+func (c *Client) GetThread(ctx context.Context, params GetThreadParams) (*GetThreadResponse, error) {
+	path := "/api/v1/threads/" + url.PathEscape(params.ThreadID)
+	query := url.Values{}
+	if params.AdminID != "" {
+		query.Set("admin_id", params.AdminID)
+	}
+	if params.StudentID != "" {
+		query.Set("student_id", params.StudentID)
+	}
+	var out GetThreadResponse
+	if err := c.do(ctx, http.MethodGet, path, query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReplyThread calls POST /api/v1/threads/{thread_id}/messages: Reply in a conversation.
+func (c *Client) ReplyThread(ctx context.Context, params ReplyThreadParams) (*ReplyThreadResponse, error) {
+	path := "/api/v1/threads/" + url.PathEscape(params.ThreadID) + "/messages"
+	var out ReplyThreadResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// MarkThreadRead calls POST /api/v1/threads/{thread_id}/read: Mark a conversation read.
+func (c *Client) MarkThreadRead(ctx context.Context, params MarkThreadReadParams) (*MarkThreadReadResponse, error) {
+	path := "/api/v1/threads/" + url.PathEscape(params.ThreadID) + "/read"
+	var out MarkThreadReadResponse
+	if err := c.do(ctx, http.MethodPost, path, nil, params, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+type GetAdminInboxResponse struct {
+	Broadcasts  []map[string]interface{} `json:"broadcasts"`
+	Count       int                      `json:"count"`
+	Threads     []map[string]interface{} `json:"threads"`
+	TotalUnread int                      `json:"total_unread"`
+}
+
+// GetAdminInboxParams are the inputs of GetAdminInbox.
+type GetAdminInboxParams struct {
+	AdminID   string `json:"-"` // path
+	MessageID string `json:"-"` // query
+}
+
+type UploadAttachmentResponse struct {
+	AttachmentID string `json:"attachment_id"`
+	ContentType  string `json:"content_type"`
+	FileName     string `json:"file_name"`
+	SizeBytes    int    `json:"size_bytes"`
+}
+
+// UploadAttachmentParams are the inputs of UploadAttachment.
+type UploadAttachmentParams struct {
+	AdminID  string    `json:"-"`
+	File     io.Reader `json:"-"`
+	FileName string    `json:"-"`
+}
+
+// DownloadAttachmentParams are the inputs of DownloadAttachment.
+type DownloadAttachmentParams struct {
+	AttachmentID string `json:"-"` // path
+	AdminID      string `json:"-"` // query
+	StudentID    string `json:"-"` // query
+}
+
+type SaveFCMTokenResponse struct {
+	Status string `json:"status"`
+}
+
+// SaveFCMTokenParams are the inputs of SaveFCMToken.
+type SaveFCMTokenParams struct {
+	DeviceType string `json:"device_type,omitempty"`
+	FCMToken   string `json:"fcm_token"`
+	UserID     string `json:"user_id"`
+	UserType   string `json:"user_type"`
+}
+
+// EventsParams are the inputs of Events.
+type EventsParams struct {
+	GroupID   string `json:"-"` // query
+	StudentID string `json:"-"` // query
+	AdminID   string `json:"-"` // query
+}
+
+type GetMyGroupsResponse struct {
+	Count  int                      `json:"count"`
+	Groups []map[string]interface{} `json:"groups"`
+}
+
+// GetMyGroupsParams are the inputs of GetMyGroups.
+type GetMyGroupsParams struct {
+	AdminID string `json:"-"` // query
+}
+
+type CreateGroupResponseGroup struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type CreateGroupResponse struct {
+	Group CreateGroupResponseGroup `json:"group"`
+}
+
+// CreateGroupParams are the inputs of CreateGroup.
+type CreateGroupParams struct {
+	AdminID string `json:"admin_id"`
+	Name    string `json:"name"`
+}
+
+type DeleteGroupResponse struct {
+	Message string `json:"message"`
+}
+
+// DeleteGroupParams are the inputs of DeleteGroup.
+type DeleteGroupParams struct {
+	GroupID string `json:"-"` // path
+}
+
+type GetAllStudentLocationsResponse struct {
+	Count    int                `json:"count"`
+	Students []AttendanceRecord `json:"students"`
+}
+
+// GetAllStudentLocationsParams are the inputs of GetAllStudentLocations.
+type GetAllStudentLocationsParams struct {
+	GroupID string `json:"-"` // path
+}
+
+type SubmitAttendanceResponse struct {
+	Distance  string `json:"distance"`
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+}
+
+// SubmitAttendanceParams are the inputs of SubmitAttendance.
+type SubmitAttendanceParams struct {
+	GroupID     string  `json:"-"` // path
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	StudentID   string  `json:"student_id"`
+	StudentName string  `json:"student_name,omitempty"`
+}
+
+// DownloadCSVParams are the inputs of DownloadCSV.
+type DownloadCSVParams struct {
+	GroupID string `json:"-"` // path
+}
+
+type GetAdminLocationResponse struct {
+	Lat          float64 `json:"lat"`
+	Lon          float64 `json:"lon"`
+	SessionName  string  `json:"session_name"`
+	Threshold    float64 `json:"threshold"`
+	WindowActive bool    `json:"window_active"`
+}
+
+// GetAdminLocationParams are the inputs of GetAdminLocation.
+type GetAdminLocationParams struct {
+	GroupID string `json:"-"` // path
+}
+
+type SetCenterResponse struct {
+	Message string `json:"message"`
+}
+
+// SetCenterParams are the inputs of SetCenter.
+type SetCenterParams struct {
+	GroupID     string  `json:"-"` // path
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	SessionName string  `json:"session_name,omitempty"`
+	Threshold   float64 `json:"threshold,omitempty"`
+}
+
+type UpdateSessionNameResponse struct {
+	Message     string `json:"message"`
+	SessionName string `json:"session_name"`
+}
+
+// UpdateSessionNameParams are the inputs of UpdateSessionName.
+type UpdateSessionNameParams struct {
+	GroupID     string `json:"-"` // path
+	SessionName string `json:"session_name"`
+}
+
+type GetGroupStudentsResponse struct {
+	Count    int                      `json:"count"`
+	Students []map[string]interface{} `json:"students"`
+}
+
+// GetGroupStudentsParams are the inputs of GetGroupStudents.
+type GetGroupStudentsParams struct {
+	GroupID string `json:"-"` // path
+}
+
+type AddStudentsToGroupResponse struct {
+	Message string `json:"message"`
+}
+
+// AddStudentsToGroupParams are the inputs of AddStudentsToGroup.
+type AddStudentsToGroupParams struct {
+	GroupID    string `json:"-"` // path
+	StudentIDs string `json:"student_ids"`
+}
+
+type StartWindowResponse struct {
+	GroupID string `json:"group_id"`
+	Message string `json:"message"`
+}
+
+// StartWindowParams are the inputs of StartWindow.
+type StartWindowParams struct {
+	GroupID   string `json:"-"` // path
+	GroupOnly *bool  `json:"group_only,omitempty"`
+}
+
+type CloseWindowResponse struct {
+	GroupID string `json:"group_id"`
+	Message string `json:"message"`
+}
+
+// CloseWindowParams are the inputs of CloseWindow.
+type CloseWindowParams struct {
+	GroupID string `json:"-"` // path
+}
+
+type GetWindowStatusResponse struct {
+	Active           bool   `json:"active"`
+	GroupID          string `json:"group_id,omitempty"`
+	GroupName        string `json:"group_name,omitempty"`
+	RemainingSeconds int    `json:"remaining_seconds"`
+	SessionName      string `json:"session_name"`
+	StartTime        string `json:"start_time,omitempty"`
+	WindowCount      int    `json:"window_count,omitempty"`
+}
+
+// GetWindowStatusParams are the inputs of GetWindowStatus.
+type GetWindowStatusParams struct {
+	GroupID   string `json:"-"` // path
+	StudentID string `json:"-"` // query
+}
+
+type GetWindowSummaryResponseDistanceDistribution struct {
+	Buckets         []DistanceBucket `json:"buckets"`
+	MaxMeters       float64          `json:"max_meters,omitempty"`
+	MeanMeters      float64          `json:"mean_meters,omitempty"`
+	MedianMeters    float64          `json:"median_meters,omitempty"`
+	MinMeters       float64          `json:"min_meters,omitempty"`
+	ThresholdMeters float64          `json:"threshold_meters"`
+}
+
+type GetWindowSummaryResponseSubmissionHistogram struct {
+	BucketSeconds int               `json:"bucket_seconds"`
+	Buckets       []HistogramBucket `json:"buckets"`
+}
+
+type GetWindowSummaryResponse struct {
+	AbsentCount          int                                          `json:"absent_count"`
+	DistanceDistribution GetWindowSummaryResponseDistanceDistribution `json:"distance_distribution"`
+	GroupID              string                                       `json:"group_id"`
+	GroupName            string                                       `json:"group_name"`
+	GroupOnly            bool                                         `json:"group_only"`
+	PendingCount         int                                          `json:"pending_count"`
+	PendingStudents      []PendingStudent                             `json:"pending_students"`
+	PresentCount         int                                          `json:"present_count"`
+	RemainingSeconds     int                                          `json:"remaining_seconds"`
+	RosterSize           int                                          `json:"roster_size"`
+	SubmissionHistogram  GetWindowSummaryResponseSubmissionHistogram  `json:"submission_histogram"`
+	SubmittedCount       int                                          `json:"submitted_count"`
+	WindowActive         bool                                         `json:"window_active"`
+	WindowEnd            string                                       `json:"window_end,omitempty"`
+	WindowStart          string                                       `json:"window_start,omitempty"`
+}
+
+// GetWindowSummaryParams are the inputs of GetWindowSummary.
+type GetWindowSummaryParams struct {
+	GroupID       string `json:"-"` // path
+	BucketSeconds int    `json:"-"` // query
+}
+
+type GetScheduledWindowsResponse struct {
+	Count            int               `json:"count"`
+	ScheduledWindows []ScheduledWindow `json:"scheduled_windows"`
+}
+
+// GetScheduledWindowsParams are the inputs of GetScheduledWindows.
+type GetScheduledWindowsParams struct {
+	GroupID string `json:"-"` // path
+	AdminID string `json:"-"` // query
+}
+
+type ScheduleWindowResponse struct {
+	ClosesAt    string  `json:"closes_at"`
+	Message     string  `json:"message"`
+	OpensAt     string  `json:"opens_at"`
+	Recurrence  *string `json:"recurrence"`
+	ScheduledID string  `json:"scheduled_id"`
+}
+
+// ScheduleWindowParams are the inputs of ScheduleWindow.
+type ScheduleWindowParams struct {
+	GroupID    string `json:"-"` // path
+	AdminID    string `json:"admin_id"`
+	GroupOnly  *bool  `json:"group_only,omitempty"`
+	OpensAt    string `json:"opens_at,omitempty"`
+	Recurrence string `json:"recurrence,omitempty"`
+}
+
+type CancelScheduledWindowResponse struct {
+	Message string `json:"message"`
+}
+
+// CancelScheduledWindowParams are the inputs of CancelScheduledWindow.
+type CancelScheduledWindowParams struct {
+	GroupID     string `json:"-"` // path
+	ScheduledID string `json:"-"` // path
+	AdminID     string `json:"-"` // query
+}
+
+type GetSentMessagesResponse struct {
+	Count      int                      `json:"count"`
+	Messages   []map[string]interface{} `json:"messages"`
+	Pagination Pagination               `json:"pagination"`
+}
+
+// GetSentMessagesParams are the inputs of GetSentMessages.
+type GetSentMessagesParams struct {
+	AdminID string `json:"-"` // query
+	Page    int    `json:"-"` // query
+	Limit   int    `json:"-"` // query
+}
+
+type SendBroadcastMessageResponse struct {
+	Duplicate bool   `json:"duplicate"`
+	JobID     string `json:"job_id"`
+	Message   string `json:"message"`
+	MessageID string `json:"message_id"`
+	Status    string `json:"status"`
+	StatusURL string `json:"status_url"`
+}
+
+// SendBroadcastMessageParams are the inputs of SendBroadcastMessage.
+type SendBroadcastMessageParams struct {
+	AdminID         string             `json:"admin_id"`
+	AttachmentIDs   []string           `json:"attachment_ids,omitempty"`
+	Audience        *BroadcastAudience `json:"audience,omitempty"`
+	ExpiresAt       string             `json:"expires_at,omitempty"`
+	GroupID         string             `json:"group_id,omitempty"`
+	IdempotencyKey  string             `json:"idempotency_key,omitempty"`
+	Message         string             `json:"message,omitempty"`
+	Priority        string             `json:"priority,omitempty"`
+	SendToAll       *bool              `json:"send_to_all,omitempty"`
+	TemplateGroupID string             `json:"template_group_id,omitempty"`
+	TemplateID      string             `json:"template_id,omitempty"`
+	Title           string             `json:"title,omitempty"`
+}
+
+type AdminDeleteMessageResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// AdminDeleteMessageParams are the inputs of AdminDeleteMessage.
+type AdminDeleteMessageParams struct {
+	MessageID string `json:"-"` // path
+	AdminID   string `json:"-"` // query
+}
+
+type EditMessageResponse struct {
+	Changed  bool   `json:"changed"`
+	EditedAt string `json:"edited_at,omitempty"`
+}
+
+// EditMessageParams are the inputs of EditMessage.
+type EditMessageParams struct {
+	MessageID string `json:"-"` // path
+	AdminID   string `json:"admin_id"`
+	Message   string `json:"message,omitempty"`
+	Title     string `json:"title,omitempty"`
+}
+
+type GetMessageEditsResponse struct {
+	Count   int           `json:"count"`
+	Edits   []MessageEdit `json:"edits"`
+	Message SentMessage   `json:"message"`
+}
+
+// GetMessageEditsParams are the inputs of GetMessageEdits.
+type GetMessageEditsParams struct {
+	MessageID string `json:"-"` // path
+	AdminID   string `json:"-"` // query
+}
+
+type RecallMessageResponse struct {
+	RecalledAt string `json:"recalled_at"`
+}
+
+// RecallMessageParams are the inputs of RecallMessage.
+type RecallMessageParams struct {
+	MessageID string `json:"-"` // path
+	AdminID   string `json:"admin_id"`
+}
+
+type GetMessageReceiptsResponse struct {
+	Delivered   int              `json:"delivered"`
+	Message     SentMessage      `json:"message"`
+	Read        []MessageReceipt `json:"read"`
+	ReadCount   int              `json:"read_count"`
+	ReadPercent float64          `json:"read_percent"`
+	Unread      []MessageReceipt `json:"unread"`
+	UnreadCount int              `json:"unread_count"`
+}
+
+// GetMessageReceiptsParams are the inputs of GetMessageReceipts.
+type GetMessageReceiptsParams struct {
+	MessageID string `json:"-"` // path
+	AdminID   string `json:"-"` // query
+}
+
+// ExportUnreadParams are the inputs of ExportUnread.
+type ExportUnreadParams struct {
+	MessageID string `json:"-"` // path
+	AdminID   string `json:"-"` // query
+}
+
+type RenotifyUnreadResponse struct {
+	DevicesNotified int `json:"devices_notified"`
+	UnreadStudents  int `json:"unread_students"`
+}
+
+// RenotifyUnreadParams are the inputs of RenotifyUnread.
+type RenotifyUnreadParams struct {
+	MessageID string `json:"-"` // path
+	AdminID   string `json:"admin_id"`
+}
+
+type GetBroadcastStatusResponse struct {
+	Job      BroadcastJob `json:"job"`
+	Progress float64      `json:"progress"`
+}
+
+// GetBroadcastStatusParams are the inputs of GetBroadcastStatus.
+type GetBroadcastStatusParams struct {
+	MessageID string `json:"-"` // path
+	JobID     string `json:"-"` // query
+}
+
+type GetScheduledMessagesResponse struct {
+	Count             int                `json:"count"`
+	ScheduledMessages []ScheduledMessage `json:"scheduled_messages"`
+}
+
+// GetScheduledMessagesParams are the inputs of GetScheduledMessages.
+type GetScheduledMessagesParams struct {
+	AdminID string `json:"-"` // query
+}
+
+type ScheduleMessageResponse struct {
+	Message     string  `json:"message"`
+	Recurrence  *string `json:"recurrence"`
+	ScheduledID string  `json:"scheduled_id"`
+	SendAt      string  `json:"send_at"`
+}
+
+// ScheduleMessageParams are the inputs of ScheduleMessage.
+type ScheduleMessageParams struct {
+	AdminID    string             `json:"admin_id"`
+	Audience   *BroadcastAudience `json:"audience,omitempty"`
+	GroupID    string             `json:"group_id,omitempty"`
+	Message    string             `json:"message"`
+	Recurrence string             `json:"recurrence,omitempty"`
+	SendAt     string             `json:"send_at,omitempty"`
+	SendToAll  *bool              `json:"send_to_all,omitempty"`
+	Title      string             `json:"title"`
+}
+
+type CancelScheduledMessageResponse struct {
+	Message string `json:"message"`
+}
+
+// CancelScheduledMessageParams are the inputs of CancelScheduledMessage.
+type CancelScheduledMessageParams struct {
+	ScheduledID string `json:"-"` // path
+	AdminID     string `json:"-"` // query
+}
+
+type AdminLoginResponseAdmin struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+type AdminLoginResponse struct {
+	Admin   AdminLoginResponseAdmin `json:"admin"`
+	Message string                  `json:"message"`
+}
+
+// AdminLoginParams are the inputs of AdminLogin.
+type AdminLoginParams struct {
+	Password string `json:"password"`
+	Username string `json:"username"`
+}
+
+type StudentLoginResponseStudent struct {
+	ID          string `json:"id"`
+	StudentID   string `json:"student_id"`
+	StudentName string `json:"student_name"`
+}
+
+type StudentLoginResponse struct {
+	Message string                      `json:"message"`
+	Student StudentLoginResponseStudent `json:"student"`
+}
+
+// StudentLoginParams are the inputs of StudentLogin.
+type StudentLoginParams struct {
+	StudentID   string `json:"student_id"`
+	StudentName string `json:"student_name"`
+}
+
+type GetAllStudentsResponse struct {
+	Count      int                      `json:"count"`
+	Pagination Pagination               `json:"pagination"`
+	Students   []map[string]interface{} `json:"students"`
+}
+
+// GetAllStudentsParams are the inputs of GetAllStudents.
+type GetAllStudentsParams struct {
+	Page  int    `json:"-"` // query
+	Limit int    `json:"-"` // query
+	View  string `json:"-"` // query
+}
+
+type AddStudentResponseStudent struct {
+	ID          string `json:"id"`
+	StudentID   string `json:"student_id"`
+	StudentName string `json:"student_name"`
+}
+
+type AddStudentResponse struct {
+	Message string                    `json:"message"`
+	Student AddStudentResponseStudent `json:"student"`
+}
+
+// AddStudentParams are the inputs of AddStudent.
+type AddStudentParams struct {
+	StudentID   string `json:"student_id"`
+	StudentName string `json:"student_name"`
+}
+
+type GetStudentAttendanceHistoryResponse struct {
+	AttendanceHistory []map[string]interface{} `json:"attendance_history"`
+	Count             int                      `json:"count"`
+}
+
+// GetStudentAttendanceHistoryParams are the inputs of GetStudentAttendanceHistory.
+type GetStudentAttendanceHistoryParams struct {
+	StudentID string `json:"-"` // path
+}
+
+type GetStudentDashboardResponse struct {
+	ActiveWindows   []ActiveWindow   `json:"active_windows"`
+	PendingCount    int              `json:"pending_count"`
+	ServerTime      string           `json:"server_time"`
+	StudentID       string           `json:"student_id"`
+	UnreadMessages  int              `json:"unread_messages"`
+	UnreadReplies   int              `json:"unread_replies"`
+	UpcomingWindows []UpcomingWindow `json:"upcoming_windows"`
+}
+
+// GetStudentDashboardParams are the inputs of GetStudentDashboard.
+type GetStudentDashboardParams struct {
+	StudentID string `json:"-"` // path
+}
+
+type GetMessagesResponse struct {
+	Count      int                      `json:"count"`
+	Messages   []map[string]interface{} `json:"messages"`
+	Pagination Pagination               `json:"pagination"`
+}
+
+// GetMessagesParams are the inputs of GetMessages.
+type GetMessagesParams struct {
+	StudentID string `json:"-"` // path
+	Limit     int    `json:"-"` // query
+	IsRead    *bool  `json:"-"` // query
+	GroupID   string `json:"-"` // query
+	Cursor    string `json:"-"` // query
+}
+
+type GetUnreadCountResponse struct {
+	Unread int `json:"unread"`
+}
+
+// GetUnreadCountParams are the inputs of GetUnreadCount.
+type GetUnreadCountParams struct {
+	StudentID string `json:"-"` // path
+}
+
+type DeleteMessageResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// DeleteMessageParams are the inputs of DeleteMessage.
+type DeleteMessageParams struct {
+	StudentID string `json:"-"` // path
+	MessageID string `json:"-"` // path
+}
+
+type MarkMessageReadResponse struct {
+	Status string `json:"status"`
+}
+
+// MarkMessageReadParams are the inputs of MarkMessageRead.
+type MarkMessageReadParams struct {
+	StudentID string `json:"-"` // path
+	MessageID string `json:"-"` // path
+}
+
+type ReplyMessageResponse struct {
+	MessageID string `json:"message_id"`
+	ThreadID  string `json:"thread_id"`
+}
+
+// ReplyMessageParams are the inputs of ReplyMessage.
+type ReplyMessageParams struct {
+	StudentID string `json:"-"` // path
+	MessageID string `json:"-"` // path
+	Body      string `json:"body"`
+}
+
+type GetStudentThreadsResponse struct {
+	Count       int                      `json:"count"`
+	Threads     []map[string]interface{} `json:"threads"`
+	TotalUnread int                      `json:"total_unread"`
+}
+
+// GetStudentThreadsParams are the inputs of GetStudentThreads.
+type GetStudentThreadsParams struct {
+	StudentID string `json:"-"` // path
+}
+
+type GetStudentWindowStatusResponse struct {
+	Active           bool   `json:"active"`
+	GroupID          string `json:"group_id,omitempty"`
+	GroupName        string `json:"group_name,omitempty"`
+	RemainingSeconds int    `json:"remaining_seconds"`
+	SessionName      string `json:"session_name"`
+	StartTime        string `json:"start_time,omitempty"`
+	WindowCount      int    `json:"window_count,omitempty"`
+}
+
+// GetStudentWindowStatusParams are the inputs of GetStudentWindowStatus.
+type GetStudentWindowStatusParams struct {
+	StudentID string `json:"-"` // path
+	GroupID   string `json:"-"` // query
+}
+
+type GetTemplatesResponse struct {
+	Count        int               `json:"count"`
+	Placeholders []string          `json:"placeholders"`
+	Templates    []MessageTemplate `json:"templates"`
+}
+
+// GetTemplatesParams are the inputs of GetTemplates.
+type GetTemplatesParams struct {
+	AdminID string `json:"-"` // query
+}
+
+type CreateTemplateResponse struct {
+	TemplateID string `json:"template_id"`
+}
+
+// CreateTemplateParams are the inputs of CreateTemplate.
+type CreateTemplateParams struct {
+	AdminID string `json:"admin_id"`
+	Body    string `json:"body"`
+	Name    string `json:"name"`
+	Shared  *bool  `json:"shared,omitempty"`
+	Title   string `json:"title"`
+}
+
+type PreviewTemplateResponse struct {
+	Message string         `json:"message"`
+	Title   string         `json:"title"`
+	Values  TemplateValues `json:"values"`
+}
+
+// PreviewTemplateParams are the inputs of PreviewTemplate.
+type PreviewTemplateParams struct {
+	AdminID    string `json:"admin_id"`
+	Body       string `json:"body,omitempty"`
+	GroupID    string `json:"group_id,omitempty"`
+	StudentID  string `json:"student_id"`
+	TemplateID string `json:"template_id,omitempty"`
+	Title      string `json:"title,omitempty"`
+}
+
+type DeleteTemplateResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// DeleteTemplateParams are the inputs of DeleteTemplate.
+type DeleteTemplateParams struct {
+	TemplateID string `json:"-"` // path
+	AdminID    string `json:"-"` // query
+}
+
+type UpdateTemplateResponse struct {
+}
+
+// UpdateTemplateParams are the inputs of UpdateTemplate.
+type UpdateTemplateParams struct {
+	TemplateID string `json:"-"` // path
+	AdminID    string `json:"admin_id"`
+	Body       string `json:"body,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Shared     *bool  `json:"shared,omitempty"`
+	Title      string `json:"title,omitempty"`
+}
+
+type OpenThreadResponse struct {
+	MessageID string `json:"message_id"`
+	ThreadID  string `json:"thread_id"`
+}
+
+// OpenThreadParams are the inputs of OpenThread.
+type OpenThreadParams struct {
+	Body      string `json:"body"`
+	GroupID   string `json:"group_id"`
+	StudentID string `json:"student_id"`
+	Subject   string `json:"subject,omitempty"`
+}
+
+type DeleteThreadResponse struct {
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
+
+// DeleteThreadParams are the inputs of DeleteThread.
+type DeleteThreadParams struct {
+	ThreadID  string `json:"-"` // path
+	AdminID   string `json:"-"` // query
+	StudentID string `json:"-"` // query
+}
+
+type GetThreadResponse struct {
+	Count    int             `json:"count"`
+	Messages []ThreadMessage `json:"messages"`
+	Thread   MessageThread   `json:"thread"`
+}
+
+// GetThreadParams are the inputs of GetThread.
+type GetThreadParams struct {
+	ThreadID  string `json:"-"` // path
+	AdminID   string `json:"-"` // query
+	StudentID string `json:"-"` // query
+}
+
+type ReplyThreadResponse struct {
+	MessageID string `json:"message_id"`
+	ThreadID  string `json:"thread_id"`
+}
+
+// ReplyThreadParams are the inputs of ReplyThread.
+type ReplyThreadParams struct {
+	ThreadID  string `json:"-"` // path
+	AdminID   string `json:"admin_id,omitempty"`
+	Body      string `json:"body"`
+	StudentID string `json:"student_id,omitempty"`
+}
+
+type MarkThreadReadResponse struct {
+	Status string `json:"status"`
+}
+
+// MarkThreadReadParams are the inputs of MarkThreadRead.
+type MarkThreadReadParams struct {
+	ThreadID  string `json:"-"` // path
+	AdminID   string `json:"admin_id,omitempty"`
+	StudentID string `json:"student_id,omitempty"`
+}
+
+type ActiveWindow struct {
+	Distance         float64 `json:"distance,omitempty"`
+	EndsAt           string  `json:"ends_at"`
+	GroupID          string  `json:"group_id"`
+	GroupName        string  `json:"group_name"`
+	GroupOnly        bool    `json:"group_only"`
+	RemainingSeconds int     `json:"remaining_seconds"`
+	Status           string  `json:"status,omitempty"`
+	Submitted        bool    `json:"submitted"`
+	SubmittedAt      string  `json:"submitted_at,omitempty"`
+}
+
+type Attachment struct {
+	AdminID     string  `json:"admin_id"`
+	ContentType string  `json:"content_type"`
+	CreatedAt   string  `json:"created_at"`
+	FileName    string  `json:"file_name"`
+	ID          string  `json:"id"`
+	MessageID   *string `json:"message_id"`
+	SHA256      string  `json:"sha256"`
+	SizeBytes   int     `json:"size_bytes"`
+	StorageKey  string  `json:"storage_key"`
+}
+
+type AttendanceRecord struct {
+	Distance    float64 `json:"distance"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	Status      string  `json:"status"`
+	StudentID   string  `json:"student_id"`
+	StudentName string  `json:"student_name"`
+	SubmittedAt string  `json:"submitted_at"`
+}
+
+type BroadcastAudience struct {
+	AbsentFromGroupID      string   `json:"absent_from_group_id,omitempty"`
+	BelowAttendancePercent *float64 `json:"below_attendance_percent,omitempty"`
+	GroupID                string   `json:"group_id,omitempty"`
+	GroupIDs               []string `json:"group_ids,omitempty"`
+	SendToAll              bool     `json:"send_to_all"`
+	StudentIDs             []string `json:"student_ids,omitempty"`
+	UnreadMessageID        string   `json:"unread_message_id,omitempty"`
+}
+
+type BroadcastJob struct {
+	Attempts            int               `json:"attempts"`
+	Audience            BroadcastAudience `json:"audience"`
+	CompletedAt         string            `json:"completed_at,omitempty"`
+	CreatedAt           string            `json:"created_at,omitempty"`
+	DeliveredRecipients int               `json:"delivered_recipients"`
+	ID                  string            `json:"id"`
+	LastError           string            `json:"last_error,omitempty"`
+	MessageID           string            `json:"message_id"`
+	NextAttemptAt       string            `json:"next_attempt_at,omitempty"`
+	NotifiedAt          string            `json:"notified_at,omitempty"`
+	Status              string            `json:"status"`
+	TotalRecipients     int               `json:"total_recipients"`
+}
+
+type DistanceBucket struct {
+	Count     int      `json:"count"`
+	MaxMeters *float64 `json:"max_meters"`
+	MinMeters float64  `json:"min_meters"`
+}
+
+type HistogramBucket struct {
+	Count        int `json:"count"`
+	EndSeconds   int `json:"end_seconds"`
+	StartSeconds int `json:"start_seconds"`
+}
+
+type MessageEdit struct {
+	AdminID         string `json:"admin_id"`
+	EditedAt        string `json:"edited_at"`
+	ID              string `json:"id"`
+	MessageID       string `json:"message_id"`
+	PreviousMessage string `json:"previous_message"`
+	PreviousTitle   string `json:"previous_title"`
+}
+
+type MessageReceipt struct {
+	DeliveredAt string  `json:"delivered_at"`
+	IsRead      bool    `json:"is_read"`
+	ReadAt      *string `json:"read_at"`
+	StudentID   string  `json:"student_id"`
+	StudentName string  `json:"student_name"`
+}
+
+type MessageTemplate struct {
+	AdminID   string `json:"admin_id"`
+	Body      string `json:"body"`
+	CreatedAt string `json:"created_at"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Shared    bool   `json:"shared"`
+	Title     string `json:"title"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type MessageThreadStudents struct {
+	StudentID   string `json:"student_id"`
+	StudentName string `json:"student_name"`
+}
+
+type MessageThread struct {
+	AdminDeleted       bool                  `json:"admin_deleted"`
+	AdminID            string                `json:"admin_id"`
+	BroadcastMessageID *string               `json:"broadcast_message_id"`
+	CreatedAt          string                `json:"created_at"`
+	GroupID            *string               `json:"group_id"`
+	ID                 string                `json:"id"`
+	LastMessageAt      string                `json:"last_message_at"`
+	StudentDeleted     bool                  `json:"student_deleted"`
+	StudentID          string                `json:"student_id"`
+	Students           MessageThreadStudents `json:"students"`
+	Subject            string                `json:"subject"`
+}
+
+type Pagination struct {
+	HasMore    bool    `json:"has_more"`
+	Limit      int     `json:"limit,omitempty"`
+	NextCursor *string `json:"next_cursor,omitempty"`
+	Page       int     `json:"page,omitempty"`
+	TotalPages int     `json:"total_pages,omitempty"`
+}
+
+type PendingStudent struct {
+	StudentID   string `json:"student_id"`
+	StudentName string `json:"student_name"`
+}
+
+type ScheduledMessage struct {
+	AdminID       string             `json:"admin_id"`
+	Audience      *BroadcastAudience `json:"audience"`
+	CreatedAt     string             `json:"created_at,omitempty"`
+	GroupID       *string            `json:"group_id"`
+	ID            string             `json:"id"`
+	LastMessageID *string            `json:"last_message_id"`
+	LastSentAt    *string            `json:"last_sent_at"`
+	Message       string             `json:"message"`
+	Recurrence    *string            `json:"recurrence"`
+	SendAt        string             `json:"send_at"`
+	SendCount     int                `json:"send_count"`
+	SentToAll     bool               `json:"sent_to_all"`
+	Status        string             `json:"status"`
+	Title         string             `json:"title"`
+}
+
+type ScheduledWindow struct {
+	AdminID      string  `json:"admin_id"`
+	CreatedAt    string  `json:"created_at,omitempty"`
+	GroupID      string  `json:"group_id"`
+	GroupOnly    bool    `json:"group_only"`
+	ID           string  `json:"id"`
+	LastOpenedAt *string `json:"last_opened_at"`
+	OpenCount    int     `json:"open_count"`
+	OpensAt      string  `json:"opens_at"`
+	Recurrence   *string `json:"recurrence"`
+	Status       string  `json:"status"`
+}
+
+type SentMessage struct {
+	AdminID    string  `json:"admin_id"`
+	CreatedAt  string  `json:"created_at"`
+	EditedAt   *string `json:"edited_at"`
+	ExpiresAt  *string `json:"expires_at"`
+	GroupID    *string `json:"group_id"`
+	ID         string  `json:"id"`
+	Message    string  `json:"message"`
+	Priority   string  `json:"priority"`
+	RecalledAt *string `json:"recalled_at"`
+	SentToAll  bool    `json:"sent_to_all"`
+	Title      string  `json:"title"`
+}
+
+type StudentLocation struct {
+	Distance    float64 `json:"Distance"`
+	Latitude    float64 `json:"Latitude"`
+	Longitude   float64 `json:"Longitude"`
+	Status      string  `json:"Status"`
+	StudentID   string  `json:"StudentID"`
+	StudentName string  `json:"StudentName"`
+	Timestamp   string  `json:"Timestamp"`
+}
+
+type TemplateValues struct {
+	AttendancePercent string `json:"attendance_percent"`
+	EndTime           string `json:"end_time"`
+	Group             string `json:"group"`
+	StudentID         string `json:"student_id"`
+	StudentName       string `json:"student_name"`
+}
+
+type ThreadMessage struct {
+	Body       string  `json:"body"`
+	CreatedAt  string  `json:"created_at"`
+	ID         string  `json:"id"`
+	IsRead     bool    `json:"is_read"`
+	ReadAt     *string `json:"read_at"`
+	SenderID   string  `json:"sender_id"`
+	SenderType string  `json:"sender_type"`
+	ThreadID   string  `json:"thread_id"`
+}
+
+type UpcomingWindow struct {
+	ClosesAt    string  `json:"closes_at"`
+	GroupID     string  `json:"group_id"`
+	GroupName   string  `json:"group_name"`
+	GroupOnly   bool    `json:"group_only"`
+	OpensAt     string  `json:"opens_at"`
+	Recurrence  *string `json:"recurrence,omitempty"`
+	ScheduledID string  `json:"scheduled_id"`
+}
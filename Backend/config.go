@@ -1,26 +1,396 @@
 package main
 
 import (
-	"log"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+// Config holds every server setting. Each field is read from, lowest
+// precedence first: the default tag, a JSON config file (-config or
+// CONFIG_FILE), its environment variable (a .env file counts as the
+// environment) and its command-line flag. The key tag names the setting in
+// the config file and, with dashes for underscores, as a flag. Secret
+// settings are never shown by /api/admin/config.
 type Config struct {
-	SupabaseURL     string
-	SupabaseAnonKey string
+	// Port the HTTP server listens on
+	Port int `key:"port" env:"PORT" default:"8080"`
+
+	SupabaseURL string `key:"supabase_url" env:"SUPABASE_URL"`
+
+	// Service-role key the server calls Supabase with. It bypasses
+	// row-level security, so it must never leave the server.
+	SupabaseServiceKey string `key:"supabase_service_key" env:"SUPABASE_SERVICE_KEY" secret:"true"`
+
+	// Each attempt at a Supabase call is cut off after SupabaseTimeout;
+	// failed reads are retried up to SupabaseRetries times. After
+	// SupabaseBreakerThreshold failed calls in a row (0 disables the
+	// breaker) calls fail at once until SupabaseBreakerCooldown has passed.
+	SupabaseTimeout          time.Duration `key:"supabase_timeout" env:"SUPABASE_TIMEOUT" default:"10s"`
+	SupabaseRetries          int           `key:"supabase_retries" env:"SUPABASE_RETRIES" default:"2"`
+	SupabaseBreakerThreshold int           `key:"supabase_breaker_threshold" env:"SUPABASE_BREAKER_THRESHOLD" default:"5"`
+	SupabaseBreakerCooldown  time.Duration `key:"supabase_breaker_cooldown" env:"SUPABASE_BREAKER_COOLDOWN" default:"30s"`
+
+	// Push notifications
+	Notifier           string `key:"notifier" env:"NOTIFIER"` // "fcm", "log" or empty to auto-detect
+	FCMProjectID       string `key:"fcm_project_id" env:"FCM_PROJECT_ID"`
+	FCMCredentialsFile string `key:"fcm_credentials_file" env:"FIREBASE_CREDENTIALS" default:"serviceAccountKey.json"`
+	FCMEndpoint        string `key:"fcm_endpoint" env:"FCM_ENDPOINT" default:"https://fcm.googleapis.com"` // Overridable to point at a local fake server
+
+	// Directory for uploaded message attachments
+	AttachmentsDir string `key:"attachments_dir" env:"ATTACHMENTS_DIR" default:"attachments"`
+
+	// Directory for the per-window attendance CSV files
+	CSVDir string `key:"csv_dir" env:"CSV_DIR" default:"."`
+
+	// How long an attendance window stays open
+	WindowDuration time.Duration `key:"window_duration" env:"WINDOW_DURATION" default:"10m"`
+
+	// Minutes before a window auto-closes to remind students who haven't
+	// submitted; 0 disables reminders
+	WindowReminderMinutes int `key:"window_reminder_minutes" env:"WINDOW_REMINDER_MINUTES" default:"3"`
+
+	// Attendance radius for groups that don't set one
+	DefaultThresholdMeters float64 `key:"default_threshold_meters" env:"DEFAULT_THRESHOLD_METERS" default:"100"`
+
+	// How long the student list (page 1) and each admin's groups are cached
+	StudentCacheTTL time.Duration `key:"student_cache_ttl" env:"STUDENT_CACHE_TTL" default:"10s"`
+	GroupsCacheTTL  time.Duration `key:"groups_cache_ttl" env:"GROUPS_CACHE_TTL" default:"5m"`
+
+	// Origins allowed to call the API from a browser; "*" allows any
+	CORSOrigins []string `key:"cors_origins" env:"CORS_ORIGINS" default:"*"`
+
+	// Shared secret for HMAC request signatures; empty disables the check
+	APISigningSecret string `key:"api_signing_secret" env:"API_SIGNING_SECRET" secret:"true"`
+
+	// Requests per second allowed per client IP, with bursts up to
+	// RateLimitBurst; 0 disables rate limiting
+	RateLimitPerSecond int `key:"rate_limit_rps" env:"RATE_LIMIT_RPS" default:"20"`
+	RateLimitBurst     int `key:"rate_limit_burst" env:"RATE_LIMIT_BURST" default:"40"`
+
+	// Log verbosity (debug, info, warn, error) and format (text or json)
+	LogLevel  string `key:"log_level" env:"LOG_LEVEL" default:"info"`
+	LogFormat string `key:"log_format" env:"LOG_FORMAT" default:"text"`
+
+	// Where each setting came from (default, file, env or flag), by key
+	sources map[string]string
+}
+
+// maxSupabaseRetries bounds supabase_retries; more would hold a request for
+// minutes on an outage the circuit breaker should handle
+const maxSupabaseRetries = 10
+
+// configField is one tagged Config field
+type configField struct {
+	key, env, def string
+	secret        bool
+	value         reflect.Value
+}
+
+func (c *Config) fields() []configField {
+	v := reflect.ValueOf(c).Elem()
+	var fields []configField
+	for i := 0; i < v.NumField(); i++ {
+		tag := v.Type().Field(i).Tag
+		if tag.Get("key") == "" {
+			continue
+		}
+		fields = append(fields, configField{
+			key:    tag.Get("key"),
+			env:    tag.Get("env"),
+			def:    tag.Get("default"),
+			secret: tag.Get("secret") == "true",
+			value:  v.Field(i),
+		})
+	}
+	return fields
+}
+
+func (f configField) flagName() string {
+	return strings.ReplaceAll(f.key, "_", "-")
+}
+
+// set parses value into the field according to its type
+func (f configField) set(value string) error {
+	switch f.value.Interface().(type) {
+	case string:
+		f.value.SetString(value)
+	case int:
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return errors.New("not an integer")
+		}
+		f.value.SetInt(int64(n))
+	case float64:
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return errors.New("not a number")
+		}
+		f.value.SetFloat(n)
+	case time.Duration:
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return errors.New("not a duration like 90s or 10m")
+		}
+		f.value.SetInt(int64(d))
+	case []string:
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported setting type %s", f.value.Type())
+	}
+	return nil
+}
+
+// LoadConfig builds and validates the configuration. args are the
+// command-line arguments after the program name; the ones left after the
+// flags (a tooling subcommand) are returned. Every invalid value is
+// reported at once.
+func LoadConfig(args []string) (Config, []string, error) {
+	// Load .env file if it exists; real environment variables win
+	if err := godotenv.Load(); err != nil {
+		slog.Info("no .env file found, using environment variables")
+	}
+
+	cfg := Config{sources: make(map[string]string)}
+	fields := cfg.fields()
+
+	flags := flag.NewFlagSet("attendance-system", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "JSON config file (env CONFIG_FILE)")
+	byFlag := make(map[string]configField)
+	for _, f := range fields {
+		usage := "env " + f.env
+		if f.def != "" {
+			usage += ", default " + f.def
+		}
+		flags.String(f.flagName(), "", usage)
+		byFlag[f.flagName()] = f
+	}
+	if err := flags.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	var errs []error
+	apply := func(f configField, value, source string) {
+		if err := f.set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s %q (from %s): %w", f.key, value, source, err))
+			return
+		}
+		cfg.sources[f.key] = source
+	}
+
+	for _, f := range fields {
+		apply(f, f.def, "default")
+	}
+	if *configFile != "" {
+		values, err := readConfigFile(*configFile)
+		if err != nil {
+			return cfg, nil, err
+		}
+		for _, f := range fields {
+			if value, ok := values[f.key]; ok {
+				apply(f, value, "file")
+				delete(values, f.key)
+			}
+		}
+		for key := range values {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", *configFile, key))
+		}
+	}
+	for _, f := range fields {
+		if value := os.Getenv(f.env); value != "" {
+			apply(f, value, "env")
+		}
+	}
+	flags.Visit(func(fl *flag.Flag) {
+		if f, ok := byFlag[fl.Name]; ok {
+			apply(f, fl.Value.String(), "flag")
+		}
+	})
+
+	errs = append(errs, cfg.validate()...)
+	if err := errors.Join(errs...); err != nil {
+		return cfg, nil, fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return cfg, flags.Args(), nil
+}
+
+// defaultConfig is the configuration with every setting at its default and
+// nothing read from files, the environment or flags
+func defaultConfig() Config {
+	cfg := Config{sources: make(map[string]string)}
+	for _, f := range cfg.fields() {
+		if err := f.set(f.def); err != nil {
+			panic(fmt.Sprintf("config: default for %s: %v", f.key, err))
+		}
+		cfg.sources[f.key] = "default"
+	}
+	return cfg
 }
 
-func LoadConfig() Config {
-	// Load .env file if it exists
-	err := godotenv.Load()
+// readConfigFile reads a flat JSON object of settings as strings. Lists
+// may be JSON arrays; durations are strings like "10m".
+func readConfigFile(path string) (map[string]string, error) {
+	raw, err := os.ReadFile(path)
 	if err != nil {
-		log.Println("No .env file found, using environment variables")
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
 	}
 
-	return Config{
-		SupabaseURL:     os.Getenv("SUPABASE_URL"),
-		SupabaseAnonKey: os.Getenv("SUPABASE_ANON_KEY"),
+	values := make(map[string]string, len(doc))
+	for key, value := range doc {
+		switch v := value.(type) {
+		case string:
+			values[key] = v
+		case float64:
+			values[key] = strconv.FormatFloat(v, 'f', -1, 64)
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				s, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("config file %s: %s must be a list of strings", path, key)
+				}
+				items = append(items, s)
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			return nil, fmt.Errorf("config file %s: %s must be a string, number or list", path, key)
+		}
 	}
+	return values, nil
+}
+
+// validate reports every setting that can't work
+func (c Config) validate() []error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Port > 0 && c.Port < 65536, "port %d is not between 1 and 65535", c.Port)
+	if c.SupabaseURL == "" {
+		errs = append(errs, errors.New("supabase_url is required (SUPABASE_URL: Settings → API → Project URL)"))
+	} else {
+		check(isHTTPURL(c.SupabaseURL), "supabase_url %q is not an http(s) URL", c.SupabaseURL)
+	}
+	if c.SupabaseServiceKey == "" {
+		errs = append(errs, errors.New("supabase_service_key is required (SUPABASE_SERVICE_KEY: Settings → API → service_role key)"))
+	} else if role := jwtRole(c.SupabaseServiceKey); role != "" {
+		check(role == "service_role", "supabase_service_key has role %q; use the service_role key, not the anon key", role)
+	}
+	check(c.SupabaseTimeout > 0, "supabase_timeout %s is not positive", c.SupabaseTimeout)
+	check(c.SupabaseRetries >= 0 && c.SupabaseRetries <= maxSupabaseRetries,
+		"supabase_retries %d is not between 0 and %d", c.SupabaseRetries, maxSupabaseRetries)
+	check(c.SupabaseBreakerThreshold >= 0, "supabase_breaker_threshold %d is negative", c.SupabaseBreakerThreshold)
+	check(c.SupabaseBreakerCooldown > 0, "supabase_breaker_cooldown %s is not positive", c.SupabaseBreakerCooldown)
+	check(c.Notifier == "" || c.Notifier == "fcm" || c.Notifier == "log", "notifier %q is not fcm, log or empty", c.Notifier)
+	check(isHTTPURL(c.FCMEndpoint), "fcm_endpoint %q is not an http(s) URL", c.FCMEndpoint)
+	check(c.AttachmentsDir != "", "attachments_dir is empty")
+	check(c.CSVDir != "", "csv_dir is empty")
+
+	check(c.WindowDuration >= time.Minute, "window_duration %s is shorter than a minute", c.WindowDuration)
+	check(c.WindowReminderMinutes >= 0, "window_reminder_minutes %d is negative", c.WindowReminderMinutes)
+	check(time.Duration(c.WindowReminderMinutes)*time.Minute < c.WindowDuration,
+		"window_reminder_minutes %d is not shorter than window_duration %s", c.WindowReminderMinutes, c.WindowDuration)
+	check(c.DefaultThresholdMeters > 0, "default_threshold_meters %g is not positive", c.DefaultThresholdMeters)
+	check(c.StudentCacheTTL >= 0, "student_cache_ttl %s is negative", c.StudentCacheTTL)
+	check(c.GroupsCacheTTL >= 0, "groups_cache_ttl %s is negative", c.GroupsCacheTTL)
+
+	check(len(c.CORSOrigins) > 0, "cors_origins is empty")
+	for _, origin := range c.CORSOrigins {
+		check(origin == "*" || isOrigin(origin), "cors_origins: %q is not * or an origin like https://app.example.com", origin)
+	}
+
+	check(c.RateLimitPerSecond >= 0, "rate_limit_rps %d is negative", c.RateLimitPerSecond)
+	check(c.RateLimitBurst >= 0, "rate_limit_burst %d is negative", c.RateLimitBurst)
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.LogLevel)) == nil, "log_level %q is not debug, info, warn or error", c.LogLevel)
+	check(c.LogFormat == "text" || c.LogFormat == "json", "log_format %q is not text or json", c.LogFormat)
+	return errs
+}
+
+// jwtRole returns the role claim of a Supabase JWT key, or "" when the key
+// isn't a JWT (newer sb_secret_ keys aren't)
+func jwtRole(key string) string {
+	parts := strings.Split(key, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Role string `json:"role"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return ""
+	}
+	return claims.Role
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// isOrigin reports whether s is scheme://host[:port] with nothing after it
+func isOrigin(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && isHTTPURL(s) && u.Path == "" && u.RawQuery == "" && u.Fragment == ""
+}
+
+// publicSettings returns the effective non-secret settings by key
+func (c Config) publicSettings() map[string]interface{} {
+	settings := make(map[string]interface{})
+	for _, f := range c.fields() {
+		if f.secret {
+			continue
+		}
+		value := f.value.Interface()
+		if d, ok := value.(time.Duration); ok {
+			value = d.String()
+		}
+		settings[f.key] = value
+	}
+	return settings
+}
+
+// Handler: GET /api/admin/config
+func getConfigHandler(w http.ResponseWriter, r *http.Request) {
+	settings := config.publicSettings()
+	sources := make(map[string]string, len(settings))
+	for key := range settings {
+		sources[key] = config.sources[key]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"settings": settings,
+		"sources":  sources,
+	})
 }
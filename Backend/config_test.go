@@ -0,0 +1,150 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// loadTestConfig runs LoadConfig with only the given environment (the .env
+// file can't add to it) and, when file isn't empty, that config file
+func loadTestConfig(t *testing.T, env map[string]string, file string, args ...string) (Config, error) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	for _, f := range (&Config{}).fields() {
+		t.Setenv(f.env, env[f.env])
+	}
+	if file != "" {
+		path := filepath.Join(t.TempDir(), "config.json")
+		if err := os.WriteFile(path, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+		args = append([]string{"-config", path}, args...)
+	}
+	cfg, _, err := LoadConfig(args)
+	return cfg, err
+}
+
+// requiredEnv is the least environment that passes validation
+var requiredEnv = map[string]string{
+	"SUPABASE_URL":         "https://project.supabase.co",
+	"SUPABASE_SERVICE_KEY": "sb_secret_test",
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		env        string
+		args       []string
+		wantPort   int
+		wantSource string
+	}{
+		{name: "default", wantPort: 8080, wantSource: "default"},
+		{name: "file over default", file: `{"port": 8081}`, wantPort: 8081, wantSource: "file"},
+		{name: "env over file", file: `{"port": 8081}`, env: "8082", wantPort: 8082, wantSource: "env"},
+		{name: "flag over env", file: `{"port": 8081}`, env: "8082", args: []string{"-port", "8083"}, wantPort: 8083, wantSource: "flag"},
+		{name: "flag over default", args: []string{"-port", "8083"}, wantPort: 8083, wantSource: "flag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{"PORT": tt.env}
+			for key, value := range requiredEnv {
+				env[key] = value
+			}
+			cfg, err := loadTestConfig(t, env, tt.file, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Port != tt.wantPort || cfg.sources["port"] != tt.wantSource {
+				t.Errorf("port %d from %s, want %d from %s", cfg.Port, cfg.sources["port"], tt.wantPort, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestLoadConfigReportsEveryError(t *testing.T) {
+	_, err := loadTestConfig(t, map[string]string{
+		"PORT":             "eighty",
+		"WINDOW_DURATION":  "30s",
+		"LOG_FORMAT":       "xml",
+		"SUPABASE_RETRIES": "1000",
+		"CORS_ORIGINS":     "https://app.example.com/path",
+	}, "")
+	if err == nil {
+		t.Fatal("invalid configuration accepted")
+	}
+	for _, want := range []string{
+		`port "eighty" (from env): not an integer`,
+		"window_duration 30s is shorter than a minute",
+		`log_format "xml" is not text or json`,
+		"supabase_retries 1000 is not between 0 and 10",
+		`cors_origins: "https://app.example.com/path" is not * or an origin`,
+		"supabase_url is required",
+		"supabase_service_key is required",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error doesn't report %q:\n%v", want, err)
+		}
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	cfg, err := loadTestConfig(t, requiredEnv, `{
+		"cors_origins": ["https://a.example.com", "https://b.example.com"],
+		"default_threshold_meters": 75.5,
+		"window_duration": "15m"
+	}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"https://a.example.com", "https://b.example.com"}; !reflect.DeepEqual(cfg.CORSOrigins, want) {
+		t.Errorf("cors_origins %q, want %q", cfg.CORSOrigins, want)
+	}
+	if cfg.DefaultThresholdMeters != 75.5 || cfg.WindowDuration.String() != "15m0s" {
+		t.Errorf("threshold %g, window %s; want 75.5 and 15m0s", cfg.DefaultThresholdMeters, cfg.WindowDuration)
+	}
+
+	tests := []struct {
+		name, file, want string
+	}{
+		{"unknown key", `{"port": 8081, "windw_duration": "15m"}`, `unknown setting "windw_duration"`},
+		{"list of numbers", `{"cors_origins": [1, 2]}`, "cors_origins must be a list of strings"},
+		{"object", `{"port": {"value": 8081}}`, "port must be a string, number or list"},
+		{"not JSON", `port = 8081`, "invalid character"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadTestConfig(t, requiredEnv, tt.file)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigHandlerHidesSecrets(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config = defaultConfig()
+	config.SupabaseServiceKey = "service-key-value"
+	config.APISigningSecret = "signing-secret-value"
+
+	rec := httptest.NewRecorder()
+	getConfigHandler(rec, httptest.NewRequest("GET", "/api/admin/config", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, `"port"`) {
+		t.Fatalf("settings missing from %s", body)
+	}
+	for _, f := range config.fields() {
+		if !f.secret {
+			continue
+		}
+		if strings.Contains(body, `"`+f.key+`"`) || strings.Contains(body, f.value.String()) {
+			t.Errorf("secret %s is shown: %s", f.key, body)
+		}
+	}
+}
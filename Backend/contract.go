@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// runCommand runs a tooling subcommand instead of the server and returns
+// the exit code:
+//
+//	openapi  print the OpenAPI document built from the routes
+//
+// The contract tests (TestContract) check the committed document and the
+// generated client against it.
+func runCommand(name string) int {
+	switch name {
+	case "openapi":
+		offlineConfig()
+		spec, problems := buildOpenAPI(newRouter())
+		os.Stdout.Write(marshalOpenAPI(spec))
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "openapi: %s\n", problem)
+		}
+		if len(problems) > 0 {
+			return 1
+		}
+		return 0
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q (want openapi)\n", name)
+	return 2
+}
+
+// offlineConfig points the handlers at a database that refuses connections,
+// turns off signing and rate limiting and keeps CSV files out of the tree,
+// so tooling never touches real data
+func offlineConfig() {
+	config.SupabaseURL = "http://127.0.0.1:1"
+	config.SupabaseServiceKey = "offline"
+	config.APISigningSecret = ""
+	config.RateLimitPerSecond = 0
+	config.CSVDir = os.TempDir()
+	submittedStudents = make(map[string]bool)
+	studentLocations = make(map[string]StudentLocation)
+}
@@ -0,0 +1,660 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"attendance-system/openapigen"
+)
+
+const contractProbeID = "contract-probe"
+
+// TestContract checks that openapi.json and the generated client are
+// current, and probes every operation against a fake PostgREST whose
+// fixtures let it succeed: each probe must answer a documented 2xx whose
+// body matches the spec. Operations with required inputs are also probed
+// with them missing, which must answer a documented error.
+func TestContract(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config = defaultConfig()
+	offlineConfig()
+	config.CSVDir = t.TempDir()
+	config.AttachmentsDir = t.TempDir()
+	useSupabase(t, newContractPostgREST())
+	t.Cleanup(func() { groupManager.DeleteGroup(contractProbeID) })
+
+	router := newRouter()
+	spec, problems := buildOpenAPI(router)
+	raw := marshalOpenAPI(spec)
+
+	if committed, err := os.ReadFile("openapi.json"); err != nil || !bytes.Equal(committed, raw) {
+		problems = append(problems, "openapi.json is out of date; run go run . openapi > openapi.json")
+	}
+	if code, err := openapigen.Generate(raw); err != nil {
+		problems = append(problems, "client generation failed: "+err.Error())
+	} else if current, err := os.ReadFile("client/client_gen.go"); err != nil || !bytes.Equal(current, code) {
+		problems = append(problems, "client/client_gen.go is out of date; run go run ./genclient")
+	}
+
+	var doc map[string]interface{}
+	json.Unmarshal(raw, &doc)
+	v := specValidator{doc: doc}
+
+	// Keep the report readable; handlers log every rejected probe
+	logger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer slog.SetDefault(logger)
+	validated := 0
+	operationIDs := map[string]string{}
+	paths, _ := doc["paths"].(map[string]interface{})
+	for _, path := range sortedKeys(paths) {
+		item, _ := paths[path].(map[string]interface{})
+		for _, method := range sortedKeys(item) {
+			op, _ := item[method].(map[string]interface{})
+			key := strings.ToUpper(method) + " " + path
+			if id, _ := op["operationId"].(string); operationIDs[id] != "" {
+				problems = append(problems, fmt.Sprintf("%s: operationId %s already used by %s", key, id, operationIDs[id]))
+			} else {
+				operationIDs[id] = key
+			}
+
+			resetContractState(t)
+			rec := probe(router, probeRequest(strings.ToUpper(method), path, op, true))
+			if rec.Code < 200 || rec.Code > 299 {
+				problems = append(problems, fmt.Sprintf("%s: probe answered %d, want a 2xx to validate: %s", key, rec.Code, strings.TrimSpace(rec.Body.String())))
+			} else if found := v.checkResponse(op, rec); len(found) > 0 {
+				for _, problem := range found {
+					problems = append(problems, key+": "+problem)
+				}
+			} else {
+				validated++
+			}
+
+			if hasRequiredInput(op) {
+				resetContractState(t)
+				rec := probe(router, probeRequest(strings.ToUpper(method), path, op, false))
+				if rec.Code < 400 {
+					problems = append(problems, fmt.Sprintf("%s: probe without its required inputs answered %d", key, rec.Code))
+				}
+				for _, problem := range v.checkResponse(op, rec) {
+					problems = append(problems, key+" (inputs missing): "+problem)
+				}
+			}
+		}
+	}
+
+	for _, problem := range problems {
+		t.Error(problem)
+	}
+	t.Logf("%d operations, %d success responses validated, %d problems", len(operationIDs), validated, len(problems))
+}
+
+// probe dispatches req; event streams are cut off once they have started
+func probe(router *Router, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	if req.Header.Get("Accept") == "text/event-stream" {
+		ctx, cancel := context.WithTimeout(req.Context(), 50*time.Millisecond)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+	router.dispatch(rec, req)
+	return rec
+}
+
+// probeValues are the inputs a probe sends, by name; inputs not listed get
+// contractProbeID (strings) or 1 (numbers). Every ID in the fixtures is
+// contractProbeID, so lookups by ID find them.
+var probeValues = map[string]interface{}{
+	"lat":          12.9716,
+	"lon":          77.5946,
+	"user_type":    "student",
+	"title":        "Contract probe",
+	"message":      "Probe message",
+	"body":         "Probe body",
+	"student_name": "Probe Student",
+	"opens_at":     "2099-01-01T09:00:00Z",
+	"send_at":      "2099-01-01T09:00:00Z",
+	"send_to_all":  true,
+}
+
+// probeOptional are optional inputs a probe fills in anyway, because the
+// operation needs one of several (title and message, or a template)
+var probeOptional = map[string]bool{
+	"group_id": true,
+	"title":    true,
+	"message":  true,
+	"body":     true,
+	"send_at":  true,
+	"opens_at": true,
+}
+
+// probeExtra adds inputs to one operation's probe
+var probeExtra = map[string]map[string]interface{}{
+	"GET /api/download-attachment":              {"admin_id": contractProbeID},
+	"GET /api/v1/attachments/{attachment_id}":   {"admin_id": contractProbeID},
+	"GET /api/get-broadcast-status":             {"message_id": contractProbeID},
+	"GET /api/get-thread":                       {"admin_id": contractProbeID},
+	"GET /api/v1/threads/{thread_id}":           {"admin_id": contractProbeID},
+	"POST /api/reply-thread":                    {"admin_id": contractProbeID},
+	"POST /api/v1/threads/{thread_id}/messages": {"admin_id": contractProbeID},
+	"POST /api/mark-thread-read":                {"admin_id": contractProbeID},
+	"POST /api/v1/threads/{thread_id}/read":     {"admin_id": contractProbeID},
+	"POST /api/delete-thread":                   {"admin_id": contractProbeID},
+	"DELETE /api/delete-thread":                 {"admin_id": contractProbeID},
+	"DELETE /api/v1/threads/{thread_id}":        {"admin_id": contractProbeID},
+	"GET /api/events":                           {"admin_id": contractProbeID},
+	"GET /api/v1/events":                        {"admin_id": contractProbeID},
+	"POST /api/add-student":                     {"student_id": "new-student"},
+	"POST /api/v1/students":                     {"student_id": "new-student"},
+}
+
+// probeValue is what a probe sends for an input
+func probeValue(name string, schema map[string]interface{}) interface{} {
+	if value, ok := probeValues[name]; ok {
+		return value
+	}
+	switch schema["type"] {
+	case "integer", "number":
+		return 1
+	case "boolean":
+		return false
+	case "array":
+		return []string{contractProbeID}
+	}
+	return contractProbeID
+}
+
+// hasRequiredInput reports whether an operation has a required input that
+// isn't carried in its path
+func hasRequiredInput(op map[string]interface{}) bool {
+	params, _ := op["parameters"].([]interface{})
+	for _, p := range params {
+		param, _ := p.(map[string]interface{})
+		if param["in"] != "path" && param["required"] == true {
+			return true
+		}
+	}
+	requestBody, _ := op["requestBody"].(map[string]interface{})
+	return requestBody["required"] == true
+}
+
+// probeRequest builds the request for an operation: with complete inputs,
+// all required ones plus those probeOptional and probeExtra name, or with
+// only its path parameters
+func probeRequest(method, path string, op map[string]interface{}, complete bool) *http.Request {
+	key := method + " " + path
+	include := func(name string, required bool) bool {
+		return complete && (required || probeOptional[name])
+	}
+
+	query := url.Values{}
+	params, _ := op["parameters"].([]interface{})
+	for _, p := range params {
+		param, _ := p.(map[string]interface{})
+		name, _ := param["name"].(string)
+		schema, _ := param["schema"].(map[string]interface{})
+		if param["in"] == "query" && include(name, param["required"] == true) {
+			query.Set(name, formValue(jsonValue(probeValue(name, schema))))
+		}
+	}
+
+	fields := map[string]interface{}{}
+	contentType := ""
+	if requestBody, ok := op["requestBody"].(map[string]interface{}); ok {
+		content, _ := requestBody["content"].(map[string]interface{})
+		for mediaType, media := range content {
+			contentType = mediaType
+			schema, _ := media.(map[string]interface{})["schema"].(map[string]interface{})
+			properties, _ := schema["properties"].(map[string]interface{})
+			required := map[string]bool{}
+			names, _ := schema["required"].([]interface{})
+			for _, name := range names {
+				required[name.(string)] = true
+			}
+			for name, prop := range properties {
+				propSchema, _ := prop.(map[string]interface{})
+				if include(name, required[name]) {
+					fields[name] = probeValue(name, propSchema)
+				}
+			}
+		}
+	}
+	if complete {
+		for name, value := range probeExtra[key] {
+			if method == http.MethodGet || method == http.MethodDelete || contentType == "" {
+				query.Set(name, formValue(jsonValue(value)))
+			} else {
+				fields[name] = value
+			}
+		}
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") {
+			segments[i] = contractProbeID
+		}
+	}
+	target := strings.Join(segments, "/")
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var body io.Reader = http.NoBody
+	switch contentType {
+	case "application/json":
+		raw, _ := json.Marshal(fields)
+		body = bytes.NewReader(raw)
+	case "application/x-www-form-urlencoded":
+		form := url.Values{}
+		for name, value := range fields {
+			form.Set(name, formValue(jsonValue(value)))
+		}
+		body = strings.NewReader(form.Encode())
+	case "multipart/form-data":
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for name, value := range fields {
+			if name == "file" {
+				part, _ := mw.CreateFormFile("file", "probe.txt")
+				part.Write([]byte("probe"))
+				continue
+			}
+			mw.WriteField(name, formValue(jsonValue(value)))
+		}
+		mw.Close()
+		body = &buf
+		contentType = mw.FormDataContentType()
+	}
+
+	req := httptest.NewRequest(method, target, body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for _, response := range responsesOf(op) {
+		if mediaTypeOf(response) == "text/event-stream" {
+			req.Header.Set("Accept", "text/event-stream")
+		}
+	}
+	return req
+}
+
+// jsonValue is value as encoding/json would decode it, for formValue
+func jsonValue(value interface{}) interface{} {
+	raw, _ := json.Marshal(value)
+	var decoded interface{}
+	json.Unmarshal(raw, &decoded)
+	return decoded
+}
+
+// resetContractState gives every probe the same starting point: the probe
+// group with a location and a fresh open window nobody has submitted to,
+// and the fixture attachment's blob, which deleting a message removes
+func resetContractState(t *testing.T) {
+	blob := blobPath(contractStorageKey)
+	if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(blob, []byte("probe attachment"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	groupManager.DeleteGroup(contractProbeID)
+	groupManager.mu.Lock()
+	groupManager.groups[contractProbeID] = &GroupData{
+		ID:                contractProbeID,
+		Name:              "Probe Group",
+		AdminID:           contractProbeID,
+		AdminLat:          12.9716,
+		AdminLon:          77.5946,
+		ThresholdMeters:   100,
+		WindowActive:      true,
+		WindowStartTime:   now,
+		WindowEndTime:     now.Add(config.WindowDuration),
+		SubmittedStudents: make(map[string]bool),
+		StudentLocations:  make(map[string]StudentLocation),
+	}
+	groupManager.mu.Unlock()
+}
+
+func responsesOf(op map[string]interface{}) map[string]interface{} {
+	responses, _ := op["responses"].(map[string]interface{})
+	return responses
+}
+
+// mediaTypeOf returns a response's only (or first) media type
+func mediaTypeOf(response interface{}) string {
+	content, _ := response.(map[string]interface{})["content"].(map[string]interface{})
+	keys := sortedKeys(content)
+	if len(keys) == 0 {
+		return ""
+	}
+	return keys[0]
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// specValidator checks JSON values against the schemas of the spec
+type specValidator struct {
+	doc map[string]interface{}
+}
+
+// checkResponse finds the documented response for the recorded status and
+// validates the body against it
+func (v specValidator) checkResponse(op map[string]interface{}, rec *httptest.ResponseRecorder) []string {
+	status := fmt.Sprint(rec.Code)
+	response, ok := responsesOf(op)[status]
+	if !ok {
+		if rec.Code < 400 {
+			return []string{"undocumented status " + status}
+		}
+		if response, ok = responsesOf(op)["default"]; !ok {
+			return []string{"undocumented status " + status}
+		}
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	content, _ := response.(map[string]interface{})["content"].(map[string]interface{})
+	media, ok := content[mediaType].(map[string]interface{})
+	if !ok {
+		media, ok = content["*/*"].(map[string]interface{})
+	}
+	if !ok {
+		return []string{fmt.Sprintf("status %s answered with undocumented content type %q", status, mediaType)}
+	}
+	if mediaType != "application/json" {
+		return nil
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		return []string{fmt.Sprintf("status %s body is not JSON: %v", status, err)}
+	}
+	schema, _ := media["schema"].(map[string]interface{})
+	var problems []string
+	for _, problem := range v.validate(schema, body, "body") {
+		problems = append(problems, fmt.Sprintf("status %s: %s", status, problem))
+	}
+	return problems
+}
+
+// validate implements the JSON Schema subset buildOpenAPI emits: $ref,
+// allOf, nullable, type, enum, properties, required and items
+func (v specValidator) validate(schema map[string]interface{}, value interface{}, at string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		components, _ := v.doc["components"].(map[string]interface{})
+		schemas, _ := components["schemas"].(map[string]interface{})
+		target, ok := schemas[strings.TrimPrefix(ref, "#/components/schemas/")].(map[string]interface{})
+		if !ok {
+			return []string{at + ": unresolved " + ref}
+		}
+		return v.validate(target, value, at)
+	}
+	if value == nil {
+		if schema["nullable"] == true || schema["type"] == nil && schema["allOf"] == nil {
+			return nil
+		}
+		return []string{at + ": null is not allowed"}
+	}
+	if allOf, ok := schema["allOf"].([]interface{}); ok {
+		var problems []string
+		for _, sub := range allOf {
+			subSchema, _ := sub.(map[string]interface{})
+			problems = append(problems, v.validate(subSchema, value, at)...)
+		}
+		return problems
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{at + ": want object"}
+		}
+		var problems []string
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := object[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing %q", at, name))
+			}
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for _, name := range sortedKeys(properties) {
+			if field, ok := object[name]; ok {
+				propSchema, _ := properties[name].(map[string]interface{})
+				problems = append(problems, v.validate(propSchema, field, at+"."+name)...)
+			}
+		}
+		return problems
+	case "array":
+		array, ok := value.([]interface{})
+		if !ok {
+			return []string{at + ": want array"}
+		}
+		items, _ := schema["items"].(map[string]interface{})
+		var problems []string
+		for i, item := range array {
+			problems = append(problems, v.validate(items, item, fmt.Sprintf("%s[%d]", at, i))...)
+		}
+		return problems
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return []string{at + ": want string"}
+		}
+		if enum, ok := schema["enum"].([]interface{}); ok {
+			for _, allowed := range enum {
+				if allowed == str {
+					return nil
+				}
+			}
+			return []string{fmt.Sprintf("%s: %q is not one of %v", at, str, enum)}
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+			return []string{at + ": want integer"}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{at + ": want number"}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{at + ": want boolean"}
+		}
+	}
+	return nil
+}
+
+// contractStorageKey names the fixture attachment's blob
+const contractStorageKey = "c0ffee00c0ffee00c0ffee00c0ffee00"
+
+// contractPostgREST answers every table the handlers read with one fixture
+// row whose IDs are all contractProbeID. Embedded resources are part of the
+// fixtures, so select lists aren't interpreted; eq/in filters on a fixture
+// column are, so lookups for something else find nothing. Writes succeed
+// and echo what was written.
+type contractPostgREST struct {
+	tables map[string][]map[string]interface{}
+}
+
+func newContractPostgREST() *contractPostgREST {
+	const (
+		past   = "2026-01-01T09:00:00Z"
+		future = "2099-01-01T09:00:00Z"
+	)
+	id := contractProbeID
+	student := map[string]interface{}{"id": id, "student_id": id, "student_name": "Probe Student"}
+	group := map[string]interface{}{
+		"id": id, "name": "Probe Group", "admin_id": id, "session_name": "Probe Session", "status": "active",
+		"location_lat": 12.9716, "location_lon": 77.5946, "threshold_meters": 100.0, "group_only": false,
+		"window_start_time": nil, "window_end_time": nil, "created_at": past,
+	}
+	message := map[string]interface{}{
+		"id": id, "admin_id": id, "group_id": id, "title": "Probe", "message": "Probe message",
+		"sent_to_all": true, "priority": PriorityNormal, "expires_at": future, "created_at": past,
+		"edited_at": nil, "recalled_at": nil, "personalized": false, "template_group_id": nil,
+		"idempotency_key": nil, "audience": nil, "admins": map[string]interface{}{"username": "probe"},
+		"message_attachments": []interface{}{map[string]interface{}{
+			"id": id, "file_name": "probe.txt", "content_type": "text/plain; charset=utf-8", "size_bytes": 16,
+		}},
+	}
+
+	return &contractPostgREST{tables: map[string][]map[string]interface{}{
+		"admins":   {{"id": id, "username": id, "password": id, "created_at": past}},
+		"students": {{"id": id, "student_id": id, "student_name": "Probe Student", "created_at": past}},
+		"groups":   {group},
+		"group_students": {{
+			"id": id, "group_id": id, "student_id": id, "students": student,
+			"groups": map[string]interface{}{"id": id, "name": "Probe Group", "admin_id": id, "status": "active"},
+		}},
+		"group_attendance": {{
+			"id": id, "group_id": id, "student_id": id, "status": "Present", "distance": 12.0,
+			"latitude": 12.9716, "longitude": 77.5946, "submitted_at": past, "students": student,
+			"groups": map[string]interface{}{"name": "Probe Group", "session_name": "Probe Session"},
+		}},
+		"fcm_tokens":         {{"id": id, "user_id": id, "user_type": "student", "fcm_token": id}},
+		"broadcast_messages": {message},
+		"broadcast_jobs": {{
+			"id": id, "message_id": id, "status": JobCompleted, "total": 1, "sent": 1, "failed": 0,
+			"attempts": 1, "error": nil, "next_attempt_at": nil, "notified_at": past,
+			"created_at": past, "updated_at": past, "completed_at": past,
+			"broadcast_messages": message,
+		}},
+		"message_recipients": {{
+			"id": id, "message_id": id, "student_id": id, "is_read": false, "read_at": nil,
+			"priority_rank": 2, "created_at": past, "rendered_title": nil, "rendered_message": nil,
+			"students": student, "broadcast_messages": message,
+			"delivered": []interface{}{map[string]interface{}{"count": 1}},
+			"read":      []interface{}{map[string]interface{}{"count": 0}},
+		}},
+		"message_attachments": {{
+			"id": id, "admin_id": id, "message_id": id, "file_name": "probe.txt",
+			"content_type": "text/plain; charset=utf-8", "size_bytes": 16, "storage_key": contractStorageKey,
+			"sha256": id, "created_at": past, "broadcast_messages": message,
+		}},
+		"message_edits": {{
+			"id": id, "message_id": id, "admin_id": id, "previous_title": "Probe", "previous_message": "Earlier",
+			"edited_at": past,
+		}},
+		"message_templates": {{
+			"id": id, "admin_id": id, "name": "Probe Template", "title": "Hello {{name}}",
+			"body": "Hi {{name}}", "shared": false, "created_at": past, "updated_at": past,
+		}},
+		"message_threads": {{
+			"id": id, "student_id": id, "admin_id": id, "group_id": id, "broadcast_message_id": id,
+			"subject": "Probe", "last_message_at": past, "student_deleted": false, "admin_deleted": false,
+			"created_at": past, "students": student,
+		}},
+		"thread_messages": {{
+			"id": id, "thread_id": id, "sender_type": SenderAdmin, "sender_id": id, "body": "Probe body",
+			"is_read": false, "created_at": past, "message_threads": map[string]interface{}{"id": id},
+		}},
+		"scheduled_messages": {{
+			"id": id, "admin_id": id, "group_id": id, "title": "Probe", "message": "Probe message",
+			"send_to_all": true, "priority": PriorityNormal, "send_at": future, "status": ScheduledPending,
+			"audience": nil, "created_at": past,
+		}},
+		"scheduled_windows": {{
+			"id": id, "admin_id": id, "group_id": id, "group_only": false, "opens_at": future,
+			"recurrence": nil, "status": ScheduledPending, "open_count": 0, "last_opened_at": nil,
+			"created_at": past, "groups": map[string]interface{}{"name": "Probe Group"},
+		}},
+	}}
+}
+
+func (f *contractPostgREST) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rows, ok := f.tables[strings.TrimPrefix(r.URL.Path, "/rest/v1/")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		matched := f.match(rows, r.URL.Query())
+		if strings.Contains(r.Header.Get("Prefer"), "count=exact") {
+			w.Header().Set("Content-Range", fmt.Sprintf("0-%d/%d", len(matched)-1, len(matched)))
+		}
+		json.NewEncoder(w).Encode(matched)
+	case http.MethodPost:
+		var written []map[string]interface{}
+		raw, _ := io.ReadAll(r.Body)
+		if json.Unmarshal(raw, &written) != nil {
+			var row map[string]interface{}
+			json.Unmarshal(raw, &row)
+			written = append(written, row)
+		}
+		for _, row := range written {
+			if row["id"] == nil {
+				row["id"] = contractProbeID
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(written)
+	case http.MethodPatch, http.MethodDelete:
+		if !strings.Contains(r.Header.Get("Prefer"), "return=representation") {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		json.NewEncoder(w).Encode(f.match(rows, r.URL.Query()))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// match keeps the rows whose columns pass the query's eq and in filters;
+// filters on other columns or with other operators are ignored
+func (f *contractPostgREST) match(rows []map[string]interface{}, query url.Values) []map[string]interface{} {
+	matched := []map[string]interface{}{}
+rows:
+	for _, row := range rows {
+		for column, filters := range query {
+			value, ok := row[column]
+			if !ok {
+				continue
+			}
+			for _, filter := range filters {
+				op, operand, _ := strings.Cut(filter, ".")
+				switch op {
+				case "eq":
+					if fmt.Sprint(value) != operand {
+						continue rows
+					}
+				case "in":
+					if !slices.Contains(strings.Split(strings.NewReplacer("(", "", ")", "", `"`, "").Replace(operand), ","), fmt.Sprint(value)) {
+						continue rows
+					}
+				}
+			}
+		}
+		matched = append(matched, row)
+	}
+	return matched
+}
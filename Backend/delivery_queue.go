@@ -0,0 +1,742 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"attendance-system/apierror"
+)
+
+// Broadcast job states (broadcast_jobs.status)
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+const (
+	deliveryWorkers     = 4
+	deliveryQueueSize   = 1000
+	recipientChunkSize  = 500
+	deliveryMaxAttempts = 5
+	deliveryBaseBackoff = 500 * time.Millisecond
+	deliveryMaxBackoff  = 30 * time.Second
+	jobSweepInterval    = time.Minute
+
+	// A job whose retries all fail is run again later, up to jobMaxAttempts
+	// times in all, waiting jobRetryDelay doubled per attempt in between
+	jobMaxAttempts   = 6
+	jobRetryDelay    = time.Minute
+	jobRetryMaxDelay = time.Hour
+)
+
+var errNoRecipients = errors.New("no students found")
+
+// BroadcastRequest is a validated broadcast ready to be queued
+type BroadcastRequest struct {
+	AdminID        string
+	Title          string
+	Message        string
+	Audience       BroadcastAudience
+	IdempotencyKey string
+
+	Priority      string     // normal, urgent or pinned
+	ExpiresAt     *time.Time // Hidden from students after this time
+	AttachmentIDs []string   // Uploaded via /api/upload-attachment
+
+	// Group used for {group} and {end_time}; defaults to the audience's group
+	TemplateGroupID string
+}
+
+// BroadcastJob tracks recipient fan-out for one broadcast message
+type BroadcastJob struct {
+	ID                  string            `json:"id"`
+	MessageID           string            `json:"message_id"`
+	Status              string            `json:"status"`
+	Audience            BroadcastAudience `json:"audience"`
+	TotalRecipients     int               `json:"total_recipients"`
+	DeliveredRecipients int               `json:"delivered_recipients"`
+	Attempts            int               `json:"attempts"`
+	LastError           string            `json:"last_error,omitempty"`
+	NextAttemptAt       string            `json:"next_attempt_at,omitempty"`
+	NotifiedAt          string            `json:"notified_at,omitempty"`
+	CreatedAt           string            `json:"created_at,omitempty"`
+	CompletedAt         string            `json:"completed_at,omitempty"`
+
+	// Message content, used for push notifications once recipients exist
+	Title   string `json:"-"`
+	Message string `json:"-"`
+
+	// Personalized messages contain placeholders rendered per recipient
+	Personalized    bool   `json:"-"`
+	TemplateGroupID string `json:"-"`
+}
+
+// DeliveryQueue runs broadcast jobs on a pool of worker goroutines.
+// Jobs are persisted in broadcast_jobs, so unfinished work is picked up
+// again after a restart.
+type DeliveryQueue struct {
+	jobs     chan *BroadcastJob
+	sweep    chan struct{}   // Runs the sweep now rather than at the next interval
+	inFlight map[string]bool // job_id -> queued or running in this process
+	mu       sync.Mutex
+	running  sync.WaitGroup // Workers, for Wait; each exits after its current job
+}
+
+var deliveryQueue = &DeliveryQueue{
+	jobs:     make(chan *BroadcastJob, deliveryQueueSize),
+	sweep:    make(chan struct{}, 1),
+	inFlight: make(map[string]bool),
+}
+
+// Start launches the workers and the sweep that re-queues persisted jobs.
+// Both stop taking work once ctx is done; jobs left in the channel stay
+// queued in the database for the next start.
+func (q *DeliveryQueue) Start(ctx context.Context) {
+	q.running.Add(deliveryWorkers)
+	for i := 0; i < deliveryWorkers; i++ {
+		go q.worker(ctx)
+	}
+	go func() {
+		for {
+			q.recover()
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.sweep:
+			case <-time.After(jobSweepInterval):
+			}
+		}
+	}()
+}
+
+// Wait blocks until the workers have stopped or ctx is done. Workers stop
+// after their current job once the context passed to Start is done.
+func (q *DeliveryQueue) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		q.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Sweep asks for persisted jobs to be re-queued now, e.g. one just requeued
+// in the database
+func (q *DeliveryQueue) Sweep() {
+	select {
+	case q.sweep <- struct{}{}:
+	default:
+	}
+}
+
+// Enqueue hands a job to the workers unless it is already in flight.
+// If the queue is full the job stays queued in the database for the next sweep.
+func (q *DeliveryQueue) Enqueue(job *BroadcastJob) {
+	q.mu.Lock()
+	if q.inFlight[job.ID] {
+		q.mu.Unlock()
+		return
+	}
+	q.inFlight[job.ID] = true
+	q.mu.Unlock()
+
+	select {
+	case q.jobs <- job:
+	default:
+		q.mu.Lock()
+		delete(q.inFlight, job.ID)
+		q.mu.Unlock()
+		slog.Warn("delivery queue full, job will be picked up by the next sweep", "job_id", job.ID)
+	}
+}
+
+func (q *DeliveryQueue) worker(ctx context.Context) {
+	defer q.running.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-q.jobs:
+			// select picks at random when both are ready; a job dequeued
+			// after shutdown began stays queued and resumes on the next start
+			if ctx.Err() == nil {
+				q.process(ctx, job)
+			}
+			q.mu.Lock()
+			delete(q.inFlight, job.ID)
+			q.mu.Unlock()
+		}
+	}
+}
+
+// recover re-queues jobs left queued or running, e.g. by a crash or restart,
+// and failed runs whose retry is due
+func (q *DeliveryQueue) recover() {
+	now := time.Now().UTC().Format(time.RFC3339)
+	jobsURL := from("broadcast_jobs").In("status", []string{JobQueued, JobRunning}).
+		Or(whereIs("next_attempt_at", IsNull), where("next_attempt_at", OpLte, now)).
+		Select("*,broadcast_messages(title,message,personalized,template_group_id)").Order("created_at.asc").URL()
+	req, err := newSupabaseRequest(context.Background(), "GET", jobsURL, nil)
+	if err != nil {
+		return
+	}
+	resp, err := supabaseClient.Do(req)
+	if err != nil {
+		slog.Error("failed to load unfinished broadcast jobs", "error", err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		slog.Error("failed to load unfinished broadcast jobs", "status", resp.StatusCode, "body", string(bodyBytes))
+		return
+	}
+
+	var rows []struct {
+		BroadcastJob
+		BroadcastMessages struct {
+			Title           string  `json:"title"`
+			Message         string  `json:"message"`
+			Personalized    bool    `json:"personalized"`
+			TemplateGroupID *string `json:"template_group_id"`
+		} `json:"broadcast_messages"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rows); err != nil {
+		slog.Error("failed to parse unfinished broadcast jobs", "error", err)
+		return
+	}
+
+	for i := range rows {
+		job := rows[i].BroadcastJob
+		job.Title = rows[i].BroadcastMessages.Title
+		job.Message = rows[i].BroadcastMessages.Message
+		job.Personalized = rows[i].BroadcastMessages.Personalized
+		if groupID := rows[i].BroadcastMessages.TemplateGroupID; groupID != nil {
+			job.TemplateGroupID = *groupID
+		}
+		q.Enqueue(&job)
+	}
+}
+
+// process resolves the audience, inserts recipients in chunks and sends pushes.
+// Recipient inserts ignore duplicates, so re-running a job is safe; pushes
+// are recorded before the job completes and not sent again by a re-run.
+// When ctx is done the job stops between retries and is left for the next
+// start.
+func (q *DeliveryQueue) process(ctx context.Context, job *BroadcastJob) {
+	job.Attempts++
+	job.Status = JobRunning
+	updateBroadcastJob(context.Background(), job.ID, map[string]interface{}{
+		"status":   JobRunning,
+		"attempts": job.Attempts,
+	})
+
+	var studentIDs, studentUUIDs []string
+	err := withRetry(ctx, "resolve recipients", func() error {
+		var err error
+		studentIDs, studentUUIDs, err = resolveBroadcastRecipients(context.Background(), job.Audience)
+		return err
+	})
+	if err == nil && len(studentUUIDs) == 0 {
+		err = errNoRecipients
+	}
+	if err != nil {
+		q.fail(ctx, job, err)
+		return
+	}
+
+	var renderer *templateRenderer
+	if job.Personalized {
+		err := withRetry(ctx, "load template values", func() error {
+			var err error
+			renderer, err = newTemplateRenderer(context.Background(), job.Title, job.Message, job.TemplateGroupID)
+			return err
+		})
+		if err != nil {
+			q.fail(ctx, job, err)
+			return
+		}
+	}
+
+	job.TotalRecipients = len(studentUUIDs)
+	job.DeliveredRecipients = 0
+	broadcastRecipients.Observe(float64(job.TotalRecipients))
+	updateBroadcastJob(context.Background(), job.ID, map[string]interface{}{
+		"total_recipients":     job.TotalRecipients,
+		"delivered_recipients": 0,
+	})
+
+	for start := 0; start < len(studentUUIDs); start += recipientChunkSize {
+		end := start + recipientChunkSize
+		if end > len(studentUUIDs) {
+			end = len(studentUUIDs)
+		}
+		chunk := studentUUIDs[start:end]
+
+		var rendered map[string]RenderedMessage
+		if renderer != nil {
+			err := withRetry(ctx, "render templates", func() error {
+				return renderer.loadStudents(context.Background(), chunk)
+			})
+			if err != nil {
+				q.fail(ctx, job, err)
+				return
+			}
+			rendered = make(map[string]RenderedMessage, len(chunk))
+			for _, studentUUID := range chunk {
+				rendered[studentUUID] = renderer.Render(studentUUID)
+			}
+		}
+
+		err := withRetry(ctx, "insert recipients", func() error {
+			return insertRecipients(context.Background(), job.MessageID, chunk, rendered)
+		})
+		if err != nil {
+			q.fail(ctx, job, err)
+			return
+		}
+
+		job.DeliveredRecipients = end
+		updateBroadcastJob(context.Background(), job.ID, map[string]interface{}{
+			"delivered_recipients": job.DeliveredRecipients,
+		})
+	}
+
+	// A run that stopped after pushing has already notified everyone
+	if job.NotifiedAt == "" {
+		batches := recipientBatches(studentIDs, studentUUIDs, func(i int) RenderedMessage {
+			if renderer != nil {
+				return renderer.Render(studentUUIDs[i])
+			}
+			return RenderedMessage{Title: job.Title, Message: job.Message}
+		})
+		for _, batch := range batches {
+			publishBroadcastMessage(job.MessageID, batch.content.Title, batch.content.Message, batch.studentIDs)
+			notifyStudents(context.Background(), batch.studentUUIDs, Notification{
+				Title: batch.content.Title,
+				Body:  batch.content.Message,
+				Data: map[string]string{
+					"type":       "broadcast_message",
+					"message_id": job.MessageID,
+					"title":      batch.content.Title,
+					"message":    batch.content.Message,
+				},
+			})
+		}
+		job.NotifiedAt = time.Now().UTC().Format(time.RFC3339)
+		updateBroadcastJob(context.Background(), job.ID, map[string]interface{}{
+			"notified_at": job.NotifiedAt,
+		})
+	}
+
+	job.Status = JobCompleted
+	updateBroadcastJob(context.Background(), job.ID, map[string]interface{}{
+		"status":          JobCompleted,
+		"last_error":      nil,
+		"next_attempt_at": nil,
+		"completed_at":    time.Now().UTC().Format(time.RFC3339),
+	})
+	slog.Info("broadcast job completed", "job_id", job.ID, "message_id", job.MessageID, "recipients", job.TotalRecipients)
+}
+
+// recipientBatch is the recipients of a message who see the same text
+type recipientBatch struct {
+	content      RenderedMessage
+	studentIDs   []string
+	studentUUIDs []string
+}
+
+// recipientBatches groups recipients by the text content(i) gives the i-th
+// one, in first-seen order, so each group shares one event and push batch
+func recipientBatches(studentIDs, studentUUIDs []string, content func(i int) RenderedMessage) []*recipientBatch {
+	byContent := make(map[RenderedMessage]*recipientBatch)
+	var batches []*recipientBatch
+	for i := range studentUUIDs {
+		c := content(i)
+		batch, exists := byContent[c]
+		if !exists {
+			batch = &recipientBatch{content: c}
+			byContent[c] = batch
+			batches = append(batches, batch)
+		}
+		batch.studentIDs = append(batch.studentIDs, studentIDs[i])
+		batch.studentUUIDs = append(batch.studentUUIDs, studentUUIDs[i])
+	}
+	return batches
+}
+
+// fail records a failed run. The job is queued again after a delay unless
+// it has used up its attempts or has no one to deliver to; a run cut short
+// by shutdown is left as it is and resumes on the next start.
+func (q *DeliveryQueue) fail(ctx context.Context, job *BroadcastJob, err error) {
+	if ctx.Err() != nil {
+		slog.Info("broadcast job interrupted by shutdown; it resumes on the next start", "job_id", job.ID)
+		return
+	}
+
+	job.LastError = err.Error()
+	if job.Attempts >= jobMaxAttempts || errors.Is(err, errNoRecipients) {
+		job.Status = JobFailed
+		job.NextAttemptAt = ""
+		updateBroadcastJob(context.Background(), job.ID, map[string]interface{}{
+			"status":          JobFailed,
+			"last_error":      job.LastError,
+			"next_attempt_at": nil,
+		})
+		slog.Error("broadcast job failed", "job_id", job.ID, "attempts", job.Attempts, "delivered", job.DeliveredRecipients, "error", err)
+		return
+	}
+
+	delay := jobRetryDelay << (job.Attempts - 1)
+	if delay <= 0 || delay > jobRetryMaxDelay {
+		delay = jobRetryMaxDelay
+	}
+	job.Status = JobQueued
+	job.NextAttemptAt = time.Now().Add(delay).UTC().Format(time.RFC3339)
+	updateBroadcastJob(context.Background(), job.ID, map[string]interface{}{
+		"status":          JobQueued,
+		"last_error":      job.LastError,
+		"next_attempt_at": job.NextAttemptAt,
+	})
+	slog.Warn("broadcast job failed, will retry", "job_id", job.ID, "attempts", job.Attempts,
+		"max_attempts", jobMaxAttempts, "retry_at", job.NextAttemptAt, "error", err)
+}
+
+// withRetry runs fn until it succeeds, backing off exponentially with
+// jitter. It gives up early when ctx is done.
+func withRetry(ctx context.Context, op string, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < deliveryMaxAttempts; attempt++ {
+		if err = fn(); err == nil || errors.Is(err, errNoRecipients) {
+			return err
+		}
+		if attempt == deliveryMaxAttempts-1 {
+			break
+		}
+		backoff := deliveryBaseBackoff << attempt
+		if backoff > deliveryMaxBackoff {
+			backoff = deliveryMaxBackoff
+		}
+		backoff += time.Duration(rand.Int63n(int64(backoff) / 2))
+		slog.Warn("operation failed, retrying", "op", op, "attempt", attempt+1, "max_attempts", deliveryMaxAttempts, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", op, ctx.Err())
+		case <-time.After(backoff):
+		}
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+// insertRecipients creates message_recipients rows, skipping existing ones.
+// rendered holds per-recipient text for personalized messages (nil otherwise).
+func insertRecipients(ctx context.Context, messageID string, studentUUIDs []string, rendered map[string]RenderedMessage) error {
+	rows := make([]map[string]interface{}, 0, len(studentUUIDs))
+	for _, studentUUID := range studentUUIDs {
+		row := map[string]interface{}{
+			"message_id": messageID,
+			"student_id": studentUUID,
+		}
+		if content, exists := rendered[studentUUID]; exists {
+			row["rendered_title"] = content.Title
+			row["rendered_message"] = content.Message
+		}
+		rows = append(rows, row)
+	}
+
+	jsonData, _ := json.Marshal(rows)
+	recipientURL := from("message_recipients").OnConflict("message_id", "student_id").URL()
+	req, err := newSupabaseRequest(ctx, "POST", recipientURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Prefer", "resolution=ignore-duplicates")
+
+	resp, err := supabaseClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+	return nil
+}
+
+// updateBroadcastJob patches a job row; progress updates are best effort
+func updateBroadcastJob(ctx context.Context, jobID string, fields map[string]interface{}) {
+	fields["updated_at"] = time.Now().UTC().Format(time.RFC3339)
+	jsonData, _ := json.Marshal(fields)
+	updateURL := from("broadcast_jobs").Eq("id", jobID).URL()
+	req, err := newSupabaseRequest(ctx, "PATCH", updateURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return
+	}
+	resp, err := supabaseClient.Do(req)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to update broadcast job", "job_id", jobID, "error", err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// requeueBroadcastJob starts a failed job over with a fresh set of attempts
+// and wakes the sweep, which loads it with its message
+func requeueBroadcastJob(ctx context.Context, job *BroadcastJob) error {
+	err := supabasePatch(ctx, from("broadcast_jobs").Eq("id", job.ID).Eq("status", JobFailed).URL(), map[string]interface{}{
+		"status":          JobQueued,
+		"attempts":        0,
+		"next_attempt_at": nil,
+		"updated_at":      time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	job.Status = JobQueued
+	job.Attempts = 0
+	slog.InfoContext(ctx, "requeued failed broadcast job", "job_id", job.ID, "message_id", job.MessageID)
+	deliveryQueue.Sweep()
+	return nil
+}
+
+// findIdempotentBroadcast looks up the message an admin already stored
+// under key, returning its ID (empty if there is none) and its delivery job.
+// The job is nil when the earlier request failed after creating the message.
+func findIdempotentBroadcast(ctx context.Context, adminID, key string) (messageID string, job *BroadcastJob, err error) {
+	var messages []struct {
+		ID string `json:"id"`
+	}
+	messageURL := from("broadcast_messages").Eq("admin_id", adminID).Eq("idempotency_key", key).Select("id").URL()
+	if err := supabaseGetJSON(ctx, messageURL, &messages); err != nil || len(messages) == 0 {
+		return "", nil, err
+	}
+	job, err = findBroadcastJob(ctx, messages[0].ID)
+	return messages[0].ID, job, err
+}
+
+// findBroadcastJob returns the delivery job of a message, or nil if there is none
+func findBroadcastJob(ctx context.Context, messageID string) (*BroadcastJob, error) {
+	jobs, err := fetchBroadcastJobs(ctx, from("broadcast_jobs").Eq("message_id", messageID).Select("*").URL())
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
+
+func fetchBroadcastJobs(ctx context.Context, jobsURL string) ([]BroadcastJob, error) {
+	req, err := newSupabaseRequest(ctx, "GET", jobsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := supabaseClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var jobs []BroadcastJob
+	if err := json.NewDecoder(resp.Body).Decode(&jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// insertReturning POSTs a row and returns the id of the created row
+func insertReturning(ctx context.Context, table string, row map[string]interface{}) (string, int, error) {
+	jsonData, _ := json.Marshal(row)
+	insertURL := from(table).URL()
+	req, err := newSupabaseRequest(ctx, "POST", insertURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Prefer", "return=representation")
+
+	resp, err := supabaseClient.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", resp.StatusCode, fmt.Errorf("status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var created []struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(bodyBytes, &created); err != nil || len(created) == 0 {
+		return "", resp.StatusCode, fmt.Errorf("no row returned from %s insert", table)
+	}
+	return created[0].ID, resp.StatusCode, nil
+}
+
+// enqueueBroadcast stores the message and its delivery job, then queues it.
+// Repeating a request with the same idempotency key from the same admin
+// returns the original job, or creates it if the earlier request stored the
+// message but failed before its job.
+func enqueueBroadcast(ctx context.Context, b BroadcastRequest) (job *BroadcastJob, duplicate bool, err error) {
+	var messageID string
+	if b.IdempotencyKey != "" {
+		messageID, job, err = findIdempotentBroadcast(ctx, b.AdminID, b.IdempotencyKey)
+		if err != nil {
+			return nil, false, err
+		}
+		if job != nil && job.Status == JobFailed {
+			// Retrying a send that gave up delivers it after all
+			if err := requeueBroadcastJob(ctx, job); err != nil {
+				return nil, false, fmt.Errorf("requeue delivery job: %w", err)
+			}
+		}
+		if job != nil {
+			return job, true, nil
+		}
+	}
+
+	messageData := map[string]interface{}{
+		"admin_id":    b.AdminID,
+		"title":       b.Title,
+		"message":     b.Message,
+		"sent_to_all": b.Audience.SendToAll,
+		"audience":    b.Audience,
+	}
+	if groupID := b.Audience.SingleGroupID(); groupID != "" {
+		messageData["group_id"] = groupID
+	}
+	if b.IdempotencyKey != "" {
+		messageData["idempotency_key"] = b.IdempotencyKey
+	}
+	if b.Priority != "" {
+		messageData["priority"] = b.Priority
+	}
+	personalized := hasPlaceholders(b.Title + b.Message)
+	if b.TemplateGroupID == "" {
+		b.TemplateGroupID = b.Audience.SingleGroupID()
+	}
+	if b.TemplateGroupID == "" {
+		b.TemplateGroupID = b.Audience.AbsentFromGroupID
+	}
+	if personalized {
+		messageData["personalized"] = true
+		if b.TemplateGroupID != "" {
+			messageData["template_group_id"] = b.TemplateGroupID
+		}
+	}
+	if b.ExpiresAt != nil {
+		messageData["expires_at"] = b.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	duplicate = messageID != ""
+	if !duplicate {
+		var status int
+		messageID, status, err = insertReturning(ctx, "broadcast_messages", messageData)
+		if status == http.StatusConflict && b.IdempotencyKey != "" {
+			// Lost a race with a concurrent request using the same key
+			existingID, existing, lookupErr := findIdempotentBroadcast(ctx, b.AdminID, b.IdempotencyKey)
+			if lookupErr == nil && existing != nil {
+				return existing, true, nil
+			}
+			if lookupErr == nil && existingID != "" {
+				messageID, duplicate, err = existingID, true, nil
+			}
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("create message: %w", err)
+		}
+	}
+	if err := linkAttachments(ctx, b.AdminID, messageID, b.AttachmentIDs); err != nil {
+		return nil, false, fmt.Errorf("link attachments: %w", err)
+	}
+
+	job = &BroadcastJob{
+		MessageID: messageID,
+		Status:    JobQueued,
+		Audience:  b.Audience,
+		Title:     b.Title,
+		Message:   b.Message,
+
+		Personalized:    personalized,
+		TemplateGroupID: b.TemplateGroupID,
+	}
+	var status int
+	job.ID, status, err = insertReturning(ctx, "broadcast_jobs", map[string]interface{}{
+		"message_id": messageID,
+		"status":     JobQueued,
+		"audience":   b.Audience,
+	})
+	if status == http.StatusConflict {
+		// A concurrent retry created the job first (message_id is unique)
+		if existing, lookupErr := findBroadcastJob(ctx, messageID); lookupErr == nil && existing != nil {
+			return existing, true, nil
+		}
+	}
+	if err != nil {
+		// With an idempotency key, a retry finds the message and creates the job
+		return nil, false, fmt.Errorf("create delivery job: %w", err)
+	}
+
+	deliveryQueue.Enqueue(job)
+	return job, duplicate, nil
+}
+
+// Handler: GET /api/get-broadcast-status?message_id=xxx (or job_id=xxx)
+func getBroadcastStatusHandler(w http.ResponseWriter, r *http.Request) {
+	messageID := r.URL.Query().Get("message_id")
+	jobID := r.URL.Query().Get("job_id")
+
+	var jobsURL string
+	switch {
+	case jobID != "":
+		jobsURL = from("broadcast_jobs").Eq("id", jobID).Select("*").URL()
+	case messageID != "":
+		jobsURL = from("broadcast_jobs").Eq("message_id", messageID).Select("*").URL()
+	default:
+		writeError(w, r, http.StatusBadRequest, "message_id or job_id is required")
+		return
+	}
+
+	jobs, err := fetchBroadcastJobs(r.Context(), jobsURL)
+	if err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Database error", err))
+		return
+	}
+	if len(jobs) == 0 {
+		writeError(w, r, http.StatusNotFound, "Broadcast job not found")
+		return
+	}
+
+	job := jobs[0]
+	progress := 0.0
+	if job.TotalRecipients > 0 {
+		progress = float64(job.DeliveredRecipients) * 100 / float64(job.TotalRecipients)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"job":      job,
+		"progress": progress,
+	})
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeBroadcastTables stores broadcast_messages under UNIQUE(admin_id,
+// idempotency_key) and broadcast_jobs under UNIQUE(message_id), answering
+// a duplicate with 409 like PostgREST. The first failJobs job inserts fail.
+type fakeBroadcastTables struct {
+	mu       sync.Mutex
+	messages []map[string]interface{}
+	jobs     []map[string]interface{}
+	failJobs int
+}
+
+func (f *fakeBroadcastTables) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	table := strings.TrimPrefix(r.URL.Path, "/rest/v1/")
+	rows := &f.messages
+	unique := []string{"admin_id", "idempotency_key"}
+	if table == "broadcast_jobs" {
+		rows, unique = &f.jobs, []string{"message_id"}
+	}
+
+	switch {
+	case r.Method == http.MethodGet && (table == "broadcast_messages" || table == "broadcast_jobs"):
+		matches := []map[string]interface{}{}
+		for _, row := range *rows {
+			match := true
+			for column, values := range r.URL.Query() {
+				if column != "select" && "eq."+fmt.Sprint(row[column]) != values[0] {
+					match = false
+				}
+			}
+			if match {
+				matches = append(matches, row)
+			}
+		}
+		json.NewEncoder(w).Encode(matches)
+	case r.Method == http.MethodPost && (table == "broadcast_messages" || table == "broadcast_jobs"):
+		var row map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&row)
+		if table == "broadcast_jobs" && f.failJobs > 0 {
+			f.failJobs--
+			http.Error(w, `{"message":"connection reset"}`, http.StatusInternalServerError)
+			return
+		}
+		for _, existing := range *rows {
+			duplicate := row[unique[len(unique)-1]] != nil
+			for _, column := range unique {
+				duplicate = duplicate && existing[column] == row[column]
+			}
+			if duplicate {
+				http.Error(w, `{"code":"23505"}`, http.StatusConflict)
+				return
+			}
+		}
+		row["id"] = fmt.Sprintf("%s-%d", table, len(*rows)+1)
+		*rows = append(*rows, row)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode([]map[string]interface{}{row})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestEnqueueBroadcastIdempotency(t *testing.T) {
+	tables := &fakeBroadcastTables{failJobs: 1}
+	useSupabase(t, tables)
+	ctx := context.Background()
+	request := func(adminID string) BroadcastRequest {
+		return BroadcastRequest{AdminID: adminID, Title: "Hi", Message: "Hello", IdempotencyKey: "key-1"}
+	}
+
+	if _, _, err := enqueueBroadcast(ctx, request("admin-1")); err == nil {
+		t.Fatal("job insert failed but enqueueBroadcast succeeded")
+	}
+
+	// The retry finds the stored message and creates its missing job
+	job, duplicate, err := enqueueBroadcast(ctx, request("admin-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !duplicate || job.MessageID != "broadcast_messages-1" || job.ID == "" {
+		t.Errorf("retry: got job %+v, duplicate %v; want a new job for the first message", job, duplicate)
+	}
+
+	again, duplicate, err := enqueueBroadcast(ctx, request("admin-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !duplicate || again.ID != job.ID {
+		t.Errorf("second retry: got job %q, duplicate %v; want job %q", again.ID, duplicate, job.ID)
+	}
+
+	// Another admin's key is separate
+	other, duplicate, err := enqueueBroadcast(ctx, request("admin-2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if duplicate || other.MessageID == job.MessageID {
+		t.Errorf("other admin: got message %q, duplicate %v; want a message of its own", other.MessageID, duplicate)
+	}
+
+	if len(tables.messages) != 2 || len(tables.jobs) != 2 {
+		t.Errorf("got %d messages and %d jobs, want 2 of each", len(tables.messages), len(tables.jobs))
+	}
+}
+
+// fakeDeliveryTables serves what a delivery job touches: a students table of
+// size students, message_recipients inserts (the first failInserts fail),
+// one device token per lookup and one broadcast_jobs row that PATCHes update.
+// calls logs each request as "METHOD table".
+type fakeDeliveryTables struct {
+	mu          sync.Mutex
+	students    int
+	failInserts int
+	chunks      []int
+	job         map[string]interface{}
+	patches     []map[string]interface{}
+	calls       []string
+	jobsQuery   url.Values
+}
+
+func (f *fakeDeliveryTables) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	table := strings.TrimPrefix(r.URL.Path, "/rest/v1/")
+	f.calls = append(f.calls, r.Method+" "+table)
+
+	switch {
+	case r.Method == http.MethodGet && table == "students":
+		students := make([]map[string]string, f.students)
+		for i := range students {
+			students[i] = map[string]string{"id": fmt.Sprintf("uuid-%d", i), "student_id": fmt.Sprintf("ST%04d", i)}
+		}
+		json.NewEncoder(w).Encode(students)
+	case r.Method == http.MethodPost && table == "message_recipients":
+		if f.failInserts > 0 {
+			f.failInserts--
+			http.Error(w, `{"message":"connection reset"}`, http.StatusServiceUnavailable)
+			return
+		}
+		var rows []map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&rows)
+		f.chunks = append(f.chunks, len(rows))
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet && table == "fcm_tokens":
+		json.NewEncoder(w).Encode([]map[string]string{{"fcm_token": "token-1"}})
+	case r.Method == http.MethodGet && table == "broadcast_jobs":
+		f.jobsQuery = r.URL.Query()
+		json.NewEncoder(w).Encode([]map[string]interface{}{f.job})
+	case r.Method == http.MethodPatch && table == "broadcast_jobs":
+		var fields map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&fields)
+		f.patches = append(f.patches, fields)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// index returns the position of the first call, or -1
+func (f *fakeDeliveryTables) index(call string) int {
+	for i, c := range f.calls {
+		if c == call {
+			return i
+		}
+	}
+	return -1
+}
+
+// patched returns the first PATCH that set column, or nil
+func (f *fakeDeliveryTables) patched(column string) map[string]interface{} {
+	for _, fields := range f.patches {
+		if _, exists := fields[column]; exists {
+			return fields
+		}
+	}
+	return nil
+}
+
+func newTestQueue() *DeliveryQueue {
+	return &DeliveryQueue{
+		jobs:     make(chan *BroadcastJob, 10),
+		sweep:    make(chan struct{}, 1),
+		inFlight: make(map[string]bool),
+	}
+}
+
+func testJob() *BroadcastJob {
+	return &BroadcastJob{ID: "job-1", MessageID: "message-1", Status: JobQueued,
+		Audience: BroadcastAudience{SendToAll: true}, Title: "Hi", Message: "Hello"}
+}
+
+func TestProcessInsertsRecipientsInChunks(t *testing.T) {
+	tables := &fakeDeliveryTables{students: 2*recipientChunkSize + 1}
+	useSupabase(t, tables)
+
+	job := testJob()
+	newTestQueue().process(context.Background(), job)
+
+	if job.Status != JobCompleted || job.DeliveredRecipients != tables.students {
+		t.Fatalf("job %s with %d delivered, want completed with %d", job.Status, job.DeliveredRecipients, tables.students)
+	}
+	if want := []int{recipientChunkSize, recipientChunkSize, 1}; fmt.Sprint(tables.chunks) != fmt.Sprint(want) {
+		t.Errorf("inserted chunks %v, want %v", tables.chunks, want)
+	}
+
+	// Pushes are recorded before the job is marked completed
+	notified, completed := -1, -1
+	for i, fields := range tables.patches {
+		if fields["notified_at"] != nil && notified < 0 {
+			notified = i
+		}
+		if fields["status"] == JobCompleted {
+			completed = i
+		}
+	}
+	if tables.index("GET fcm_tokens") < 0 || notified < 0 || completed < notified {
+		t.Errorf("pushes recorded at patch %d, completed at patch %d; want pushes sent and recorded first", notified, completed)
+	}
+}
+
+func TestProcessRetriesTransientFailure(t *testing.T) {
+	tables := &fakeDeliveryTables{students: 3, failInserts: 1}
+	useSupabase(t, tables)
+
+	job := testJob()
+	newTestQueue().process(context.Background(), job)
+
+	if job.Status != JobCompleted || job.Attempts != 1 {
+		t.Fatalf("job %s after %d runs, want completed in one run", job.Status, job.Attempts)
+	}
+	if fmt.Sprint(tables.chunks) != "[3]" {
+		t.Errorf("inserted chunks %v, want the chunk inserted once on retry", tables.chunks)
+	}
+}
+
+func TestRecoverResumesRunningJob(t *testing.T) {
+	// The server stopped after pushing but before marking the job completed
+	tables := &fakeDeliveryTables{students: 3, job: map[string]interface{}{
+		"id": "job-1", "message_id": "message-1", "status": JobRunning, "attempts": 1,
+		"audience": map[string]bool{"send_to_all": true}, "delivered_recipients": 3,
+		"notified_at":        "2026-01-01T09:00:00Z",
+		"broadcast_messages": map[string]string{"title": "Hi", "message": "Hello"},
+	}}
+	useSupabase(t, tables)
+
+	q := newTestQueue()
+	q.recover()
+	if got := tables.jobsQuery.Get("or"); !strings.Contains(got, "next_attempt_at.is.null") {
+		t.Errorf("sweep filter or=%q, want jobs whose retry is due", got)
+	}
+	if len(q.jobs) != 1 {
+		t.Fatalf("recovered %d jobs, want 1", len(q.jobs))
+	}
+	job := <-q.jobs
+	if job.Title != "Hi" || job.Status != JobRunning {
+		t.Fatalf("recovered job %+v", job)
+	}
+	q.process(context.Background(), job)
+
+	if job.Status != JobCompleted || job.Attempts != 2 {
+		t.Errorf("resumed job %s after %d runs, want completed after 2", job.Status, job.Attempts)
+	}
+	if fmt.Sprint(tables.chunks) != "[3]" {
+		t.Errorf("inserted chunks %v, want the recipients inserted again", tables.chunks)
+	}
+	if tables.index("GET fcm_tokens") >= 0 {
+		t.Error("pushes sent again by the resumed job")
+	}
+}
+
+func TestFailedJobIsRetriedLater(t *testing.T) {
+	tables := &fakeDeliveryTables{}
+	useSupabase(t, tables)
+	q := newTestQueue()
+
+	job := testJob()
+	job.Attempts = 1
+	q.fail(context.Background(), job, errors.New("connection reset"))
+	retry := tables.patched("next_attempt_at")
+	if job.Status != JobQueued || retry == nil || retry["status"] != JobQueued || retry["next_attempt_at"] == nil {
+		t.Fatalf("first failure: job %s, patch %v; want queued with a retry time", job.Status, retry)
+	}
+
+	job.Attempts = jobMaxAttempts
+	q.fail(context.Background(), job, errors.New("connection reset"))
+	if last := tables.patches[len(tables.patches)-1]; job.Status != JobFailed || last["status"] != JobFailed {
+		t.Errorf("last attempt: job %s, patch %v; want failed", job.Status, last)
+	}
+
+	// Shutdown leaves the run to resume on the next start
+	tables.patches = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	q.fail(ctx, testJob(), context.Canceled)
+	if len(tables.patches) != 0 {
+		t.Errorf("interrupted run patched %v", tables.patches)
+	}
+}
+
+func TestEnqueueBroadcastRequeuesFailedJob(t *testing.T) {
+	var requeue map[string]interface{}
+	var requeueQuery url.Values
+	useSupabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/v1/broadcast_messages":
+			json.NewEncoder(w).Encode([]map[string]string{{"id": "message-1"}})
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/v1/broadcast_jobs":
+			json.NewEncoder(w).Encode([]map[string]interface{}{{"id": "job-1", "message_id": "message-1", "status": JobFailed, "attempts": jobMaxAttempts}})
+		case r.Method == http.MethodPatch && r.URL.Path == "/rest/v1/broadcast_jobs":
+			requeueQuery = r.URL.Query()
+			json.NewDecoder(r.Body).Decode(&requeue)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	job, duplicate, err := enqueueBroadcast(context.Background(), BroadcastRequest{AdminID: "admin-1", Title: "Hi", Message: "Hello", IdempotencyKey: "key-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !duplicate || job.ID != "job-1" || job.Status != JobQueued {
+		t.Errorf("got job %+v, duplicate %v; want job-1 queued again", job, duplicate)
+	}
+	if requeue["status"] != JobQueued || requeue["attempts"] != 0.0 || requeueQuery.Get("status") != "eq."+JobFailed {
+		t.Errorf("requeue patch %v on %v, want the failed job queued with fresh attempts", requeue, requeueQuery)
+	}
+}
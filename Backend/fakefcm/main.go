@@ -0,0 +1,40 @@
+// Command fakefcm is a local stand-in for the FCM HTTP v1 API and Google's
+// OAuth2 token endpoint, for exercising push delivery without Firebase.
+//
+//	go run ./fakefcm -credentials fake-credentials.json
+//	FIREBASE_CREDENTIALS=fake-credentials.json FCM_ENDPOINT=http://localhost:9099 NOTIFIER=fcm go run .
+//
+// Tokens starting with "invalid" are rejected as UNREGISTERED so token
+// pruning can be tested. Delivered messages are listed at GET /messages.
+// The server itself is package fcmtest.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"attendance-system/fcmtest"
+)
+
+func main() {
+	addr := flag.String("addr", ":9099", "listen address")
+	credentials := flag.String("credentials", "", "write a service account key pointing at this server to this path")
+	flag.Parse()
+
+	if *credentials != "" {
+		host := *addr
+		if strings.HasPrefix(host, ":") {
+			host = "localhost" + host
+		}
+		if err := fcmtest.WriteCredentials(*credentials, "http://"+host); err != nil {
+			log.Fatalf("Failed to write credentials: %v", err)
+		}
+		fmt.Printf("Wrote fake service account key to %s\n", *credentials)
+	}
+
+	fmt.Printf("Fake FCM server running on %s\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, fcmtest.NewServer()))
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// fcmMaxPayload is FCM's limit on a message's notification and data, in bytes
+const fcmMaxPayload = 4096
+
+// serviceAccount is the subset of a Firebase service account key we need
+type serviceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// fcmNotifier sends notifications through the FCM HTTP v1 API
+type fcmNotifier struct {
+	projectID string
+	endpoint  string
+	account   serviceAccount
+	key       *rsa.PrivateKey
+	client    *http.Client
+
+	// Cached OAuth2 access token
+	accessToken string
+	tokenExpiry time.Time
+	mu          sync.Mutex
+}
+
+func newFCMNotifier(cfg Config) (*fcmNotifier, error) {
+	raw, err := os.ReadFile(cfg.FCMCredentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("read credentials: %w", err)
+	}
+
+	var account serviceAccount
+	if err := json.Unmarshal(raw, &account); err != nil {
+		return nil, fmt.Errorf("parse credentials: %w", err)
+	}
+	if account.ClientEmail == "" || account.PrivateKey == "" || account.TokenURI == "" {
+		return nil, errors.New("credentials missing client_email, private_key or token_uri")
+	}
+
+	key, err := parseRSAPrivateKey(account.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	projectID := cfg.FCMProjectID
+	if projectID == "" {
+		projectID = account.ProjectID
+	}
+	if projectID == "" {
+		return nil, errors.New("no FCM project ID configured")
+	}
+
+	return &fcmNotifier{
+		projectID: projectID,
+		endpoint:  strings.TrimRight(cfg.FCMEndpoint, "/"),
+		account:   account,
+		key:       key,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func parseRSAPrivateKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("private_key is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, errors.New("private_key is not an RSA key")
+		}
+		return rsaKey, nil
+	}
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// token returns a valid OAuth2 access token, refreshing it when close to expiry
+func (f *fcmNotifier) token(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.accessToken != "" && time.Until(f.tokenExpiry) > time.Minute {
+		return f.accessToken, nil
+	}
+
+	assertion, err := f.signJWT(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", f.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token exchange: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("token exchange: status %d: %s", resp.StatusCode, body)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", fmt.Errorf("token exchange: %w", err)
+	}
+
+	f.accessToken = tokenResp.AccessToken
+	f.tokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	return f.accessToken, nil
+}
+
+// signJWT builds the RS256 assertion used for the service account token exchange
+func (f *fcmNotifier) signJWT(now time.Time) (string, error) {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   f.account.ClientEmail,
+		"scope": fcmScope,
+		"aud":   f.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+
+	enc := base64.RawURLEncoding
+	signingInput := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("sign assertion: %w", err)
+	}
+	return signingInput + "." + enc.EncodeToString(signature), nil
+}
+
+// Send delivers the notification to each token, a bounded number at a time
+func (f *fcmNotifier) Send(ctx context.Context, tokens []string, n Notification) []DeliveryResult {
+	results := make([]DeliveryResult, len(tokens))
+	n = fitPayload(n)
+
+	accessToken, err := f.token(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "FCM authentication failed", "error", err)
+		for i, token := range tokens {
+			results[i] = DeliveryResult{Token: token, Error: err.Error()}
+		}
+		return results
+	}
+
+	sem := make(chan struct{}, notifyConcurrency)
+	var wg sync.WaitGroup
+	for i, token := range tokens {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, token string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = f.sendOne(ctx, accessToken, token, n)
+		}(i, token)
+	}
+	wg.Wait()
+	return results
+}
+
+func (f *fcmNotifier) sendOne(ctx context.Context, accessToken, token string, n Notification) DeliveryResult {
+	result := DeliveryResult{Token: token}
+
+	message := map[string]interface{}{
+		"token": token,
+		"data":  n.Data,
+		"android": map[string]string{
+			"priority": "high",
+		},
+	}
+	// Without a title or body this is a silent data message the app handles itself
+	if n.Title != "" || n.Body != "" {
+		message["notification"] = map[string]string{
+			"title": n.Title,
+			"body":  n.Body,
+		}
+	}
+	payload, _ := json.Marshal(map[string]interface{}{"message": message})
+
+	sendURL := fmt.Sprintf("%s/v1/projects/%s/messages:send", f.endpoint, f.projectID)
+	req, err := http.NewRequestWithContext(ctx, "POST", sendURL, bytes.NewReader(payload))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode == http.StatusOK {
+		var sent struct {
+			Name string `json:"name"`
+		}
+		json.Unmarshal(body, &sent)
+		result.Success = true
+		result.MessageID = sent.Name
+		return result
+	}
+
+	code := fcmErrorCode(body)
+	result.Error = fmt.Sprintf("status %d: %s", resp.StatusCode, code)
+	// UNREGISTERED or 404: app uninstalled or token expired. A 400
+	// INVALID_ARGUMENT may just as well be about the payload, so the token
+	// is kept.
+	result.InvalidToken = code == "UNREGISTERED" || resp.StatusCode == http.StatusNotFound
+	return result
+}
+
+// fitPayload cuts the title, body and data values to a common length so
+// they fit in fcmMaxPayload, shortening only the longest ones. They carry
+// admin-written text, often twice as broadcasts copy it into data, and FCM
+// rejects a larger message outright.
+func fitPayload(n Notification) Notification {
+	values := []string{n.Title, n.Body}
+	keys := 0
+	for key, value := range n.Data {
+		values = append(values, value)
+		keys += len(key)
+	}
+	longest := 0
+	for _, value := range values {
+		longest = max(longest, len(value))
+	}
+	size := func(limit int) int {
+		total := keys
+		for _, value := range values {
+			total += min(len(value), limit)
+		}
+		return total
+	}
+	if size(longest) <= fcmMaxPayload {
+		return n
+	}
+
+	// The longest length every value can keep, found by binary search
+	limit := sort.Search(longest, func(limit int) bool { return size(limit+1) > fcmMaxPayload })
+	data := make(map[string]string, len(n.Data))
+	for key, value := range n.Data {
+		data[key] = truncateText(value, limit)
+	}
+	n.Title = truncateText(n.Title, limit)
+	n.Body = truncateText(n.Body, limit)
+	n.Data = data
+	return n
+}
+
+// truncateText cuts s to at most limit bytes on a character boundary,
+// ending it with an ellipsis when there's room for one
+func truncateText(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	const ellipsis = "…"
+	end := max(limit, 0)
+	if end > len(ellipsis) {
+		end -= len(ellipsis)
+	}
+	for end > 0 && !utf8.RuneStart(s[end]) {
+		end--
+	}
+	if limit > len(ellipsis) {
+		return s[:end] + ellipsis
+	}
+	return s[:end]
+}
+
+// fcmErrorCode extracts the FCM error code from an HTTP v1 error body
+func fcmErrorCode(body []byte) string {
+	var errResp struct {
+		Error struct {
+			Status  string `json:"status"`
+			Details []struct {
+				Type      string `json:"@type"`
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &errResp) != nil {
+		return "UNKNOWN"
+	}
+	for _, detail := range errResp.Error.Details {
+		if detail.ErrorCode != "" {
+			return detail.ErrorCode
+		}
+	}
+	if errResp.Error.Status != "" {
+		return errResp.Error.Status
+	}
+	return "UNKNOWN"
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"attendance-system/fcmtest"
+)
+
+// newFakeFCM starts an fcmtest server and an fcmNotifier that sends to it
+func newFakeFCM(t *testing.T) (*fcmNotifier, *fcmtest.Server) {
+	t.Helper()
+	fake := fcmtest.NewServer()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	credentials := filepath.Join(t.TempDir(), "credentials.json")
+	if err := fcmtest.WriteCredentials(credentials, srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	notifier, err := newFCMNotifier(Config{FCMCredentialsFile: credentials, FCMEndpoint: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return notifier, fake
+}
+
+func TestFCMNotifierSend(t *testing.T) {
+	notifier, fake := newFakeFCM(t)
+
+	results := notifier.Send(context.Background(), []string{"device-1", "invalid-1", "device-2"}, Notification{
+		Title: "Hello",
+		Body:  "World",
+		Data:  map[string]string{"type": "broadcast_message"},
+	})
+
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	for _, i := range []int{0, 2} {
+		if !results[i].Success || results[i].MessageID == "" || results[i].InvalidToken {
+			t.Errorf("%s: got %+v, want delivered", results[i].Token, results[i])
+		}
+	}
+	if results[1].Success || !results[1].InvalidToken {
+		t.Errorf("UNREGISTERED token: got %+v, want it marked invalid", results[1])
+	}
+	if got := len(fake.Messages()); got != 2 {
+		t.Errorf("fake FCM received %d messages, want 2", got)
+	}
+}
+
+func TestFCMNotifierKeepsTokenOnBadRequest(t *testing.T) {
+	notifier, _ := newFakeFCM(t)
+
+	// FCM refuses reserved data keys with 400 INVALID_ARGUMENT; the token is fine
+	results := notifier.Send(context.Background(), []string{"device-1"}, Notification{
+		Title: "Hello",
+		Data:  map[string]string{"from": "admin"},
+	})
+
+	if len(results) != 1 || results[0].Success {
+		t.Fatalf("got %+v, want a failed delivery", results)
+	}
+	if results[0].InvalidToken {
+		t.Errorf("400 INVALID_ARGUMENT marked the token invalid: %+v", results[0])
+	}
+}
+
+func TestFCMNotifierFitsPayload(t *testing.T) {
+	notifier, fake := newFakeFCM(t)
+
+	message := strings.Repeat("é", fcmMaxPayload)
+	n := Notification{
+		Title: "Long announcement",
+		Body:  message,
+		Data:  map[string]string{"type": "broadcast_message", "message": message},
+	}
+	results := notifier.Send(context.Background(), []string{"device-1"}, n)
+
+	if len(results) != 1 || !results[0].Success {
+		t.Fatalf("got %+v, want delivered", results)
+	}
+	if n.Data["message"] != message {
+		t.Error("Send changed the caller's data")
+	}
+
+	var sent struct {
+		Notification map[string]string `json:"notification"`
+		Data         map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(fake.Messages()[0], &sent); err != nil {
+		t.Fatal(err)
+	}
+	size := 0
+	for _, value := range sent.Notification {
+		size += len(value)
+	}
+	for key, value := range sent.Data {
+		size += len(key) + len(value)
+	}
+	if size > fcmMaxPayload {
+		t.Errorf("sent %d bytes, limit %d", size, fcmMaxPayload)
+	}
+	if sent.Notification["title"] != n.Title || sent.Data["type"] != "broadcast_message" {
+		t.Errorf("short fields were cut: %+v", sent)
+	}
+	if body := sent.Notification["body"]; !utf8.ValidString(body) || !strings.HasSuffix(body, "…") {
+		t.Errorf("body not cut at a character with an ellipsis: %q", body[len(body)-8:])
+	}
+}
+
+func TestFCMErrorCode(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"error":{"status":"NOT_FOUND","details":[{"errorCode":"UNREGISTERED"}]}}`, "UNREGISTERED"},
+		{`{"error":{"status":"INVALID_ARGUMENT"}}`, "INVALID_ARGUMENT"},
+		{`{"error":{}}`, "UNKNOWN"},
+		{`<html>Bad Gateway</html>`, "UNKNOWN"},
+	}
+	for _, tt := range tests {
+		if got := fcmErrorCode([]byte(tt.body)); got != tt.want {
+			t.Errorf("fcmErrorCode(%s) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Package fcmtest is a local stand-in for the FCM HTTP v1 API and Google's
+// OAuth2 token endpoint, for exercising push delivery without Firebase. It
+// backs the fakefcm command and the notifier's tests.
+//
+// Tokens starting with "invalid" are rejected as UNREGISTERED. Messages FCM
+// would refuse whatever the token (over 4096 bytes of notification and
+// data, or a reserved data key) get 400 INVALID_ARGUMENT.
+package fcmtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// AccessToken is the bearer token the fake token endpoint hands out
+const AccessToken = "fake-access-token"
+
+// MaxPayload is FCM's limit on a message's notification and data, in bytes
+const MaxPayload = 4096
+
+// Server records every message it accepts
+type Server struct {
+	mux       *http.ServeMux
+	mu        sync.Mutex
+	delivered []json.RawMessage
+}
+
+func NewServer() *Server {
+	s := &Server{mux: http.NewServeMux()}
+	s.mux.HandleFunc("/token", s.tokenHandler)
+	s.mux.HandleFunc("/v1/projects/", s.sendHandler)
+	s.mux.HandleFunc("/messages", s.messagesHandler)
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Messages returns the messages delivered so far
+func (s *Server) Messages() []json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]json.RawMessage(nil), s.delivered...)
+}
+
+// WriteCredentials generates a throwaway RSA key and writes a service
+// account file whose token_uri points at the server at baseURL
+func WriteCredentials(path, baseURL string) error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	account, _ := json.MarshalIndent(map[string]string{
+		"type":         "service_account",
+		"project_id":   "fake-project",
+		"client_email": "fake@fake-project.iam.gserviceaccount.com",
+		"private_key":  string(pemKey),
+		"token_uri":    strings.TrimRight(baseURL, "/") + "/token",
+	}, "", "  ")
+	return os.WriteFile(path, account, 0600)
+}
+
+// Handler: POST /token (OAuth2 JWT bearer exchange, any assertion accepted)
+func (s *Server) tokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.FormValue("assertion") == "" {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": AccessToken,
+		"expires_in":   3600,
+		"token_type":   "Bearer",
+	})
+}
+
+// Handler: POST /v1/projects/{project}/messages:send
+func (s *Server) sendHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Header.Get("Authorization") != "Bearer "+AccessToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(fcmError(http.StatusUnauthorized, "UNAUTHENTICATED", "THIRD_PARTY_AUTH_ERROR"))
+		return
+	}
+
+	var body struct {
+		Message json.RawMessage `json:"message"`
+	}
+	var message struct {
+		Token        string            `json:"token"`
+		Notification map[string]string `json:"notification"`
+		Data         map[string]string `json:"data"`
+	}
+	if json.NewDecoder(r.Body).Decode(&body) != nil || json.Unmarshal(body.Message, &message) != nil ||
+		message.Token == "" || !acceptable(message.Notification, message.Data) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(fcmError(http.StatusBadRequest, "INVALID_ARGUMENT", "INVALID_ARGUMENT"))
+		return
+	}
+
+	if strings.HasPrefix(message.Token, "invalid") {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(fcmError(http.StatusNotFound, "NOT_FOUND", "UNREGISTERED"))
+		return
+	}
+
+	s.mu.Lock()
+	s.delivered = append(s.delivered, body.Message)
+	id := len(s.delivered)
+	s.mu.Unlock()
+
+	project := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/v1/projects/"), "/messages:send")
+	json.NewEncoder(w).Encode(map[string]string{
+		"name": fmt.Sprintf("projects/%s/messages/%d", project, id),
+	})
+}
+
+// acceptable applies FCM's payload rules: data keys must not be reserved,
+// and the notification and data together must fit in MaxPayload
+func acceptable(notification, data map[string]string) bool {
+	size := 0
+	for _, value := range notification {
+		size += len(value)
+	}
+	for key, value := range data {
+		if key == "from" || key == "notification" || key == "message_type" ||
+			strings.HasPrefix(key, "google") || strings.HasPrefix(key, "gcm") {
+			return false
+		}
+		size += len(key) + len(value)
+	}
+	return size <= MaxPayload
+}
+
+// Handler: GET /messages (inspect delivered messages; DELETE clears them)
+func (s *Server) messagesHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Method == http.MethodDelete {
+		s.delivered = nil
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"messages": s.delivered,
+		"count":    len(s.delivered),
+	})
+}
+
+func fcmError(code int, status, errorCode string) map[string]interface{} {
+	return map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    code,
+			"status":  status,
+			"message": errorCode,
+			"details": []map[string]string{{
+				"@type":     "type.googleapis.com/google.firebase.fcm.v1.FcmError",
+				"errorCode": errorCode,
+			}},
+		},
+	}
+}
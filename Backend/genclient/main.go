@@ -0,0 +1,47 @@
+// Command genclient regenerates the typed client in client/ from the
+// committed OpenAPI document.
+//
+//	go run . openapi > openapi.json    # refresh the spec from the routes
+//	go run ./genclient                  # regenerate client/client_gen.go
+//	go run ./genclient -check           # fail if client_gen.go is stale
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+
+	"attendance-system/openapigen"
+)
+
+func main() {
+	specPath := flag.String("spec", "openapi.json", "OpenAPI document to read")
+	outPath := flag.String("out", "client/client_gen.go", "Go file to write")
+	check := flag.Bool("check", false, "Only report whether the output file is up to date")
+	flag.Parse()
+
+	spec, err := os.ReadFile(*specPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "genclient: %v\n", err)
+		os.Exit(1)
+	}
+	code, err := openapigen.Generate(spec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "genclient: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *check {
+		current, err := os.ReadFile(*outPath)
+		if err != nil || !bytes.Equal(current, code) {
+			fmt.Fprintf(os.Stderr, "genclient: %s is out of date; run go run ./genclient\n", *outPath)
+			os.Exit(1)
+		}
+		return
+	}
+	if err := os.WriteFile(*outPath, code, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "genclient: %v\n", err)
+		os.Exit(1)
+	}
+}
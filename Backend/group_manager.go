@@ -1,15 +1,19 @@
 package main
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
 	"sync"
 	"time"
+
+	"attendance-system/apierror"
 )
 
 // Groups cache for performance optimization
@@ -19,8 +23,6 @@ var groupsCache struct {
 	mu         sync.RWMutex
 }
 
-const groupsCacheDuration = 5 * time.Minute // Extended cache for better performance
-
 // GroupData holds all state for a specific group
 type GroupData struct {
 	ID                string
@@ -71,6 +73,7 @@ func (gm *GroupManager) GetOrCreateGroup(groupID string) *GroupData {
 
 	group = &GroupData{
 		ID:                groupID,
+		ThresholdMeters:   config.DefaultThresholdMeters,
 		SubmittedStudents: make(map[string]bool),
 		StudentLocations:  make(map[string]StudentLocation),
 		WindowActive:      false,
@@ -87,6 +90,17 @@ func (gm *GroupManager) GetGroup(groupID string) (*GroupData, bool) {
 	return group, exists
 }
 
+// forEachGroup calls fn for every group with the group's read lock held
+func (gm *GroupManager) forEachGroup(fn func(group *GroupData)) {
+	gm.mu.RLock()
+	defer gm.mu.RUnlock()
+	for _, group := range gm.groups {
+		group.mu.RLock()
+		fn(group)
+		group.mu.RUnlock()
+	}
+}
+
 // DeleteGroup removes a group (cleanup)
 func (gm *GroupManager) DeleteGroup(groupID string) {
 	gm.mu.Lock()
@@ -113,44 +127,23 @@ func getGroupID(r *http.Request) string {
 
 // Handler: POST /api/create-group
 func createGroupHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Method not allowed",
-		})
-		return
-	}
+	w.Header().Set("Content-Type", "application/json")
 
 	if err := r.ParseForm(); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Failed to parse form",
-		})
+		writeError(w, r, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
 
 	groupName := r.FormValue("name")
 	adminID := r.FormValue("admin_id")
 
-	fmt.Printf("DEBUG: Creating group - name: %s, admin_id: %s\n", groupName, adminID)
-
 	if groupName == "" || adminID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Group name and admin_id are required",
-		})
+		writeError(w, r, http.StatusBadRequest, "Group name and admin_id are required")
 		return
 	}
 
 	// Create group in database
-	url := fmt.Sprintf("%s/rest/v1/groups", config.SupabaseURL)
+	url := from("groups").URL()
 	groupData := map[string]interface{}{
 		"name":     groupName,
 		"admin_id": adminID,
@@ -158,71 +151,44 @@ func createGroupHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	jsonData, _ := json.Marshal(groupData)
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(jsonData)))
+	req, err := newSupabaseRequest(r.Context(), "POST", url, strings.NewReader(string(jsonData)))
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Failed to create request",
-		})
+		writeError(w, r, http.StatusInternalServerError, "Failed to create request")
 		return
 	}
 
-	req.Header.Set("apikey", config.SupabaseAnonKey)
-	req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Prefer", "return=representation")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := supabaseClient.Do(req)
 	if err != nil {
-		fmt.Printf("DEBUG: Database request error: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Database connection error",
-		})
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Database connection error", err))
 		return
 	}
 	defer resp.Body.Close()
 
-	fmt.Printf("DEBUG: Supabase response status: %d\n", resp.StatusCode)
-
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		fmt.Printf("DEBUG: Supabase error response: %s\n", string(bodyBytes))
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":   "Failed to create group",
-			"details": string(bodyBytes),
-		})
+		respondError(w, r, apierror.Persistence("Failed to create group", fmt.Errorf("supabase status %d: %s", resp.StatusCode, bodyBytes)))
 		return
 	}
 
 	bodyBytes, _ := io.ReadAll(resp.Body)
-	fmt.Printf("DEBUG: Supabase response body: %s\n", string(bodyBytes))
 	
 	var createdGroup []struct {
 		ID   string `json:"id"`
 		Name string `json:"name"`
 	}
 	if err := json.Unmarshal(bodyBytes, &createdGroup); err != nil {
-		fmt.Printf("DEBUG: JSON decode error: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":   "Failed to parse created group",
-			"details": err.Error(),
-		})
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to parse created group", err))
 		return
 	}
 	
 	if len(createdGroup) == 0 {
-		fmt.Printf("DEBUG: No group returned from Supabase\n")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "No group data returned from database",
-		})
+		writeError(w, r, http.StatusInternalServerError, "No group data returned from database")
 		return
 	}
 	
-	fmt.Printf("DEBUG: Created group - ID: %s, Name: %s\n", createdGroup[0].ID, createdGroup[0].Name)
+	slog.InfoContext(r.Context(), "group created", "group_id", createdGroup[0].ID, "name", createdGroup[0].Name, "admin_id", adminID)
 
 	// Initialize in-memory group data with proper metadata
 	group := groupManager.GetOrCreateGroup(createdGroup[0].ID)
@@ -230,7 +196,6 @@ func createGroupHandler(w http.ResponseWriter, r *http.Request) {
 	group.Name = createdGroup[0].Name
 	group.AdminID = adminID
 	group.mu.Unlock()
-	fmt.Printf("DEBUG: createGroupHandler - Initialized group in manager: ID=%s, Name=%s, AdminID=%s\n", createdGroup[0].ID, createdGroup[0].Name, adminID)
 
 	// Invalidate groups cache for this admin to ensure fresh data on next fetch
 	groupsCache.mu.Lock()
@@ -252,30 +217,17 @@ func createGroupHandler(w http.ResponseWriter, r *http.Request) {
 		},
 	}); err != nil {
 		// If encoding fails, log it but response might already be sent
-		fmt.Printf("Error encoding response: %v\n", err)
+		slog.WarnContext(r.Context(), "failed to encode response", "error", err)
 	}
 }
 
 // Handler: GET /api/get-my-groups?admin_id=xxx
 func getMyGroupsHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+	w.Header().Set("Content-Type", "application/json")
 
 	adminID := r.URL.Query().Get("admin_id")
 	if adminID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "admin_id is required",
-		})
+		writeError(w, r, http.StatusBadRequest, "admin_id is required")
 		return
 	}
 
@@ -298,8 +250,9 @@ func getMyGroupsHandler(w http.ResponseWriter, r *http.Request) {
 		groupsCache.mu.RLock()
 		if cachedGroups, exists := groupsCache.data[adminID]; exists {
 			if timestamp, hasTimestamp := groupsCache.timestamps[adminID]; hasTimestamp {
-				if time.Since(timestamp) < groupsCacheDuration {
+				if time.Since(timestamp) < config.GroupsCacheTTL {
 					groupsCache.mu.RUnlock()
+					cacheLookups.Inc("groups", "hit")
 					// Return cached data
 					w.Header().Set("Content-Type", "application/json")
 					w.Header().Set("X-Cache", "HIT")
@@ -312,37 +265,32 @@ func getMyGroupsHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		groupsCache.mu.RUnlock()
+		cacheLookups.Inc("groups", "miss")
 	}
 
 	// Query groups from database
-	url := fmt.Sprintf("%s/rest/v1/groups?admin_id=eq.%s&order=created_at.desc&select=id,name,status,created_at", config.SupabaseURL, adminID)
-	req, err := http.NewRequest("GET", url, nil)
+	url := from("groups").Eq("admin_id", adminID).Order("created_at.desc").Select("id,name,status,created_at").URL()
+	req, err := newSupabaseRequest(r.Context(), "GET", url, nil)
 	if err != nil {
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Failed to create request")
 		return
 	}
 
-	req.Header.Set("apikey", config.SupabaseAnonKey)
-	req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := supabaseClient.Do(req)
 	if err != nil {
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Database connection error")
 		return
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Failed to fetch groups",
-		})
+		writeError(w, r, http.StatusInternalServerError, "Failed to fetch groups")
 		return
 	}
 
 	var groups []map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&groups); err != nil {
-		http.Error(w, "Failed to parse response", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Failed to parse response")
 		return
 	}
 
@@ -366,26 +314,10 @@ func getMyGroupsHandler(w http.ResponseWriter, r *http.Request) {
 
 // Handler: POST /api/add-students-to-group
 func addStudentsToGroupHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Method not allowed",
-		})
-		return
-	}
+	w.Header().Set("Content-Type", "application/json")
 
 	if err := r.ParseForm(); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Failed to parse form",
-		})
+		writeError(w, r, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
 
@@ -393,10 +325,7 @@ func addStudentsToGroupHandler(w http.ResponseWriter, r *http.Request) {
 	studentIDs := r.FormValue("student_ids") // Comma-separated list
 
 	if groupID == "" || studentIDs == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "group_id and student_ids are required",
-		})
+		writeError(w, r, http.StatusBadRequest, "group_id and student_ids are required")
 		return
 	}
 
@@ -405,9 +334,7 @@ func addStudentsToGroupHandler(w http.ResponseWriter, r *http.Request) {
 	var insertData []map[string]string
 	var failedIDs []string
 	
-	fmt.Printf("DEBUG: addStudentsToGroup - Group ID: %s\n", groupID)
-	fmt.Printf("DEBUG: Received student IDs string: %s\n", studentIDs)
-	fmt.Printf("DEBUG: Parsed %d student IDs\n", len(ids))
+	slog.DebugContext(r.Context(), "adding students to group", "group_id", groupID, "count", len(ids))
 	
 	// Batch lookup: Get all student UUIDs in one query for better performance
 	// Build OR query: student_id=in.(ST001,ST002,ST003,...)
@@ -420,21 +347,16 @@ func addStudentsToGroupHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	
 	if len(studentIDList) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "No valid student IDs provided",
-		})
+		writeError(w, r, http.StatusBadRequest, "No valid student IDs provided")
 		return
 	}
 	
 	// Batch lookup all students at once
-	studentUUIDMap := getStudentUUIDsByIDs(studentIDList)
-	fmt.Printf("DEBUG: Found UUIDs for %d out of %d students\n", len(studentUUIDMap), len(studentIDList))
+	studentUUIDMap := getStudentUUIDsByIDs(r.Context(), studentIDList)
 	
 	for _, id := range studentIDList {
 		studentUUID, found := studentUUIDMap[id]
 		if !found || studentUUID == "" {
-			fmt.Printf("ERROR: Failed to find UUID for student_id: %s\n", id)
 			failedIDs = append(failedIDs, id)
 			continue
 		}
@@ -445,48 +367,32 @@ func addStudentsToGroupHandler(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	fmt.Printf("DEBUG: Successfully converted %d students, failed: %d\n", len(insertData), len(failedIDs))
+	slog.DebugContext(r.Context(), "resolved student IDs", "group_id", groupID, "found", len(insertData), "missing", len(failedIDs))
 
 	if len(failedIDs) > 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":   "Some student IDs not found",
-			"details": fmt.Sprintf("Failed to find UUIDs for: %s", strings.Join(failedIDs, ", ")),
-		})
+		writeError(w, r, http.StatusBadRequest, "Some student IDs not found: "+strings.Join(failedIDs, ", "))
 		return
 	}
 
 	if len(insertData) == 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "No valid student IDs provided",
-		})
+		writeError(w, r, http.StatusBadRequest, "No valid student IDs provided")
 		return
 	}
 
 	// Insert into group_students table
-	url := fmt.Sprintf("%s/rest/v1/group_students", config.SupabaseURL)
+	url := from("group_students").URL()
 	jsonData, _ := json.Marshal(insertData)
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(jsonData)))
+	req, err := newSupabaseRequest(r.Context(), "POST", url, strings.NewReader(string(jsonData)))
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Failed to create request",
-		})
+		writeError(w, r, http.StatusInternalServerError, "Failed to create request")
 		return
 	}
 
-	req.Header.Set("apikey", config.SupabaseAnonKey)
-	req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Prefer", "resolution=merge-duplicates")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := supabaseClient.Do(req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Database connection error",
-		})
+		writeError(w, r, http.StatusInternalServerError, "Database connection error")
 		return
 	}
 	defer resp.Body.Close()
@@ -499,30 +405,13 @@ func addStudentsToGroupHandler(w http.ResponseWriter, r *http.Request) {
 		})
 	} else {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":   "Failed to add students",
-			"details": string(bodyBytes),
-		})
+		respondError(w, r, apierror.Persistence("Failed to add students", fmt.Errorf("supabase status %d: %s", resp.StatusCode, bodyBytes)))
 	}
 }
 
 // Handler: DELETE /api/delete-group
 func deleteGroupHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if r.Method != http.MethodDelete && r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Method not allowed",
-		})
-		return
-	}
+	w.Header().Set("Content-Type", "application/json")
 
 	// Support both DELETE and POST (for form data)
 	var groupID string
@@ -530,43 +419,28 @@ func deleteGroupHandler(w http.ResponseWriter, r *http.Request) {
 		groupID = r.URL.Query().Get("group_id")
 	} else {
 		if err := r.ParseForm(); err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error": "Failed to parse form",
-			})
+			writeError(w, r, http.StatusBadRequest, "Failed to parse form")
 			return
 		}
 		groupID = r.FormValue("group_id")
 	}
 
 	if groupID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "group_id is required",
-		})
+		writeError(w, r, http.StatusBadRequest, "group_id is required")
 		return
 	}
 
 	// Delete from database (CASCADE will handle related records)
-	url := fmt.Sprintf("%s/rest/v1/groups?id=eq.%s", config.SupabaseURL, groupID)
-	req, err := http.NewRequest("DELETE", url, nil)
+	url := from("groups").Eq("id", groupID).URL()
+	req, err := newSupabaseRequest(r.Context(), "DELETE", url, nil)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Failed to create request",
-		})
+		writeError(w, r, http.StatusInternalServerError, "Failed to create request")
 		return
 	}
 
-	req.Header.Set("apikey", config.SupabaseAnonKey)
-	req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := supabaseClient.Do(req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Database connection error",
-		})
+		writeError(w, r, http.StatusInternalServerError, "Database connection error")
 		return
 	}
 	defer resp.Body.Close()
@@ -595,66 +469,43 @@ func deleteGroupHandler(w http.ResponseWriter, r *http.Request) {
 		})
 	} else {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":   "Failed to delete group",
-			"details": string(bodyBytes),
-		})
+		respondError(w, r, apierror.Persistence("Failed to delete group", fmt.Errorf("supabase status %d: %s", resp.StatusCode, bodyBytes)))
 	}
 }
 
 // Handler: GET /api/get-group-students?group_id=xxx
 func getGroupStudentsHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+	w.Header().Set("Content-Type", "application/json")
 
 	groupID := r.URL.Query().Get("group_id")
 	if groupID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "group_id is required",
-		})
+		writeError(w, r, http.StatusBadRequest, "group_id is required")
 		return
 	}
 
 	// Query students in this group
-	url := fmt.Sprintf("%s/rest/v1/group_students?group_id=eq.%s&select=student_id,students(id,student_id,student_name)", config.SupabaseURL, groupID)
-	req, err := http.NewRequest("GET", url, nil)
+	url := from("group_students").Eq("group_id", groupID).Select("student_id,students(id,student_id,student_name)").URL()
+	req, err := newSupabaseRequest(r.Context(), "GET", url, nil)
 	if err != nil {
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Failed to create request")
 		return
 	}
 
-	req.Header.Set("apikey", config.SupabaseAnonKey)
-	req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := supabaseClient.Do(req)
 	if err != nil {
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Database connection error")
 		return
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Failed to fetch group students",
-		})
+		writeError(w, r, http.StatusInternalServerError, "Failed to fetch group students")
 		return
 	}
 
 	var groupStudents []map[string]interface{}
 	if err := json.NewDecoder(resp.Body).Decode(&groupStudents); err != nil {
-		http.Error(w, "Failed to parse response", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Failed to parse response")
 		return
 	}
 
@@ -675,36 +526,32 @@ func getGroupStudentsHandler(w http.ResponseWriter, r *http.Request) {
 
 // Helper function to get student UUID by student_id
 // Batch lookup: Get UUIDs for multiple student IDs at once (much faster)
-func getStudentUUIDsByIDs(studentIDs []string) map[string]string {
+func getStudentUUIDsByIDs(ctx context.Context, studentIDs []string) map[string]string {
 	result := make(map[string]string)
 	if len(studentIDs) == 0 {
 		return result
 	}
 	
-	// Build OR query using Supabase's `in` operator
-	// Format: student_id=in.(ST001,ST002,ST003)
-	idsParam := strings.Join(studentIDs, ",")
-	url := fmt.Sprintf("%s/rest/v1/students?student_id=in.(%s)&select=id,student_id", config.SupabaseURL, idsParam)
+	// One query using Supabase's `in` operator
+	// Format: student_id=in.("ST001","ST002","ST003")
+	url := from("students").In("student_id", studentIDs).Select("id,student_id").URL()
 	
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := newSupabaseRequest(ctx, "GET", url, nil)
 	if err != nil {
-		fmt.Printf("ERROR: getStudentUUIDsByIDs - Failed to create request: %v\n", err)
+		slog.ErrorContext(ctx, "failed to look up students", "error", err)
 		return result
 	}
 
-	req.Header.Set("apikey", config.SupabaseAnonKey)
-	req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := supabaseClient.Do(req)
 	if err != nil {
-		fmt.Printf("ERROR: getStudentUUIDsByIDs - Database connection error: %v\n", err)
+		slog.ErrorContext(ctx, "failed to look up students", "error", err)
 		return result
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		fmt.Printf("ERROR: getStudentUUIDsByIDs - Supabase returned status %d, body: %s\n", resp.StatusCode, string(bodyBytes))
+		slog.ErrorContext(ctx, "failed to look up students", "status", resp.StatusCode, "body", string(bodyBytes))
 		return result
 	}
 
@@ -713,22 +560,20 @@ func getStudentUUIDsByIDs(studentIDs []string) map[string]string {
 		StudentID string `json:"student_id"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&students); err != nil {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		fmt.Printf("ERROR: getStudentUUIDsByIDs - Failed to parse response: %v, body: %s\n", err, string(bodyBytes))
+		slog.ErrorContext(ctx, "failed to parse students", "error", err)
 		return result
 	}
 
 	for _, student := range students {
 		result[student.StudentID] = student.ID
-		fmt.Printf("DEBUG: Mapped student_id %s -> UUID %s\n", student.StudentID, student.ID)
 	}
 	
-	fmt.Printf("DEBUG: getStudentUUIDsByIDs - Found %d out of %d students\n", len(result), len(studentIDs))
+	slog.DebugContext(ctx, "looked up students", "found", len(result), "requested", len(studentIDs))
 	return result
 }
 
 // Single lookup (kept for backward compatibility, but use batch version when possible)
-func getStudentUUIDByID(studentID string) string {
-	result := getStudentUUIDsByIDs([]string{studentID})
+func getStudentUUIDByID(ctx context.Context, studentID string) string {
+	result := getStudentUUIDsByIDs(ctx, []string{studentID})
 	return result[studentID]
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultInboxPageSize = 20
+	maxInboxPageSize     = 100
+)
+
+// inboxSelect embeds each recipient row's message so the inbox is one query;
+// !inner lets filters on broadcast_messages drop recipient rows
+const inboxSelect = "id,is_read,priority_rank,created_at,rendered_title,rendered_message," +
+	"broadcast_messages!inner(id,title,message,created_at,edited_at,group_id,priority,expires_at,admins(username)," +
+	"message_attachments(id,file_name,content_type,size_bytes))"
+
+type inboxRow struct {
+	ID                string  `json:"id"`
+	IsRead            bool    `json:"is_read"`
+	PriorityRank      int     `json:"priority_rank"`
+	CreatedAt         string  `json:"created_at"`
+	RenderedTitle     *string `json:"rendered_title"`
+	RenderedMessage   *string `json:"rendered_message"`
+	BroadcastMessages struct {
+		ID        string  `json:"id"`
+		Title     string  `json:"title"`
+		Message   string  `json:"message"`
+		CreatedAt string  `json:"created_at"`
+		EditedAt  *string `json:"edited_at"`
+		GroupID   *string `json:"group_id"`
+		Priority  string  `json:"priority"`
+		ExpiresAt *string `json:"expires_at"`
+		Admins    *struct {
+			Username string `json:"username"`
+		} `json:"admins"`
+		MessageAttachments []json.RawMessage `json:"message_attachments"`
+	} `json:"broadcast_messages"`
+}
+
+// unexpired is the or= matching messages that haven't expired
+func unexpired() []Condition {
+	return []Condition{
+		whereIs("expires_at", IsNull),
+		where("expires_at", OpGt, time.Now().UTC().Format(time.RFC3339)),
+	}
+}
+
+// inboxCursor is the sort key of the last row on a page. The inbox is
+// ordered by (priority_rank asc, created_at desc, id desc).
+type inboxCursor struct {
+	Rank      int    `json:"r"`
+	CreatedAt string `json:"t"`
+	ID        string `json:"i"`
+}
+
+func (c inboxCursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeInboxCursor(s string) (inboxCursor, error) {
+	var c inboxCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, err
+	}
+	if c.CreatedAt == "" || c.ID == "" {
+		return c, errors.New("incomplete cursor")
+	}
+	return c, nil
+}
+
+// filter is the or= selecting rows that sort after the cursor
+func (c inboxCursor) filter() []Condition {
+	rank := strconv.Itoa(c.Rank)
+	return []Condition{
+		where("priority_rank", OpGt, rank),
+		and(where("priority_rank", OpEq, rank), where("created_at", OpLt, c.CreatedAt)),
+		and(where("priority_rank", OpEq, rank), where("created_at", OpEq, c.CreatedAt), where("id", OpLt, c.ID)),
+	}
+}
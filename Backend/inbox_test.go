@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestInboxCursorRoundTrip(t *testing.T) {
+	want := inboxCursor{Rank: 1, CreatedAt: "2026-01-05T08:00:00+00:00", ID: "m1"}
+	got, err := decodeInboxCursor(want.encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeInboxCursorRejects(t *testing.T) {
+	for _, s := range []string{
+		"not base64!",
+		base64.RawURLEncoding.EncodeToString([]byte("not json")),
+		inboxCursor{Rank: 2, ID: "m1"}.encode(),
+		inboxCursor{Rank: 2, CreatedAt: "2026-01-05T08:00:00"}.encode(),
+	} {
+		if _, err := decodeInboxCursor(s); err == nil {
+			t.Errorf("decodeInboxCursor(%q) accepted", s)
+		}
+	}
+}
+
+// fakeInbox serves student ST001 and three inbox rows, recording the
+// queries made for them
+type fakeInbox struct {
+	mu      sync.Mutex
+	queries []url.Values
+}
+
+func (f *fakeInbox) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/rest/v1/students":
+		json.NewEncoder(w).Encode([]map[string]string{{"id": "u1", "student_id": "ST001"}})
+	case "/rest/v1/message_recipients":
+		f.mu.Lock()
+		f.queries = append(f.queries, r.URL.Query())
+		f.mu.Unlock()
+		row := func(id string, rank int, createdAt string) map[string]interface{} {
+			return map[string]interface{}{
+				"id": id, "is_read": false, "priority_rank": rank, "created_at": createdAt,
+				"broadcast_messages": map[string]interface{}{"id": "m-" + id, "title": "Hi", "message": "Hello", "created_at": createdAt},
+			}
+		}
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			row("r3", 1, "2026-01-04T08:00:00+00:00"),
+			row("r4", 2, "2026-01-04T07:00:00+00:00"),
+			row("r5", 2, "2026-01-03T07:00:00+00:00"),
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestGetMessagesPage(t *testing.T) {
+	fake := &fakeInbox{}
+	useSupabase(t, fake)
+
+	cursor := inboxCursor{Rank: 1, CreatedAt: "2026-01-05T08:00:00+00:00", ID: "r2"}.encode()
+	req := httptest.NewRequest(http.MethodGet, "/api/get-messages?student_id=ST001&is_read=false&limit=2&cursor="+cursor, nil)
+	rec := httptest.NewRecorder()
+	getMessagesHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	if len(fake.queries) != 1 {
+		t.Fatalf("%d inbox queries, want 1", len(fake.queries))
+	}
+	query := fake.queries[0]
+	for param, want := range map[string]string{
+		"student_id":                     "eq.u1",
+		"is_read":                        "eq.false",
+		"select":                         inboxSelect,
+		"order":                          "priority_rank.asc,created_at.desc,id.desc",
+		"limit":                          "3",
+		"broadcast_messages.recalled_at": "is.null",
+		"or": `(priority_rank.gt."1",and(priority_rank.eq."1",created_at.lt."2026-01-05T08:00:00+00:00"),` +
+			`and(priority_rank.eq."1",created_at.eq."2026-01-05T08:00:00+00:00",id.lt."r2"))`,
+	} {
+		if got := query.Get(param); got != want {
+			t.Errorf("%s=%q, want %q", param, got, want)
+		}
+	}
+	if query.Get("broadcast_messages.or") == "" {
+		t.Error("expired messages aren't filtered out")
+	}
+
+	var page struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+		Count      int    `json:"count"`
+		HasMore    bool   `json:"has_more"`
+		NextCursor string `json:"next_cursor"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Count != 2 || !page.HasMore || page.Messages[1].ID != "m-r4" {
+		t.Errorf("got %d messages (has_more %v): %+v; want m-r3 and m-r4 with more", page.Count, page.HasMore, page.Messages)
+	}
+	next, err := decodeInboxCursor(page.NextCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (inboxCursor{Rank: 2, CreatedAt: "2026-01-04T07:00:00+00:00", ID: "r4"}); next != want {
+		t.Errorf("next cursor %+v, want the last row's %+v", next, want)
+	}
+}
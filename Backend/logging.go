@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"unicode"
+)
+
+// redactedKeys are log attributes that carry a position, alone or as the
+// last word of a key (location_lat, AdminLat). Attendance
+// coordinates place a student at a time, so they never reach the logs.
+var redactedKeys = map[string]bool{
+	"lat":       true,
+	"lon":       true,
+	"latitude":  true,
+	"longitude": true,
+	"location":  true,
+	"locations": true,
+}
+
+// redactedKey reports whether the last word of a snake_case or CamelCase
+// key names a position
+func redactedKey(key string) bool {
+	for i := len(key) - 1; i > 0; i-- {
+		if key[i] == '_' {
+			key = key[i+1:]
+			break
+		}
+		if unicode.IsUpper(rune(key[i])) && unicode.IsLower(rune(key[i-1])) {
+			key = key[i:]
+			break
+		}
+	}
+	return redactedKeys[strings.ToLower(key)]
+}
+
+// setupLogging installs the default slog logger. level is debug, info,
+// warn or error; format is text or json (for the log aggregator).
+func setupLogging(w io.Writer, level, format string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid LOG_LEVEL %q", level)
+	}
+	options := &slog.HandlerOptions{Level: lvl, ReplaceAttr: redactAttr}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", "text":
+		handler = slog.NewTextHandler(w, options)
+	case "json":
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return fmt.Errorf("invalid LOG_FORMAT %q (want text or json)", format)
+	}
+	slog.SetDefault(slog.New(contextHandler{handler}))
+	return nil
+}
+
+// redactAttr hides positions, whether logged under a position key or as
+// StudentLocation collections (single StudentLocations log without theirs)
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if redactedKey(a.Key) {
+		return slog.String(a.Key, "[redacted]")
+	}
+	if a.Value.Kind() == slog.KindAny {
+		switch a.Value.Any().(type) {
+		case []StudentLocation, map[string]StudentLocation:
+			return slog.String(a.Key, "[redacted]")
+		}
+	}
+	return a
+}
+
+// contextHandler adds the request ID of the context to each record, so
+// slog.InfoContext(r.Context(), ...) lines can be matched to a request
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := requestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLoggingRedactsCoordinates(t *testing.T) {
+	logger := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(logger) })
+	var out bytes.Buffer
+	if err := setupLogging(&out, "debug", "json"); err != nil {
+		t.Fatal(err)
+	}
+
+	loc := StudentLocation{StudentID: "ST001", StudentName: "Ann", Latitude: 12.9716, Longitude: 77.5946, Distance: 12, Status: "Present"}
+	slog.Info("group row", "group_id", "g1", "location_lat", 12.9716, "location_lon", 77.5946, "threshold_meters", 50)
+	slog.Info("submission", "student", loc)
+	slog.Info("window", slog.Group("center", "AdminLat", 12.9716, "admin_longitude", 77.5946))
+	slog.Info("window closed", "submitted", []StudentLocation{loc}, "by_student", map[string]StudentLocation{"ST001": loc})
+	slog.With("student", loc).Info("attributes added up front")
+
+	logged := out.String()
+	for _, coordinate := range []string{"12.97", "77.59"} {
+		if strings.Contains(logged, coordinate) {
+			t.Errorf("coordinate %s logged:\n%s", coordinate, logged)
+		}
+	}
+	for _, kept := range []string{`"group_id":"g1"`, `"threshold_meters":50`, `"student_id":"ST001"`} {
+		if !strings.Contains(logged, kept) {
+			t.Errorf("%s missing from:\n%s", kept, logged)
+		}
+	}
+}
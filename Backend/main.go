@@ -1,17 +1,26 @@
 package main
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"log/slog"
 	"math"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+
+	"attendance-system/apierror"
 )
 
 type StudentLocation struct {
@@ -24,6 +33,15 @@ type StudentLocation struct {
 	Status      string  `json:"Status"`
 }
 
+// LogValue keeps the coordinates out of the logs
+func (loc StudentLocation) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("student_id", loc.StudentID),
+		slog.String("status", loc.Status),
+		slog.Float64("distance", loc.Distance),
+	)
+}
+
 var (
 	adminLat          float64
 	adminLon          float64
@@ -47,7 +65,13 @@ var studentCache struct {
 	mu        sync.RWMutex
 }
 
-const cacheDuration = 10 * time.Second
+// createAttendanceCSV creates an attendance CSV file in the CSV directory
+func createAttendanceCSV(filename string) (*os.File, error) {
+	if err := os.MkdirAll(config.CSVDir, 0755); err != nil {
+		return nil, err
+	}
+	return os.Create(filepath.Join(config.CSVDir, filename))
+}
 
 func parseFloat(s string) (float64, error) {
 	var result float64
@@ -56,14 +80,6 @@ func parseFloat(s string) (float64, error) {
 	return result, err
 }
 
-// enableCORS sets CORS headers for cross-origin requests
-func enableCORS(w http.ResponseWriter) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-	// Note: Content-Type for JSON should be set in each handler after enableCORS
-}
-
 // haversine calculates distance between two GPS coordinates in meters
 func haversine(lat1, lon1, lat2, lon2 float64) float64 {
 	const R = 6371000 // Earth radius in meters
@@ -82,20 +98,8 @@ func haversine(lat1, lon1, lat2, lon2 float64) float64 {
 }
 
 func setCenterHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
 
@@ -106,50 +110,54 @@ func setCenterHandler(w http.ResponseWriter, r *http.Request) {
 		groupID = "default"
 	}
 
-	group := groupManager.GetOrCreateGroup(groupID)
-	group.mu.Lock()
-	defer group.mu.Unlock()
-
 	// Parse form data
 	latStr := r.FormValue("lat")
 	lonStr := r.FormValue("lon")
 	sessionName := r.FormValue("session_name")
 	thresholdStr := r.FormValue("threshold")
 
-	var err error
-	group.AdminLat, err = parseFloat(latStr)
+	lat, err := parseFloat(latStr)
 	if err != nil {
-		http.Error(w, "Invalid latitude", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid latitude")
 		return
 	}
-	group.AdminLon, err = parseFloat(lonStr)
+	lon, err := parseFloat(lonStr)
 	if err != nil {
-		http.Error(w, "Invalid longitude", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid longitude")
 		return
 	}
-	group.ThresholdMeters, err = parseFloat(thresholdStr)
-	if err != nil {
-		http.Error(w, "Invalid threshold", http.StatusBadRequest)
-		return
-	}
-	if sessionName != "" {
-		group.Name = sessionName
+	threshold := config.DefaultThresholdMeters
+	if thresholdStr != "" {
+		threshold, err = parseFloat(thresholdStr)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid threshold")
+			return
+		}
 	}
 
-	// Update group in database
+	// Update group in database first, so a failed write leaves the group as it was
 	if groupID != "default" {
-		updateURL := fmt.Sprintf("%s/rest/v1/groups?id=eq.%s", config.SupabaseURL, groupID)
+		updateURL := from("groups").Eq("id", groupID).URL()
 		updateData := map[string]interface{}{
-			"location_lat":     group.AdminLat,
-			"location_lon":     group.AdminLon,
-			"threshold_meters": group.ThresholdMeters,
+			"location_lat":     lat,
+			"location_lon":     lon,
+			"threshold_meters": threshold,
 		}
-		jsonData, _ := json.Marshal(updateData)
-		req, _ := http.NewRequest("PATCH", updateURL, strings.NewReader(string(jsonData)))
-		req.Header.Set("apikey", config.SupabaseAnonKey)
-		req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-		req.Header.Set("Content-Type", "application/json")
-		http.DefaultClient.Do(req) // Fire and forget
+		if err := supabasePatch(r.Context(), updateURL, updateData); err != nil {
+			respondError(w, r, apierror.Persistence("Failed to save location", err))
+			return
+		}
+	}
+
+	group := groupManager.GetOrCreateGroup(groupID)
+	group.mu.Lock()
+	defer group.mu.Unlock()
+
+	group.AdminLat = lat
+	group.AdminLon = lon
+	group.ThresholdMeters = threshold
+	if sessionName != "" {
+		group.Name = sessionName
 	}
 
 	// Create CSV file
@@ -157,9 +165,9 @@ func setCenterHandler(w http.ResponseWriter, r *http.Request) {
 	filename := fmt.Sprintf("%s_%s.csv", group.Name, timestamp)
 
 	var fileErr error
-	group.CSVFile, fileErr = os.Create(filename)
+	group.CSVFile, fileErr = createAttendanceCSV(filename)
 	if fileErr != nil {
-		http.Error(w, "Failed to create CSV file", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Failed to create CSV file")
 		return
 	}
 
@@ -175,6 +183,7 @@ func setCenterHandler(w http.ResponseWriter, r *http.Request) {
 	group.CSVWriter.Flush()
 
 	// Return success response
+	w.Header().Set("Content-Type", "application/json")
 	response := map[string]interface{}{
 		"success": true,
 		"message": "Center set",
@@ -184,18 +193,6 @@ func setCenterHandler(w http.ResponseWriter, r *http.Request) {
 
 // Handler: POST /api/start-window
 func startWindowHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	// Get group_id (required for multi-group support)
 	groupID := getGroupID(r)
 	if groupID == "" {
@@ -203,127 +200,158 @@ func startWindowHandler(w http.ResponseWriter, r *http.Request) {
 		groupID = "default"
 	}
 
-	group := groupManager.GetOrCreateGroup(groupID)
-	group.mu.Lock()
-	defer group.mu.Unlock()
-
 	// Parse scope mode (group_only parameter)
 	groupOnlyStr := r.FormValue("group_only")
-	group.GroupOnly = groupOnlyStr == "true" // Default to false if not specified
-	
-	// Load group metadata from database if not already set (for newly created groups)
-	if group.Name == "" && groupID != "default" {
-		// Fetch group name and admin_id from database
-		groupURL := fmt.Sprintf("%s/rest/v1/groups?id=eq.%s&select=id,name,admin_id", config.SupabaseURL, groupID)
-		req, _ := http.NewRequest("GET", groupURL, nil)
-		req.Header.Set("apikey", config.SupabaseAnonKey)
-		req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-		
-		resp, err := http.DefaultClient.Do(req)
-		if err == nil {
-			defer resp.Body.Close()
-			var groups []struct {
-				ID      string `json:"id"`
-				Name    string `json:"name"`
-				AdminID string `json:"admin_id"`
-			}
-			if json.NewDecoder(resp.Body).Decode(&groups) == nil && len(groups) > 0 {
-				group.Name = groups[0].Name
-				group.AdminID = groups[0].AdminID
-				fmt.Printf("DEBUG: startWindowHandler - Loaded group metadata: Name=%s, AdminID=%s\n", group.Name, group.AdminID)
-			}
-		}
+	if err := openWindow(r.Context(), groupID, groupOnlyStr == "true"); err != nil { // Default to false if not specified
+		respondError(w, r, err)
+		return
 	}
 
-	// Start the window
-	group.WindowActive = true
-	group.WindowStartTime = time.Now()
-	group.WindowEndTime = time.Now().Add(10 * time.Minute)
-	group.SubmittedStudents = make(map[string]bool)           // Reset submissions
-	group.StudentLocations = make(map[string]StudentLocation) // Reset student locations
-	
-	// Create CSV file if it doesn't exist
-	if group.CSVFile == nil {
-		timestamp := time.Now().Format("20060102_150405")
-		groupName := group.Name
-		if groupName == "" {
-			groupName = "Group"
-		}
-		filename := fmt.Sprintf("%s_%s.csv", groupName, timestamp)
-		
-		var fileErr error
-		group.CSVFile, fileErr = os.Create(filename)
-		if fileErr != nil {
-			fmt.Printf("WARNING: Failed to create CSV file: %v\n", fileErr)
-		} else {
-			group.CSVWriter = csv.NewWriter(group.CSVFile)
-			// Write CSV headers
-			headers := []string{
-				"StudentName",
-				"Time",
-				"Distance(m)",
-			}
-			group.CSVWriter.Write(headers)
-			group.CSVWriter.Flush()
-			fmt.Printf("DEBUG: startWindowHandler - Created CSV file: %s\n", filename)
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"success": true,
+		"message": "Window opened",
+		"group_id": groupID,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// openWindow starts an attendance window of config.WindowDuration for a group; used by
+// startWindowHandler and by scheduled windows. The window status is stored
+// first; if that fails the window stays closed and the error is returned.
+func openWindow(ctx context.Context, groupID string, groupOnly bool) error {
+	group := groupManager.GetOrCreateGroup(groupID)
+
+	// Load group metadata from database if not already set (for newly created groups).
+	// Fetched before taking the group lock so a slow database doesn't hold up submissions.
+	group.mu.RLock()
+	needsMetadata := group.Name == "" && groupID != "default"
+	group.mu.RUnlock()
+	var groups []struct {
+		ID      string `json:"id"`
+		Name    string `json:"name"`
+		AdminID string `json:"admin_id"`
+	}
+	if needsMetadata {
+		groupURL := from("groups").Eq("id", groupID).Select("id,name,admin_id").URL()
+		if err := supabaseGetJSON(ctx, groupURL, &groups); err != nil {
+			slog.WarnContext(ctx, "failed to load group metadata", "group_id", groupID, "error", err)
 		}
 	}
-	
-	fmt.Printf("DEBUG: startWindowHandler - Started window for group %s (Name=%s, GroupOnly=%v, WindowActive=%v)\n", groupID, group.Name, group.GroupOnly, group.WindowActive)
-	
-	// Persist window status to database
+
+	// Persist window status to database, also without the group lock
+	startTime := time.Now()
+	endTime := startTime.Add(config.WindowDuration)
 	if groupID != "default" {
-		updateURL := fmt.Sprintf("%s/rest/v1/groups?id=eq.%s", config.SupabaseURL, groupID)
+		updateURL := from("groups").Eq("id", groupID).URL()
 		updateData := map[string]interface{}{
 			"status":            "active",
-			"window_start_time": group.WindowStartTime.Format("2006-01-02 15:04:05"),
-			"window_end_time":   group.WindowEndTime.Format("2006-01-02 15:04:05"),
+			"group_only":        groupOnly,
+			"window_start_time": startTime.Format(windowTimeLayout),
+			"window_end_time":   endTime.Format(windowTimeLayout),
 		}
-		jsonData, _ := json.Marshal(updateData)
-		req, _ := http.NewRequest("PATCH", updateURL, strings.NewReader(string(jsonData)))
-		req.Header.Set("apikey", config.SupabaseAnonKey)
-		req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-		req.Header.Set("Content-Type", "application/json")
-		http.DefaultClient.Do(req) // Fire and forget
-	}
-
-	// Start goroutine to auto-close after 10 minutes
-	go func(gID string) {
-		time.Sleep(10 * time.Minute)
-		if g, exists := groupManager.GetGroup(gID); exists {
-			g.mu.Lock()
-			g.WindowActive = false
-			if g.CSVWriter != nil {
-				g.CSVWriter.Flush()
-			}
-			g.mu.Unlock()
+		if err := supabasePatch(ctx, updateURL, updateData); err != nil {
+			return apierror.Persistence("Failed to open window", err)
 		}
-	}(groupID)
+	}
 
-	response := map[string]interface{}{
-		"success": true,
-		"message": "Window opened",
-		"group_id": groupID,
+	group.mu.Lock()
+	defer group.mu.Unlock()
+
+	if group.Name == "" && len(groups) > 0 {
+		group.Name = groups[0].Name
+		group.AdminID = groups[0].AdminID
+		slog.DebugContext(ctx, "loaded group metadata", "group_id", groupID, "name", group.Name, "admin_id", group.AdminID)
 	}
-	json.NewEncoder(w).Encode(response)
+
+	// Start the window
+	group.GroupOnly = groupOnly
+	group.WindowActive = true
+	group.WindowStartTime = startTime
+	group.WindowEndTime = endTime
+	group.SubmittedStudents = make(map[string]bool)           // Reset submissions
+	group.StudentLocations = make(map[string]StudentLocation) // Reset student locations
+	openWindowCSVLocked(ctx, group)
+
+	slog.InfoContext(ctx, "window opened", "group_id", groupID, "name", group.Name, "group_only", group.GroupOnly, "ends_at", group.WindowEndTime)
+	publishWindowOpened(group)
+	go announceWindowOpened(context.WithoutCancel(ctx), groupID, group.Name, group.AdminID, group.GroupOnly, group.WindowStartTime, group.WindowEndTime)
+	scheduleWindowReminder(groupID, group.WindowStartTime, group.WindowEndTime)
+	scheduleWindowExpiry(groupID, group.WindowEndTime)
+	return nil
 }
 
-// Handler: POST /api/close-window
-func closeWindowHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
+// openWindowCSVLocked creates the group's attendance CSV if it has none.
+// A CSV that can't be created is logged; attendance is still recorded in
+// the database. Caller must hold group.mu for writing.
+func openWindowCSVLocked(ctx context.Context, group *GroupData) {
+	if group.CSVFile != nil {
+		return
+	}
+	timestamp := time.Now().Format("20060102_150405")
+	groupName := group.Name
+	if groupName == "" {
+		groupName = "Group"
+	}
+	filename := fmt.Sprintf("%s_%s.csv", groupName, timestamp)
 
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+	var fileErr error
+	group.CSVFile, fileErr = createAttendanceCSV(filename)
+	if fileErr != nil {
+		slog.WarnContext(ctx, "failed to create CSV file", "group_id", group.ID, "error", fileErr)
 		return
 	}
+	group.CSVWriter = csv.NewWriter(group.CSVFile)
+	// Write CSV headers
+	headers := []string{
+		"StudentName",
+		"Time",
+		"Distance(m)",
+	}
+	group.CSVWriter.Write(headers)
+	group.CSVWriter.Flush()
+	slog.DebugContext(ctx, "created CSV file", "group_id", group.ID, "file", filename)
+}
 
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// scheduleWindowExpiry auto-closes the group's window once endTime passes
+func scheduleWindowExpiry(groupID string, endTime time.Time) {
+	time.AfterFunc(time.Until(endTime), func() {
+		if g, exists := groupManager.GetGroup(groupID); exists {
+			expireWindow(g)
+		}
+	})
+}
+
+// closeWindowLocked ends the group's window, records it in the window
+// metrics and publishes window_closed. It does nothing when the window is
+// already closed, so each window is reported once however it ends.
+// Caller must hold group.mu for writing.
+func closeWindowLocked(group *GroupData, reason string) {
+	if !group.WindowActive {
 		return
 	}
+	group.WindowActive = false
+	windowSubmissions.Observe(float64(len(group.SubmittedStudents)), reason)
+	publishWindowClosed(group, reason)
+	if group.CSVWriter != nil {
+		group.CSVWriter.Flush()
+	}
+}
 
+// expireWindow closes the group's window once its time is up. A window
+// reopened since the check was scheduled ends later and is left open.
+func expireWindow(group *GroupData) {
+	group.mu.Lock()
+	defer group.mu.Unlock()
+	if !time.Now().Before(group.WindowEndTime) {
+		closeWindowLocked(group, "expired")
+	}
+}
+
+// Handler: POST /api/close-window
+func closeWindowHandler(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
 
@@ -334,42 +362,29 @@ func closeWindowHandler(w http.ResponseWriter, r *http.Request) {
 
 	group, exists := groupManager.GetGroup(groupID)
 	if !exists {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Group not found",
-		})
+		writeError(w, r, http.StatusNotFound, "Group not found")
 		return
 	}
 
+	// Update database first, without the group lock; the window stays open if that fails
+	if groupID != "default" {
+		updateURL := from("groups").Eq("id", groupID).URL()
+		if err := supabasePatch(r.Context(), updateURL, map[string]interface{}{"status": "closed"}); err != nil {
+			respondError(w, r, apierror.Persistence("Failed to close window", err))
+			return
+		}
+	}
+
 	group.mu.Lock()
 	defer group.mu.Unlock()
 
-	group.WindowActive = false
-	
-	fmt.Printf("DEBUG: closeWindowHandler - Closed window for group %s\n", groupID)
-
-	if group.CSVWriter != nil {
-		group.CSVWriter.Flush()
-	}
+	closeWindowLocked(group, "closed")
+	slog.InfoContext(r.Context(), "window closed", "group_id", groupID)
 
 	if group.CSVFile != nil {
 		group.CSVFile.Close()
 	}
 
-	// Update database
-	if groupID != "default" {
-		updateURL := fmt.Sprintf("%s/rest/v1/groups?id=eq.%s", config.SupabaseURL, groupID)
-		updateData := map[string]interface{}{
-			"status": "closed",
-		}
-		jsonData, _ := json.Marshal(updateData)
-		req, _ := http.NewRequest("PATCH", updateURL, strings.NewReader(string(jsonData)))
-		req.Header.Set("apikey", config.SupabaseAnonKey)
-		req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-		req.Header.Set("Content-Type", "application/json")
-		http.DefaultClient.Do(req)
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 	response := map[string]interface{}{
 		"success":  true,
@@ -381,20 +396,10 @@ func closeWindowHandler(w http.ResponseWriter, r *http.Request) {
 
 // Handler: POST /api/submit-attendance
 func submitAttendanceHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+	w.Header().Set("Content-Type", "application/json")
 
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
 
@@ -404,66 +409,14 @@ func submitAttendanceHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	group, exists := groupManager.GetGroup(groupID)
-	if !exists {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Group not found",
-		})
-		return
+	if !exists && restoreGroupWindow(r.Context(), groupID) {
+		group, exists = groupManager.GetGroup(groupID)
 	}
-
-	group.mu.RLock()
-	windowActive := group.WindowActive
-	groupOnly := group.GroupOnly
-	group.mu.RUnlock()
-
-	if !windowActive {
-		w.WriteHeader(http.StatusForbidden)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Attendance window is closed",
-		})
+	if !exists {
+		writeError(w, r, http.StatusNotFound, "Group not found")
 		return
 	}
 
-	// Check if student is in group (if group_only mode)
-	if groupOnly && groupID != "default" {
-		studentID := r.FormValue("student_id")
-		studentUUID := getStudentUUIDByID(studentID)
-		
-		if studentUUID == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error": "Invalid student ID",
-			})
-			return
-		}
-		
-		// Check if student is in this group
-		checkURL := fmt.Sprintf("%s/rest/v1/group_students?group_id=eq.%s&student_id=eq.%s&select=id&limit=1", 
-			config.SupabaseURL, groupID, studentUUID)
-		req, _ := http.NewRequest("GET", checkURL, nil)
-		req.Header.Set("apikey", config.SupabaseAnonKey)
-		req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-		
-		resp, err := http.DefaultClient.Do(req)
-		if err == nil {
-			defer resp.Body.Close()
-			var results []map[string]interface{}
-			json.NewDecoder(resp.Body).Decode(&results)
-			
-			if len(results) == 0 {
-				w.WriteHeader(http.StatusForbidden)
-				json.NewEncoder(w).Encode(map[string]string{
-					"error": "You are not a member of this group. Attendance is restricted to group members only.",
-				})
-				return
-			}
-		}
-	}
-
-	group.mu.Lock()
-	defer group.mu.Unlock()
-
 	// Parse form data
 	studentName := r.FormValue("student_name")
 	studentID := r.FormValue("student_id")
@@ -473,36 +426,109 @@ func submitAttendanceHandler(w http.ResponseWriter, r *http.Request) {
 	// Parse coordinates
 	studentLat, err := parseFloat(latStr)
 	if err != nil {
-		http.Error(w, "Invalid latitude", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid latitude")
 		return
 	}
 
 	studentLon, err := parseFloat(lonStr)
 	if err != nil {
-		http.Error(w, "Invalid longitude", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid longitude")
 		return
 	}
 
-	// Check if already submitted
-	if group.SubmittedStudents[studentID] {
-		w.WriteHeader(http.StatusConflict)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Already submitted",
-		})
+	// Snapshot the window; the database calls below run without the group
+	// lock so a slow Supabase doesn't queue up the group's other submissions
+	group.mu.RLock()
+	windowActive := group.WindowActive
+	windowStart := group.WindowStartTime
+	groupOnly := group.GroupOnly
+	alreadySubmitted := group.SubmittedStudents[studentID]
+	adminLat, adminLon, threshold := group.AdminLat, group.AdminLon, group.ThresholdMeters
+	group.mu.RUnlock()
+
+	if !windowActive {
+		respondError(w, r, apierror.New(http.StatusForbidden, "Attendance window is closed").WithCode(apierror.WindowClosed))
 		return
 	}
+	if alreadySubmitted {
+		respondError(w, r, apierror.New(http.StatusConflict, "Already submitted").WithCode(apierror.AlreadySubmitted))
+		return
+	}
+
+	var studentUUID string
+	if groupID != "default" {
+		studentUUID = getStudentUUIDByID(r.Context(), studentID)
+		if studentUUID == "" {
+			if groupOnly {
+				writeError(w, r, http.StatusBadRequest, "Invalid student ID")
+			} else {
+				writeError(w, r, http.StatusNotFound, "Student not found")
+			}
+			return
+		}
+	}
+
+	// Check if student is in group (if group_only mode)
+	if groupOnly && groupID != "default" {
+		checkURL := from("group_students").Eq("group_id", groupID).Eq("student_id", studentUUID).
+			Select("id").Limit(1).URL()
+		var results []map[string]interface{}
+		if err := supabaseGetJSON(r.Context(), checkURL, &results); err != nil {
+			respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Database connection error", err))
+			return
+		}
+
+		if len(results) == 0 {
+			respondError(w, r, apierror.New(http.StatusForbidden, "You are not a member of this group. Attendance is restricted to group members only.").WithCode(apierror.NotGroupMember))
+			return
+		}
+	}
 
 	// Calculate distance
-	distance := haversine(group.AdminLat, group.AdminLon, studentLat, studentLon)
+	distance := haversine(adminLat, adminLon, studentLat, studentLon)
 
 	// Determine status
 	status := "Absent"
-	if distance <= group.ThresholdMeters {
+	if distance <= threshold {
 		status = "Present"
 	}
 
 	// Get current timestamp
-	timestamp := time.Now().Format("2006-01-02 15:04:05")
+	timestamp := time.Now().Format(windowTimeLayout)
+
+	// Store in database before anything else, so a failed write is reported
+	// and the student can submit again
+	if groupID != "default" {
+		attendanceData := map[string]interface{}{
+			"group_id":   groupID,
+			"student_id": studentUUID,
+			"status":     status,
+			"distance":   distance,
+			"latitude":   studentLat,
+			"longitude":  studentLon,
+			// Refreshed on every submission, so a restored window can tell
+			// its own submissions from earlier windows'
+			"submitted_at": timestamp,
+		}
+		if err := supabaseInsert(r.Context(), from("group_attendance").OnConflict("group_id", "student_id"), attendanceData, "resolution=merge-duplicates"); err != nil {
+			respondError(w, r, apierror.Persistence("Failed to record attendance", err))
+			return
+		}
+	}
+
+	group.mu.Lock()
+	defer group.mu.Unlock()
+
+	// The window closed, or a new one opened, while the write was in flight
+	if !group.WindowActive || !time.Now().Before(group.WindowEndTime) || !group.WindowStartTime.Equal(windowStart) {
+		respondError(w, r, apierror.New(http.StatusForbidden, "Attendance window is closed").WithCode(apierror.WindowClosed))
+		return
+	}
+	// A concurrent submission from the same student got here first
+	if group.SubmittedStudents[studentID] {
+		respondError(w, r, apierror.New(http.StatusConflict, "Already submitted").WithCode(apierror.AlreadySubmitted))
+		return
+	}
 
 	// Write to CSV (only student name, time, and distance)
 	if group.CSVWriter != nil {
@@ -516,7 +542,7 @@ func submitAttendanceHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Store student location
-	group.StudentLocations[studentID] = StudentLocation{
+	loc := StudentLocation{
 		StudentID:   studentID,
 		StudentName: studentName,
 		Latitude:    studentLat,
@@ -525,40 +551,19 @@ func submitAttendanceHandler(w http.ResponseWriter, r *http.Request) {
 		Timestamp:   timestamp,
 		Status:      status,
 	}
+	group.StudentLocations[studentID] = loc
+	publishAttendanceSubmitted(group, loc)
 
-	// Debug: Print stored location
-	fmt.Printf("DEBUG: Stored student location - %s (%s): %.6f, %.6f, %.0fm, %s\n",
-		studentName, studentID, studentLat, studentLon, distance, status)
-	fmt.Printf("DEBUG: Total student locations: %d\n", len(group.StudentLocations))
+	slog.DebugContext(r.Context(), "attendance recorded", "group_id", groupID, "student_id", studentID,
+		"status", status, "distance_m", math.Round(distance), "submissions", len(group.StudentLocations))
 
 	// Mark as submitted
 	group.SubmittedStudents[studentID] = true
+	attendanceSubmissions.Inc(status)
 
-	// Store in database for persistence
-	if groupID != "default" {
-		studentUUID := getStudentUUIDByID(studentID)
-		if studentUUID != "" {
-			attendanceURL := fmt.Sprintf("%s/rest/v1/group_attendance", config.SupabaseURL)
-			attendanceData := map[string]interface{}{
-				"group_id":   groupID,
-				"student_id": studentUUID,
-				"status":     status,
-				"distance":   distance,
-				"latitude":   studentLat,
-				"longitude":  studentLon,
-			}
-			jsonData, _ := json.Marshal(attendanceData)
-			req, _ := http.NewRequest("POST", attendanceURL, strings.NewReader(string(jsonData)))
-			req.Header.Set("apikey", config.SupabaseAnonKey)
-			req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-			req.Header.Set("Content-Type", "application/json")
-			req.Header.Set("Prefer", "resolution=merge-duplicates")
-			http.DefaultClient.Do(req) // Fire and forget
-		}
-	}
+	go confirmAttendance(context.WithoutCancel(r.Context()), groupID, group.AdminID, studentID, status, distance, group.WindowStartTime)
 
 	// Return success response
-	w.Header().Set("Content-Type", "application/json")
 	response := map[string]string{
 		"status":    status,
 		"distance":  fmt.Sprintf("%.0f", distance),
@@ -569,18 +574,6 @@ func submitAttendanceHandler(w http.ResponseWriter, r *http.Request) {
 
 // Handler: GET /api/download-csv
 func downloadCSVHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	// Get group_id (for multi-group support)
 	groupID := getGroupID(r)
 	if groupID == "" {
@@ -589,10 +582,7 @@ func downloadCSVHandler(w http.ResponseWriter, r *http.Request) {
 
 	group, exists := groupManager.GetGroup(groupID)
 	if !exists {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Group not found",
-		})
+		writeError(w, r, http.StatusNotFound, "Group not found")
 		return
 	}
 
@@ -606,12 +596,10 @@ func downloadCSVHandler(w http.ResponseWriter, r *http.Request) {
 		// Try to generate CSV from database if file doesn't exist
 		if groupID != "default" {
 			// Generate CSV from database attendance records
-			attendanceURL := fmt.Sprintf("%s/rest/v1/group_attendance?group_id=eq.%s&select=*,students(student_id,student_name)&order=submitted_at.asc", config.SupabaseURL, groupID)
-			req, _ := http.NewRequest("GET", attendanceURL, nil)
-			req.Header.Set("apikey", config.SupabaseAnonKey)
-			req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
+			attendanceURL := from("group_attendance").Eq("group_id", groupID).Select("*,students(student_id,student_name)").Order("submitted_at.asc").URL()
+			req, _ := newSupabaseRequest(r.Context(), "GET", attendanceURL, nil)
 			
-			resp, err := http.DefaultClient.Do(req)
+			resp, err := supabaseClient.Do(req)
 			if err == nil {
 				defer resp.Body.Close()
 				var records []map[string]interface{}
@@ -635,10 +623,7 @@ func downloadCSVHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "No attendance data",
-		})
+		writeError(w, r, http.StatusNotFound, "No attendance data")
 		return
 	}
 
@@ -653,7 +638,7 @@ func downloadCSVHandler(w http.ResponseWriter, r *http.Request) {
 	// Read file contents
 	fileBytes, err := os.ReadFile(filename)
 	if err != nil {
-		http.Error(w, "Failed to read file", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Failed to read file")
 		return
 	}
 
@@ -667,18 +652,6 @@ func downloadCSVHandler(w http.ResponseWriter, r *http.Request) {
 
 // Handler: GET /api/get-admin-location
 func getAdminLocationHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	// Get group_id (for multi-group support)
 	groupID := getGroupID(r)
 	if groupID == "" {
@@ -706,12 +679,10 @@ func getAdminLocationHandler(w http.ResponseWriter, r *http.Request) {
 
 	// If group doesn't exist or location not set, try to load from database
 	if groupID != "default" {
-		groupURL := fmt.Sprintf("%s/rest/v1/groups?id=eq.%s&select=location_lat,location_lon,threshold_meters,name", config.SupabaseURL, groupID)
-		req, _ := http.NewRequest("GET", groupURL, nil)
-		req.Header.Set("apikey", config.SupabaseAnonKey)
-		req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
+		groupURL := from("groups").Eq("id", groupID).Select("location_lat,location_lon,threshold_meters,name").URL()
+		req, _ := newSupabaseRequest(r.Context(), "GET", groupURL, nil)
 		
-		resp, err := http.DefaultClient.Do(req)
+		resp, err := supabaseClient.Do(req)
 		if err == nil {
 			defer resp.Body.Close()
 			var groups []struct {
@@ -756,15 +727,7 @@ func getAdminLocationHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Check if location is set
 	if adminLat == 0 && adminLon == 0 {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		response := map[string]string{
-			"error": "Admin location not set",
-		}
-		if err := json.NewEncoder(w).Encode(response); err != nil {
-			http.Error(w, "Failed to encode response", http.StatusInternalServerError)
-			return
-		}
+		writeError(w, r, http.StatusNotFound, "Admin location not set")
 		return
 	}
 
@@ -779,132 +742,42 @@ func getAdminLocationHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Failed to encode response")
 		return
 	}
 }
 
 // Handler: GET /api/get-window-status
 func getWindowStatusHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	// Check if student_id is provided (for student dashboard)
 	studentID := r.URL.Query().Get("student_id")
 	
 	// If student_id provided, find active windows they can access
 	if studentID != "" {
-		studentUUID := getStudentUUIDByID(studentID)
-		fmt.Printf("DEBUG: getWindowStatusHandler - studentID: %s, studentUUID: %s\n", studentID, studentUUID)
+		studentUUID := getStudentUUIDByID(r.Context(), studentID)
 		if studentUUID != "" {
 			// Find all groups this student belongs to
-			groupsURL := fmt.Sprintf("%s/rest/v1/group_students?student_id=eq.%s&select=group_id", config.SupabaseURL, studentUUID)
-			req, _ := http.NewRequest("GET", groupsURL, nil)
-			req.Header.Set("apikey", config.SupabaseAnonKey)
-			req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-			
-			resp, err := http.DefaultClient.Do(req)
-			if err == nil {
-				defer resp.Body.Close()
-				var groupMemberships []map[string]interface{}
-				if err := json.NewDecoder(resp.Body).Decode(&groupMemberships); err != nil {
-					fmt.Printf("DEBUG: getWindowStatusHandler - Failed to decode group memberships: %v\n", err)
-				}
-				fmt.Printf("DEBUG: getWindowStatusHandler - Found %d group memberships for student\n", len(groupMemberships))
-				
-				// Check all groups for active windows
-				var activeWindows []map[string]interface{}
-				
-				groupManager.mu.RLock()
-				fmt.Printf("DEBUG: getWindowStatusHandler - Total groups in manager: %d\n", len(groupManager.groups))
-				for _, membership := range groupMemberships {
-					gID, ok := membership["group_id"].(string)
-					if !ok {
-						fmt.Printf("DEBUG: getWindowStatusHandler - Invalid group_id in membership: %v\n", membership)
-						continue
-					}
-					fmt.Printf("DEBUG: getWindowStatusHandler - Checking group %s for active window\n", gID)
-					if group, exists := groupManager.groups[gID]; exists {
-						group.mu.RLock()
-						fmt.Printf("DEBUG: getWindowStatusHandler - Group %s exists, WindowActive: %v, GroupOnly: %v\n", gID, group.WindowActive, group.GroupOnly)
-						if group.WindowActive {
-							elapsed := time.Since(group.WindowStartTime)
-							remaining := 600 - int(elapsed.Seconds())
-							if remaining < 0 {
-								remaining = 0
-							}
-							fmt.Printf("DEBUG: getWindowStatusHandler - Found active window in group %s with %d seconds remaining\n", gID, remaining)
-							activeWindows = append(activeWindows, map[string]interface{}{
-								"group_id":          gID,
-								"group_name":        group.Name,
-								"group_only":        group.GroupOnly,
-								"remaining_seconds": remaining,
-							})
-						}
-						group.mu.RUnlock()
-					} else {
-						fmt.Printf("DEBUG: getWindowStatusHandler - Group %s not found in groupManager\n", gID)
-					}
-				}
-				
-				// Also check for "all students" windows (group_only = false)
-				for gID, group := range groupManager.groups {
-					group.mu.RLock()
-					if group.WindowActive && !group.GroupOnly {
-						elapsed := time.Since(group.WindowStartTime)
-						remaining := 600 - int(elapsed.Seconds())
-						if remaining < 0 {
-							remaining = 0
-						}
-						// Check if not already added
-						found := false
-						for _, w := range activeWindows {
-							if w["group_id"] == gID {
-								found = true
-								break
-							}
-						}
-						if !found {
-							activeWindows = append(activeWindows, map[string]interface{}{
-								"group_id":          gID,
-								"group_name":        group.Name,
-								"group_only":        false,
-								"remaining_seconds": remaining,
-							})
-						}
-					}
-					group.mu.RUnlock()
-				}
-				groupManager.mu.RUnlock()
-				
-				// Return the first active window (or most relevant one)
+			groupIDs, err := studentGroupIDs(r.Context(), studentUUID)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "failed to load group memberships", "student_id", studentID, "error", err)
+			}
+			slog.DebugContext(r.Context(), "loaded group memberships", "student_id", studentID, "groups", len(groupIDs))
+
+			// Windows come sorted by remaining time; this legacy endpoint only
+			// reports the first, get-student-dashboard lists them all
+			activeWindows := studentActiveWindows(studentID, groupIDs)
+			if len(activeWindows) > 0 {
+				bestWindow := activeWindows[0]
 				w.Header().Set("Content-Type", "application/json")
-				if len(activeWindows) > 0 {
-					// Return the window with most time remaining
-					bestWindow := activeWindows[0]
-					for _, w := range activeWindows {
-						if w["remaining_seconds"].(int) > bestWindow["remaining_seconds"].(int) {
-							bestWindow = w
-						}
-					}
-					json.NewEncoder(w).Encode(map[string]interface{}{
-						"active":            true,
-						"remaining_seconds": bestWindow["remaining_seconds"],
-						"group_id":          bestWindow["group_id"],
-						"group_name":        bestWindow["group_name"],
-						"session_name":      bestWindow["group_name"], // session_name is same as group_name
-					})
-					return
-				}
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"active":            true,
+					"remaining_seconds": bestWindow.RemainingSeconds,
+					"group_id":          bestWindow.GroupID,
+					"group_name":        bestWindow.GroupName,
+					"session_name":      bestWindow.GroupName, // session_name is same as group_name
+					"window_count":      len(activeWindows),
+				})
+				return
 			}
 		}
 	}
@@ -917,50 +790,10 @@ func getWindowStatusHandler(w http.ResponseWriter, r *http.Request) {
 
 	group, exists := groupManager.GetGroup(groupID)
 	if !exists {
-		// Try to restore window status from database
-		if groupID != "default" {
-			groupURL := fmt.Sprintf("%s/rest/v1/groups?id=eq.%s&select=id,name,status,window_start_time,window_end_time", config.SupabaseURL, groupID)
-			req, _ := http.NewRequest("GET", groupURL, nil)
-			req.Header.Set("apikey", config.SupabaseAnonKey)
-			req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-			
-			resp, err := http.DefaultClient.Do(req)
-			if err == nil {
-				defer resp.Body.Close()
-				var groups []struct {
-					ID              string     `json:"id"`
-					Name            string     `json:"name"`
-					Status          string     `json:"status"`
-					WindowStartTime *string    `json:"window_start_time"`
-					WindowEndTime   *string    `json:"window_end_time"`
-				}
-				if json.NewDecoder(resp.Body).Decode(&groups) == nil && len(groups) > 0 {
-					g := groups[0]
-					if g.Status == "active" && g.WindowStartTime != nil && g.WindowEndTime != nil {
-						// Restore window from database
-						group = groupManager.GetOrCreateGroup(groupID)
-						group.mu.Lock()
-						group.Name = g.Name
-						// Parse time with timezone support (handle both formats)
-						startTime, err1 := time.Parse(time.RFC3339, *g.WindowStartTime)
-						if err1 != nil {
-							startTime, _ = time.Parse("2006-01-02T15:04:05", strings.Split(*g.WindowStartTime, "+")[0])
-						}
-						endTime, err2 := time.Parse(time.RFC3339, *g.WindowEndTime)
-						if err2 != nil {
-							endTime, _ = time.Parse("2006-01-02T15:04:05", strings.Split(*g.WindowEndTime, "+")[0])
-						}
-						group.WindowStartTime = startTime
-						group.WindowEndTime = endTime
-						group.WindowActive = time.Now().Before(endTime) // Only active if not expired
-						group.mu.Unlock()
-						exists = true
-						fmt.Printf("DEBUG: getWindowStatusHandler - Restored window from database for group %s (Active=%v, EndTime=%v, Now=%v)\n", groupID, group.WindowActive, endTime, time.Now())
-					}
-				}
-			}
+		// Restore the window from the database if it wasn't at startup
+		if restoreGroupWindow(r.Context(), groupID) {
+			group, exists = groupManager.GetGroup(groupID)
 		}
-		
 		if !exists {
 			w.Header().Set("Content-Type", "application/json")
 			json.NewEncoder(w).Encode(map[string]interface{}{
@@ -972,39 +805,32 @@ func getWindowStatusHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Close a window whose time is up before the auto-close timer gets to it
+	group.mu.RLock()
+	expired := group.WindowActive && time.Now().After(group.WindowEndTime)
+	group.mu.RUnlock()
+	if expired {
+		expireWindow(group)
+	}
+
 	group.mu.RLock()
 	defer group.mu.RUnlock()
 
 	w.Header().Set("Content-Type", "application/json")
 
-	// Check if window is still active (not expired)
-	if !group.WindowActive || time.Now().After(group.WindowEndTime) {
-		// Window expired or closed
-		if group.WindowActive {
-			// Auto-close expired window
-			group.mu.RUnlock()
-			group.mu.Lock()
-			group.WindowActive = false
-			group.mu.Unlock()
-			group.mu.RLock()
+	if !group.WindowActive {
+		response := map[string]interface{}{
+			"active":            false,
+			"remaining_seconds": 0,
+			"group_id":          groupID,
+			"session_name":      group.Name,
 		}
-	response := map[string]interface{}{
-		"active":            false,
-		"remaining_seconds": 0,
-		"group_id":          groupID,
-		"session_name":      group.Name,
+		json.NewEncoder(w).Encode(response)
+		return
 	}
-	json.NewEncoder(w).Encode(response)
-	return
-}
 
-	// Calculate remaining seconds
-	elapsed := time.Since(group.WindowStartTime)
-	remaining := 600 - int(elapsed.Seconds()) // 10 minutes = 600 seconds
-
-	if remaining < 0 {
-		remaining = 0
-	}
+	// Same countdown the realtime events and the auto-close use
+	remaining := remainingSeconds(group)
 
 	response := map[string]interface{}{
 		"active":            group.WindowActive,
@@ -1017,20 +843,54 @@ func getWindowStatusHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// Handler: GET /api/get-all-student-locations
-func getAllStudentLocationsHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
+// restoreStudentLocations reloads a group's submissions from group_attendance
+// (e.g. after a restart), those made since a non-zero since only, and
+// returns how many locations the group now has
+func restoreStudentLocations(ctx context.Context, group *GroupData, since time.Time) int {
+	var records []struct {
+		Status      string  `json:"status"`
+		Distance    float64 `json:"distance"`
+		Latitude    float64 `json:"latitude"`
+		Longitude   float64 `json:"longitude"`
+		SubmittedAt string  `json:"submitted_at"`
+		Students    *struct {
+			StudentID   string `json:"student_id"`
+			StudentName string `json:"student_name"`
+		} `json:"students"`
+	}
+	attendanceQuery := from("group_attendance").Eq("group_id", group.ID).Select("*,students(student_id,student_name)").Order("submitted_at.asc")
+	if !since.IsZero() {
+		attendanceQuery.Filter("submitted_at", OpGte, since.Format(windowTimeLayout))
+	}
+	if err := supabaseGetJSON(ctx, attendanceQuery.URL(), &records); err != nil {
+		slog.ErrorContext(ctx, "failed to restore student locations", "group_id", group.ID, "error", err)
+		return 0
 	}
 
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	group.mu.Lock()
+	defer group.mu.Unlock()
+	for _, record := range records {
+		if record.Students == nil {
+			continue
+		}
+		studentID := record.Students.StudentID
+		group.StudentLocations[studentID] = StudentLocation{
+			StudentID:   studentID,
+			StudentName: record.Students.StudentName,
+			Latitude:    record.Latitude,
+			Longitude:   record.Longitude,
+			Distance:    record.Distance,
+			Timestamp:   record.SubmittedAt,
+			Status:      record.Status,
+		}
+		group.SubmittedStudents[studentID] = true
 	}
+	slog.DebugContext(ctx, "restored student locations", "group_id", group.ID, "count", len(records))
+	return len(group.StudentLocations)
+}
 
+// Handler: GET /api/get-all-student-locations
+func getAllStudentLocationsHandler(w http.ResponseWriter, r *http.Request) {
 	groupID := getGroupID(r)
 	if groupID == "" {
 		groupID = "default"
@@ -1058,40 +918,7 @@ func getAllStudentLocationsHandler(w http.ResponseWriter, r *http.Request) {
 
 	// If no locations in memory, try to load from database
 	if locationsCount == 0 && groupID != "default" {
-		// Load attendance records from database
-		attendanceURL := fmt.Sprintf("%s/rest/v1/group_attendance?group_id=eq.%s&select=*,students(student_id,student_name)&order=submitted_at.asc", config.SupabaseURL, groupID)
-		req, _ := http.NewRequest("GET", attendanceURL, nil)
-		req.Header.Set("apikey", config.SupabaseAnonKey)
-		req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-		
-		resp, err := http.DefaultClient.Do(req)
-		if err == nil {
-			defer resp.Body.Close()
-			var records []map[string]interface{}
-			if json.NewDecoder(resp.Body).Decode(&records) == nil {
-				group.mu.Lock()
-				for _, record := range records {
-					student := record["students"].(map[string]interface{})
-					studentID := student["student_id"].(string)
-					studentName := student["student_name"].(string)
-					
-					loc := StudentLocation{
-						StudentID:   studentID,
-						StudentName: studentName,
-						Latitude:    record["latitude"].(float64),
-						Longitude:   record["longitude"].(float64),
-						Distance:    record["distance"].(float64),
-						Timestamp:   record["submitted_at"].(string),
-						Status:      record["status"].(string),
-					}
-					group.StudentLocations[studentID] = loc
-					group.SubmittedStudents[studentID] = true
-				}
-				locationsCount = len(group.StudentLocations)
-				group.mu.Unlock()
-				fmt.Printf("DEBUG: getAllStudentLocationsHandler - Loaded %d locations from database for group %s\n", locationsCount, groupID)
-			}
-		}
+		locationsCount = restoreStudentLocations(r.Context(), group, time.Time{})
 	}
 
 	group.mu.RLock()
@@ -1102,12 +929,6 @@ func getAllStudentLocationsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	group.mu.RUnlock()
 
-	// Debug: Print student locations count
-	fmt.Printf("DEBUG: Returning %d student locations\n", len(locations))
-	for _, loc := range locations {
-		fmt.Printf("  - %s (%s): %.6f, %.6f, %.0fm\n", loc.StudentName, loc.StudentID, loc.Latitude, loc.Longitude, loc.Distance)
-	}
-
 	// Set headers
 	w.Header().Set("Content-Type", "application/json")
 
@@ -1116,44 +937,27 @@ func getAllStudentLocationsHandler(w http.ResponseWriter, r *http.Request) {
 		"count":    len(locations),
 	}
 
-	// Debug: Print response before encoding
-	fmt.Printf("DEBUG: Response map: %+v\n", response)
-
 	// Encode JSON manually to ensure it works
 	jsonBytes, err := json.Marshal(response)
 	if err != nil {
-		fmt.Printf("ERROR: Failed to marshal JSON: %v\n", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to encode response", err))
 		return
 	}
 
-	fmt.Printf("DEBUG: JSON bytes length: %d\n", len(jsonBytes))
-	fmt.Printf("DEBUG: JSON string: %s\n", string(jsonBytes))
-
 	// Write JSON bytes directly
 	if _, err := w.Write(jsonBytes); err != nil {
-		fmt.Printf("ERROR: Failed to write response: %v\n", err)
+		slog.WarnContext(r.Context(), "failed to write response", "error", err)
 		return
 	}
 
-	fmt.Printf("DEBUG: Successfully sent response with %d students\n", len(locations))
+	slog.DebugContext(r.Context(), "sent student locations", "group_id", groupID, "count", len(locations))
 }
 
 // Handler: GET /api/get-all-students
 // Fetches all students from the database with pagination support
 // Query parameters: page (default: 1), limit (default: 10)
 func getAllStudentsHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+	w.Header().Set("Content-Type", "application/json")
 
 	// Parse pagination parameters
 	page := 1
@@ -1186,7 +990,7 @@ func getAllStudentsHandler(w http.ResponseWriter, r *http.Request) {
 	// Check cache first (only for list view, page 1, and if cache is valid)
 	if isListView && page == 1 && !studentCache.timestamp.IsZero() {
 		studentCache.mu.RLock()
-		if time.Since(studentCache.timestamp) < cacheDuration && len(studentCache.data) > 0 {
+		if time.Since(studentCache.timestamp) < config.StudentCacheTTL && len(studentCache.data) > 0 {
 			// Return cached data
 			cachedData := studentCache.data
 			cachedTotal := studentCache.totalCount
@@ -1198,6 +1002,7 @@ func getAllStudentsHandler(w http.ResponseWriter, r *http.Request) {
 				totalPages = (cachedTotal + limit - 1) / limit
 			}
 
+			cacheLookups.Inc("students", "hit")
 			w.Header().Set("Content-Type", "application/json")
 			w.Header().Set("X-Cache", "HIT") // Indicate cache hit
 			json.NewEncoder(w).Encode(map[string]interface{}{
@@ -1212,28 +1017,25 @@ func getAllStudentsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		studentCache.mu.RUnlock()
 	}
+	if isListView && page == 1 {
+		cacheLookups.Inc("students", "miss")
+	}
 
 	// Query Supabase to get paginated students with count in single request
-	url := fmt.Sprintf("%s/rest/v1/students?select=id,student_id,student_name&order=student_id.asc&limit=%d&offset=%d",
-		config.SupabaseURL, limit, offset)
+	url := from("students").Select("id,student_id,student_name").Order("student_id.asc").
+		Limit(limit).Offset(offset).URL()
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := newSupabaseRequest(r.Context(), "GET", url, nil)
 	if err != nil {
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Failed to create request")
 		return
 	}
 
-	req.Header.Set("apikey", config.SupabaseAnonKey)
-	req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Prefer", "count=exact") // Get count in same request
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := supabaseClient.Do(req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Database connection error",
-		})
+		writeError(w, r, http.StatusInternalServerError, "Database connection error")
 		return
 	}
 	defer resp.Body.Close()
@@ -1251,12 +1053,7 @@ func getAllStudentsHandler(w http.ResponseWriter, r *http.Request) {
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":   "Failed to fetch students from database",
-			"status":  resp.StatusCode,
-			"details": string(bodyBytes),
-		})
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to fetch students from database", fmt.Errorf("supabase status %d: %s", resp.StatusCode, bodyBytes)))
 		return
 	}
 
@@ -1268,11 +1065,7 @@ func getAllStudentsHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Decode directly from response body
 	if err := json.NewDecoder(resp.Body).Decode(&students); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":   "Failed to parse response",
-			"details": err.Error(),
-		})
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to parse response", err))
 		return
 	}
 
@@ -1370,8 +1163,7 @@ func getAllStudentsHandler(w http.ResponseWriter, r *http.Request) {
 
 	jsonBytes, err := json.Marshal(response)
 	if err != nil {
-		fmt.Printf("ERROR: Failed to marshal JSON: %v\n", err)
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to encode response", err))
 		return
 	}
 
@@ -1383,50 +1175,27 @@ func getAllStudentsHandler(w http.ResponseWriter, r *http.Request) {
 
 // Handler: GET /api/get-student-attendance-history
 func getStudentAttendanceHistoryHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	studentID := r.URL.Query().Get("student_id")
 	if studentID == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{"error": "student_id is required"})
+		writeError(w, r, http.StatusBadRequest, "student_id is required")
 		return
 	}
 
-	studentUUID := getStudentUUIDByID(studentID)
+	studentUUID := getStudentUUIDByID(r.Context(), studentID)
 	if studentUUID == "" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Student not found"})
+		writeError(w, r, http.StatusNotFound, "Student not found")
 		return
 	}
 
 	// Get attendance history from database
-	attendanceURL := fmt.Sprintf("%s/rest/v1/group_attendance?student_id=eq.%s&select=*,groups(name,session_name),submitted_at&order=submitted_at.desc",
-		config.SupabaseURL, studentUUID)
-	
-	fmt.Printf("DEBUG: getStudentAttendanceHistoryHandler - Querying URL: %s\n", attendanceURL)
-	fmt.Printf("DEBUG: getStudentAttendanceHistoryHandler - studentID: %s, studentUUID: %s\n", studentID, studentUUID)
-	
-	req, _ := http.NewRequest("GET", attendanceURL, nil)
-	req.Header.Set("apikey", config.SupabaseAnonKey)
-	req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
+	attendanceURL := from("group_attendance").Eq("student_id", studentUUID).
+		Select("*,groups(name,session_name),submitted_at").Order("submitted_at.desc").URL()
+
+	req, _ := newSupabaseRequest(r.Context(), "GET", attendanceURL, nil)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := supabaseClient.Do(req)
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{"error": "Database error"})
+		writeError(w, r, http.StatusInternalServerError, "Database error")
 		return
 	}
 	defer resp.Body.Close()
@@ -1435,30 +1204,20 @@ func getStudentAttendanceHistoryHandler(w http.ResponseWriter, r *http.Request)
 	if resp.StatusCode == http.StatusOK {
 		bodyBytes, readErr := io.ReadAll(resp.Body)
 		if readErr != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{"error": "Failed to read response"})
+			writeError(w, r, http.StatusInternalServerError, "Failed to read response")
 			return
 		}
 		
 		if len(bodyBytes) > 0 {
 			if decodeErr := json.Unmarshal(bodyBytes, &records); decodeErr != nil {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(map[string]string{"error": "Failed to parse database response"})
+				writeError(w, r, http.StatusInternalServerError, "Failed to parse database response")
 				return
 			}
 		}
 	} else {
 		// If not OK status, return error
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		errorMsg := "Database query failed"
-		if len(bodyBytes) > 0 {
-			errorMsg = string(bodyBytes)
-		}
-		json.NewEncoder(w).Encode(map[string]string{"error": errorMsg})
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Database query failed", fmt.Errorf("supabase status %d: %s", resp.StatusCode, bodyBytes)))
 		return
 	}
 
@@ -1488,36 +1247,22 @@ func getStudentAttendanceHistoryHandler(w http.ResponseWriter, r *http.Request)
 		"count":              len(attendanceHistory),
 	}
 	
-	fmt.Printf("DEBUG: getStudentAttendanceHistoryHandler - Returning %d records for student %s\n", len(attendanceHistory), studentID)
+	slog.DebugContext(r.Context(), "sent attendance history", "student_id", studentID, "count", len(attendanceHistory))
 	
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if encodeErr := json.NewEncoder(w).Encode(response); encodeErr != nil {
-		// If encoding fails, try to write a simple error response
-		fmt.Printf("ERROR: Failed to encode attendance history response: %v\n", encodeErr)
-		// Reset and write error
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, `{"error": "Failed to encode response"}`)
+		// The status is already sent; all that's left is to log it
+		slog.WarnContext(r.Context(), "failed to encode attendance history", "error", encodeErr)
 	}
 }
 
 // Handler: POST /api/update-session-name
 func updateSessionNameHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+	w.Header().Set("Content-Type", "application/json")
 
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
 
@@ -1525,51 +1270,34 @@ func updateSessionNameHandler(w http.ResponseWriter, r *http.Request) {
 	groupID := r.FormValue("group_id")
 
 	if sessionName == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "session_name is required",
-		})
+		writeError(w, r, http.StatusBadRequest, "session_name is required")
 		return
 	}
 
 	// If group_id is provided, update the group in database
 	if groupID != "" && groupID != "default" {
 		// Update group in database
-		updateURL := fmt.Sprintf("%s/rest/v1/groups?id=eq.%s", config.SupabaseURL, groupID)
+		updateURL := from("groups").Eq("id", groupID).URL()
 		updateData := map[string]interface{}{
 			"name": sessionName,
 		}
 		jsonData, _ := json.Marshal(updateData)
-		req, err := http.NewRequest("PATCH", updateURL, strings.NewReader(string(jsonData)))
+		req, err := newSupabaseRequest(r.Context(), "PATCH", updateURL, strings.NewReader(string(jsonData)))
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error": "Failed to create request",
-			})
+			writeError(w, r, http.StatusInternalServerError, "Failed to create request")
 			return
 		}
 
-		req.Header.Set("apikey", config.SupabaseAnonKey)
-		req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-		req.Header.Set("Content-Type", "application/json")
-
-		resp, err := http.DefaultClient.Do(req)
+		resp, err := supabaseClient.Do(req)
 		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error": "Database connection error",
-			})
+			writeError(w, r, http.StatusInternalServerError, "Database connection error")
 			return
 		}
 		defer resp.Body.Close()
 
 		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 			bodyBytes, _ := io.ReadAll(resp.Body)
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]interface{}{
-				"error":   "Failed to update session name",
-				"details": string(bodyBytes),
-			})
+			respondError(w, r, apierror.Persistence("Failed to update session name", fmt.Errorf("supabase status %d: %s", resp.StatusCode, bodyBytes)))
 			return
 		}
 
@@ -1596,21 +1324,11 @@ func updateSessionNameHandler(w http.ResponseWriter, r *http.Request) {
 
 // Handler: POST /api/admin-login
 func adminLoginHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+	w.Header().Set("Content-Type", "application/json")
 
 	// Parse form data
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
 
@@ -1618,30 +1336,22 @@ func adminLoginHandler(w http.ResponseWriter, r *http.Request) {
 	password := r.FormValue("password")
 
 	if username == "" || password == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Username and password are required",
-		})
+		writeError(w, r, http.StatusBadRequest, "Username and password are required")
 		return
 	}
 
 	// Query Supabase using REST API
-	url := fmt.Sprintf("%s/rest/v1/admins?username=eq.%s&password=eq.%s&select=*",
-		config.SupabaseURL, username, password)
+	url := from("admins").Eq("username", username).Eq("password", password).Select("*").URL()
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := newSupabaseRequest(r.Context(), "GET", url, nil)
 	if err != nil {
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Failed to create request")
 		return
 	}
 
-	req.Header.Set("apikey", config.SupabaseAnonKey)
-	req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := supabaseClient.Do(req)
 	if err != nil {
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Database connection error")
 		return
 	}
 	defer resp.Body.Close()
@@ -1652,15 +1362,12 @@ func adminLoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&admins); err != nil {
-		http.Error(w, "Failed to parse response", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Failed to parse response")
 		return
 	}
 
 	if len(admins) == 0 {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Invalid credentials",
-		})
+		writeError(w, r, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
@@ -1677,21 +1384,11 @@ func adminLoginHandler(w http.ResponseWriter, r *http.Request) {
 
 // Handler: POST /api/student-login
 func studentLoginHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+	w.Header().Set("Content-Type", "application/json")
 
 	// Parse form data
 	if err := r.ParseForm(); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Failed to parse form")
 		return
 	}
 
@@ -1699,31 +1396,23 @@ func studentLoginHandler(w http.ResponseWriter, r *http.Request) {
 	studentName := r.FormValue("student_name")
 
 	if studentID == "" || studentName == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Student ID and name are required",
-		})
+		writeError(w, r, http.StatusBadRequest, "Student ID and name are required")
 		return
 	}
 
 	// Query Supabase using REST API
 	// First check if student_id exists
-	url := fmt.Sprintf("%s/rest/v1/students?student_id=eq.%s&select=*",
-		config.SupabaseURL, studentID)
+	url := from("students").Eq("student_id", studentID).Select("*").URL()
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := newSupabaseRequest(r.Context(), "GET", url, nil)
 	if err != nil {
-		http.Error(w, "Failed to create request", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Failed to create request")
 		return
 	}
 
-	req.Header.Set("apikey", config.SupabaseAnonKey)
-	req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := supabaseClient.Do(req)
 	if err != nil {
-		http.Error(w, "Database connection error", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Database connection error")
 		return
 	}
 	defer resp.Body.Close()
@@ -1735,24 +1424,18 @@ func studentLoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&students); err != nil {
-		http.Error(w, "Failed to parse response", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, "Failed to parse response")
 		return
 	}
 
 	if len(students) == 0 {
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Student ID not found",
-		})
+		writeError(w, r, http.StatusNotFound, "Student ID not found")
 		return
 	}
 
 	// Check if name matches (case-insensitive)
 	if !strings.EqualFold(students[0].StudentName, studentName) {
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Student name does not match the registered ID",
-		})
+		writeError(w, r, http.StatusUnauthorized, "Student name does not match the registered ID")
 		return
 	}
 
@@ -1768,97 +1451,162 @@ func studentLoginHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// Main function
-func main() {
-	// Load configuration
-	config = LoadConfig()
-
-	// Validate config
-	if config.SupabaseURL == "" || config.SupabaseAnonKey == "" {
-		fmt.Println("Warning: SUPABASE_URL or SUPABASE_ANON_KEY not set")
-		fmt.Println("Please create a .env file with:")
-		fmt.Println("  SUPABASE_URL=your_supabase_url")
-		fmt.Println("  SUPABASE_ANON_KEY=your_anon_key")
-	}
-
-	// Initialize submitted students map and student locations
-	submittedStudents = make(map[string]bool)
-	studentLocations = make(map[string]StudentLocation)
+// newRouter builds the router with its middleware and every route
+func newRouter() *Router {
+	// Every request goes through the middleware chain, outermost first
+	router := NewRouter()
+	router.Use(
+		recoverMiddleware,
+		requestIDMiddleware,
+		loggingMiddleware,
+		metricsMiddleware,
+		corsMiddleware(config.CORSOrigins),
+		newRateLimiter(config.RateLimitPerSecond, config.RateLimitBurst).Middleware,
+		authMiddleware(config.APISigningSecret),
+	)
 
 	// Register handlers
-	http.HandleFunc("/api/admin-login", adminLoginHandler)
-	http.HandleFunc("/api/student-login", studentLoginHandler)
-	http.HandleFunc("/api/set-center", setCenterHandler)
-	http.HandleFunc("/api/start-window", startWindowHandler)
-	http.HandleFunc("/api/close-window", closeWindowHandler)
-	http.HandleFunc("/api/submit-attendance", submitAttendanceHandler)
-	http.HandleFunc("/api/download-csv", downloadCSVHandler)
-	http.HandleFunc("/api/get-admin-location", getAdminLocationHandler)
-	http.HandleFunc("/api/get-window-status", getWindowStatusHandler)
-	http.HandleFunc("/api/get-all-student-locations", getAllStudentLocationsHandler)
-	http.HandleFunc("/api/get-all-students", getAllStudentsHandler)
+	router.POST("/api/admin-login", adminLoginHandler)
+	router.POST("/api/student-login", studentLoginHandler)
+	router.POST("/api/set-center", setCenterHandler)
+	router.POST("/api/start-window", startWindowHandler)
+	router.POST("/api/close-window", closeWindowHandler)
+	router.POST("/api/submit-attendance", submitAttendanceHandler)
+	router.GET("/api/download-csv", downloadCSVHandler)
+	router.GET("/api/get-admin-location", getAdminLocationHandler)
+	router.GET("/api/get-window-status", getWindowStatusHandler)
+	router.GET("/api/get-all-student-locations", getAllStudentLocationsHandler)
+	router.GET("/api/get-all-students", getAllStudentsHandler)
 
 	// Register group management handlers
-	http.HandleFunc("/api/create-group", createGroupHandler)
-	http.HandleFunc("/api/get-my-groups", getMyGroupsHandler)
-	http.HandleFunc("/api/add-students-to-group", addStudentsToGroupHandler)
-	http.HandleFunc("/api/get-group-students", getGroupStudentsHandler)
-	http.HandleFunc("/api/delete-group", deleteGroupHandler)
+	router.POST("/api/create-group", createGroupHandler)
+	router.GET("/api/get-my-groups", getMyGroupsHandler)
+	router.POST("/api/add-students-to-group", addStudentsToGroupHandler)
+	router.GET("/api/get-group-students", getGroupStudentsHandler)
+	router.POST("/api/delete-group", deleteGroupHandler)
+	router.DELETE("/api/delete-group", deleteGroupHandler)
 
 	// Register student management handlers
-	http.HandleFunc("/api/add-student", addStudentHandler)
+	router.POST("/api/add-student", addStudentHandler)
 	
 	// Register session management handlers
-	http.HandleFunc("/api/update-session-name", updateSessionNameHandler)
+	router.POST("/api/update-session-name", updateSessionNameHandler)
 	
 	// Register messaging handlers
-	http.HandleFunc("/api/save-fcm-token", saveFCMTokenHandler)
-	http.HandleFunc("/api/send-broadcast-message", sendBroadcastMessageHandler)
-	http.HandleFunc("/api/get-messages", getMessagesHandler)
-	http.HandleFunc("/api/mark-message-read", markMessageReadHandler)
-	http.HandleFunc("/api/delete-message", deleteMessageHandler)
-	http.HandleFunc("/api/get-student-attendance-history", getStudentAttendanceHistoryHandler)
-
-	// Enable CORS (for Flutter app)
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-	})
+	router.POST("/api/save-fcm-token", saveFCMTokenHandler)
+	router.POST("/api/send-broadcast-message", sendBroadcastMessageHandler)
+	router.GET("/api/get-broadcast-status", getBroadcastStatusHandler)
+	router.POST("/api/upload-attachment", uploadAttachmentHandler)
+	router.GET("/api/download-attachment", downloadAttachmentHandler)
+	router.POST("/api/schedule-message", scheduleMessageHandler)
+	router.POST("/api/cancel-scheduled-message", cancelScheduledMessageHandler)
+	router.GET("/api/get-scheduled-messages", getScheduledMessagesHandler)
+	router.POST("/api/schedule-window", scheduleWindowHandler)
+	router.POST("/api/cancel-scheduled-window", cancelScheduledWindowHandler)
+	router.GET("/api/get-scheduled-windows", getScheduledWindowsHandler)
+	router.GET("/api/get-student-dashboard", getStudentDashboardHandler)
+	router.GET("/api/get-window-summary", getWindowSummaryHandler)
+	router.GET("/api/get-sent-messages", getSentMessagesHandler)
+	router.GET("/api/get-message-receipts", getMessageReceiptsHandler)
+	router.GET("/api/export-unread", exportUnreadHandler)
+	router.POST("/api/renotify-unread", renotifyUnreadHandler)
+	router.POST("/api/edit-message", editMessageHandler)
+	router.GET("/api/get-message-edits", getMessageEditsHandler)
+	router.POST("/api/recall-message", recallMessageHandler)
+	router.POST("/api/admin-delete-message", adminDeleteMessageHandler)
+	router.DELETE("/api/admin-delete-message", adminDeleteMessageHandler)
+	router.POST("/api/create-template", createTemplateHandler)
+	router.POST("/api/update-template", updateTemplateHandler)
+	router.PUT("/api/update-template", updateTemplateHandler)
+	router.GET("/api/get-templates", getTemplatesHandler)
+	router.POST("/api/delete-template", deleteTemplateHandler)
+	router.DELETE("/api/delete-template", deleteTemplateHandler)
+	router.POST("/api/preview-template", previewTemplateHandler)
+	router.GET("/api/get-messages", getMessagesHandler)
+	router.GET("/api/get-unread-count", getUnreadCountHandler)
+	router.POST("/api/mark-message-read", markMessageReadHandler)
+	router.POST("/api/delete-message", deleteMessageHandler)
+	router.DELETE("/api/delete-message", deleteMessageHandler)
+	router.POST("/api/reply-message", replyMessageHandler)
+	router.POST("/api/open-thread", openThreadHandler)
+	router.GET("/api/get-admin-inbox", getAdminInboxHandler)
+	router.GET("/api/get-student-threads", getStudentThreadsHandler)
+	router.GET("/api/get-thread", getThreadHandler)
+	router.POST("/api/reply-thread", replyThreadHandler)
+	router.POST("/api/mark-thread-read", markThreadReadHandler)
+	router.POST("/api/delete-thread", deleteThreadHandler)
+	router.DELETE("/api/delete-thread", deleteThreadHandler)
+	router.GET("/api/get-student-attendance-history", getStudentAttendanceHistoryHandler)
+
+	// Register real-time event stream
+	router.GET("/api/events", eventsHandler)
+
+	// API description and effective configuration
+	router.GET("/api/openapi.json", openAPIHandler(router))
+	router.GET("/api/admin/config", getConfigHandler)
+
+	// Prometheus scrape target and orchestrator probes
+	router.GET("/metrics", metricsHandler)
+	router.GET("/healthz", healthzHandler)
+	router.GET("/readyz", readyzHandler)
+
+	// Resource-oriented JSON API over the same handlers
+	registerV1Routes(router)
+	return router
+}
+
+// Main function
+func main() {
+	// Load configuration: defaults < config file < environment < flags
+	var args []string
+	var err error
+	config, args, err = LoadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := setupLogging(os.Stderr, config.LogLevel, config.LogFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// Tooling subcommands (openapi) run instead of the server
+	if len(args) > 0 {
+		os.Exit(runCommand(args[0]))
+	}
+
+	// Set up push notifications (FCM if credentials are available)
+	notifier = newNotifier(config)
+
+	// SIGTERM (deploys) and Ctrl-C start a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// Start broadcast delivery workers (also resumes unfinished jobs)
+	deliveryQueue.Start(ctx)
+	// Reopen the windows that were open when the server last stopped
+	restoreOpenWindows(ctx)
+	go runScheduler(ctx)
+
+	// Initialize submitted students map and student locations
+	submittedStudents = make(map[string]bool)
+	studentLocations = make(map[string]StudentLocation)
+
+	router := newRouter()
+	go runWindowTicker(ctx)
 
 	// Start server
-	fmt.Println("Server running on :8080")
-	fmt.Println("Endpoints:")
-	fmt.Println("  POST /api/admin-login")
-	fmt.Println("  POST /api/student-login")
-	fmt.Println("  POST /api/set-center")
-	fmt.Println("  POST /api/start-window")
-	fmt.Println("  POST /api/close-window")
-	fmt.Println("  POST /api/submit-attendance")
-	fmt.Println("  GET  /api/download-csv")
-	fmt.Println("  GET  /api/get-admin-location")
-	fmt.Println("  GET  /api/get-window-status")
-	fmt.Println("  GET  /api/get-all-student-locations")
-	fmt.Println("  GET  /api/get-all-students")
-	fmt.Println("  POST /api/create-group")
-	fmt.Println("  GET  /api/get-my-groups")
-	fmt.Println("  POST /api/add-students-to-group")
-	fmt.Println("  GET  /api/get-group-students")
-	fmt.Println("  DELETE /api/delete-group")
-	fmt.Println("  POST /api/add-student")
-	fmt.Println("  POST /api/save-fcm-token")
-	fmt.Println("  POST /api/send-broadcast-message")
-	fmt.Println("  GET  /api/get-messages")
-	fmt.Println("  POST /api/mark-message-read")
-	fmt.Println("  POST /api/delete-message")
-	fmt.Println("  GET  /api/get-student-attendance-history")
-
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		fmt.Printf("Server failed to start: %v\n", err)
+	for _, route := range router.Routes() {
+		slog.Debug("route", "method", route.Method, "pattern", route.Pattern)
+	}
+	addr := fmt.Sprintf(":%d", config.Port)
+	slog.Info("server running", "addr", addr, "routes", len(router.Routes()))
+
+	if err := serve(ctx, addr, router); err != nil {
+		slog.Error("server failed", "error", err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAttendanceTable answers the student lookup and stores group_attendance
+// rows under UNIQUE(group_id, student_id), failing a duplicate with 409 the
+// way PostgREST does unless the upsert names that key in on_conflict
+type fakeAttendanceTable struct {
+	mu   sync.Mutex
+	rows map[string]map[string]interface{}
+}
+
+func (f *fakeAttendanceTable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/rest/v1/students":
+		json.NewEncoder(w).Encode([]map[string]string{{"id": "uuid-st001", "student_id": "ST001"}})
+	case r.Method == http.MethodPost && r.URL.Path == "/rest/v1/group_attendance":
+		var row map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&row); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		key := row["group_id"].(string) + "/" + row["student_id"].(string)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		merge := r.Header.Get("Prefer") == "resolution=merge-duplicates" &&
+			r.URL.Query().Get("on_conflict") == "group_id,student_id"
+		if _, exists := f.rows[key]; exists && !merge {
+			http.Error(w, `{"code":"23505","message":"duplicate key value violates unique constraint"}`, http.StatusConflict)
+			return
+		}
+		f.rows[key] = row
+		w.WriteHeader(http.StatusCreated)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// postAttendance submits ST001's attendance from (0, 0) to a group
+func postAttendance(groupID string) *httptest.ResponseRecorder {
+	form := url.Values{"group_id": {groupID}, "student_id": {"ST001"}, "student_name": {"Ann"}, "lat": {"0"}, "lon": {"0"}}
+	req := httptest.NewRequest(http.MethodPost, "/api/submit-attendance", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	submitAttendanceHandler(rec, req)
+	return rec
+}
+
+func TestSubmitAttendanceTwiceForSameGroup(t *testing.T) {
+	table := &fakeAttendanceTable{rows: make(map[string]map[string]interface{})}
+	useSupabase(t, table)
+	group := useGroup(t, "group-1")
+
+	if rec := postAttendance("group-1"); rec.Code != http.StatusOK {
+		t.Fatalf("first submission: status %d: %s", rec.Code, rec.Body)
+	}
+	if rec := postAttendance("group-1"); rec.Code != http.StatusConflict {
+		t.Fatalf("repeat in the same window: status %d, want %d", rec.Code, http.StatusConflict)
+	}
+
+	// A new window for the group forgets who submitted, but the row from the
+	// last window is still in the table
+	group.mu.Lock()
+	group.SubmittedStudents = make(map[string]bool)
+	group.StudentLocations = make(map[string]StudentLocation)
+	group.mu.Unlock()
+
+	if rec := postAttendance("group-1"); rec.Code != http.StatusOK {
+		t.Fatalf("submission in the next window: status %d: %s", rec.Code, rec.Body)
+	}
+	if len(table.rows) != 1 {
+		t.Errorf("got %d attendance rows, want 1", len(table.rows))
+	}
+}
+
+func TestSubmitAttendanceReportsFailedWrite(t *testing.T) {
+	useSupabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/v1/students":
+			json.NewEncoder(w).Encode([]map[string]string{{"id": "uuid-st001", "student_id": "ST001"}})
+		default:
+			http.Error(w, `{"message":"database is read-only"}`, http.StatusInternalServerError)
+		}
+	}))
+	group := useGroup(t, "g1")
+
+	rec := postAttendance("g1")
+	var body map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusInternalServerError || body["code"] != "persistence_failed" {
+		t.Errorf("got %d %v, want 500 persistence_failed", rec.Code, body)
+	}
+	if strings.Contains(rec.Body.String(), "read-only") {
+		t.Error("database error leaked to the client")
+	}
+	// Nothing was recorded, so the student can try again
+	if group.SubmittedStudents["ST001"] {
+		t.Error("student marked as submitted after the write failed")
+	}
+}
+
+func TestWindowStatusClosesExpiredWindowOnce(t *testing.T) {
+	group := useGroup(t, "g1")
+	group.WindowEndTime = time.Now().Add(-time.Second)
+	sub := eventHub.Subscribe([]string{groupTopic("g1")})
+	t.Cleanup(func() { eventHub.Unsubscribe(sub) })
+	expired := func() uint64 {
+		windowSubmissions.mu.Lock()
+		defer windowSubmissions.mu.Unlock()
+		if s := windowSubmissions.series[labelKey([]string{"expired"})]; s != nil {
+			return s.count
+		}
+		return 0
+	}
+	before := expired()
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		getWindowStatusHandler(rec, httptest.NewRequest(http.MethodGet, "/api/get-window-status?group_id=g1", nil))
+		if strings.Contains(rec.Body.String(), `"active":true`) {
+			t.Fatalf("expired window reported active: %s", rec.Body)
+		}
+	}
+	// The auto-close timer firing late finds the window already closed
+	expireWindow(group)
+
+	if len(sub.ch) != 1 {
+		t.Fatalf("got %d events, want one window_closed", len(sub.ch))
+	}
+	if event := <-sub.ch; event.Type != EventWindowClosed {
+		t.Errorf("got %q, want %q", event.Type, EventWindowClosed)
+	}
+	if got := expired() - before; got != 1 {
+		t.Errorf("window observed %d times in the metrics, want 1", got)
+	}
+}
+
+func TestSubmitAttendanceDoesNotHoldGroupLockDuringWrites(t *testing.T) {
+	table := &fakeAttendanceTable{rows: make(map[string]map[string]interface{})}
+	entered, release := make(chan string), make(chan struct{})
+	useSupabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entered <- r.URL.Path
+		<-release
+		table.ServeHTTP(w, r)
+	}))
+	group := useGroup(t, "g1")
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postAttendance("g1") }()
+
+	// Both the student lookup and the upsert run with the group unlocked
+	for i := 0; i < 2; i++ {
+		path := <-entered
+		if !group.mu.TryLock() {
+			t.Errorf("group locked during %s", path)
+		} else {
+			group.mu.Unlock()
+		}
+		release <- struct{}{}
+	}
+	if rec := <-done; rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if !group.SubmittedStudents["ST001"] {
+		t.Error("submission not recorded")
+	}
+}
+
+func TestSubmitAttendanceRejectedWhenWindowClosesDuringWrite(t *testing.T) {
+	table := &fakeAttendanceTable{rows: make(map[string]map[string]interface{})}
+	var mu sync.Mutex
+	var confirmations int
+	entered, release := make(chan struct{}), make(chan struct{})
+	useSupabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/v1/group_attendance":
+			entered <- struct{}{}
+			<-release
+		case "/rest/v1/broadcast_messages":
+			mu.Lock()
+			confirmations++
+			mu.Unlock()
+		}
+		table.ServeHTTP(w, r)
+	}))
+	group := useGroup(t, "g1")
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- postAttendance("g1") }()
+
+	// The admin closes the window while the upsert is in flight
+	<-entered
+	group.mu.Lock()
+	closeWindowLocked(group, "closed")
+	group.mu.Unlock()
+	close(release)
+
+	rec := <-done
+	var body map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code == http.StatusOK || body["code"] != "window_closed" {
+		t.Fatalf("got %d %v, want window_closed", rec.Code, body)
+	}
+	group.mu.RLock()
+	submitted, located := group.SubmittedStudents["ST001"], len(group.StudentLocations) > 0
+	group.mu.RUnlock()
+	if submitted || located {
+		t.Error("submission recorded after the window closed")
+	}
+	// A confirmation would be sent asynchronously; give it the chance
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if confirmations != 0 {
+		t.Errorf("%d confirmations sent for a rejected submission", confirmations)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"attendance-system/apierror"
+)
+
+// MessageEdit is the content of a broadcast before one edit
+type MessageEdit struct {
+	ID              string `json:"id"`
+	MessageID       string `json:"message_id"`
+	AdminID         string `json:"admin_id"`
+	PreviousTitle   string `json:"previous_title"`
+	PreviousMessage string `json:"previous_message"`
+	EditedAt        string `json:"edited_at"`
+}
+
+// recipientTopics returns the SSE topics and UUIDs of a message's recipients
+func recipientTopics(ctx context.Context, messageID string) ([]string, []string, error) {
+	receipts, err := fetchMessageReceipts(ctx, messageID, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	topics := make([]string, 0, len(receipts))
+	uuids := make([]string, 0, len(receipts))
+	for _, receipt := range receipts {
+		topics = append(topics, studentTopic(receipt.StudentID))
+		uuids = append(uuids, receipt.StudentUUID)
+	}
+	return topics, uuids, nil
+}
+
+// publishMessageRecalled tells recipients to drop a message, over SSE and as
+// a data push so closed apps refresh too
+func publishMessageRecalled(ctx context.Context, messageID string) {
+	topics, uuids, err := recipientTopics(ctx, messageID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load recipients of recalled message", "message_id", messageID, "error", err)
+		return
+	}
+	eventHub.Publish(EventMessageRecalled, map[string]string{"message_id": messageID}, topics...)
+	notifyStudents(ctx, uuids, Notification{
+		Data: map[string]string{
+			"type":       EventMessageRecalled,
+			"message_id": messageID,
+		},
+	})
+}
+
+// loadEditableMessage checks the request's admin owns a message that is still live
+func loadEditableMessage(w http.ResponseWriter, r *http.Request, adminID, messageID string) *SentMessage {
+	message, err := fetchOwnedMessage(r.Context(), adminID, messageID)
+	if err != nil || message == nil {
+		writeOwnedMessageError(w, r, err)
+		return nil
+	}
+	if message.RecalledAt != nil {
+		writeError(w, r, http.StatusConflict, "Message has been recalled")
+		return nil
+	}
+	return message
+}
+
+// Handler: POST /api/edit-message
+// Updates a sent message's title and/or text, keeping the previous version
+func editMessageHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		AdminID   string `json:"admin_id"`
+		MessageID string `json:"message_id"`
+		Title     string `json:"title"`
+		Message   string `json:"message"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if data.AdminID == "" || data.MessageID == "" || (data.Title == "" && data.Message == "") {
+		writeError(w, r, http.StatusBadRequest, "admin_id, message_id and a new title or message are required")
+		return
+	}
+	if err := checkPlaceholders(data.Title, data.Message); err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	message := loadEditableMessage(w, r, data.AdminID, data.MessageID)
+	if message == nil {
+		return
+	}
+	if data.Title == "" {
+		data.Title = message.Title
+	}
+	if data.Message == "" {
+		data.Message = message.Message
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if data.Title == message.Title && data.Message == message.Message {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "changed": false})
+		return
+	}
+
+	// Recipients' copies first: until the message itself is updated a
+	// failure leaves it unchanged, so resubmitting the edit redoes every step
+	if err := rerenderRecipients(r.Context(), message.ID, data.Title, data.Message); err != nil {
+		respondError(w, r, apierror.Persistence("Failed to update recipients' copies", err))
+		return
+	}
+
+	// History before the update, so a failed update never loses the old text
+	if _, _, err := insertReturning(r.Context(), "message_edits", map[string]interface{}{
+		"message_id":       message.ID,
+		"admin_id":         data.AdminID,
+		"previous_title":   message.Title,
+		"previous_message": message.Message,
+	}); err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to save edit history", err))
+		return
+	}
+
+	editedAt := time.Now().UTC().Format(time.RFC3339)
+	updateURL := from("broadcast_messages").Eq("id", message.ID).URL()
+	if err := supabasePatch(r.Context(), updateURL, map[string]interface{}{
+		"title":     data.Title,
+		"message":   data.Message,
+		"edited_at": editedAt,
+	}); err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to update message", err))
+		return
+	}
+
+	if topics, _, err := recipientTopics(r.Context(), message.ID); err == nil {
+		event := map[string]interface{}{
+			"message_id": message.ID,
+			"edited_at":  editedAt,
+		}
+		// Personalized text differs per student; clients refetch it instead
+		if !hasPlaceholders(data.Title + data.Message) {
+			event["title"] = data.Title
+			event["message"] = data.Message
+		}
+		eventHub.Publish(EventMessageEdited, event, topics...)
+	}
+
+	slog.InfoContext(r.Context(), "message edited", "admin_id", data.AdminID, "message_id", message.ID)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"changed":   true,
+		"edited_at": editedAt,
+	})
+}
+
+// Handler: GET /api/get-message-edits?admin_id=xxx&message_id=xxx
+func getMessageEditsHandler(w http.ResponseWriter, r *http.Request) {
+	adminID := r.URL.Query().Get("admin_id")
+	messageID := r.URL.Query().Get("message_id")
+	if adminID == "" || messageID == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id and message_id are required")
+		return
+	}
+
+	message, err := fetchOwnedMessage(r.Context(), adminID, messageID)
+	if err != nil || message == nil {
+		writeOwnedMessageError(w, r, err)
+		return
+	}
+
+	var edits []MessageEdit
+	editsURL := from("message_edits").Eq("message_id", messageID).Select("*").Order("edited_at.desc").URL()
+	if err := supabaseGetJSON(r.Context(), editsURL, &edits); err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to fetch edit history", err))
+		return
+	}
+	if edits == nil {
+		edits = []MessageEdit{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message": message,
+		"edits":   edits,
+		"count":   len(edits),
+	})
+}
+
+// Handler: POST /api/recall-message
+// Withdraws a message from every recipient; the admin keeps it in their history
+func recallMessageHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		AdminID   string `json:"admin_id"`
+		MessageID string `json:"message_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if data.AdminID == "" || data.MessageID == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id and message_id are required")
+		return
+	}
+
+	message := loadEditableMessage(w, r, data.AdminID, data.MessageID)
+	if message == nil {
+		return
+	}
+
+	recalledAt := time.Now().UTC().Format(time.RFC3339)
+	updateURL := from("broadcast_messages").Eq("id", message.ID).URL()
+	if err := supabasePatch(r.Context(), updateURL, map[string]interface{}{"recalled_at": recalledAt}); err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to recall message", err))
+		return
+	}
+
+	go publishMessageRecalled(context.WithoutCancel(r.Context()), message.ID)
+
+	slog.InfoContext(r.Context(), "message recalled", "admin_id", data.AdminID, "message_id", message.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"recalled_at": recalledAt,
+	})
+}
+
+// Handler: POST /api/admin-delete-message
+// Permanently deletes a message, its recipients and attachments
+func adminDeleteMessageHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		AdminID   string `json:"admin_id"`
+		MessageID string `json:"message_id"`
+	}
+
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid JSON")
+			return
+		}
+	} else {
+		data.AdminID = r.URL.Query().Get("admin_id")
+		data.MessageID = r.URL.Query().Get("message_id")
+	}
+
+	if data.AdminID == "" || data.MessageID == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id and message_id are required")
+		return
+	}
+
+	message, err := fetchOwnedMessage(r.Context(), data.AdminID, data.MessageID)
+	if err != nil || message == nil {
+		writeOwnedMessageError(w, r, err)
+		return
+	}
+
+	// Collect what has to be cleaned up before the cascade removes it
+	topics, _, _ := recipientTopics(r.Context(), message.ID)
+	var attachments []Attachment
+	attachmentsURL := from("message_attachments").Eq("message_id", message.ID).Select("id,storage_key").URL()
+	if err := supabaseGetJSON(r.Context(), attachmentsURL, &attachments); err != nil {
+		slog.ErrorContext(r.Context(), "failed to list attachments", "message_id", message.ID, "error", err)
+	}
+
+	deleteURL := from("broadcast_messages").Eq("id", message.ID).Eq("admin_id", data.AdminID).URL()
+	req, _ := newSupabaseRequest(r.Context(), "DELETE", deleteURL, nil)
+	resp, err := supabaseClient.Do(req)
+	if err != nil || (resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent) {
+		if resp != nil {
+			resp.Body.Close()
+		}
+		writeError(w, r, http.StatusInternalServerError, "Failed to delete message")
+		return
+	}
+	resp.Body.Close()
+
+	for _, attachment := range attachments {
+		if attachment.StorageKey != "" {
+			os.Remove(blobPath(attachment.StorageKey))
+		}
+	}
+
+	// Clients treat a deleted message like a recalled one
+	if message.RecalledAt == nil {
+		eventHub.Publish(EventMessageRecalled, map[string]string{"message_id": message.ID}, topics...)
+	}
+
+	slog.InfoContext(r.Context(), "message deleted", "admin_id", data.AdminID, "message_id", message.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Message deleted successfully"})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeEdits serves admin-1's live message m1 and recalled message m2, and
+// records the edit history rows and message updates it receives. With
+// personalized set, m1 has per-recipient copies; failRecipients fails
+// writes to them.
+type fakeEdits struct {
+	mu             sync.Mutex
+	history        []map[string]interface{}
+	updates        []map[string]interface{}
+	personalized   bool
+	failRecipients bool
+}
+
+func (f *fakeEdits) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query := r.URL.Query()
+	switch {
+	case r.URL.Path == "/rest/v1/broadcast_messages" && r.Method == http.MethodGet && query.Get("select") == "personalized,template_group_id":
+		json.NewEncoder(w).Encode([]map[string]interface{}{{"personalized": f.personalized}})
+	case r.URL.Path == "/rest/v1/broadcast_messages" && r.Method == http.MethodGet:
+		messages := []map[string]interface{}{}
+		if query.Get("admin_id") == "eq.admin-1" {
+			switch query.Get("id") {
+			case "eq.m1":
+				messages = append(messages, map[string]interface{}{"id": "m1", "admin_id": "admin-1", "title": "Old", "message": "Old text"})
+			case "eq.m2":
+				messages = append(messages, map[string]interface{}{"id": "m2", "admin_id": "admin-1", "title": "Gone", "message": "Gone", "recalled_at": "2026-01-05T08:00:00Z"})
+			}
+		}
+		json.NewEncoder(w).Encode(messages)
+	case r.URL.Path == "/rest/v1/broadcast_messages" && r.Method == http.MethodPatch:
+		var fields map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&fields)
+		f.updates = append(f.updates, fields)
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == "/rest/v1/message_edits" && r.Method == http.MethodPost:
+		var row map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&row)
+		f.history = append(f.history, row)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode([]map[string]string{{"id": "e1"}})
+	case r.URL.Path == "/rest/v1/message_recipients" && r.Method == http.MethodPatch:
+		if f.failRecipients {
+			http.Error(w, `{"message":"connection reset"}`, http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Path == "/rest/v1/message_recipients":
+		json.NewEncoder(w).Encode([]interface{}{})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func postEdit(body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/edit-message", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	editMessageHandler(rec, req)
+	return rec
+}
+
+func TestEditMessage(t *testing.T) {
+	fake := &fakeEdits{}
+	useSupabase(t, fake)
+
+	rec := postEdit(`{"admin_id":"admin-1","message_id":"m1","title":"New"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	if len(fake.history) != 1 || fake.history[0]["previous_title"] != "Old" || fake.history[0]["previous_message"] != "Old text" {
+		t.Errorf("history = %v, want the previous title and text", fake.history)
+	}
+	if len(fake.updates) != 1 || fake.updates[0]["title"] != "New" || fake.updates[0]["message"] != "Old text" {
+		t.Errorf("updates = %v, want new title with the text kept", fake.updates)
+	}
+
+	// Resubmitting the same content writes nothing
+	rec = postEdit(`{"admin_id":"admin-1","message_id":"m1","title":"Old","message":"Old text"}`)
+	if rec.Code != http.StatusOK || len(fake.history) != 1 || len(fake.updates) != 1 {
+		t.Errorf("unchanged edit: status %d, %d history rows, %d updates", rec.Code, len(fake.history), len(fake.updates))
+	}
+}
+
+func TestEditMessageRejects(t *testing.T) {
+	fake := &fakeEdits{}
+	useSupabase(t, fake)
+
+	tests := []struct {
+		name, body string
+		status     int
+	}{
+		{"recalled message", `{"admin_id":"admin-1","message_id":"m2","title":"New"}`, http.StatusConflict},
+		{"another admin's message", `{"admin_id":"admin-2","message_id":"m1","title":"New"}`, http.StatusNotFound},
+		{"nothing to change", `{"admin_id":"admin-1","message_id":"m1"}`, http.StatusBadRequest},
+		{"unknown placeholder", `{"admin_id":"admin-1","message_id":"m1","title":"Hi {first_name}"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := postEdit(tt.body); rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, rec.Code, tt.status)
+		}
+	}
+	if len(fake.history) != 0 || len(fake.updates) != 0 {
+		t.Errorf("rejected edits wrote %d history rows and %d updates", len(fake.history), len(fake.updates))
+	}
+}
+
+func TestEditMessageFailsWhenCopiesAreNotUpdated(t *testing.T) {
+	fake := &fakeEdits{personalized: true, failRecipients: true}
+	useSupabase(t, fake)
+
+	rec := postEdit(`{"admin_id":"admin-1","message_id":"m1","title":"New"}`)
+	var body map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusInternalServerError || body["code"] != "persistence_failed" {
+		t.Errorf("got %d %v, want 500 persistence_failed", rec.Code, body)
+	}
+	// The message is left as it was, so the edit can be resubmitted
+	if len(fake.history) != 0 || len(fake.updates) != 0 {
+		t.Errorf("failed edit wrote %d history rows and %d updates", len(fake.history), len(fake.updates))
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"attendance-system/apierror"
+)
+
+// SentMessage is a broadcast as seen by the admin who sent it
+type SentMessage struct {
+	ID         string  `json:"id"`
+	AdminID    string  `json:"admin_id"`
+	GroupID    *string `json:"group_id"`
+	Title      string  `json:"title"`
+	Message    string  `json:"message"`
+	SentToAll  bool    `json:"sent_to_all"`
+	Priority   string  `json:"priority"`
+	ExpiresAt  *string `json:"expires_at"`
+	CreatedAt  string  `json:"created_at"`
+	EditedAt   *string `json:"edited_at"`
+	RecalledAt *string `json:"recalled_at"`
+}
+
+const sentMessageSelect = "id,admin_id,group_id,title,message,sent_to_all,priority,expires_at,created_at,edited_at,recalled_at"
+
+// MessageReceipt is one recipient's delivery and read state
+type MessageReceipt struct {
+	StudentUUID string  `json:"-"`
+	StudentID   string  `json:"student_id"`
+	StudentName string  `json:"student_name"`
+	IsRead      bool    `json:"is_read"`
+	ReadAt      *string `json:"read_at"`
+	DeliveredAt string  `json:"delivered_at"`
+
+	// The recipient's own text when the message is personalized
+	Rendered *RenderedMessage `json:"-"`
+}
+
+func readPercent(read, delivered int) float64 {
+	if delivered == 0 {
+		return 0
+	}
+	return float64(read) * 100 / float64(delivered)
+}
+
+// fetchOwnedMessage loads a message only if it was sent by adminID
+func fetchOwnedMessage(ctx context.Context, adminID, messageID string) (*SentMessage, error) {
+	var messages []SentMessage
+	messageURL := from("broadcast_messages").Eq("id", messageID).Eq("admin_id", adminID).Select(sentMessageSelect).URL()
+	if err := supabaseGetJSON(ctx, messageURL, &messages); err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	return &messages[0], nil
+}
+
+// fetchMessageReceipts lists every recipient of a message with read state
+func fetchMessageReceipts(ctx context.Context, messageID string, unreadOnly bool) ([]MessageReceipt, error) {
+	receiptsQuery := from("message_recipients").Eq("message_id", messageID).
+		Select("is_read,read_at,created_at,rendered_title,rendered_message,students(id,student_id,student_name)").Order("created_at.asc")
+	if unreadOnly {
+		receiptsQuery.Eq("is_read", "false")
+	}
+
+	var rows []struct {
+		IsRead          bool    `json:"is_read"`
+		ReadAt          *string `json:"read_at"`
+		CreatedAt       string  `json:"created_at"`
+		RenderedTitle   *string `json:"rendered_title"`
+		RenderedMessage *string `json:"rendered_message"`
+		Students        struct {
+			ID          string `json:"id"`
+			StudentID   string `json:"student_id"`
+			StudentName string `json:"student_name"`
+		} `json:"students"`
+	}
+	if err := supabaseGetJSON(ctx, receiptsQuery.URL(), &rows); err != nil {
+		return nil, err
+	}
+
+	receipts := make([]MessageReceipt, 0, len(rows))
+	for _, row := range rows {
+		receipt := MessageReceipt{
+			StudentUUID: row.Students.ID,
+			StudentID:   row.Students.StudentID,
+			StudentName: row.Students.StudentName,
+			IsRead:      row.IsRead,
+			ReadAt:      row.ReadAt,
+			DeliveredAt: row.CreatedAt,
+		}
+		if row.RenderedTitle != nil && row.RenderedMessage != nil {
+			receipt.Rendered = &RenderedMessage{Title: *row.RenderedTitle, Message: *row.RenderedMessage}
+		}
+		receipts = append(receipts, receipt)
+	}
+	return receipts, nil
+}
+
+// writeOwnedMessageError reports why an admin can't access a message
+func writeOwnedMessageError(w http.ResponseWriter, r *http.Request, err error) {
+	if err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Database error", err))
+		return
+	}
+	writeError(w, r, http.StatusNotFound, "Message not found")
+}
+
+// Handler: GET /api/get-sent-messages?admin_id=xxx&page=1&limit=20
+// Lists an admin's broadcasts with delivered/read counts
+func getSentMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	adminID := r.URL.Query().Get("admin_id")
+	w.Header().Set("Content-Type", "application/json")
+	if adminID == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id is required")
+		return
+	}
+
+	page := 1
+	limit := 20
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	// Embedded counts: one aliased for all recipients, one filtered to read ones
+	listURL := from("broadcast_messages").Eq("admin_id", adminID).
+		Select(sentMessageSelect+",delivered:message_recipients(count),read:message_recipients(count)").
+		Eq("read.is_read", "true").Order("created_at.desc").Limit(limit).Offset((page - 1) * limit).URL()
+
+	var rows []struct {
+		SentMessage
+		Delivered []struct {
+			Count int `json:"count"`
+		} `json:"delivered"`
+		Read []struct {
+			Count int `json:"count"`
+		} `json:"read"`
+	}
+	if err := supabaseGetJSON(r.Context(), listURL, &rows); err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to fetch sent messages", err))
+		return
+	}
+
+	messages := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		delivered, read := 0, 0
+		if len(row.Delivered) > 0 {
+			delivered = row.Delivered[0].Count
+		}
+		if len(row.Read) > 0 {
+			read = row.Read[0].Count
+		}
+		messages = append(messages, map[string]interface{}{
+			"id":           row.ID,
+			"title":        row.Title,
+			"message":      row.Message,
+			"group_id":     row.GroupID,
+			"sent_to_all":  row.SentToAll,
+			"created_at":   row.CreatedAt,
+			"priority":     row.Priority,
+			"expires_at":   row.ExpiresAt,
+			"edited_at":    row.EditedAt,
+			"recalled_at":  row.RecalledAt,
+			"delivered":    delivered,
+			"read":         read,
+			"unread":       delivered - read,
+			"read_percent": readPercent(read, delivered),
+		})
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"messages": messages,
+		"count":    len(messages),
+		"page":     page,
+		"limit":    limit,
+		"hasMore":  len(messages) == limit,
+	})
+}
+
+// Handler: GET /api/get-message-receipts?admin_id=xxx&message_id=xxx
+// Shows who has and hasn't read one message
+func getMessageReceiptsHandler(w http.ResponseWriter, r *http.Request) {
+	adminID := r.URL.Query().Get("admin_id")
+	messageID := r.URL.Query().Get("message_id")
+	if adminID == "" || messageID == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id and message_id are required")
+		return
+	}
+
+	message, err := fetchOwnedMessage(r.Context(), adminID, messageID)
+	if err != nil || message == nil {
+		writeOwnedMessageError(w, r, err)
+		return
+	}
+
+	receipts, err := fetchMessageReceipts(r.Context(), messageID, false)
+	if err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to fetch receipts", err))
+		return
+	}
+
+	readList := make([]MessageReceipt, 0)
+	unreadList := make([]MessageReceipt, 0)
+	for _, receipt := range receipts {
+		if receipt.IsRead {
+			readList = append(readList, receipt)
+		} else {
+			unreadList = append(unreadList, receipt)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":      message,
+		"delivered":    len(receipts),
+		"read_count":   len(readList),
+		"unread_count": len(unreadList),
+		"read_percent": readPercent(len(readList), len(receipts)),
+		"read":         readList,
+		"unread":       unreadList,
+	})
+}
+
+// Handler: GET /api/export-unread?admin_id=xxx&message_id=xxx
+// Downloads the students who haven't read a message as CSV
+func exportUnreadHandler(w http.ResponseWriter, r *http.Request) {
+	adminID := r.URL.Query().Get("admin_id")
+	messageID := r.URL.Query().Get("message_id")
+	if adminID == "" || messageID == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id and message_id are required")
+		return
+	}
+
+	message, err := fetchOwnedMessage(r.Context(), adminID, messageID)
+	if err != nil || message == nil {
+		writeOwnedMessageError(w, r, err)
+		return
+	}
+
+	unread, err := fetchMessageReceipts(r.Context(), messageID, true)
+	if err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to fetch receipts", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=unread_%s_%s.csv", messageID, time.Now().Format("20060102_150405")))
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"StudentID", "StudentName", "DeliveredAt"})
+	for _, receipt := range unread {
+		writer.Write([]string{receipt.StudentID, receipt.StudentName, receipt.DeliveredAt})
+	}
+	writer.Flush()
+}
+
+// Handler: POST /api/renotify-unread
+// Sends the message again as a push reminder to recipients who haven't read it
+func renotifyUnreadHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		AdminID   string `json:"admin_id"`
+		MessageID string `json:"message_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if data.AdminID == "" || data.MessageID == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id and message_id are required")
+		return
+	}
+
+	message := loadEditableMessage(w, r, data.AdminID, data.MessageID)
+	if message == nil {
+		return
+	}
+
+	unread, err := fetchMessageReceipts(r.Context(), data.MessageID, true)
+	if err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to fetch receipts", err))
+		return
+	}
+
+	studentIDs := make([]string, 0, len(unread))
+	studentUUIDs := make([]string, 0, len(unread))
+	for _, receipt := range unread {
+		studentIDs = append(studentIDs, receipt.StudentID)
+		studentUUIDs = append(studentUUIDs, receipt.StudentUUID)
+	}
+
+	// Personalized messages go out with each student's own text, as in the
+	// original delivery
+	batches := recipientBatches(studentIDs, studentUUIDs, func(i int) RenderedMessage {
+		if unread[i].Rendered != nil {
+			return *unread[i].Rendered
+		}
+		return RenderedMessage{Title: message.Title, Message: message.Message}
+	})
+	delivered := 0
+	for _, batch := range batches {
+		publishBroadcastMessage(message.ID, batch.content.Title, batch.content.Message, batch.studentIDs)
+		results := notifyStudents(r.Context(), batch.studentUUIDs, Notification{
+			Title: "Reminder: " + batch.content.Title,
+			Body:  batch.content.Message,
+			Data: map[string]string{
+				"type":       "broadcast_message",
+				"message_id": message.ID,
+				"title":      batch.content.Title,
+				"message":    batch.content.Message,
+			},
+		})
+		for _, result := range results {
+			if result.Success {
+				delivered++
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":          true,
+		"unread_students":  len(unread),
+		"devices_notified": delivered,
+	})
+}
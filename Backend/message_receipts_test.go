@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeReceipts serves one message sent by admin-1 to two students, one of
+// whom has read it
+func fakeReceipts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	switch r.URL.Path {
+	case "/rest/v1/broadcast_messages":
+		messages := []map[string]interface{}{}
+		if query.Get("id") == "eq.m1" && query.Get("admin_id") == "eq.admin-1" {
+			messages = append(messages, map[string]interface{}{"id": "m1", "admin_id": "admin-1", "title": "Hi", "message": "Hello"})
+		}
+		json.NewEncoder(w).Encode(messages)
+	case "/rest/v1/message_recipients":
+		receipts := []map[string]interface{}{
+			{"is_read": true, "read_at": "2026-01-05T09:00:00", "created_at": "2026-01-05T08:00:00",
+				"students": map[string]string{"id": "u1", "student_id": "ST001", "student_name": "Ann"}},
+			{"is_read": false, "created_at": "2026-01-05T08:00:00",
+				"students": map[string]string{"id": "u2", "student_id": "ST002", "student_name": "Ben"}},
+		}
+		if query.Get("is_read") == "eq.false" {
+			receipts = receipts[1:]
+		}
+		json.NewEncoder(w).Encode(receipts)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestExportUnread(t *testing.T) {
+	useSupabase(t, http.HandlerFunc(fakeReceipts))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export-unread?admin_id=admin-1&message_id=m1", nil)
+	rec := httptest.NewRecorder()
+	exportUnreadHandler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	want := "StudentID,StudentName,DeliveredAt\nST002,Ben,2026-01-05T08:00:00\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("got CSV %q, want %q", got, want)
+	}
+
+	// Another admin's message is not found rather than exported
+	req = httptest.NewRequest(http.MethodGet, "/api/export-unread?admin_id=admin-2&message_id=m1", nil)
+	rec = httptest.NewRecorder()
+	exportUnreadHandler(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("other admin: status %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestReadPercent(t *testing.T) {
+	if got := readPercent(1, 4); got != 25 {
+		t.Errorf("readPercent(1, 4) = %v, want 25", got)
+	}
+	if got := readPercent(0, 0); got != 0 {
+		t.Errorf("readPercent(0, 0) = %v, want 0", got)
+	}
+}
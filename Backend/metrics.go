@@ -0,0 +1,316 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics are exposed at GET /metrics in the Prometheus text format
+// (version 0.0.4). The collectors below are the few the server needs:
+// counters and histograms keyed by label values, and gauges computed at
+// scrape time.
+
+var (
+	httpRequests = newCounterVec("http_requests_total",
+		"HTTP requests by method, route pattern and status code.", "method", "route", "status")
+	httpDuration = newHistogramVec("http_request_duration_seconds",
+		"HTTP request latency by method and route pattern.", defaultBuckets, "method", "route")
+
+	supabaseRequests = newCounterVec("supabase_requests_total",
+		"Supabase REST calls by method, table and outcome (ok, error).", "method", "table", "outcome")
+	supabaseDuration = newHistogramVec("supabase_request_duration_seconds",
+		"Supabase REST call latency by method and table.", defaultBuckets, "method", "table")
+	supabaseRetries = newCounterVec("supabase_retries_total",
+		"Supabase reads retried after a failed attempt, by table.", "table")
+
+	attendanceSubmissions = newCounterVec("attendance_submissions_total",
+		"Attendance submissions by status (Present, Absent).", "status")
+	windowSubmissions = newHistogramVec("attendance_window_submissions",
+		"Submissions per attendance window, observed when the window closes.",
+		[]float64{0, 5, 10, 25, 50, 100, 200, 500, 1000}, "reason")
+
+	cacheLookups = newCounterVec("cache_lookups_total",
+		"Cache lookups by cache (students, groups) and result (hit, miss).", "cache", "result")
+
+	broadcastRecipients = newHistogramVec("broadcast_recipients",
+		"Recipients per broadcast job (fan-out size).",
+		[]float64{1, 10, 50, 100, 250, 500, 1000, 2500, 5000, 10000})
+)
+
+// defaultBuckets are the Prometheus client defaults, in seconds
+var defaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// collector writes one metric family
+type collector interface {
+	writeTo(w *bufio.Writer)
+}
+
+var collectors = []collector{
+	httpRequests, httpDuration,
+	supabaseRequests, supabaseDuration, supabaseRetries,
+	gaugeFunc{"supabase_circuit_open", "1 while the Supabase circuit breaker fails calls fast, else 0.", supabaseCircuitOpen},
+	gaugeFunc{"attendance_windows_active", "Attendance windows currently open.", activeWindowCount},
+	gaugeFunc{"attendance_window_open_submissions", "Submissions so far in windows that are open.", openWindowSubmissions},
+	attendanceSubmissions, windowSubmissions,
+	cacheLookups,
+	broadcastRecipients,
+	gaugeFunc{"go_goroutines", "Number of goroutines.", func() float64 { return float64(runtime.NumGoroutine()) }},
+}
+
+// Handler: GET /metrics
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	out := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.writeTo(out)
+	}
+	out.Flush()
+}
+
+// counterVec is a counter per combination of label values
+type counterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func newCounterVec(name, help string, labels ...string) *counterVec {
+	return &counterVec{name: name, help: help, labels: labels, values: make(map[string]float64)}
+}
+
+// Inc adds one to the series for the given label values
+func (c *counterVec) Inc(values ...string) {
+	key := labelKey(values)
+	c.mu.Lock()
+	c.values[key]++
+	c.mu.Unlock()
+}
+
+func (c *counterVec) writeTo(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, key := range sortedSeries(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, key, "", ""), formatFloat(c.values[key]))
+	}
+}
+
+// histogramVec is a histogram per combination of label values
+type histogramVec struct {
+	name, help string
+	buckets    []float64
+	labels     []string
+
+	mu     sync.Mutex
+	series map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64 // Per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{name: name, help: help, buckets: buckets, labels: labels, series: make(map[string]*histogram)}
+}
+
+// Observe records v in the series for the given label values
+func (h *histogramVec) Observe(v float64, values ...string) {
+	key := labelKey(values)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *histogramVec) writeTo(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedSeries(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "le", formatFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labels, key, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, key, "", ""), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, key, "", ""), s.count)
+	}
+}
+
+// gaugeFunc is a gauge read at scrape time
+type gaugeFunc struct {
+	name, help string
+	value      func() float64
+}
+
+func (g gaugeFunc) writeTo(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", g.name, g.help, g.name, g.name, formatFloat(g.value()))
+}
+
+// Label values are joined with a byte that can't appear in them
+const labelSeparator = "\xff"
+
+func labelKey(values []string) string {
+	return strings.Join(values, labelSeparator)
+}
+
+func sortedSeries[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// formatLabels renders {name="value",...}, plus an extra label (le) when
+// extraName is set
+func formatLabels(names []string, key, extraName, extraValue string) string {
+	var values []string
+	if len(names) > 0 {
+		values = strings.Split(key, labelSeparator)
+	}
+	var b strings.Builder
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name + `="` + escapeLabelValue(values[i]) + `"`)
+	}
+	if extraName != "" {
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extraName + `="` + extraValue + `"`)
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "{" + b.String() + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// matchedRouteKey holds a *string the router fills in with the pattern of
+// the route that served the request, so per-route metrics don't explode
+// into one series per path parameter value
+type matchedRouteKey struct{}
+
+// unmatchedRoute labels requests that reached no route (404/405)
+const unmatchedRoute = "unmatched"
+
+// metricsMiddleware counts requests and records their latency per route
+func metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		routePattern := unmatchedRoute
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), matchedRouteKey{}, &routePattern)))
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		httpRequests.Inc(r.Method, routePattern, strconv.Itoa(rec.status))
+		httpDuration.Observe(time.Since(start).Seconds(), r.Method, routePattern)
+	})
+}
+
+// setMatchedRoute records the served route for metricsMiddleware
+func setMatchedRoute(r *http.Request, pattern string) {
+	if holder, ok := r.Context().Value(matchedRouteKey{}).(*string); ok {
+		*holder = pattern
+	}
+}
+
+// instrumentedTransport records latency and outcome of Supabase calls by
+// table
+type instrumentedTransport struct {
+	base http.RoundTripper
+}
+
+func (t instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	table := supabaseTable(req.URL.Path)
+	supabaseDuration.Observe(time.Since(start).Seconds(), req.Method, table)
+	outcome := "ok"
+	if err != nil || resp.StatusCode >= 400 {
+		outcome = "error"
+	}
+	supabaseRequests.Inc(req.Method, table, outcome)
+	return resp, err
+}
+
+// supabaseTable returns the table of a PostgREST path: /rest/v1/groups -> groups
+func supabaseTable(path string) string {
+	table := strings.TrimPrefix(path, "/rest/v1/")
+	if table == path || table == "" {
+		return "other"
+	}
+	if i := strings.IndexByte(table, '/'); i >= 0 {
+		table = table[:i]
+	}
+	return table
+}
+
+func supabaseCircuitOpen() float64 {
+	if supabaseBreaker.open() {
+		return 1
+	}
+	return 0
+}
+
+// activeWindowCount counts groups with an open attendance window
+func activeWindowCount() float64 {
+	active := 0
+	groupManager.forEachGroup(func(group *GroupData) {
+		if group.WindowActive {
+			active++
+		}
+	})
+	return float64(active)
+}
+
+// openWindowSubmissions sums the submissions of the open windows
+func openWindowSubmissions() float64 {
+	submitted := 0
+	groupManager.forEachGroup(func(group *GroupData) {
+		if group.WindowActive {
+			submitted += len(group.SubmittedStudents)
+		}
+	})
+	return float64(submitted)
+}
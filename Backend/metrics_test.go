@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHistogramExposition(t *testing.T) {
+	h := newHistogramVec("test_latency_seconds", "Test latency.", []float64{1, 5, 10}, "path")
+	for _, v := range []float64{0.5, 3, 3, 20} {
+		h.Observe(v, "a\"b\\c\nd")
+	}
+
+	var out strings.Builder
+	w := bufio.NewWriter(&out)
+	h.writeTo(w)
+	w.Flush()
+
+	want := `# HELP test_latency_seconds Test latency.
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{path="a\"b\\c\nd",le="1"} 1
+test_latency_seconds_bucket{path="a\"b\\c\nd",le="5"} 3
+test_latency_seconds_bucket{path="a\"b\\c\nd",le="10"} 3
+test_latency_seconds_bucket{path="a\"b\\c\nd",le="+Inf"} 4
+test_latency_seconds_sum{path="a\"b\\c\nd"} 26.5
+test_latency_seconds_count{path="a\"b\\c\nd"} 4
+`
+	if out.String() != want {
+		t.Errorf("exposition:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+// sample is one parsed exposition line
+type sample struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+var (
+	sampleLine = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(?:\{(.*)\})? (\S+)$`)
+	labelPair  = regexp.MustCompile(`([a-zA-Z_][a-zA-Z0-9_]*)="((?:[^"\\]|\\.)*)"(?:,|$)`)
+	labelValue = strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n")
+)
+
+// parseExposition parses the text format, failing on any malformed line or
+// a sample of a family without a TYPE
+func parseExposition(t *testing.T, text string) []sample {
+	t.Helper()
+	types := map[string]string{}
+	var samples []sample
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if strings.HasPrefix(line, "# HELP ") {
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) == 4 && fields[0] == "#" && fields[1] == "TYPE" {
+			types[fields[2]] = fields[3]
+			continue
+		}
+		m := sampleLine.FindStringSubmatch(line)
+		if m == nil {
+			t.Fatalf("malformed line %q", line)
+		}
+		value, err := strconv.ParseFloat(m[3], 64)
+		if err != nil {
+			t.Fatalf("line %q: bad value: %v", line, err)
+		}
+		s := sample{name: m[1], labels: map[string]string{}, value: value}
+		rest := m[2]
+		for rest != "" {
+			pair := labelPair.FindStringSubmatchIndex(rest)
+			if pair == nil || pair[0] != 0 {
+				t.Fatalf("line %q: malformed labels", line)
+			}
+			s.labels[rest[pair[2]:pair[3]]] = labelValue.Replace(rest[pair[4]:pair[5]])
+			rest = rest[pair[1]:]
+		}
+		family := s.name
+		if types[family] == "" {
+			family = strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(family, "_bucket"), "_sum"), "_count")
+		}
+		if types[family] == "" {
+			t.Fatalf("sample %q has no TYPE", line)
+		}
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+// seriesKey identifies a histogram series: its name and labels without le
+func seriesKey(name string, labels map[string]string) string {
+	var pairs []string
+	for _, key := range sortedSeries(labels) {
+		if key != "le" {
+			pairs = append(pairs, key+"="+labels[key])
+		}
+	}
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+func TestMetricsScrape(t *testing.T) {
+	saved := config
+	t.Cleanup(func() { config = saved })
+	config = defaultConfig()
+	offlineConfig()
+	useSupabase(t, http.NotFoundHandler())
+	router := newRouter()
+
+	for i := 0; i < 2; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/v1/students/ST-9001/dashboard", nil))
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type %q, want the 0.0.4 text format", ct)
+	}
+	body := rec.Body.String()
+	if strings.Contains(body, "ST-9001") {
+		t.Error("a raw request path leaked into a label")
+	}
+
+	samples := parseExposition(t, body)
+	routed := false
+	buckets := map[string][]sample{}
+	sums := map[string]bool{}
+	counts := map[string]float64{}
+	for _, s := range samples {
+		if s.name == "http_requests_total" && s.labels["route"] == "/api/v1/students/{student_id}/dashboard" && s.value >= 2 {
+			routed = true
+		}
+		switch {
+		case strings.HasSuffix(s.name, "_bucket"):
+			key := seriesKey(strings.TrimSuffix(s.name, "_bucket"), s.labels)
+			buckets[key] = append(buckets[key], s)
+		case strings.HasSuffix(s.name, "_sum"):
+			sums[seriesKey(strings.TrimSuffix(s.name, "_sum"), s.labels)] = true
+		case strings.HasSuffix(s.name, "_count"):
+			counts[seriesKey(strings.TrimSuffix(s.name, "_count"), s.labels)] = s.value
+		}
+	}
+	if !routed {
+		t.Error("requests aren't counted under their route pattern")
+	}
+	if len(buckets) == 0 {
+		t.Fatal("no histogram series scraped")
+	}
+
+	for key, series := range buckets {
+		previousBound, previousCount := -1.0, 0.0
+		for _, s := range series {
+			bound, err := strconv.ParseFloat(s.labels["le"], 64)
+			if err != nil {
+				t.Fatalf("%s: bad le %q", key, s.labels["le"])
+			}
+			if bound <= previousBound || s.value < previousCount {
+				t.Errorf("%s: bucket le=%s (%g) after le=%g (%g) isn't cumulative", key, s.labels["le"], s.value, previousBound, previousCount)
+			}
+			previousBound, previousCount = bound, s.value
+		}
+		if last := series[len(series)-1]; last.labels["le"] != "+Inf" || last.value != counts[key] {
+			t.Errorf("%s: last bucket le=%s holds %g, want le=+Inf holding _count %g", key, last.labels["le"], last.value, counts[key])
+		}
+		if !sums[key] {
+			t.Errorf("%s: no _sum", key)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type contextKey string
+
+const requestIDKey contextKey = "request_id"
+
+const (
+	maxSignatureSkew  = 5 * time.Minute
+	maxSignedBodySize = maxAttachmentSize + 1<<20
+)
+
+// requestID returns the ID assigned to the request by requestIDMiddleware
+func requestID(r *http.Request) string {
+	return requestIDFromContext(r.Context())
+}
+
+// requestIDFromContext returns the request ID carried by ctx, if any
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// statusRecorder remembers the status code for logging. It passes Flush
+// through and supports http.ResponseController via Unwrap, so the SSE
+// stream keeps working behind it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += n
+	return n, err
+}
+
+func (rec *statusRecorder) Flush() {
+	if flusher, ok := rec.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// recoverMiddleware turns a panicking handler into a 500 instead of a
+// dropped connection
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				slog.ErrorContext(r.Context(), "panic serving request", "method", r.Method, "path", r.URL.Path,
+					"panic", fmt.Sprint(err), "stack", string(debug.Stack()))
+				writeError(w, r, http.StatusInternalServerError, "Internal server error")
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestIDMiddleware tags each request with an ID, reusing a sane
+// X-Request-ID from the client, and echoes it in the response
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !validRequestID(id) {
+			raw := make([]byte, 8)
+			rand.Read(raw)
+			id = hex.EncodeToString(raw)
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// loggingMiddleware logs one line per request with status and duration
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		slog.InfoContext(r.Context(), "request", "method", r.Method, "path", r.URL.Path, "status", rec.status,
+			"bytes", rec.bytes, "duration", time.Since(start).Round(time.Millisecond))
+	})
+}
+
+// corsMiddleware allows the configured origins (CORS_ORIGINS, "*" for any)
+// to call the API and answers preflight requests
+func corsMiddleware(origins []string) Middleware {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[origin] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if allowed["*"] {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Add("Vary", "Origin")
+				if origin := r.Header.Get("Origin"); allowed[origin] {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+				}
+			}
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Idempotency-Key, X-Request-ID, X-Timestamp, X-Signature")
+			w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, Retry-After")
+
+			if r.Method == http.MethodOptions {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// unsignedPaths don't need a request signature
+var unsignedPaths = map[string]bool{
+	"/metrics": true,
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// signRequest computes the signature clients send in X-Signature:
+// hex(HMAC-SHA256(secret, METHOD \n PATH \n QUERY \n TIMESTAMP \n hex(SHA256(body))))
+// where QUERY is canonicalQuery of the request's query string
+func signRequest(secret, method, path, query, timestamp string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", method, path, query, timestamp, hex.EncodeToString(bodyHash[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// canonicalQuery is the query as signed: every parameter but ts and sig,
+// sorted by name and encoded the way url.Values.Encode does
+func canonicalQuery(query url.Values) string {
+	signed := make(url.Values, len(query))
+	for key, values := range query {
+		if key != "ts" && key != "sig" {
+			signed[key] = values
+		}
+	}
+	return signed.Encode()
+}
+
+// authMiddleware checks request signatures made with the shared API secret.
+// The timestamp and signature come from X-Timestamp/X-Signature, or from
+// the ts/sig query parameters for clients that can't set headers
+// (EventSource, download links). An empty secret disables the check.
+// Paths in unsignedPaths are for infrastructure (scrapers, probes) and
+// skip it.
+func authMiddleware(secret string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if secret == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if unsignedPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			timestamp := r.Header.Get("X-Timestamp")
+			signature := r.Header.Get("X-Signature")
+			if signature == "" {
+				timestamp = r.URL.Query().Get("ts")
+				signature = r.URL.Query().Get("sig")
+			}
+			if timestamp == "" || signature == "" {
+				writeError(w, r, http.StatusUnauthorized, "Missing request signature")
+				return
+			}
+
+			unix, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil || math.Abs(time.Since(time.Unix(unix, 0)).Seconds()) > maxSignatureSkew.Seconds() {
+				writeError(w, r, http.StatusUnauthorized, "Request timestamp expired")
+				return
+			}
+
+			var body []byte
+			if r.Body != nil {
+				body, err = io.ReadAll(io.LimitReader(r.Body, maxSignedBodySize+1))
+				if err != nil {
+					writeError(w, r, http.StatusBadRequest, "Failed to read request body")
+					return
+				}
+				if len(body) > maxSignedBodySize {
+					writeError(w, r, http.StatusRequestEntityTooLarge, "Request body too large")
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			expected := signRequest(secret, r.Method, r.URL.Path, canonicalQuery(r.URL.Query()), timestamp, body)
+			if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+				writeError(w, r, http.StatusUnauthorized, "Invalid request signature")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimiter is a per-client token bucket
+type rateLimiter struct {
+	rate  float64 // Tokens per second
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+const rateLimiterIdle = 10 * time.Minute
+
+// newRateLimiter allows rate requests per second per client IP with bursts
+// up to burst; a rate of 0 disables limiting
+func newRateLimiter(rate, burst int) *rateLimiter {
+	if burst < rate {
+		burst = rate
+	}
+	rl := &rateLimiter{rate: float64(rate), burst: float64(burst), buckets: make(map[string]*tokenBucket)}
+	if rate > 0 {
+		go rl.sweep()
+	}
+	return rl
+}
+
+// allow takes a token for key, or returns how long until one is available
+func (rl *rateLimiter) allow(key string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: rl.burst, last: now}
+		rl.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(rl.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*rl.rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / rl.rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// sweep forgets clients that have been idle long enough to have a full bucket
+func (rl *rateLimiter) sweep() {
+	for range time.Tick(rateLimiterIdle) {
+		rl.mu.Lock()
+		for key, bucket := range rl.buckets {
+			if time.Since(bucket.last) > rateLimiterIdle {
+				delete(rl.buckets, key)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
+
+// Middleware answers 429 with Retry-After once a client exceeds its rate
+func (rl *rateLimiter) Middleware(next http.Handler) http.Handler {
+	if rl.rate <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		if ok, wait := rl.allow(ip); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, r, http.StatusTooManyRequests, "Too many requests")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthMiddlewareSignsQuery(t *testing.T) {
+	const secret = "test-secret"
+	handler := authMiddleware(secret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signed := url.Values{"group_id": {"g1"}, "admin_id": {"a1"}}
+	signature := signRequest(secret, http.MethodGet, "/api/events", canonicalQuery(signed), timestamp, nil)
+
+	tests := []struct {
+		name   string
+		query  string
+		header bool
+		want   int
+	}{
+		{"headers", "admin_id=a1&group_id=g1", true, http.StatusNoContent},
+		{"parameters in another order", "group_id=g1&admin_id=a1", true, http.StatusNoContent},
+		{"ts and sig in the query", "group_id=g1&ts=" + timestamp + "&admin_id=a1&sig=" + signature, false, http.StatusNoContent},
+		{"changed parameter", "admin_id=a2&group_id=g1", true, http.StatusUnauthorized},
+		{"added parameter", "admin_id=a1&group_id=g1&student_id=s1", true, http.StatusUnauthorized},
+		{"dropped parameter", "group_id=g1", true, http.StatusUnauthorized},
+		{"changed parameter with ts and sig in the query", "group_id=g2&ts=" + timestamp + "&admin_id=a1&sig=" + signature, false, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/events?"+tt.query, nil)
+			if tt.header {
+				req.Header.Set("X-Timestamp", timestamp)
+				req.Header.Set("X-Signature", signature)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareSignsBody(t *testing.T) {
+	const secret = "test-secret"
+	handler := authMiddleware(secret)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The handler still gets the body the middleware read
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	const body = `{"admin_id":"a1"}`
+
+	tests := []struct {
+		name, timestamp, signature, body string
+		want                             int
+	}{
+		{"valid", now, signRequest(secret, http.MethodPost, "/api/send-broadcast", "", now, []byte(body)), body, http.StatusOK},
+		{"changed body", now, signRequest(secret, http.MethodPost, "/api/send-broadcast", "", now, []byte(body)), `{"admin_id":"a2"}`, http.StatusUnauthorized},
+		{"other path", now, signRequest(secret, http.MethodPost, "/api/close-window", "", now, []byte(body)), body, http.StatusUnauthorized},
+		{"wrong secret", now, signRequest("other", http.MethodPost, "/api/send-broadcast", "", now, []byte(body)), body, http.StatusUnauthorized},
+		{"stale timestamp", stale, signRequest(secret, http.MethodPost, "/api/send-broadcast", "", stale, []byte(body)), body, http.StatusUnauthorized},
+		{"unsigned", "", "", body, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/send-broadcast", strings.NewReader(tt.body))
+			req.Header.Set("X-Timestamp", tt.timestamp)
+			req.Header.Set("X-Signature", tt.signature)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusOK && rec.Body.String() != body {
+				t.Errorf("handler got body %q, want %q", rec.Body, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Notification is a push message sent to one or more devices
+type Notification struct {
+	Title string
+	Body  string
+	Data  map[string]string // Delivered to the app as message.data
+
+	// Title and Body may both be empty for a data-only update
+}
+
+// DeliveryResult reports what happened to a single device token
+type DeliveryResult struct {
+	Token        string `json:"token"`
+	Success      bool   `json:"success"`
+	MessageID    string `json:"message_id,omitempty"`
+	Error        string `json:"error,omitempty"`
+	InvalidToken bool   `json:"invalid_token,omitempty"` // Token is dead and should be pruned
+}
+
+// Notifier delivers push notifications to device tokens
+type Notifier interface {
+	Send(ctx context.Context, tokens []string, n Notification) []DeliveryResult
+}
+
+// notifier is the active push backend, set up in main
+var notifier Notifier = logNotifier{}
+
+const (
+	notifyTimeout     = 30 * time.Second
+	tokenLookupChunk  = 100 // Keeps user_id=in.(...) URLs bounded
+	notifyConcurrency = 10
+)
+
+// logNotifier only logs notifications; used when FCM isn't configured
+type logNotifier struct{}
+
+func (logNotifier) Send(ctx context.Context, tokens []string, n Notification) []DeliveryResult {
+	results := make([]DeliveryResult, 0, len(tokens))
+	for _, token := range tokens {
+		slog.DebugContext(ctx, "push (log only)", "title", n.Title, "token", truncateToken(token)+"...")
+		results = append(results, DeliveryResult{Token: token, Success: true})
+	}
+	return results
+}
+
+// newNotifier picks the push backend from config, falling back to logging
+func newNotifier(cfg Config) Notifier {
+	if cfg.Notifier == "log" {
+		slog.Info("push notifications: logging only", "reason", "NOTIFIER=log")
+		return logNotifier{}
+	}
+
+	fcm, err := newFCMNotifier(cfg)
+	if err != nil {
+		if cfg.Notifier == "fcm" {
+			slog.Warn("NOTIFIER=fcm but FCM could not be initialized", "error", err)
+		}
+		slog.Info("push notifications: logging only", "reason", "FCM not configured")
+		return logNotifier{}
+	}
+
+	slog.Info("push notifications: FCM", "project", fcm.projectID)
+	return fcm
+}
+
+func truncateToken(token string) string {
+	if len(token) > 12 {
+		return token[:12]
+	}
+	return token
+}
+
+// lookupFCMTokens returns every registered token for the given users
+func lookupFCMTokens(ctx context.Context, userType string, userIDs []string) []string {
+	var tokens []string
+	seen := make(map[string]bool)
+
+	for start := 0; start < len(userIDs); start += tokenLookupChunk {
+		end := start + tokenLookupChunk
+		if end > len(userIDs) {
+			end = len(userIDs)
+		}
+
+		tokensURL := from("fcm_tokens").Eq("user_type", userType).In("user_id", userIDs[start:end]).Select("fcm_token").URL()
+		req, err := newSupabaseRequest(ctx, "GET", tokensURL, nil)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to look up FCM tokens", "error", err)
+			continue
+		}
+
+		resp, err := supabaseClient.Do(req)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to look up FCM tokens", "error", err)
+			continue
+		}
+
+		var rows []struct {
+			FCMToken string `json:"fcm_token"`
+		}
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&rows); err != nil {
+				slog.ErrorContext(ctx, "failed to parse FCM tokens", "error", err)
+			}
+		} else {
+			slog.ErrorContext(ctx, "failed to look up FCM tokens", "status", resp.StatusCode)
+		}
+		resp.Body.Close()
+
+		for _, row := range rows {
+			if row.FCMToken != "" && !seen[row.FCMToken] {
+				seen[row.FCMToken] = true
+				tokens = append(tokens, row.FCMToken)
+			}
+		}
+	}
+	return tokens
+}
+
+// pruneFCMTokens removes tokens FCM reported as unregistered or invalid
+func pruneFCMTokens(ctx context.Context, tokens []string) {
+	for _, token := range tokens {
+		deleteURL := from("fcm_tokens").Eq("fcm_token", token).URL()
+		req, err := newSupabaseRequest(ctx, "DELETE", deleteURL, nil)
+		if err != nil {
+			continue
+		}
+		resp, err := supabaseClient.Do(req)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to delete FCM token", "error", err)
+			continue
+		}
+		resp.Body.Close()
+
+		fcmTokensCache.mu.Lock()
+		for key, cached := range fcmTokensCache.tokens {
+			if cached == token {
+				delete(fcmTokensCache.tokens, key)
+			}
+		}
+		fcmTokensCache.mu.Unlock()
+	}
+	if len(tokens) > 0 {
+		slog.DebugContext(ctx, "pruned invalid FCM tokens", "count", len(tokens))
+	}
+}
+
+// notifyUsers pushes a notification to every device of the given users,
+// prunes tokens FCM rejected and returns the per-token results
+func notifyUsers(ctx context.Context, userType string, userIDs []string, n Notification) []DeliveryResult {
+	tokens := lookupFCMTokens(ctx, userType, userIDs)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	// Deliveries outlive the request that triggered them
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), notifyTimeout)
+	defer cancel()
+	results := notifier.Send(ctx, tokens, n)
+
+	sent := 0
+	var invalid []string
+	for _, result := range results {
+		if result.Success {
+			sent++
+		}
+		if result.InvalidToken {
+			invalid = append(invalid, result.Token)
+		}
+	}
+	pruneFCMTokens(ctx, invalid)
+
+	slog.DebugContext(ctx, "push delivered", "title", n.Title, "sent", sent, "devices", len(tokens), "invalid", len(invalid))
+	return results
+}
+
+// notifyStudents pushes a notification to students by their UUIDs
+func notifyStudents(ctx context.Context, studentUUIDs []string, n Notification) []DeliveryResult {
+	return notifyUsers(ctx, "student", studentUUIDs, n)
+}
+
+// eligibleStudents returns the students allowed to submit to a group's window:
+// the group's members, or every student when the window isn't group-only
+func eligibleStudents(ctx context.Context, groupID string, groupOnly bool) ([]audienceStudent, error) {
+	if groupOnly {
+		var rows []struct {
+			Students audienceStudent `json:"students"`
+		}
+		membersURL := from("group_students").Eq("group_id", groupID).Select("students(id,student_id,student_name)").URL()
+		if err := supabaseGetJSON(ctx, membersURL, &rows); err != nil {
+			return nil, err
+		}
+		students := make([]audienceStudent, 0, len(rows))
+		for _, row := range rows {
+			students = append(students, row.Students)
+		}
+		return students, nil
+	}
+
+	var students []audienceStudent
+	if err := supabaseGetJSON(ctx, from("students").Select("id,student_id,student_name").URL(), &students); err != nil {
+		return nil, err
+	}
+	return students, nil
+}
+
+// eligibleStudentUUIDs is eligibleStudents reduced to UUIDs
+func eligibleStudentUUIDs(ctx context.Context, groupID string, groupOnly bool) []string {
+	students, err := eligibleStudents(ctx, groupID, groupOnly)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load eligible students", "group_id", groupID, "error", err)
+		return nil
+	}
+	uuids := make([]string, 0, len(students))
+	for _, student := range students {
+		uuids = append(uuids, student.ID)
+	}
+	return uuids
+}
+
+// notifyWindowOpened pushes a "window open" notification to eligible students
+func notifyWindowOpened(ctx context.Context, groupID, groupName string, groupOnly bool, endTime time.Time) {
+	if groupID == "default" {
+		return
+	}
+	students := eligibleStudentUUIDs(ctx, groupID, groupOnly)
+	notifyStudents(ctx, students, Notification{
+		Title: "Attendance window open",
+		Body:  fmt.Sprintf("Attendance for %s is open until %s", groupName, endTime.Format("15:04")),
+		Data: map[string]string{
+			"type":       "window_opened",
+			"group_id":   groupID,
+			"group_name": groupName,
+			"end_time":   endTime.Format(time.RFC3339),
+		},
+	})
+}
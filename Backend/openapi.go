@@ -0,0 +1,773 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"attendance-system/apierror"
+)
+
+// The OpenAPI document is built from the router at runtime, so it can't
+// list a route that doesn't exist or miss one that does. What the router
+// doesn't know - parameters and response shapes - comes from endpointDocs
+// below and from the Go types handlers encode. The generated copy is
+// committed as openapi.json and the Go client in client/ is generated from
+// it; TestContract verifies both are current and probes the handlers
+// against the spec.
+
+const openAPIVersion = "3.0.3"
+
+// Schema is an OpenAPI (JSON Schema subset) schema object
+type Schema map[string]interface{}
+
+func stringSchema() Schema {
+	return Schema{"type": "string"}
+}
+
+func dateTimeSchema() Schema {
+	return Schema{"type": "string", "format": "date-time"}
+}
+
+func integerSchema() Schema {
+	return Schema{"type": "integer"}
+}
+
+func numberSchema() Schema {
+	return Schema{"type": "number"}
+}
+
+func booleanSchema() Schema {
+	return Schema{"type": "boolean"}
+}
+
+func arrayOf(items Schema) Schema {
+	return Schema{"type": "array", "items": items}
+}
+
+func ref(name string) Schema {
+	return Schema{"$ref": "#/components/schemas/" + name}
+}
+
+// nullable allows null; a $ref can't carry siblings, so it is wrapped
+func nullable(s Schema) Schema {
+	if _, ok := s["$ref"]; ok {
+		return Schema{"allOf": []Schema{s}, "nullable": true}
+	}
+	out := Schema{"nullable": true}
+	for k, v := range s {
+		out[k] = v
+	}
+	return out
+}
+
+// anyObject is a JSON object whose properties aren't documented
+func anyObject() Schema {
+	return Schema{"type": "object", "additionalProperties": true}
+}
+
+// apiField is a named request parameter or response property
+type apiField struct {
+	name     string
+	schema   Schema
+	required bool
+}
+
+func req(name string, schema Schema) apiField {
+	return apiField{name: name, schema: schema, required: true}
+}
+
+func opt(name string, schema Schema) apiField {
+	return apiField{name: name, schema: schema}
+}
+
+func objectOf(fields ...apiField) Schema {
+	properties := Schema{}
+	var required []string
+	for _, f := range fields {
+		properties[f.name] = f.schema
+		if f.required {
+			required = append(required, f.name)
+		}
+	}
+	s := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+// endpointDoc documents a legacy endpoint; v1 routes share the doc of the
+// legacy route served by the same handler
+type endpointDoc struct {
+	summary  string
+	tag      string
+	input    legacyInput // How legacy POSTs carry params; GET and DELETE use the query
+	params   []apiField
+	status   int        // Success status, 200 when zero
+	response []apiField // JSON success body
+	content  string     // Media type of non-JSON success bodies
+}
+
+var (
+	groupIDParam   = opt("group_id", stringSchema())
+	adminIDParam   = req("admin_id", stringSchema())
+	studentIDParam = req("student_id", stringSchema())
+	messageIDParam = req("message_id", stringSchema())
+	successField   = req("success", booleanSchema())
+	messageField   = req("message", stringSchema())
+	statusField    = req("status", stringSchema())
+	countField     = req("count", integerSchema())
+)
+
+var endpointDocs = map[string]endpointDoc{
+	"/api/openapi.json": {summary: "This OpenAPI document", tag: "Meta", response: []apiField{
+		req("openapi", stringSchema()), req("paths", anyObject())}},
+	"/api/admin/config": {summary: "Effective non-secret server settings and where each came from", tag: "Meta",
+		response: []apiField{req("settings", anyObject()), req("sources", anyObject())}},
+	"/metrics": {summary: "Prometheus metrics in the text exposition format", tag: "Meta", content: "text/plain"},
+	"/healthz": {summary: "Liveness probe: the process is serving", tag: "Meta",
+		response: []apiField{statusField}},
+	"/readyz": {summary: "Readiness probe: Supabase is reachable (503 unavailable when not)", tag: "Meta",
+		response: []apiField{statusField, req("checks", objectOf(req("supabase", stringSchema())))}},
+
+	// Sessions
+	"/api/admin-login": {summary: "Log in as an admin", tag: "Sessions",
+		params: []apiField{req("username", stringSchema()), req("password", stringSchema())},
+		response: []apiField{successField, messageField, req("admin", objectOf(
+			req("id", stringSchema()), req("username", stringSchema())))}},
+	"/api/student-login": {summary: "Log in as a student by roll number and name", tag: "Sessions",
+		params: []apiField{studentIDParam, req("student_name", stringSchema())},
+		response: []apiField{successField, messageField, req("student", objectOf(
+			req("id", stringSchema()), req("student_id", stringSchema()), req("student_name", stringSchema())))}},
+
+	// Attendance windows
+	"/api/set-center": {summary: "Set a group's attendance location and radius", tag: "Attendance",
+		params: []apiField{groupIDParam, req("lat", numberSchema()), req("lon", numberSchema()),
+			opt("threshold", numberSchema()), opt("session_name", stringSchema())},
+		response: []apiField{successField, messageField}},
+	"/api/start-window": {summary: "Open an attendance window", tag: "Attendance",
+		params:   []apiField{groupIDParam, opt("group_only", booleanSchema())},
+		response: []apiField{successField, messageField, req("group_id", stringSchema())}},
+	"/api/close-window": {summary: "Close the open attendance window", tag: "Attendance",
+		params:   []apiField{groupIDParam},
+		response: []apiField{successField, messageField, req("group_id", stringSchema())}},
+	"/api/submit-attendance": {summary: "Submit a student's location to the open window", tag: "Attendance",
+		params: []apiField{groupIDParam, studentIDParam, opt("student_name", stringSchema()),
+			req("lat", numberSchema()), req("lon", numberSchema())},
+		response: []apiField{statusField, req("distance", stringSchema()), req("timestamp", stringSchema())}},
+	"/api/download-csv": {summary: "Download the window's attendance sheet", tag: "Attendance",
+		params: []apiField{groupIDParam}, content: "text/csv"},
+	"/api/get-admin-location": {summary: "Get a group's attendance location", tag: "Attendance",
+		params: []apiField{groupIDParam},
+		response: []apiField{req("lat", numberSchema()), req("lon", numberSchema()), req("session_name", stringSchema()),
+			req("threshold", numberSchema()), req("window_active", booleanSchema())}},
+	"/api/get-window-status": {summary: "Get the state of a group's window, or a student's best open window", tag: "Attendance",
+		params: []apiField{groupIDParam, opt("student_id", stringSchema())},
+		response: []apiField{req("active", booleanSchema()), req("remaining_seconds", integerSchema()),
+			req("session_name", stringSchema()), opt("group_id", stringSchema()), opt("group_name", stringSchema()),
+			opt("window_count", integerSchema()), opt("start_time", stringSchema())}},
+	"/api/get-all-student-locations": {summary: "List the submissions of a group's window", tag: "Attendance",
+		params:   []apiField{groupIDParam},
+		response: []apiField{req("students", arrayOf(ref("StudentLocation"))), countField}},
+	"/api/get-window-summary": {summary: "Summarize a group's window for the live view", tag: "Attendance",
+		params: []apiField{groupIDParam, opt("bucket_seconds", integerSchema())},
+		response: []apiField{req("group_id", stringSchema()), req("group_name", stringSchema()),
+			req("group_only", booleanSchema()), req("window_active", booleanSchema()),
+			req("remaining_seconds", integerSchema()), req("roster_size", integerSchema()),
+			req("submitted_count", integerSchema()), req("present_count", integerSchema()),
+			req("absent_count", integerSchema()), req("pending_count", integerSchema()),
+			req("pending_students", arrayOf(ref("PendingStudent"))),
+			req("submission_histogram", objectOf(req("bucket_seconds", integerSchema()),
+				req("buckets", arrayOf(ref("HistogramBucket"))))),
+			req("distance_distribution", objectOf(req("threshold_meters", numberSchema()),
+				req("buckets", arrayOf(ref("DistanceBucket"))), opt("min_meters", numberSchema()),
+				opt("max_meters", numberSchema()), opt("mean_meters", numberSchema()), opt("median_meters", numberSchema()))),
+			opt("window_start", dateTimeSchema()), opt("window_end", dateTimeSchema())}},
+	"/api/schedule-window": {summary: "Schedule a window to open later, optionally recurring", tag: "Attendance", input: inputJSON,
+		params: []apiField{adminIDParam, req("group_id", stringSchema()), opt("group_only", booleanSchema()),
+			opt("opens_at", dateTimeSchema()), opt("recurrence", stringSchema())},
+		response: []apiField{successField, messageField, req("scheduled_id", stringSchema()),
+			req("opens_at", dateTimeSchema()), req("closes_at", dateTimeSchema()), req("recurrence", nullable(stringSchema()))}},
+	"/api/cancel-scheduled-window": {summary: "Cancel a scheduled window", tag: "Attendance", input: inputJSON,
+		params:   []apiField{adminIDParam, req("scheduled_id", stringSchema())},
+		response: []apiField{successField, messageField}},
+	"/api/get-scheduled-windows": {summary: "List an admin's scheduled windows", tag: "Attendance",
+		params:   []apiField{adminIDParam, groupIDParam},
+		response: []apiField{req("scheduled_windows", arrayOf(ref("ScheduledWindow"))), countField}},
+
+	// Groups
+	"/api/create-group": {summary: "Create a group", tag: "Groups",
+		params: []apiField{adminIDParam, req("name", stringSchema())},
+		response: []apiField{successField, req("group", objectOf(
+			req("id", stringSchema()), req("name", stringSchema())))}},
+	"/api/get-my-groups": {summary: "List an admin's groups", tag: "Groups",
+		params:   []apiField{adminIDParam},
+		response: []apiField{req("groups", arrayOf(anyObject())), countField}},
+	"/api/add-students-to-group": {summary: "Add students to a group", tag: "Groups",
+		params: []apiField{req("group_id", stringSchema()),
+			req("student_ids", Schema{"type": "string", "description": "Comma-separated student UUIDs"})},
+		response: []apiField{successField, messageField}},
+	"/api/get-group-students": {summary: "List a group's students", tag: "Groups",
+		params:   []apiField{req("group_id", stringSchema())},
+		response: []apiField{req("students", arrayOf(anyObject())), countField}},
+	"/api/delete-group": {summary: "Delete a group", tag: "Groups",
+		params:   []apiField{req("group_id", stringSchema())},
+		response: []apiField{successField, messageField}},
+	"/api/update-session-name": {summary: "Rename a group", tag: "Groups",
+		params:   []apiField{req("group_id", stringSchema()), req("session_name", stringSchema())},
+		response: []apiField{successField, messageField, req("session_name", stringSchema())}},
+
+	// Students
+	"/api/get-all-students": {summary: "List students, paginated", tag: "Students",
+		params: []apiField{opt("page", integerSchema()), opt("limit", integerSchema()),
+			opt("view", Schema{"type": "string", "enum": []string{"list"}})},
+		response: []apiField{req("students", arrayOf(anyObject())), countField, req("page", integerSchema()),
+			req("limit", integerSchema()), req("hasMore", booleanSchema()), req("totalPages", integerSchema())}},
+	"/api/add-student": {summary: "Register a student", tag: "Students", input: inputJSON,
+		params: []apiField{studentIDParam, req("student_name", stringSchema())},
+		response: []apiField{successField, messageField, req("student", objectOf(
+			req("id", stringSchema()), req("student_id", stringSchema()), req("student_name", stringSchema())))}},
+	"/api/get-student-attendance-history": {summary: "List a student's attendance records", tag: "Students",
+		params:   []apiField{studentIDParam},
+		response: []apiField{req("attendance_history", arrayOf(anyObject())), countField}},
+	"/api/get-student-dashboard": {summary: "Everything the student home screen shows", tag: "Students",
+		params: []apiField{studentIDParam},
+		response: []apiField{req("student_id", stringSchema()), req("active_windows", arrayOf(ref("ActiveWindow"))),
+			req("pending_count", integerSchema()), req("upcoming_windows", arrayOf(ref("UpcomingWindow"))),
+			req("unread_messages", integerSchema()), req("unread_replies", integerSchema()),
+			req("server_time", dateTimeSchema())}},
+	"/api/save-fcm-token": {summary: "Register a device for push notifications", tag: "Students", input: inputJSON,
+		params: []apiField{req("user_id", stringSchema()), req("user_type", stringSchema()),
+			req("fcm_token", stringSchema()), opt("device_type", stringSchema())},
+		response: []apiField{statusField}},
+
+	// Broadcast messages
+	"/api/send-broadcast-message": {summary: "Queue a broadcast message", tag: "Messages", input: inputJSON, status: http.StatusAccepted,
+		params: []apiField{adminIDParam, opt("title", stringSchema()), opt("message", stringSchema()),
+			groupIDParam, opt("send_to_all", booleanSchema()), opt("audience", ref("BroadcastAudience")),
+			opt("priority", stringSchema()), opt("expires_at", dateTimeSchema()),
+			opt("attachment_ids", arrayOf(stringSchema())), opt("template_id", stringSchema()),
+			opt("template_group_id", stringSchema()), opt("idempotency_key", stringSchema())},
+		response: []apiField{successField, messageField, req("message_id", stringSchema()), req("job_id", stringSchema()),
+			statusField, req("duplicate", booleanSchema()), req("status_url", stringSchema())}},
+	"/api/get-broadcast-status": {summary: "Get a broadcast's delivery progress", tag: "Messages",
+		params:   []apiField{opt("job_id", stringSchema()), opt("message_id", stringSchema())},
+		response: []apiField{req("job", ref("BroadcastJob")), req("progress", numberSchema())}},
+	"/api/upload-attachment": {summary: "Upload a file to attach to a broadcast", tag: "Messages", input: inputRaw, status: http.StatusCreated,
+		params: []apiField{adminIDParam, req("file", Schema{"type": "string", "format": "binary"})},
+		response: []apiField{successField, req("attachment_id", stringSchema()), req("file_name", stringSchema()),
+			req("content_type", stringSchema()), req("size_bytes", integerSchema())}},
+	"/api/download-attachment": {summary: "Download an attachment", tag: "Messages",
+		params:  []apiField{req("attachment_id", stringSchema()), opt("admin_id", stringSchema()), opt("student_id", stringSchema())},
+		content: "*/*"}, // Served with the type detected at upload
+	"/api/schedule-message": {summary: "Schedule a broadcast, optionally recurring", tag: "Messages", input: inputJSON,
+		params: []apiField{adminIDParam, req("title", stringSchema()), req("message", stringSchema()),
+			opt("send_at", dateTimeSchema()), opt("recurrence", stringSchema()), groupIDParam,
+			opt("send_to_all", booleanSchema()), opt("audience", ref("BroadcastAudience"))},
+		response: []apiField{successField, messageField, req("scheduled_id", stringSchema()),
+			req("send_at", dateTimeSchema()), req("recurrence", nullable(stringSchema()))}},
+	"/api/cancel-scheduled-message": {summary: "Cancel a scheduled broadcast", tag: "Messages", input: inputJSON,
+		params:   []apiField{adminIDParam, req("scheduled_id", stringSchema())},
+		response: []apiField{successField, messageField}},
+	"/api/get-scheduled-messages": {summary: "List an admin's scheduled broadcasts", tag: "Messages",
+		params:   []apiField{adminIDParam},
+		response: []apiField{req("scheduled_messages", arrayOf(ref("ScheduledMessage"))), countField}},
+	"/api/get-sent-messages": {summary: "List an admin's sent broadcasts", tag: "Messages",
+		params: []apiField{adminIDParam, opt("page", integerSchema()), opt("limit", integerSchema())},
+		response: []apiField{req("messages", arrayOf(anyObject())), countField, req("page", integerSchema()),
+			req("limit", integerSchema()), req("hasMore", booleanSchema())}},
+	"/api/get-message-receipts": {summary: "List who has read a broadcast", tag: "Messages",
+		params: []apiField{adminIDParam, messageIDParam},
+		response: []apiField{req("message", ref("SentMessage")), req("delivered", integerSchema()),
+			req("read_count", integerSchema()), req("unread_count", integerSchema()), req("read_percent", numberSchema()),
+			req("read", arrayOf(ref("MessageReceipt"))), req("unread", arrayOf(ref("MessageReceipt")))}},
+	"/api/export-unread": {summary: "Download the students who haven't read a broadcast", tag: "Messages",
+		params: []apiField{adminIDParam, messageIDParam}, content: "text/csv"},
+	"/api/renotify-unread": {summary: "Push a reminder to students who haven't read a broadcast", tag: "Messages", input: inputJSON,
+		params:   []apiField{adminIDParam, messageIDParam},
+		response: []apiField{successField, req("unread_students", integerSchema()), req("devices_notified", integerSchema())}},
+	"/api/edit-message": {summary: "Edit a sent broadcast", tag: "Messages", input: inputJSON,
+		params:   []apiField{adminIDParam, messageIDParam, opt("title", stringSchema()), opt("message", stringSchema())},
+		response: []apiField{successField, req("changed", booleanSchema()), opt("edited_at", dateTimeSchema())}},
+	"/api/get-message-edits": {summary: "List a broadcast's edit history", tag: "Messages",
+		params:   []apiField{adminIDParam, messageIDParam},
+		response: []apiField{req("message", ref("SentMessage")), req("edits", arrayOf(ref("MessageEdit"))), countField}},
+	"/api/recall-message": {summary: "Recall a broadcast from students' inboxes", tag: "Messages", input: inputJSON,
+		params:   []apiField{adminIDParam, messageIDParam},
+		response: []apiField{successField, req("recalled_at", dateTimeSchema())}},
+	"/api/admin-delete-message": {summary: "Delete a sent broadcast", tag: "Messages", input: inputJSON,
+		params:   []apiField{adminIDParam, messageIDParam},
+		response: []apiField{statusField, messageField}},
+
+	// Templates
+	"/api/create-template": {summary: "Create a message template", tag: "Templates", input: inputJSON, status: http.StatusCreated,
+		params: []apiField{adminIDParam, req("name", stringSchema()), req("title", stringSchema()),
+			req("body", stringSchema()), opt("shared", booleanSchema())},
+		response: []apiField{successField, req("template_id", stringSchema())}},
+	"/api/update-template": {summary: "Update a message template", tag: "Templates", input: inputJSON,
+		params: []apiField{adminIDParam, req("template_id", stringSchema()), opt("name", stringSchema()),
+			opt("title", stringSchema()), opt("body", stringSchema()), opt("shared", booleanSchema())},
+		response: []apiField{successField}},
+	"/api/get-templates": {summary: "List the templates an admin can use", tag: "Templates",
+		params: []apiField{adminIDParam},
+		response: []apiField{req("templates", arrayOf(ref("MessageTemplate"))), countField,
+			req("placeholders", arrayOf(stringSchema()))}},
+	"/api/delete-template": {summary: "Delete a message template", tag: "Templates", input: inputJSON,
+		params:   []apiField{adminIDParam, req("template_id", stringSchema())},
+		response: []apiField{statusField, messageField}},
+	"/api/preview-template": {summary: "Render a template for one student", tag: "Templates", input: inputJSON,
+		params: []apiField{adminIDParam, studentIDParam, opt("template_id", stringSchema()),
+			opt("title", stringSchema()), opt("body", stringSchema()), groupIDParam},
+		response: []apiField{req("title", stringSchema()), messageField, req("values", ref("TemplateValues"))}},
+
+	// Student inbox
+	"/api/get-messages": {summary: "List a student's broadcast inbox", tag: "Inbox",
+		params: []apiField{studentIDParam, opt("limit", integerSchema()), opt("is_read", booleanSchema()),
+			groupIDParam, opt("cursor", stringSchema())},
+		response: []apiField{req("messages", arrayOf(anyObject())), countField, req("has_more", booleanSchema()),
+			req("next_cursor", nullable(stringSchema()))}},
+	"/api/get-unread-count": {summary: "Count a student's unread broadcasts", tag: "Inbox",
+		params:   []apiField{studentIDParam},
+		response: []apiField{req("unread", integerSchema())}},
+	"/api/mark-message-read": {summary: "Mark a broadcast read", tag: "Inbox", input: inputJSON,
+		params:   []apiField{studentIDParam, messageIDParam},
+		response: []apiField{statusField}},
+	"/api/delete-message": {summary: "Remove a broadcast from a student's inbox", tag: "Inbox", input: inputJSON,
+		params:   []apiField{studentIDParam, messageIDParam},
+		response: []apiField{statusField, messageField}},
+
+	// Conversations
+	"/api/reply-message": {summary: "Reply privately to a broadcast", tag: "Conversations", input: inputJSON,
+		params:   []apiField{studentIDParam, messageIDParam, req("body", stringSchema())},
+		response: []apiField{successField, req("thread_id", stringSchema()), req("message_id", stringSchema())}},
+	"/api/open-thread": {summary: "Start a conversation with a group's admin", tag: "Conversations", input: inputJSON,
+		params: []apiField{studentIDParam, req("group_id", stringSchema()), opt("subject", stringSchema()),
+			req("body", stringSchema())},
+		response: []apiField{successField, req("thread_id", stringSchema()), req("message_id", stringSchema())}},
+	"/api/get-admin-inbox": {summary: "List an admin's conversations", tag: "Conversations",
+		params: []apiField{adminIDParam, opt("message_id", stringSchema())},
+		response: []apiField{req("threads", arrayOf(anyObject())), req("broadcasts", arrayOf(anyObject())),
+			countField, req("total_unread", integerSchema())}},
+	"/api/get-student-threads": {summary: "List a student's conversations", tag: "Conversations",
+		params:   []apiField{studentIDParam},
+		response: []apiField{req("threads", arrayOf(anyObject())), countField, req("total_unread", integerSchema())}},
+	"/api/get-thread": {summary: "Get a conversation with its messages", tag: "Conversations",
+		params:   []apiField{req("thread_id", stringSchema()), opt("admin_id", stringSchema()), opt("student_id", stringSchema())},
+		response: []apiField{req("thread", ref("MessageThread")), req("messages", arrayOf(ref("ThreadMessage"))), countField}},
+	"/api/reply-thread": {summary: "Reply in a conversation", tag: "Conversations", input: inputJSON,
+		params: []apiField{req("thread_id", stringSchema()), opt("admin_id", stringSchema()), opt("student_id", stringSchema()),
+			req("body", stringSchema())},
+		response: []apiField{successField, req("thread_id", stringSchema()), req("message_id", stringSchema())}},
+	"/api/mark-thread-read": {summary: "Mark a conversation read", tag: "Conversations", input: inputJSON,
+		params:   []apiField{req("thread_id", stringSchema()), opt("admin_id", stringSchema()), opt("student_id", stringSchema())},
+		response: []apiField{statusField}},
+	"/api/delete-thread": {summary: "Hide a conversation from one participant", tag: "Conversations", input: inputJSON,
+		params:   []apiField{req("thread_id", stringSchema()), opt("admin_id", stringSchema()), opt("student_id", stringSchema())},
+		response: []apiField{statusField, messageField}},
+
+	// Real-time events
+	"/api/events": {summary: "Server-sent event stream for a student, or for an admin and a group they own", tag: "Events",
+		params:  []apiField{opt("group_id", stringSchema()), opt("student_id", stringSchema()), opt("admin_id", stringSchema())},
+		content: "text/event-stream"},
+}
+
+// v1OperationIDs names v1 operations whose legacy handler serves more than
+// one v1 route
+var v1OperationIDs = map[string]string{
+	"GET /api/v1/students/{student_id}/windows/current": "getStudentWindowStatus",
+}
+
+// componentTypes are the Go types documented under components/schemas
+var componentTypes = []interface{}{
+	APIError{},
+	ActiveWindow{},
+	Attachment{},
+	AttendanceRecord{},
+	BroadcastAudience{},
+	BroadcastJob{},
+	DistanceBucket{},
+	HistogramBucket{},
+	MessageEdit{},
+	MessageReceipt{},
+	MessageTemplate{},
+	MessageThread{},
+	Pagination{},
+	PendingStudent{},
+	ScheduledMessage{},
+	ScheduledWindow{},
+	SentMessage{},
+	StudentLocation{},
+	TemplateValues{},
+	ThreadMessage{},
+	UpcomingWindow{},
+}
+
+// schemaForType describes how encoding/json renders a Go type. Struct
+// fields without omitempty are always present, so they are required.
+func schemaForType(t reflect.Type, components map[reflect.Type]string) Schema {
+	if name, ok := components[t]; ok {
+		return ref(name)
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return nullable(schemaForType(t.Elem(), components))
+	case reflect.String:
+		return stringSchema()
+	case reflect.Bool:
+		return booleanSchema()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return integerSchema()
+	case reflect.Float32, reflect.Float64:
+		return numberSchema()
+	case reflect.Slice, reflect.Array:
+		if t == reflect.TypeOf(json.RawMessage{}) {
+			return Schema{}
+		}
+		return arrayOf(schemaForType(t.Elem(), components))
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": schemaForType(t.Elem(), components)}
+	case reflect.Struct:
+		var fields []apiField
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, options, _ := strings.Cut(tag, ",")
+			if name == "" {
+				name = sf.Name
+			}
+			fields = append(fields, apiField{
+				name:     name,
+				schema:   schemaForType(sf.Type, components),
+				required: !strings.Contains(options, "omitempty"),
+			})
+		}
+		return objectOf(fields...)
+	}
+	return Schema{}
+}
+
+// componentSchemas documents componentTypes plus the error bodies
+func componentSchemas() Schema {
+	names := make(map[reflect.Type]string, len(componentTypes))
+	for _, v := range componentTypes {
+		t := reflect.TypeOf(v)
+		names[t] = t.Name()
+	}
+	schemas := Schema{}
+	for t, name := range names {
+		// Describe the struct itself rather than a reference to it
+		delete(names, t)
+		schemas[name] = schemaForType(t, names)
+		names[t] = name
+	}
+
+	schemas["APIError"].(Schema)["properties"].(Schema)["code"] = errorCodeSchema()
+	schemas["LegacyError"] = objectOf(req("error", stringSchema()), req("code", errorCodeSchema()))
+	schemas["ErrorEnvelope"] = objectOf(req("error", ref("APIError")))
+	return schemas
+}
+
+// errorCodeSchema lists the stable error codes of the apierror package
+func errorCodeSchema() Schema {
+	codes := make([]string, len(apierror.Codes))
+	for i, code := range apierror.Codes {
+		codes[i] = string(code)
+	}
+	return Schema{"type": "string", "enum": codes}
+}
+
+// operationName turns a legacy path into an operation ID:
+// /api/get-my-groups -> getMyGroups
+func operationName(legacyPath string) string {
+	name := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(legacyPath, "/api"), "/"), ".json")
+	var b strings.Builder
+	upper := false
+	for _, c := range name {
+		if c == '-' || c == '_' || c == '.' || c == '/' {
+			upper = true
+			continue
+		}
+		if upper {
+			c = unicode.ToUpper(c)
+			upper = false
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+func upperFirst(s string) string {
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// parametersFor lists query parameters; body fields go in requestBodyFor
+func parametersFor(fields []apiField, in string) []Schema {
+	params := make([]Schema, 0, len(fields))
+	for _, f := range fields {
+		params = append(params, Schema{"name": f.name, "in": in, "required": f.required || in == "path", "schema": f.schema})
+	}
+	return params
+}
+
+func requestBodyFor(fields []apiField, mediaType string) Schema {
+	required := false
+	for _, f := range fields {
+		required = required || f.required
+	}
+	return Schema{
+		"required": required,
+		"content":  Schema{mediaType: Schema{"schema": objectOf(fields...)}},
+	}
+}
+
+// inputFields splits an endpoint's params into query parameters and body
+// fields for the given method, input style and path parameters
+func inputFields(doc endpointDoc, method string, pathNames []string) (query, body []apiField) {
+	inPath := make(map[string]bool, len(pathNames))
+	for _, name := range pathNames {
+		inPath[name] = true
+	}
+	for _, f := range doc.params {
+		switch {
+		case inPath[f.name]:
+		case method == http.MethodGet || method == http.MethodDelete:
+			query = append(query, f)
+		default:
+			body = append(body, f)
+		}
+	}
+	return query, body
+}
+
+// legacyOperation documents a route on the legacy /api/<verb> surface
+func legacyOperation(doc endpointDoc, method, operationID string) Schema {
+	op := Schema{
+		"operationId": operationID,
+		"summary":     doc.summary,
+		"tags":        []string{doc.tag},
+	}
+	query, body := inputFields(doc, method, nil)
+	if len(query) > 0 {
+		op["parameters"] = parametersFor(query, "query")
+	}
+	if len(body) > 0 {
+		mediaType := "application/x-www-form-urlencoded"
+		switch doc.input {
+		case inputJSON:
+			mediaType = "application/json"
+		case inputRaw:
+			mediaType = "multipart/form-data"
+		}
+		op["requestBody"] = requestBodyFor(body, mediaType)
+	}
+
+	op["responses"] = Schema{
+		successStatus(doc): successResponse(doc, objectOf(doc.response...)),
+		"default": Schema{
+			"description": "Error",
+			"content":     Schema{"application/json": Schema{"schema": ref("LegacyError")}},
+		},
+	}
+	return op
+}
+
+// v1Operation documents a /api/v1 route: path parameters replace the
+// fields they name, bodies are JSON and responses use the envelope
+func v1Operation(doc endpointDoc, route v1Route, operationID string) Schema {
+	op := Schema{
+		"operationId": operationID,
+		"summary":     doc.summary,
+		"tags":        []string{doc.tag},
+	}
+	names := pathParams(route.pattern)
+	query, body := inputFields(doc, route.method, names)
+	var params []Schema
+	for _, name := range names {
+		params = append(params, parametersFor([]apiField{req(name, stringSchema())}, "path")...)
+	}
+	params = append(params, parametersFor(query, "query")...)
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+	if len(body) > 0 {
+		mediaType := "application/json"
+		if route.input == inputRaw {
+			mediaType = "multipart/form-data"
+		}
+		op["requestBody"] = requestBodyFor(body, mediaType)
+	}
+
+	var data []apiField
+	if converted, ok := v1Responses[route.method+" "+route.pattern]; ok {
+		data = converted.fields
+	} else {
+		for _, f := range doc.response {
+			if f.name != "success" {
+				data = append(data, f)
+			}
+		}
+	}
+	op["responses"] = Schema{
+		successStatus(doc): successResponse(doc, objectOf(req("data", objectOf(data...)))),
+		"default": Schema{
+			"description": "Error",
+			"content":     Schema{"application/json": Schema{"schema": ref("ErrorEnvelope")}},
+		},
+	}
+	return op
+}
+
+func successStatus(doc endpointDoc) string {
+	if doc.status != 0 {
+		return strconv.Itoa(doc.status)
+	}
+	return "200"
+}
+
+func successResponse(doc endpointDoc, body Schema) Schema {
+	if doc.content != "" {
+		s := stringSchema()
+		if doc.content != "text/csv" {
+			s["format"] = "binary"
+		}
+		return Schema{"description": "OK", "content": Schema{doc.content: Schema{"schema": s}}}
+	}
+	return Schema{"description": "OK", "content": Schema{"application/json": Schema{"schema": body}}}
+}
+
+// buildOpenAPI documents every route registered on the router. Routes
+// with no entry in endpointDocs are returned as problems.
+func buildOpenAPI(router *Router) (Schema, []string) {
+	var problems []string
+
+	// v1 routes find their docs through the legacy route of the same handler
+	legacyPathFor := map[uintptr]string{}
+	for _, route := range router.routes {
+		if !strings.HasPrefix(route.pattern, apiV1Prefix) {
+			if fn, ok := route.handler.(http.HandlerFunc); ok {
+				ptr := reflect.ValueOf(fn).Pointer()
+				if _, seen := legacyPathFor[ptr]; !seen {
+					legacyPathFor[ptr] = route.pattern
+				}
+			}
+		}
+	}
+	v1ByKey := make(map[string]v1Route, len(v1Routes))
+	for _, route := range v1Routes {
+		v1ByKey[route.method+" "+route.pattern] = route
+	}
+
+	paths := Schema{}
+	methodCount := map[string]int{}
+	for _, route := range router.routes {
+		key := route.method + " " + route.pattern
+		item, _ := paths[route.pattern].(Schema)
+		if item == nil {
+			item = Schema{}
+			paths[route.pattern] = item
+		}
+
+		if strings.HasPrefix(route.pattern, apiV1Prefix) {
+			v1, ok := v1ByKey[key]
+			if !ok {
+				problems = append(problems, key+": not in v1Routes")
+				continue
+			}
+			legacyPath := legacyPathFor[reflect.ValueOf(v1.handler).Pointer()]
+			doc, ok := endpointDocs[legacyPath]
+			if !ok {
+				problems = append(problems, key+": no legacy route shares its handler")
+				continue
+			}
+			operationID := v1OperationIDs[key]
+			if operationID == "" {
+				operationID = operationName(legacyPath)
+			}
+			item[strings.ToLower(route.method)] = v1Operation(doc, v1, operationID)
+			continue
+		}
+
+		doc, ok := endpointDocs[route.pattern]
+		if !ok {
+			problems = append(problems, key+": missing from endpointDocs")
+			continue
+		}
+		// The first method registered for a path gets the plain name
+		operationID := "legacy" + upperFirst(operationName(route.pattern))
+		if methodCount[route.pattern] > 0 {
+			operationID += upperFirst(strings.ToLower(route.method))
+		}
+		methodCount[route.pattern]++
+		item[strings.ToLower(route.method)] = legacyOperation(doc, route.method, operationID)
+	}
+
+	registered := map[string]bool{}
+	for _, route := range router.routes {
+		registered[route.pattern] = true
+	}
+	for path := range endpointDocs {
+		if !registered[path] {
+			problems = append(problems, path+": documented but not registered")
+		}
+	}
+	sort.Strings(problems)
+
+	spec := Schema{
+		"openapi": openAPIVersion,
+		"info": Schema{
+			"title":   "Attendance System API",
+			"version": "1.0.0",
+			"description": "Legacy /api/<verb> routes and the resource-oriented /api/v1 surface. " +
+				"When the server has API_SIGNING_SECRET set, requests carry X-Timestamp (Unix seconds) and " +
+				"X-Signature: hex(HMAC-SHA256(secret, METHOD \\n PATH \\n QUERY \\n TIMESTAMP \\n hex(SHA256(body)))), " +
+				"where QUERY is the query string without ts and sig, sorted by name and form-encoded.",
+		},
+		"paths": paths,
+		"components": Schema{
+			"schemas": componentSchemas(),
+			"securitySchemes": Schema{
+				"signature": Schema{"type": "apiKey", "in": "header", "name": "X-Signature"},
+				"timestamp": Schema{"type": "apiKey", "in": "header", "name": "X-Timestamp"},
+			},
+		},
+		"security": []Schema{{}, {"signature": []string{}, "timestamp": []string{}}},
+	}
+	return spec, problems
+}
+
+// marshalOpenAPI renders the spec the way openapi.json is committed
+func marshalOpenAPI(spec Schema) []byte {
+	out, _ := json.MarshalIndent(spec, "", "  ")
+	return append(out, '\n')
+}
+
+// openAPIHandler serves the spec built from router
+//
+// Handler: GET /api/openapi.json
+func openAPIHandler(router *Router) http.HandlerFunc {
+	var (
+		once sync.Once
+		body []byte
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			spec, _ := buildOpenAPI(router)
+			body = marshalOpenAPI(spec)
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}
+}
@@ -0,0 +1,462 @@
+// Package openapigen generates the typed Go client in client/ from the
+// backend's OpenAPI document. Only /api/v1 operations get client methods;
+// the legacy routes are documented for existing apps but not generated.
+package openapigen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+)
+
+const v1Prefix = "/api/v1/"
+
+type document struct {
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string      `json:"operationId"`
+	Summary     string      `json:"summary"`
+	Parameters  []parameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]mediaType `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]mediaType `json:"content"`
+	} `json:"responses"`
+}
+
+type parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *schema `json:"schema"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref         string             `json:"$ref"`
+	Type        string             `json:"type"`
+	Format      string             `json:"format"`
+	Description string             `json:"description"`
+	Properties  map[string]*schema `json:"properties"`
+	Required    []string           `json:"required"`
+	Items       *schema            `json:"items"`
+	AllOf       []*schema          `json:"allOf"`
+	Nullable    bool               `json:"nullable"`
+
+	AdditionalProperties interface{} `json:"additionalProperties"`
+}
+
+// errorSchemas are handled by the hand-written transport
+var errorSchemas = map[string]bool{"LegacyError": true, "ErrorEnvelope": true, "APIError": true}
+
+type generator struct {
+	doc     *document
+	imports map[string]bool
+	types   bytes.Buffer // Named types, emitted after the methods
+	methods bytes.Buffer
+	named   map[string]bool
+}
+
+// Generate renders client_gen.go for the OpenAPI document in spec
+func Generate(spec []byte) ([]byte, error) {
+	var doc document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("parse spec: %w", err)
+	}
+	g := &generator{doc: &doc, imports: map[string]bool{"context": true, "net/http": true}, named: map[string]bool{}}
+
+	type opRef struct {
+		method, path string
+		op           *operation
+	}
+	var ops []opRef
+	for path, item := range doc.Paths {
+		if !strings.HasPrefix(path, v1Prefix) {
+			continue
+		}
+		for method, op := range item {
+			ops = append(ops, opRef{strings.ToUpper(method), path, op})
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].path != ops[j].path {
+			return ops[i].path < ops[j].path
+		}
+		return ops[i].method < ops[j].method
+	})
+	for _, o := range ops {
+		if err := g.operation(o.method, o.path, o.op); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", o.method, o.path, err)
+		}
+	}
+
+	var components []string
+	for name := range doc.Components.Schemas {
+		if !errorSchemas[name] {
+			components = append(components, name)
+		}
+	}
+	sort.Strings(components)
+	for _, name := range components {
+		g.namedStruct(name, doc.Components.Schemas[name])
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by genclient from openapi.json; DO NOT EDIT.\n\npackage client\n\nimport (\n")
+	imports := make([]string, 0, len(g.imports))
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		fmt.Fprintf(&out, "\t%q\n", imp)
+	}
+	out.WriteString(")\n")
+	out.Write(g.methods.Bytes())
+	out.Write(g.types.Bytes())
+
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return formatted, nil
+}
+
+var initialisms = map[string]string{
+	"api": "API", "csv": "CSV", "fcm": "FCM", "id": "ID", "ids": "IDs", "sha256": "SHA256", "url": "URL", "uuid": "UUID",
+}
+
+// goName converts a JSON or operation name to an exported Go identifier:
+// student_id -> StudentID, getMyGroups -> GetMyGroups
+func goName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '-' || r == '.' }) {
+		start := 0
+		for i := 1; i <= len(part); i++ {
+			if i < len(part) && !(isUpper(part[i]) && !isUpper(part[i-1])) {
+				continue
+			}
+			word := part[start:i]
+			if upper, ok := initialisms[strings.ToLower(word)]; ok {
+				b.WriteString(upper)
+			} else {
+				b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+			}
+			start = i
+		}
+	}
+	return b.String()
+}
+
+func isUpper(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
+
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+// goType returns the Go type for s; object schemas with properties become
+// named structs called name
+func (g *generator) goType(s *schema, name string) string {
+	if s == nil {
+		return "interface{}"
+	}
+	if s.Ref != "" {
+		return refName(s.Ref)
+	}
+	if len(s.AllOf) == 1 {
+		inner := g.goType(s.AllOf[0], name)
+		if s.Nullable {
+			return "*" + inner
+		}
+		return inner
+	}
+
+	var t string
+	switch s.Type {
+	case "string":
+		t = "string"
+	case "integer":
+		t = "int"
+	case "number":
+		t = "float64"
+	case "boolean":
+		t = "bool"
+	case "array":
+		return "[]" + g.goType(s.Items, name+"Item")
+	case "object":
+		if len(s.Properties) == 0 && s.AdditionalProperties != nil {
+			return "map[string]interface{}"
+		}
+		g.namedStruct(name, s)
+		t = name
+	default:
+		return "interface{}"
+	}
+	if s.Nullable {
+		return "*" + t
+	}
+	return t
+}
+
+// namedStruct emits a struct type for an object schema
+func (g *generator) namedStruct(name string, s *schema) {
+	if g.named[name] {
+		return
+	}
+	g.named[name] = true
+
+	required := map[string]bool{}
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	var fields bytes.Buffer
+	for _, prop := range sortedProperties(s) {
+		t := g.goType(s.Properties[prop], name+goName(prop))
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(&fields, "\t%s %s `json:%q`\n", goName(prop), t, tag)
+	}
+	fmt.Fprintf(&g.types, "\ntype %s struct {\n%s}\n", name, fields.String())
+}
+
+func sortedProperties(s *schema) []string {
+	props := make([]string, 0, len(s.Properties))
+	for prop := range s.Properties {
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+	return props
+}
+
+// inputField is one member of an operation's Params struct
+type inputField struct {
+	json, goName, goType, in string
+	required                 bool
+	binary                   bool
+}
+
+// operation emits the Params and Response types and the client method
+func (g *generator) operation(method, path string, op *operation) error {
+	if op.OperationID == "" {
+		return fmt.Errorf("missing operationId")
+	}
+	name := goName(op.OperationID)
+
+	var inputs []inputField
+	for _, p := range op.Parameters {
+		t := g.goType(p.Schema, name+goName(p.Name))
+		if p.In == "query" && !p.Required && t == "bool" {
+			t = "*bool"
+		}
+		inputs = append(inputs, inputField{json: p.Name, goName: goName(p.Name), goType: t, in: p.In, required: p.Required})
+	}
+
+	multipart := false
+	if op.RequestBody != nil {
+		bodyType, ok := op.RequestBody.Content["application/json"]
+		if !ok {
+			bodyType, ok = op.RequestBody.Content["multipart/form-data"]
+			multipart = true
+		}
+		if !ok || bodyType.Schema == nil {
+			return fmt.Errorf("request body must be application/json or multipart/form-data")
+		}
+		required := map[string]bool{}
+		for _, r := range bodyType.Schema.Required {
+			required[r] = true
+		}
+		for _, prop := range sortedProperties(bodyType.Schema) {
+			ps := bodyType.Schema.Properties[prop]
+			field := inputField{json: prop, goName: goName(prop), in: "body", required: required[prop]}
+			switch {
+			case ps.Type == "string" && ps.Format == "binary":
+				field.goType, field.binary = "io.Reader", true
+				g.imports["io"] = true
+			default:
+				field.goType = g.goType(ps, name+goName(prop))
+				if !field.required && (field.goType == "bool" || ps.Ref != "") {
+					field.goType = "*" + field.goType
+				}
+			}
+			inputs = append(inputs, field)
+		}
+	}
+
+	// Success response: the first 2xx status
+	var statuses []string
+	for status := range op.Responses {
+		if strings.HasPrefix(status, "2") {
+			statuses = append(statuses, status)
+		}
+	}
+	if len(statuses) == 0 {
+		return fmt.Errorf("no success response")
+	}
+	sort.Strings(statuses)
+	success := op.Responses[statuses[0]]
+	jsonBody, isJSON := success.Content["application/json"]
+	stream := !isJSON
+
+	var resultType string
+	if !stream {
+		if jsonBody.Schema == nil || jsonBody.Schema.Properties["data"] == nil {
+			return fmt.Errorf("success response has no data envelope")
+		}
+		resultType = g.goType(jsonBody.Schema.Properties["data"], name+"Response")
+	}
+
+	// Params struct
+	if len(inputs) > 0 {
+		var fields bytes.Buffer
+		for _, f := range inputs {
+			tag := "-"
+			if f.in == "body" && !multipart {
+				tag = f.json
+				if !f.required {
+					tag += ",omitempty"
+				}
+			}
+			comment := ""
+			if f.in != "body" {
+				comment = " // " + f.in
+			}
+			fmt.Fprintf(&fields, "\t%s %s `json:%q`%s\n", f.goName, f.goType, tag, comment)
+			if f.binary {
+				fmt.Fprintf(&fields, "\t%sName string `json:\"-\"`\n", f.goName)
+			}
+		}
+		fmt.Fprintf(&g.types, "\n// %sParams are the inputs of %s.\ntype %sParams struct {\n%s}\n", name, name, name, fields.String())
+	}
+
+	// Method
+	m := &g.methods
+	fmt.Fprintf(m, "\n// %s calls %s %s: %s.\n", name, method, path, op.Summary)
+	args := "ctx context.Context"
+	if len(inputs) > 0 {
+		args += ", params " + name + "Params"
+	}
+	switch {
+	case stream:
+		g.imports["io"] = true
+		fmt.Fprintf(m, "func (c *Client) %s(%s) (io.ReadCloser, error) {\n", name, args)
+	default:
+		fmt.Fprintf(m, "func (c *Client) %s(%s) (*%s, error) {\n", name, args, resultType)
+	}
+
+	// Path with escaped parameters
+	var pathExpr []string
+	rest := path
+	for {
+		open := strings.Index(rest, "{")
+		if open < 0 {
+			break
+		}
+		end := strings.Index(rest, "}")
+		pathExpr = append(pathExpr, fmt.Sprintf("%q", rest[:open]), "url.PathEscape(params."+goName(rest[open+1:end])+")")
+		g.imports["net/url"] = true
+		rest = rest[end+1:]
+	}
+	if rest != "" || len(pathExpr) == 0 {
+		pathExpr = append(pathExpr, fmt.Sprintf("%q", rest))
+	}
+	fmt.Fprintf(m, "\tpath := %s\n", strings.Join(pathExpr, " + "))
+
+	hasQuery := false
+	for _, f := range inputs {
+		if f.in == "query" {
+			hasQuery = true
+		}
+	}
+	queryArg := "nil"
+	if hasQuery {
+		g.imports["net/url"] = true
+		queryArg = "query"
+		fmt.Fprintf(m, "\tquery := url.Values{}\n")
+		for _, f := range inputs {
+			if f.in != "query" {
+				continue
+			}
+			g.writeQuerySet(m, f)
+		}
+	}
+
+	httpMethod := "http.Method" + strings.ToUpper(method[:1]) + strings.ToLower(method[1:])
+	switch {
+	case multipart:
+		fmt.Fprintf(m, "\tfields := map[string]string{}\n")
+		fileField, fileArg, nameArg := "", "nil", `""`
+		for _, f := range inputs {
+			if f.in != "body" {
+				continue
+			}
+			if f.binary {
+				fileField, fileArg, nameArg = f.json, "params."+f.goName, "params."+f.goName+"Name"
+				continue
+			}
+			fmt.Fprintf(m, "\tif params.%s != \"\" {\n\t\tfields[%q] = params.%s\n\t}\n", f.goName, f.json, f.goName)
+		}
+		fmt.Fprintf(m, "\tvar out %s\n", resultType)
+		fmt.Fprintf(m, "\tif err := c.upload(ctx, %s, path, fields, %q, %s, %s, &out); err != nil {\n\t\treturn nil, err\n\t}\n\treturn &out, nil\n}\n",
+			httpMethod, fileField, nameArg, fileArg)
+	case stream:
+		fmt.Fprintf(m, "\treturn c.stream(ctx, %s, path, %s)\n}\n", httpMethod, queryArg)
+	default:
+		bodyArg := "nil"
+		for _, f := range inputs {
+			if f.in == "body" {
+				bodyArg = "params"
+			}
+		}
+		fmt.Fprintf(m, "\tvar out %s\n", resultType)
+		fmt.Fprintf(m, "\tif err := c.do(ctx, %s, path, %s, %s, &out); err != nil {\n\t\treturn nil, err\n\t}\n\treturn &out, nil\n}\n",
+			httpMethod, queryArg, bodyArg)
+	}
+	return nil
+}
+
+// writeQuerySet emits the code adding one query parameter; optional ones
+// are only sent when set
+func (g *generator) writeQuerySet(m *bytes.Buffer, f inputField) {
+	value := "params." + f.goName
+	cond := ""
+	switch f.goType {
+	case "string":
+		cond = value + ` != ""`
+	case "int":
+		g.imports["strconv"] = true
+		cond, value = value+" != 0", "strconv.Itoa("+value+")"
+	case "float64":
+		g.imports["strconv"] = true
+		cond, value = value+" != 0", "strconv.FormatFloat("+value+", 'f', -1, 64)"
+	case "bool":
+		g.imports["strconv"] = true
+		cond, value = value, "strconv.FormatBool("+value+")"
+	case "*bool":
+		g.imports["strconv"] = true
+		cond, value = value+" != nil", "strconv.FormatBool(*"+value+")"
+	default:
+		cond, value = "true", "fmt.Sprint("+value+")"
+		g.imports["fmt"] = true
+	}
+	if f.required && f.goType != "*bool" {
+		fmt.Fprintf(m, "\tquery.Set(%q, %s)\n", f.json, value)
+		return
+	}
+	fmt.Fprintf(m, "\tif %s {\n\t\tquery.Set(%q, %s)\n\t}\n", cond, f.json, value)
+}
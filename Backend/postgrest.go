@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Query builds a PostgREST URL. Values are always escaped, and quoted where
+// PostgREST would otherwise read punctuation in them as syntax, so no input
+// can add a filter or change an operator. Column names, select lists and
+// orderings come from code and are checked against PostgREST's grammar;
+// a bad one panics, as it's a bug rather than bad input.
+//
+//	from("students").Eq("student_id", id).Select("id").URL()
+type Query struct {
+	table  string
+	params []string
+}
+
+// Operator is a PostgREST comparison operator. Only these are allowed;
+// in and is have their own methods because their values are restricted.
+type Operator string
+
+const (
+	OpEq  Operator = "eq"
+	OpNeq Operator = "neq"
+	OpGt  Operator = "gt"
+	OpGte Operator = "gte"
+	OpLt  Operator = "lt"
+	OpLte Operator = "lte"
+)
+
+var operators = map[Operator]bool{OpEq: true, OpNeq: true, OpGt: true, OpGte: true, OpLt: true, OpLte: true}
+
+// IsValue is one of the literals PostgREST's is operator accepts
+type IsValue string
+
+const (
+	IsNull  IsValue = "null"
+	IsTrue  IsValue = "true"
+	IsFalse IsValue = "false"
+)
+
+var (
+	// A column, optionally qualified by an embedded resource or alias
+	columnPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*(\.[a-z_][a-z0-9_]*)*$`)
+	// Select lists with embeds (name:table!inner(cols)) and order lists
+	selectPattern = regexp.MustCompile(`^[a-z0-9_.,*()!:]+$`)
+	orderPattern  = regexp.MustCompile(`^[a-z_][a-z0-9_]*\.(asc|desc)(,[a-z_][a-z0-9_]*\.(asc|desc))*$`)
+)
+
+// from starts a query on table
+func from(table string) *Query {
+	mustColumn(table)
+	return &Query{table: table}
+}
+
+// Filter adds column=op.value
+func (q *Query) Filter(column string, op Operator, value string) *Query {
+	mustColumn(column)
+	mustOperator(op)
+	return q.add(column, string(op)+"."+value)
+}
+
+// Eq adds column=eq.value
+func (q *Query) Eq(column, value string) *Query {
+	return q.Filter(column, OpEq, value)
+}
+
+// In adds column=in.(values), each value quoted
+func (q *Query) In(column string, values []string) *Query {
+	mustColumn(column)
+	return q.add(column, "in."+quoteList(values))
+}
+
+// Is adds column=is.value
+func (q *Query) Is(column string, value IsValue) *Query {
+	mustColumn(column)
+	return q.add(column, "is."+string(value))
+}
+
+// Or adds or=(conds) on the queried table
+func (q *Query) Or(conds ...Condition) *Query {
+	return q.add("or", joinConditions(conds))
+}
+
+// EmbeddedOr adds resource.or=(conds), filtering an embedded resource
+func (q *Query) EmbeddedOr(resource string, conds ...Condition) *Query {
+	mustColumn(resource)
+	return q.add(resource+".or", joinConditions(conds))
+}
+
+// Select sets the columns and embedded resources returned
+func (q *Query) Select(columns string) *Query {
+	if !selectPattern.MatchString(columns) {
+		panic(fmt.Sprintf("postgrest: invalid select %q", columns))
+	}
+	return q.add("select", columns)
+}
+
+// Order sets the ordering, e.g. "created_at.desc,id.desc"
+func (q *Query) Order(order string) *Query {
+	if !orderPattern.MatchString(order) {
+		panic(fmt.Sprintf("postgrest: invalid order %q", order))
+	}
+	return q.add("order", order)
+}
+
+func (q *Query) Limit(n int) *Query {
+	return q.add("limit", strconv.Itoa(n))
+}
+
+func (q *Query) Offset(n int) *Query {
+	return q.add("offset", strconv.Itoa(n))
+}
+
+// OnConflict names the unique columns an upsert merges on
+func (q *Query) OnConflict(columns ...string) *Query {
+	for _, column := range columns {
+		mustColumn(column)
+	}
+	return q.add("on_conflict", strings.Join(columns, ","))
+}
+
+// URL returns the request URL on the configured Supabase project
+func (q *Query) URL() string {
+	var b strings.Builder
+	b.WriteString(config.SupabaseURL + "/rest/v1/" + q.table)
+	for i, param := range q.params {
+		if i == 0 {
+			b.WriteByte('?')
+		} else {
+			b.WriteByte('&')
+		}
+		b.WriteString(param)
+	}
+	return b.String()
+}
+
+func (q *Query) add(key, value string) *Query {
+	q.params = append(q.params, key+"="+escapeValue(value))
+	return q
+}
+
+// Condition is one branch of an or=(...) filter
+type Condition struct {
+	expr string
+}
+
+// where matches column op value; the value is quoted
+func where(column string, op Operator, value string) Condition {
+	mustColumn(column)
+	mustOperator(op)
+	return Condition{column + "." + string(op) + "." + quote(value)}
+}
+
+func whereIn(column string, values []string) Condition {
+	mustColumn(column)
+	return Condition{column + ".in." + quoteList(values)}
+}
+
+func whereIs(column string, value IsValue) Condition {
+	mustColumn(column)
+	return Condition{column + ".is." + string(value)}
+}
+
+// and groups conditions that must all hold inside an or=
+func and(conds ...Condition) Condition {
+	return Condition{"and" + joinConditions(conds)}
+}
+
+func joinConditions(conds []Condition) string {
+	exprs := make([]string, len(conds))
+	for i, cond := range conds {
+		exprs[i] = cond.expr
+	}
+	return "(" + strings.Join(exprs, ",") + ")"
+}
+
+// quote makes value a single PostgREST token: inside double quotes, commas,
+// parentheses and dots are literal, and \ escapes \ and "
+func quote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
+
+func quoteList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = quote(value)
+	}
+	return "(" + strings.Join(quoted, ",") + ")"
+}
+
+// escapeValue percent-encodes a query value. Spaces become %20 rather than
+// +, which not every decoder reads back as a space.
+func escapeValue(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+func mustColumn(name string) {
+	if !columnPattern.MatchString(name) {
+		panic(fmt.Sprintf("postgrest: invalid column %q", name))
+	}
+}
+
+func mustOperator(op Operator) {
+	if !operators[op] {
+		panic(fmt.Sprintf("postgrest: operator %q not allowed", op))
+	}
+}
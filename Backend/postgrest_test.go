@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// queryInjections are inputs that change a hand-built PostgREST query
+var queryInjections = []string{
+	"x&admin_id=eq.y", "ST001),or(id.neq.0", `a","b`, `\`, `"`, "a,b", "(", ")",
+	"eq.", "is.null", "1=1", "%26", "+", " ", "#frag", "?x=y", "\n", "é", "",
+}
+
+// FuzzQuery builds a query from arbitrary values, decodes the URL the way
+// PostgREST parses it and fails if its filters differ from the ones built.
+// go test runs the seed corpus; go test -fuzz=FuzzQuery explores further.
+func FuzzQuery(f *testing.F) {
+	for i, injection := range queryInjections {
+		f.Add(injection, "ST001", "2026-01-01")
+		f.Add("ST001", injection, queryInjections[(i+1)%len(queryInjections)])
+	}
+	f.Add(`abc019&=?#%+ ,.()"\:!*`, "\x00\té", `"\"`)
+
+	f.Fuzz(func(t *testing.T, a, b, c string) {
+		built := from("students").Eq("student_id", a).In("id", []string{b, c}).
+			Or(where("admin_id", OpEq, b), and(where("created_at", OpLt, c), whereIs("expires_at", IsNull))).
+			Select("id").URL()
+		plain := map[string]string{"student_id": "eq." + a, "select": "id"}
+		lists := map[string][]string{
+			"id": {"in.(", b, c, ")"},
+			"or": {"(", "admin_id.eq." + b, "and(", "created_at.lt." + c, "expires_at.is.null", ")", ")"},
+		}
+		if err := checkQuery(built, plain, lists); err != nil {
+			t.Errorf("%v for %q, %q, %q", err, a, b, c)
+		}
+	})
+}
+
+// checkQuery reports whether a built URL has exactly the plain parameters
+// and the lists, given as the tokens parseList returns
+func checkQuery(built string, plain map[string]string, lists map[string][]string) error {
+	u, err := url.Parse(built)
+	if err != nil {
+		return err
+	}
+	got, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return err
+	}
+	if len(got) != len(plain)+len(lists) {
+		return fmt.Errorf("got parameters %q", got)
+	}
+	for key, value := range plain {
+		if len(got[key]) != 1 || got[key][0] != value {
+			return fmt.Errorf("%s = %q", key, got[key])
+		}
+	}
+	for key, tokens := range lists {
+		if len(got[key]) != 1 {
+			return fmt.Errorf("%s = %q", key, got[key])
+		}
+		parsed, err := parseList(got[key][0])
+		if err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		if !slices.Equal(parsed, tokens) {
+			return fmt.Errorf("%s parsed as %q", key, parsed)
+		}
+	}
+	return nil
+}
+
+// parseList tokenizes a PostgREST list value the way PostgREST reads it:
+// "in.(a,b)" or "(x.eq.1,and(y.lt.2))". A double-quoted stretch is literal,
+// with \ escaping the next character. Each opening parenthesis is a token
+// carrying the text before it, each closing one a ")" token, and every item
+// in between a token of its own.
+func parseList(s string) ([]string, error) {
+	var tokens []string
+	var item strings.Builder
+	quoted := false
+	depth := 0
+	flush := func() {
+		if item.Len() > 0 || quoted {
+			tokens = append(tokens, item.String())
+		}
+		item.Reset()
+		quoted = false
+	}
+	for i := 0; i < len(s); i++ {
+		if depth == 0 && len(tokens) > 0 {
+			return nil, errors.New("text after the list")
+		}
+		switch ch := s[i]; ch {
+		case '"':
+			quoted = true
+			for i++; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				item.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, errors.New("unterminated quote")
+			}
+		case '(':
+			tokens = append(tokens, item.String()+"(")
+			item.Reset()
+			depth++
+		case ')', ',':
+			flush()
+			if ch == ')' {
+				tokens = append(tokens, ")")
+				depth--
+			}
+		default:
+			item.WriteByte(ch)
+		}
+	}
+	if depth != 0 {
+		return nil, errors.New("unbalanced parentheses")
+	}
+	return tokens, nil
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"attendance-system/apierror"
+)
+
+// Event is a single real-time update pushed to subscribers over SSE
+type Event struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+	Time string      `json:"time"`
+}
+
+// Event types emitted by the server
+const (
+	EventWindowOpened        = "window_opened"
+	EventWindowClosed        = "window_closed"
+	EventWindowTick          = "window_tick"
+	EventAttendanceSubmitted = "attendance_submitted"
+	EventBroadcastMessage    = "broadcast_message"
+	EventThreadMessage       = "thread_message"
+	EventMessageEdited       = "message_edited"
+	EventMessageRecalled     = "message_recalled"
+)
+
+// Topic for windows open to every student (group_only = false)
+const allStudentsTopic = "students"
+
+const (
+	subscriberBufferSize = 32
+	sseKeepAliveInterval = 15 * time.Second
+	windowTickInterval   = 5 * time.Second
+)
+
+type subscriber struct {
+	ch chan Event
+}
+
+// EventHub fans out events to subscribers by topic
+type EventHub struct {
+	subscribers map[string]map[*subscriber]bool // topic -> subscribers
+	mu          sync.RWMutex
+
+	closed    chan struct{} // Closed on shutdown to end every stream
+	closeOnce sync.Once
+}
+
+var eventHub = &EventHub{
+	subscribers: make(map[string]map[*subscriber]bool),
+	closed:      make(chan struct{}),
+}
+
+func groupTopic(groupID string) string     { return "group:" + groupID }
+func studentTopic(studentID string) string { return "student:" + studentID }
+func adminTopic(adminID string) string     { return "admin:" + adminID }
+
+// groupAdminTopic carries a group's events for its admin only, such as
+// submissions with the student's coordinates. Group members follow
+// groupTopic and never this one.
+func groupAdminTopic(groupID string) string { return "group-admin:" + groupID }
+
+// Subscribe registers a subscriber for the given topics
+func (h *EventHub) Subscribe(topics []string) *subscriber {
+	sub := &subscriber{ch: make(chan Event, subscriberBufferSize)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, topic := range topics {
+		if h.subscribers[topic] == nil {
+			h.subscribers[topic] = make(map[*subscriber]bool)
+		}
+		h.subscribers[topic][sub] = true
+	}
+	return sub
+}
+
+// Unsubscribe removes a subscriber from every topic
+func (h *EventHub) Unsubscribe(sub *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for topic, subs := range h.subscribers {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(h.subscribers, topic)
+		}
+	}
+}
+
+// Close ends every event stream; clients reconnect to the next instance
+func (h *EventHub) Close() {
+	h.closeOnce.Do(func() { close(h.closed) })
+}
+
+// Publish sends an event to every subscriber of the given topics.
+// A subscriber listening on several of the topics receives the event once.
+// Never blocks: slow subscribers drop events instead of stalling handlers.
+func (h *EventHub) Publish(eventType string, data interface{}, topics ...string) {
+	event := Event{
+		Type: eventType,
+		Data: data,
+		Time: time.Now().Format(time.RFC3339),
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	delivered := make(map[*subscriber]bool)
+	for _, topic := range topics {
+		for sub := range h.subscribers[topic] {
+			if delivered[sub] {
+				continue
+			}
+			delivered[sub] = true
+			select {
+			case sub.ch <- event:
+			default:
+				slog.Warn("dropping event for slow subscriber", "event", eventType, "topic", topic)
+			}
+		}
+	}
+}
+
+// windowTopics returns the topics that should hear about a group's window.
+// Caller must hold group.mu.
+func windowTopics(group *GroupData) []string {
+	topics := []string{groupTopic(group.ID)}
+	if group.AdminID != "" {
+		topics = append(topics, adminTopic(group.AdminID))
+	}
+	if !group.GroupOnly {
+		topics = append(topics, allStudentsTopic)
+	}
+	return topics
+}
+
+// remainingSeconds returns the seconds left before the window auto-closes.
+// Caller must hold group.mu.
+func remainingSeconds(group *GroupData) int {
+	remaining := int(time.Until(group.WindowEndTime).Seconds())
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining
+}
+
+// publishWindowOpened notifies subscribers that a window was opened.
+// Caller must hold group.mu.
+func publishWindowOpened(group *GroupData) {
+	eventHub.Publish(EventWindowOpened, map[string]interface{}{
+		"group_id":          group.ID,
+		"group_name":        group.Name,
+		"group_only":        group.GroupOnly,
+		"remaining_seconds": remainingSeconds(group),
+		"end_time":          group.WindowEndTime.Format(time.RFC3339),
+	}, windowTopics(group)...)
+}
+
+// publishWindowClosed notifies subscribers that a window was closed.
+// Caller must hold group.mu.
+func publishWindowClosed(group *GroupData, reason string) {
+	eventHub.Publish(EventWindowClosed, map[string]interface{}{
+		"group_id":   group.ID,
+		"group_name": group.Name,
+		"reason":     reason, // "closed" or "expired"
+	}, windowTopics(group)...)
+}
+
+// publishAttendanceSubmitted notifies the group's admin view of a new
+// submission. The event carries the student's coordinates, so it goes to
+// admin topics only. Caller must hold group.mu.
+func publishAttendanceSubmitted(group *GroupData, loc StudentLocation) {
+	topics := []string{groupAdminTopic(group.ID)}
+	if group.AdminID != "" {
+		topics = append(topics, adminTopic(group.AdminID))
+	}
+	eventHub.Publish(EventAttendanceSubmitted, map[string]interface{}{
+		"group_id": group.ID,
+		"student":  loc,
+		"count":    len(group.StudentLocations),
+	}, topics...)
+}
+
+// publishBroadcastMessage notifies each recipient student of a new message
+func publishBroadcastMessage(messageID, title, message string, studentIDs []string) {
+	topics := make([]string, 0, len(studentIDs))
+	for _, id := range studentIDs {
+		topics = append(topics, studentTopic(id))
+	}
+	eventHub.Publish(EventBroadcastMessage, map[string]interface{}{
+		"message_id": messageID,
+		"title":      title,
+		"message":    message,
+	}, topics...)
+}
+
+// runWindowTicker periodically publishes the remaining time of every active window
+func runWindowTicker(ctx context.Context) {
+	ticker := time.NewTicker(windowTickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		groupManager.mu.RLock()
+		for _, group := range groupManager.groups {
+			group.mu.RLock()
+			if group.WindowActive {
+				eventHub.Publish(EventWindowTick, map[string]interface{}{
+					"group_id":          group.ID,
+					"remaining_seconds": remainingSeconds(group),
+				}, windowTopics(group)...)
+			}
+			group.mu.RUnlock()
+		}
+		groupManager.mu.RUnlock()
+	}
+}
+
+// studentEventTopics resolves the topics a student listens on:
+// their own topic, all-student windows and every group they belong to
+func studentEventTopics(ctx context.Context, studentID string) []string {
+	topics := []string{studentTopic(studentID), allStudentsTopic}
+
+	studentUUID := getStudentUUIDByID(ctx, studentID)
+	if studentUUID == "" {
+		return topics
+	}
+
+	groupIDs, err := studentGroupIDs(ctx, studentUUID)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to load group memberships", "student_id", studentID, "error", err)
+		return topics
+	}
+	for _, groupID := range groupIDs {
+		topics = append(topics, groupTopic(groupID))
+	}
+	return topics
+}
+
+// adminEventTopics returns the topics an admin may follow: their own and,
+// with groupID, the group's, which needs the admin to own the group. The
+// legacy "default" group has no owner and is open to any admin.
+func adminEventTopics(ctx context.Context, adminID, groupID string) ([]string, error) {
+	lookup := from("admins").Eq("id", adminID)
+	if groupID != "" && groupID != "default" {
+		lookup = from("groups").Eq("id", groupID).Eq("admin_id", adminID)
+	}
+	var rows []struct {
+		ID string `json:"id"`
+	}
+	if err := supabaseGetJSON(ctx, lookup.Select("id").Limit(1).URL(), &rows); err != nil {
+		return nil, apierror.Wrap(http.StatusInternalServerError, "Database error", err)
+	}
+	if len(rows) == 0 {
+		if groupID != "" {
+			return nil, apierror.New(http.StatusForbidden, "Group events are only available to the group's admin")
+		}
+		return nil, apierror.New(http.StatusForbidden, "Unknown admin")
+	}
+
+	topics := []string{adminTopic(adminID)}
+	if groupID != "" {
+		topics = append(topics, groupTopic(groupID), groupAdminTopic(groupID))
+	}
+	return topics, nil
+}
+
+// writeSSE writes a single event in text/event-stream format
+func writeSSE(w http.ResponseWriter, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, payload)
+	return err
+}
+
+// Handler: GET /api/events?group_id=xxx&student_id=xxx&admin_id=xxx
+// Streams Server-Sent Events for the requested student and/or admin. A
+// student follows the groups they belong to; group_id is for the group's
+// admin and needs admin_id.
+func eventsHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, r, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	groupID := r.URL.Query().Get("group_id")
+	studentID := r.URL.Query().Get("student_id")
+	adminID := r.URL.Query().Get("admin_id")
+
+	if groupID == "" && studentID == "" && adminID == "" {
+		writeError(w, r, http.StatusBadRequest, "group_id, student_id or admin_id is required")
+		return
+	}
+
+	if groupID != "" && adminID == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id is required with group_id")
+		return
+	}
+
+	var topics []string
+	if studentID != "" {
+		topics = append(topics, studentEventTopics(r.Context(), studentID)...)
+	}
+	if adminID != "" {
+		adminTopics, err := adminEventTopics(r.Context(), adminID, groupID)
+		if err != nil {
+			respondError(w, r, err)
+			return
+		}
+		topics = append(topics, adminTopics...)
+	}
+
+	sub := eventHub.Subscribe(topics)
+	defer eventHub.Unsubscribe(sub)
+
+	// The stream outlives the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		slog.WarnContext(r.Context(), "cannot clear write deadline for event stream", "error", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering
+	w.WriteHeader(http.StatusOK)
+
+	writeSSE(w, Event{
+		Type: "connected",
+		Data: map[string]interface{}{"topics": topics},
+		Time: time.Now().Format(time.RFC3339),
+	})
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-eventHub.closed:
+			return
+		case event := <-sub.ch:
+			if err := writeSSE(w, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"attendance-system/apierror"
+)
+
+func TestEventHubPublish(t *testing.T) {
+	hub := &EventHub{subscribers: make(map[string]map[*subscriber]bool)}
+	admin := hub.Subscribe([]string{groupTopic("g1"), adminTopic("a1")})
+	other := hub.Subscribe([]string{groupTopic("g2")})
+
+	// Listening on both topics, the admin still gets the event once
+	hub.Publish(EventAttendanceSubmitted, map[string]string{"group_id": "g1"}, groupTopic("g1"), adminTopic("a1"))
+
+	if got := len(admin.ch); got != 1 {
+		t.Fatalf("subscriber on both topics got %d events, want 1", got)
+	}
+	if event := <-admin.ch; event.Type != EventAttendanceSubmitted {
+		t.Errorf("got event %q, want %q", event.Type, EventAttendanceSubmitted)
+	}
+	if got := len(other.ch); got != 0 {
+		t.Errorf("subscriber on another group got %d events", got)
+	}
+
+	hub.Unsubscribe(admin)
+	hub.Publish(EventWindowClosed, nil, groupTopic("g1"))
+	if got := len(admin.ch); got != 0 {
+		t.Errorf("unsubscribed subscriber got %d events", got)
+	}
+	if len(hub.subscribers[groupTopic("g1")]) != 0 {
+		t.Error("empty topic left behind after Unsubscribe")
+	}
+}
+
+func TestEventHubDropsForSlowSubscriber(t *testing.T) {
+	hub := &EventHub{subscribers: make(map[string]map[*subscriber]bool)}
+	sub := hub.Subscribe([]string{groupTopic("g1")})
+
+	// Publish must not block once the buffer is full
+	for i := 0; i < subscriberBufferSize+5; i++ {
+		hub.Publish(EventWindowTick, i, groupTopic("g1"))
+	}
+	if got := len(sub.ch); got != subscriberBufferSize {
+		t.Errorf("buffered %d events, want %d", got, subscriberBufferSize)
+	}
+}
+
+func TestWriteSSE(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := writeSSE(rec, Event{Type: EventWindowOpened, Data: map[string]string{"group_id": "g1"}, Time: "t"}); err != nil {
+		t.Fatal(err)
+	}
+	want := "event: window_opened\ndata: {\"type\":\"window_opened\",\"data\":{\"group_id\":\"g1\"},\"time\":\"t\"}\n\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestAdminEventTopics(t *testing.T) {
+	useSupabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		rows := []map[string]string{}
+		switch r.URL.Path {
+		case "/rest/v1/admins":
+			if query.Get("id") == "eq.admin-1" || query.Get("id") == "eq.admin-2" {
+				rows = append(rows, map[string]string{"id": "admin"})
+			}
+		case "/rest/v1/groups":
+			if query.Get("id") == "eq.g1" && query.Get("admin_id") == "eq.admin-1" {
+				rows = append(rows, map[string]string{"id": "g1"})
+			}
+		}
+		json.NewEncoder(w).Encode(rows)
+	}))
+	ctx := context.Background()
+
+	topics, err := adminEventTopics(ctx, "admin-1", "g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{adminTopic("admin-1"), groupTopic("g1"), groupAdminTopic("g1")}; !slices.Equal(topics, want) {
+		t.Errorf("owner: got %v, want %v", topics, want)
+	}
+
+	tests := []struct {
+		name, adminID, groupID string
+	}{
+		{"another admin's group", "admin-2", "g1"},
+		{"unknown admin", "admin-3", ""},
+		{"unknown admin on the default group", "admin-3", "default"},
+	}
+	for _, tt := range tests {
+		_, err := adminEventTopics(ctx, tt.adminID, tt.groupID)
+		if apiErr := apierror.From(err); err == nil || apiErr.Status != http.StatusForbidden {
+			t.Errorf("%s: got %v, want 403", tt.name, err)
+		}
+	}
+}
+
+func TestEventsRequiresAdminForGroup(t *testing.T) {
+	rec := httptest.NewRecorder()
+	eventsHandler(rec, httptest.NewRequest(http.MethodGet, "/api/events?group_id=g1", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("group_id alone: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestAttendanceEventReachesAdminsOnly(t *testing.T) {
+	member := eventHub.Subscribe([]string{groupTopic("g1")})
+	admin := eventHub.Subscribe([]string{groupAdminTopic("g1")})
+	t.Cleanup(func() {
+		eventHub.Unsubscribe(member)
+		eventHub.Unsubscribe(admin)
+	})
+
+	group := &GroupData{ID: "g1", StudentLocations: map[string]StudentLocation{}}
+	publishAttendanceSubmitted(group, StudentLocation{StudentID: "ST001", Latitude: 12.5, Longitude: 77.25})
+
+	if len(member.ch) != 0 {
+		t.Error("group member received another student's submission")
+	}
+	if len(admin.ch) != 1 {
+		t.Fatalf("admin got %d events, want 1", len(admin.ch))
+	}
+	payload, _ := json.Marshal((<-admin.ch).Data)
+	if !strings.Contains(string(payload), `"Latitude":12.5`) || !strings.Contains(string(payload), `"Longitude":77.25`) {
+		t.Errorf("admin event %s lacks the coordinates", payload)
+	}
+}
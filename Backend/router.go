@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"attendance-system/apierror"
+)
+
+// Middleware wraps a handler; see middleware.go
+type Middleware func(http.Handler) http.Handler
+
+// Router dispatches on method and path. Patterns are literal paths whose
+// segments may be {name} parameters, read by handlers with r.PathValue.
+// Unknown paths get a JSON 404 and known paths with the wrong method a
+// JSON 405 with an Allow header. Middleware runs for every request,
+// including the 404/405 ones.
+type Router struct {
+	routes     []*route
+	middleware []Middleware
+
+	once    sync.Once
+	handler http.Handler
+}
+
+type route struct {
+	method   string
+	pattern  string
+	segments []string
+	handler  http.Handler
+}
+
+// NewRouter returns an empty router
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Use appends middleware; the first one added is the outermost
+func (rt *Router) Use(mw ...Middleware) {
+	rt.middleware = append(rt.middleware, mw...)
+}
+
+// Handle registers a handler for method and pattern; GET, POST, PUT, PATCH
+// and DELETE are shorthands
+func (rt *Router) Handle(method, pattern string, handler http.HandlerFunc) {
+	rt.routes = append(rt.routes, &route{
+		method:   method,
+		pattern:  pattern,
+		segments: strings.Split(strings.Trim(pattern, "/"), "/"),
+		handler:  handler,
+	})
+}
+
+func (rt *Router) GET(pattern string, handler http.HandlerFunc) {
+	rt.Handle(http.MethodGet, pattern, handler)
+}
+
+func (rt *Router) POST(pattern string, handler http.HandlerFunc) {
+	rt.Handle(http.MethodPost, pattern, handler)
+}
+
+func (rt *Router) PUT(pattern string, handler http.HandlerFunc) {
+	rt.Handle(http.MethodPut, pattern, handler)
+}
+
+func (rt *Router) PATCH(pattern string, handler http.HandlerFunc) {
+	rt.Handle(http.MethodPatch, pattern, handler)
+}
+
+func (rt *Router) DELETE(pattern string, handler http.HandlerFunc) {
+	rt.Handle(http.MethodDelete, pattern, handler)
+}
+
+// RouteInfo describes a registered route
+type RouteInfo struct {
+	Method  string
+	Pattern string
+}
+
+// Routes lists the registered routes in registration order
+func (rt *Router) Routes() []RouteInfo {
+	infos := make([]RouteInfo, 0, len(rt.routes))
+	for _, route := range rt.routes {
+		infos = append(infos, RouteInfo{Method: route.method, Pattern: route.pattern})
+	}
+	return infos
+}
+
+// allowedMethods lists the methods registered for a path; GET implies HEAD
+func (rt *Router) allowedMethods(path string) []string {
+	seen := map[string]bool{}
+	for _, route := range rt.routes {
+		if _, ok := route.match(path); ok {
+			seen[route.method] = true
+			if route.method == http.MethodGet {
+				seen[http.MethodHead] = true
+			}
+		}
+	}
+	methods := make([]string, 0, len(seen)+1)
+	for method := range seen {
+		methods = append(methods, method)
+	}
+	if len(methods) > 0 {
+		methods = append(methods, http.MethodOptions)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// match reports whether path fits the route's pattern and returns the
+// path parameters
+func (route *route) match(path string) (map[string]string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != len(route.segments) {
+		return nil, false
+	}
+	var params map[string]string
+	for i, segment := range route.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if parts[i] == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[segment[1:len(segment)-1]] = parts[i]
+			continue
+		}
+		if segment != parts[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// lookup finds the route for a request; HEAD falls back to GET routes
+func (rt *Router) lookup(method, path string) (*route, map[string]string) {
+	for _, route := range rt.routes {
+		if route.method != method {
+			continue
+		}
+		if params, ok := route.match(path); ok {
+			return route, params
+		}
+	}
+	if method == http.MethodHead {
+		return rt.lookup(http.MethodGet, path)
+	}
+	return nil, nil
+}
+
+func (rt *Router) dispatch(w http.ResponseWriter, r *http.Request) {
+	if route, params := rt.lookup(r.Method, r.URL.Path); route != nil {
+		for name, value := range params {
+			r.SetPathValue(name, value)
+		}
+		setMatchedRoute(r, route.pattern)
+		route.handler.ServeHTTP(w, r)
+		return
+	}
+
+	if allowed := rt.allowedMethods(r.URL.Path); len(allowed) > 0 {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	writeError(w, r, http.StatusNotFound, "Not found")
+}
+
+// ServeHTTP runs the middleware chain around route dispatch. Routes and
+// middleware must all be registered before the first request.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.once.Do(func() {
+		var handler http.Handler = http.HandlerFunc(rt.dispatch)
+		for i := len(rt.middleware) - 1; i >= 0; i-- {
+			handler = rt.middleware[i](handler)
+		}
+		rt.handler = handler
+	})
+	rt.handler.ServeHTTP(w, r)
+}
+
+// writeError answers with an error whose code follows from status
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	respondError(w, r, apierror.New(status, message))
+}
+
+// respondError answers with err as JSON: {"error": message, "code": code}
+// on the legacy routes and the typed envelope on /api/v1. Errors that are
+// not *apierror.Error become a generic 500, and a 500 caused by the open
+// Supabase circuit breaker a 503. Causes are logged, never sent.
+func respondError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := apierror.From(err)
+	if apiErr.Status >= 500 && errors.Is(err, errSupabaseUnavailable) {
+		apiErr = apierror.Wrap(http.StatusServiceUnavailable, "Database temporarily unavailable; try again shortly", err)
+	}
+	if apiErr.Cause != nil {
+		level := slog.LevelError
+		if apiErr.Status < 500 {
+			level = slog.LevelInfo
+		}
+		slog.Log(r.Context(), level, apiErr.Message, "method", r.Method, "path", r.URL.Path, "status", apiErr.Status, "error", apiErr.Cause)
+	}
+	if isV1Request(r) {
+		writeAPIError(w, r, apiErr)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(map[string]string{"error": apiErr.Message, "code": string(apiErr.Code)})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	router := NewRouter()
+	router.GET("/api/v1/groups/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("get " + r.PathValue("id")))
+	})
+	router.DELETE("/api/v1/groups/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("delete " + r.PathValue("id")))
+	})
+
+	tests := []struct {
+		method, path string
+		status       int
+		body, allow  string
+	}{
+		{http.MethodGet, "/api/v1/groups/g1", http.StatusOK, "get g1", ""},
+		{http.MethodDelete, "/api/v1/groups/g1/", http.StatusOK, "delete g1", ""},
+		{http.MethodHead, "/api/v1/groups/g1", http.StatusOK, "get g1", ""},
+		{http.MethodPost, "/api/v1/groups/g1", http.StatusMethodNotAllowed, "", "DELETE, GET, HEAD, OPTIONS"},
+		{http.MethodGet, "/api/v1/groups", http.StatusNotFound, "", ""},
+		{http.MethodGet, "/api/v1/groups/g1/students", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, rec.Code, tt.status)
+			continue
+		}
+		if tt.body != "" && rec.Body.String() != tt.body {
+			t.Errorf("%s %s: body %q, want %q", tt.method, tt.path, rec.Body, tt.body)
+		}
+		if got := rec.Header().Get("Allow"); got != tt.allow {
+			t.Errorf("%s %s: Allow %q, want %q", tt.method, tt.path, got, tt.allow)
+		}
+	}
+}
+
+func TestRouterMiddlewareOrder(t *testing.T) {
+	var order []string
+	tag := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	router := NewRouter()
+	router.Use(tag("outer"), tag("inner"))
+
+	// Middleware also runs for unknown paths
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if len(order) != 2 || order[0] != "outer" || order[1] != "inner" {
+		t.Errorf("middleware ran as %v, want [outer inner]", order)
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"attendance-system/apierror"
+)
+
+// Scheduled message states (scheduled_messages.status)
+const (
+	ScheduledPending   = "pending"
+	ScheduledSent      = "sent"
+	ScheduledCancelled = "cancelled"
+)
+
+const schedulerInterval = 30 * time.Second
+
+// ScheduledMessage is a broadcast to be sent later, optionally repeating
+type ScheduledMessage struct {
+	ID            string             `json:"id"`
+	AdminID       string             `json:"admin_id"`
+	GroupID       *string            `json:"group_id"`
+	Title         string             `json:"title"`
+	Message       string             `json:"message"`
+	SentToAll     bool               `json:"sent_to_all"`
+	Audience      *BroadcastAudience `json:"audience"`
+	SendAt        string             `json:"send_at"`
+	Recurrence    *string            `json:"recurrence"`
+	Status        string             `json:"status"`
+	SendCount     int                `json:"send_count"`
+	LastSentAt    *string            `json:"last_sent_at"`
+	LastMessageID *string            `json:"last_message_id"`
+	CreatedAt     string             `json:"created_at,omitempty"`
+}
+
+// Recurrence is a weekly schedule such as "every monday 08:00".
+// Times are in the server's local time zone.
+type Recurrence struct {
+	Days   [7]bool // Indexed by time.Weekday
+	Hour   int
+	Minute int
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// parseRecurrence parses "every <days> HH:MM" where days is "day", "weekday",
+// "weekend" or a comma-separated list of weekday names
+func parseRecurrence(s string) (*Recurrence, error) {
+	fields := strings.Fields(strings.ToLower(strings.TrimSpace(s)))
+	if len(fields) != 3 || fields[0] != "every" {
+		return nil, errors.New(`recurrence must look like "every monday 08:00"`)
+	}
+
+	rec := &Recurrence{}
+	switch fields[1] {
+	case "day":
+		for d := range rec.Days {
+			rec.Days[d] = true
+		}
+	case "weekday":
+		for d := time.Monday; d <= time.Friday; d++ {
+			rec.Days[d] = true
+		}
+	case "weekend":
+		rec.Days[time.Saturday] = true
+		rec.Days[time.Sunday] = true
+	default:
+		for _, name := range strings.Split(fields[1], ",") {
+			day, ok := weekdayNames[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown day %q", name)
+			}
+			rec.Days[day] = true
+		}
+	}
+
+	clock := strings.SplitN(fields[2], ":", 2)
+	if len(clock) != 2 {
+		return nil, fmt.Errorf("invalid time %q, expected HH:MM", fields[2])
+	}
+	hour, errH := strconv.Atoi(clock[0])
+	minute, errM := strconv.Atoi(clock[1])
+	if errH != nil || errM != nil || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return nil, fmt.Errorf("invalid time %q, expected HH:MM", fields[2])
+	}
+	rec.Hour = hour
+	rec.Minute = minute
+	return rec, nil
+}
+
+// Next returns the first occurrence strictly after t
+func (rec *Recurrence) Next(t time.Time) time.Time {
+	local := t.In(time.Local)
+	for i := 0; i <= 7; i++ {
+		day := local.AddDate(0, 0, i)
+		candidate := time.Date(day.Year(), day.Month(), day.Day(), rec.Hour, rec.Minute, 0, 0, time.Local)
+		if rec.Days[candidate.Weekday()] && candidate.After(t) {
+			return candidate
+		}
+	}
+	return time.Time{} // Unreachable for a parsed recurrence
+}
+
+// runScheduler delivers due scheduled messages through the broadcast queue
+// and opens scheduled attendance windows
+func runScheduler(ctx context.Context) {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for {
+		deliverDueMessages(ctx)
+		openDueWindows(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func deliverDueMessages(ctx context.Context) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	dueURL := from("scheduled_messages").Eq("status", ScheduledPending).Filter("send_at", OpLte, now).
+		Select("*").Order("send_at.asc").URL()
+	due, err := fetchScheduledMessages(ctx, dueURL)
+	if err != nil {
+		slog.ErrorContext(ctx, "scheduler: failed to load due messages", "error", err)
+		return
+	}
+
+	for _, sm := range due {
+		deliverScheduledMessage(ctx, sm)
+	}
+}
+
+// deliverScheduledMessage queues one occurrence and advances the schedule.
+// The idempotency key is tied to the occurrence, so a crash between queuing
+// and updating the row can't send the same occurrence twice.
+func deliverScheduledMessage(ctx context.Context, sm ScheduledMessage) {
+	sendAt, err := time.Parse(time.RFC3339, sm.SendAt)
+	if err != nil {
+		slog.ErrorContext(ctx, "scheduler: invalid send_at", "scheduled_id", sm.ID, "send_at", sm.SendAt, "error", err)
+		return
+	}
+
+	audience := BroadcastAudience{SendToAll: sm.SentToAll}
+	if sm.Audience != nil {
+		audience = *sm.Audience
+	} else if sm.GroupID != nil {
+		audience.GroupID = *sm.GroupID
+	}
+
+	job, _, err := enqueueBroadcast(ctx, BroadcastRequest{
+		AdminID:        sm.AdminID,
+		Title:          sm.Title,
+		Message:        sm.Message,
+		Audience:       audience,
+		IdempotencyKey: fmt.Sprintf("scheduled:%s:%d", sm.ID, sendAt.Unix()),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "scheduler: failed to queue scheduled message", "scheduled_id", sm.ID, "error", err)
+		return
+	}
+
+	update := map[string]interface{}{
+		"send_count":      sm.SendCount + 1,
+		"last_sent_at":    time.Now().UTC().Format(time.RFC3339),
+		"last_message_id": job.MessageID,
+	}
+
+	if sm.Recurrence != nil && *sm.Recurrence != "" {
+		rec, err := parseRecurrence(*sm.Recurrence)
+		if err != nil {
+			slog.ErrorContext(ctx, "scheduler: invalid recurrence, stopping it", "scheduled_id", sm.ID, "error", err)
+			update["status"] = ScheduledSent
+		} else {
+			// Skip occurrences missed while the server was down
+			next := rec.Next(sendAt)
+			for !next.After(time.Now()) {
+				next = rec.Next(next)
+			}
+			update["send_at"] = next.UTC().Format(time.RFC3339)
+		}
+	} else {
+		update["status"] = ScheduledSent
+	}
+
+	// Only a pending schedule advances, so a cancel made while the
+	// occurrence was queued isn't undone
+	updateURL := from("scheduled_messages").Eq("id", sm.ID).Eq("status", ScheduledPending).URL()
+	if err := supabasePatch(ctx, updateURL, update); err != nil {
+		slog.ErrorContext(ctx, "scheduler: failed to update scheduled message", "scheduled_id", sm.ID, "error", err)
+		return
+	}
+
+	slog.InfoContext(ctx, "scheduler: sent scheduled message", "scheduled_id", sm.ID, "message_id", job.MessageID)
+}
+
+func fetchScheduledMessages(ctx context.Context, listURL string) ([]ScheduledMessage, error) {
+	req, err := newSupabaseRequest(ctx, "GET", listURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := supabaseClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var messages []ScheduledMessage
+	if err := json.NewDecoder(resp.Body).Decode(&messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// Handler: POST /api/schedule-message
+func scheduleMessageHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		AdminID    string `json:"admin_id"`
+		GroupID    string `json:"group_id"`
+		Title      string `json:"title"`
+		Message    string `json:"message"`
+		SendToAll  bool   `json:"send_to_all"`
+		SendAt     string `json:"send_at"`    // RFC3339; optional for recurring messages
+		Recurrence string `json:"recurrence"` // Optional, e.g. "every monday 08:00"
+
+		Audience *BroadcastAudience `json:"audience"` // Optional, overrides group_id/send_to_all
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if data.AdminID == "" || data.Title == "" || data.Message == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id, title and message are required")
+		return
+	}
+	if data.SendAt == "" && data.Recurrence == "" {
+		writeError(w, r, http.StatusBadRequest, "send_at or recurrence is required")
+		return
+	}
+	if err := checkPlaceholders(data.Title, data.Message); err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	audience := BroadcastAudience{
+		SendToAll: data.SendToAll || data.GroupID == "",
+		GroupID:   data.GroupID,
+	}
+	if data.Audience != nil {
+		audience = *data.Audience
+	}
+	if err := audience.Validate(); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid audience: "+err.Error())
+		return
+	}
+
+	var rec *Recurrence
+	if data.Recurrence != "" {
+		var err error
+		if rec, err = parseRecurrence(data.Recurrence); err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid recurrence: "+err.Error())
+			return
+		}
+	}
+
+	var sendAt time.Time
+	if data.SendAt != "" {
+		var err error
+		if sendAt, err = time.Parse(time.RFC3339, data.SendAt); err != nil {
+			writeError(w, r, http.StatusBadRequest, "send_at must be an RFC3339 timestamp")
+			return
+		}
+		if sendAt.Before(time.Now().Add(-time.Minute)) {
+			writeError(w, r, http.StatusBadRequest, "send_at must be in the future")
+			return
+		}
+	} else {
+		sendAt = rec.Next(time.Now())
+	}
+
+	row := map[string]interface{}{
+		"admin_id":    data.AdminID,
+		"title":       data.Title,
+		"message":     data.Message,
+		"sent_to_all": audience.SendToAll,
+		"audience":    audience,
+		"send_at":     sendAt.UTC().Format(time.RFC3339),
+		"status":      ScheduledPending,
+	}
+	if groupID := audience.SingleGroupID(); groupID != "" {
+		row["group_id"] = groupID
+	}
+	if data.Recurrence != "" {
+		row["recurrence"] = data.Recurrence
+	}
+
+	scheduledID, _, err := insertReturning(r.Context(), "scheduled_messages", row)
+	if err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to schedule message", err))
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"message":      "Message scheduled",
+		"scheduled_id": scheduledID,
+		"send_at":      sendAt.UTC().Format(time.RFC3339),
+		"recurrence":   data.Recurrence,
+	})
+}
+
+// Handler: POST /api/cancel-scheduled-message
+func cancelScheduledMessageHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		AdminID     string `json:"admin_id"`
+		ScheduledID string `json:"scheduled_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if data.AdminID == "" || data.ScheduledID == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id and scheduled_id are required")
+		return
+	}
+
+	// Only pending messages owned by this admin can be cancelled
+	updateURL := from("scheduled_messages").Eq("id", data.ScheduledID).Eq("admin_id", data.AdminID).
+		Eq("status", ScheduledPending).URL()
+	jsonData, _ := json.Marshal(map[string]string{"status": ScheduledCancelled})
+	req, _ := newSupabaseRequest(r.Context(), "PATCH", updateURL, bytes.NewBuffer(jsonData))
+	req.Header.Set("Prefer", "return=representation")
+
+	resp, err := supabaseClient.Do(req)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Database connection error")
+		return
+	}
+	defer resp.Body.Close()
+
+	var cancelled []ScheduledMessage
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&cancelled) != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to cancel scheduled message")
+		return
+	}
+	if len(cancelled) == 0 {
+		writeError(w, r, http.StatusNotFound, "No pending scheduled message found")
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Scheduled message cancelled",
+	})
+}
+
+// Handler: GET /api/get-scheduled-messages?admin_id=xxx
+func getScheduledMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	adminID := r.URL.Query().Get("admin_id")
+	w.Header().Set("Content-Type", "application/json")
+	if adminID == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id is required")
+		return
+	}
+
+	listURL := from("scheduled_messages").Eq("admin_id", adminID).Eq("status", ScheduledPending).
+		Select("*").Order("send_at.asc").URL()
+	messages, err := fetchScheduledMessages(r.Context(), listURL)
+	if err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to fetch scheduled messages", err))
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"scheduled_messages": messages,
+		"count":              len(messages),
+	})
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	valid := []string{"every day 08:00", "every weekday 8:05", "every weekend 23:59", "Every Mon,Wed,friday 00:00"}
+	for _, s := range valid {
+		if _, err := parseRecurrence(s); err != nil {
+			t.Errorf("parseRecurrence(%q): %v", s, err)
+		}
+	}
+	invalid := []string{"", "daily 08:00", "every day", "every someday 08:00", "every day 24:00", "every day 08:60", "every day 8am"}
+	for _, s := range invalid {
+		if _, err := parseRecurrence(s); err == nil {
+			t.Errorf("parseRecurrence(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	// 2026-01-05 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, time.January, day, hour, minute, 0, 0, time.Local)
+	}
+	tests := []struct {
+		recurrence string
+		after      time.Time
+		want       time.Time
+	}{
+		{"every day 08:00", at(5, 7, 0), at(5, 8, 0)},
+		{"every day 08:00", at(5, 8, 0), at(6, 8, 0)}, // Strictly after
+		{"every weekday 09:30", at(9, 10, 0), at(12, 9, 30)},
+		{"every weekend 12:00", at(5, 12, 0), at(10, 12, 0)},
+		{"every monday 08:00", at(5, 8, 1), at(12, 8, 0)},
+	}
+	for _, tt := range tests {
+		rec, err := parseRecurrence(tt.recurrence)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := rec.Next(tt.after); !got.Equal(tt.want) {
+			t.Errorf("%q after %v: got %v, want %v", tt.recurrence, tt.after, got, tt.want)
+		}
+	}
+}
+
+// fakeScheduledMessages records the idempotency keys of the broadcasts queued
+// and the updates made to scheduled_messages; every read finds nothing
+type fakeScheduledMessages struct {
+	mu      sync.Mutex
+	keys    []string
+	updates []scheduledMessageUpdate
+}
+
+type scheduledMessageUpdate struct {
+	query  url.Values
+	fields map[string]interface{}
+}
+
+func (f *fakeScheduledMessages) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/rest/v1/broadcast_messages":
+		var message map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&message)
+		key, _ := message["idempotency_key"].(string)
+		f.keys = append(f.keys, key)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode([]map[string]string{{"id": fmt.Sprintf("m%d", len(f.keys))}})
+	case r.Method == http.MethodPost && r.URL.Path == "/rest/v1/broadcast_jobs":
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode([]map[string]string{{"id": fmt.Sprintf("j%d-%d", len(f.keys), time.Now().UnixNano())}})
+	case r.Method == http.MethodPatch && r.URL.Path == "/rest/v1/scheduled_messages":
+		var fields map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&fields)
+		f.updates = append(f.updates, scheduledMessageUpdate{r.URL.Query(), fields})
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet:
+		w.Write([]byte("[]"))
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestDeliverScheduledMessage(t *testing.T) {
+	every := "every day 08:00"
+	tests := []struct {
+		name       string
+		sentAgo    time.Duration
+		recurrence *string
+		wantStatus interface{}
+	}{
+		{"one-off", time.Minute, nil, ScheduledSent},
+		{"recurring", time.Minute, &every, nil},
+		{"recurring missed", 3 * 24 * time.Hour, &every, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeScheduledMessages{}
+			useSupabase(t, fake)
+
+			sendAt := time.Now().Add(-tt.sentAgo).UTC().Truncate(time.Second)
+			deliverScheduledMessage(context.Background(), ScheduledMessage{
+				ID: "sm1", AdminID: "admin-1", Title: "Reminder", Message: "Class today", SentToAll: true,
+				SendAt: sendAt.Format(time.RFC3339), Recurrence: tt.recurrence, Status: ScheduledPending, SendCount: 2,
+			})
+
+			fake.mu.Lock()
+			defer fake.mu.Unlock()
+			if want := fmt.Sprintf("scheduled:sm1:%d", sendAt.Unix()); len(fake.keys) != 1 || fake.keys[0] != want {
+				t.Fatalf("queued with keys %q, want one with %q", fake.keys, want)
+			}
+			if len(fake.updates) != 1 {
+				t.Fatalf("%d scheduled message updates, want 1", len(fake.updates))
+			}
+			update := fake.updates[0]
+			if update.query.Get("id") != "eq.sm1" || update.query.Get("status") != "eq."+ScheduledPending {
+				t.Errorf("update filtered by %v, want id=eq.sm1 and status=eq.pending", update.query)
+			}
+			if update.fields["status"] != tt.wantStatus || update.fields["send_count"] != 3.0 {
+				t.Errorf("update %v, want status %v and send_count 3", update.fields, tt.wantStatus)
+			}
+			if tt.recurrence != nil {
+				// Occurrences missed while the server was down are skipped
+				next, err := time.Parse(time.RFC3339, update.fields["send_at"].(string))
+				if err != nil || !next.After(time.Now()) || next.Sub(time.Now()) > 24*time.Hour {
+					t.Errorf("next send_at %v, want the next occurrence after now", update.fields["send_at"])
+				}
+			} else if _, advanced := update.fields["send_at"]; advanced {
+				t.Error("one-off message was given another occurrence")
+			}
+		})
+	}
+}
+
+func TestDeliverScheduledMessageOncePerOccurrence(t *testing.T) {
+	fake := &fakeScheduledMessages{}
+	useSupabase(t, fake)
+	every := "every day 08:00"
+	first := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+	sm := ScheduledMessage{
+		ID: "sm1", AdminID: "admin-1", Title: "Reminder", Message: "Class today",
+		SentToAll: true, Recurrence: &every, Status: ScheduledPending,
+	}
+
+	// The row update after the first delivery was lost, so the same
+	// occurrence is delivered again before the next one
+	for _, sendAt := range []time.Time{first, first, first.Add(24 * time.Hour)} {
+		sm.SendAt = sendAt.Format(time.RFC3339)
+		deliverScheduledMessage(context.Background(), sm)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.keys) != 3 || fake.keys[0] != fake.keys[1] || fake.keys[1] == fake.keys[2] {
+		t.Errorf("idempotency keys %q, want one per occurrence", fake.keys)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"attendance-system/apierror"
+)
+
+// Server timeouts. Writes get a deadline per request; the SSE stream clears
+// its own through http.ResponseController.
+const (
+	readHeaderTimeout = 10 * time.Second
+	readTimeout       = 60 * time.Second // Attachment uploads are the slowest bodies
+	writeTimeout      = 60 * time.Second
+	idleTimeout       = 120 * time.Second
+
+	shutdownTimeout  = 30 * time.Second
+	readinessTimeout = 3 * time.Second
+)
+
+// serve runs the HTTP server until ctx is done (SIGTERM or SIGINT), then
+// shuts down: in-flight requests drain, event streams end, running
+// broadcast jobs finish, open windows are persisted and CSV files flushed.
+func serve(ctx context.Context, addr string, handler http.Handler) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
+	}
+	// Streams never go idle, so Shutdown would wait them out
+	server.RegisterOnShutdown(eventHub.Close)
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down", "timeout", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("requests still running at shutdown", "error", err)
+	}
+	if err := deliveryQueue.Wait(shutdownCtx); err != nil {
+		slog.Warn("broadcast jobs still running at shutdown; they resume on the next start", "error", err)
+	}
+	persistOpenWindows(shutdownCtx)
+	flushCSVWriters()
+
+	slog.Info("shutdown complete")
+	return nil
+}
+
+// windowTimeLayout is how window times are stored in the groups table: the
+// server's local wall-clock time in a TIMESTAMP column
+const windowTimeLayout = "2006-01-02 15:04:05"
+
+// persistOpenWindows writes every open window to its group row, so
+// restoreOpenWindows can reopen it after a restart
+func persistOpenWindows(ctx context.Context) {
+	type activeWindow struct {
+		groupID    string
+		groupOnly  bool
+		start, end time.Time
+	}
+	var windows []activeWindow
+	groupManager.forEachGroup(func(group *GroupData) {
+		if group.WindowActive && group.ID != "default" {
+			windows = append(windows, activeWindow{group.ID, group.GroupOnly, group.WindowStartTime, group.WindowEndTime})
+		}
+	})
+
+	for _, window := range windows {
+		updateURL := from("groups").Eq("id", window.groupID).URL()
+		err := supabasePatch(ctx, updateURL, map[string]interface{}{
+			"status":            "active",
+			"group_only":        window.groupOnly,
+			"window_start_time": window.start.Format(windowTimeLayout),
+			"window_end_time":   window.end.Format(windowTimeLayout),
+		})
+		if err != nil {
+			slog.Error("failed to persist open window", "group_id", window.groupID, "error", err)
+			continue
+		}
+		slog.Info("persisted open window", "group_id", window.groupID, "ends_at", window.end)
+	}
+}
+
+// savedWindow is a group row's window state
+type savedWindow struct {
+	ID              string  `json:"id"`
+	Status          string  `json:"status"`
+	GroupOnly       bool    `json:"group_only"`
+	WindowStartTime *string `json:"window_start_time"`
+	WindowEndTime   *string `json:"window_end_time"`
+}
+
+const savedWindowSelect = "id,status,group_only,window_start_time,window_end_time"
+
+// times parses the saved window's start and end
+func (w savedWindow) times() (start, end time.Time, err error) {
+	if w.WindowStartTime == nil || w.WindowEndTime == nil {
+		return start, end, errors.New("window times not saved")
+	}
+	if start, err = parseWindowTime(*w.WindowStartTime); err != nil {
+		return start, end, err
+	}
+	end, err = parseWindowTime(*w.WindowEndTime)
+	return start, end, err
+}
+
+// restoreOpenWindows reopens the windows that were open when the server last
+// stopped, so a deploy doesn't close them. Windows that ran out while the
+// server was down stay closed.
+func restoreOpenWindows(ctx context.Context) {
+	var saved []savedWindow
+	activeURL := from("groups").Eq("status", "active").Select(savedWindowSelect).URL()
+	if err := supabaseGetJSON(ctx, activeURL, &saved); err != nil {
+		slog.ErrorContext(ctx, "failed to load open windows", "error", err)
+		return
+	}
+	for _, window := range saved {
+		if err := restoreWindow(ctx, window); err != nil {
+			slog.ErrorContext(ctx, "failed to restore window", "group_id", window.ID, "error", err)
+		}
+	}
+}
+
+// restoreGroupWindow restores one group's window from the database when the
+// group isn't in memory (its restore at startup failed). Reports whether the
+// group is now known.
+func restoreGroupWindow(ctx context.Context, groupID string) bool {
+	if groupID == "default" {
+		return false
+	}
+	var saved []savedWindow
+	groupURL := from("groups").Eq("id", groupID).Select(savedWindowSelect).URL()
+	if err := supabaseGetJSON(ctx, groupURL, &saved); err != nil {
+		slog.ErrorContext(ctx, "failed to load window", "group_id", groupID, "error", err)
+		return false
+	}
+	if len(saved) == 0 || saved[0].Status != "active" {
+		return false
+	}
+	if err := restoreWindow(ctx, saved[0]); err != nil {
+		slog.ErrorContext(ctx, "failed to restore window", "group_id", groupID, "error", err)
+		return false
+	}
+	return true
+}
+
+// restoreWindow reopens a saved window with everything openWindow sets up:
+// the group's location, its scope, the submissions made so far, a CSV and
+// the auto-close. An expired window is left closed.
+func restoreWindow(ctx context.Context, window savedWindow) error {
+	startTime, endTime, err := window.times()
+	if err != nil {
+		return err
+	}
+	if !time.Now().Before(endTime) {
+		slog.InfoContext(ctx, "saved window has expired", "group_id", window.ID, "ended_at", endTime)
+		return nil
+	}
+
+	// Submissions are measured against the group's location
+	if err := loadGroupLocation(ctx, window.ID); err != nil {
+		return err
+	}
+	group, _ := groupManager.GetGroup(window.ID)
+	restoreStudentLocations(ctx, group, startTime)
+
+	group.mu.Lock()
+	defer group.mu.Unlock()
+	if group.WindowActive {
+		return nil
+	}
+	group.GroupOnly = window.GroupOnly
+	group.WindowStartTime = startTime
+	group.WindowEndTime = endTime
+	group.WindowActive = true
+	openWindowCSVLocked(ctx, group)
+	scheduleWindowReminder(group.ID, startTime, endTime)
+	scheduleWindowExpiry(group.ID, endTime)
+
+	slog.InfoContext(ctx, "restored window", "group_id", group.ID, "group_only", group.GroupOnly,
+		"submissions", len(group.SubmittedStudents), "ends_at", endTime)
+	return nil
+}
+
+// parseWindowTime reads a window time back from the groups table
+func parseWindowTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04:05", value, time.Local)
+}
+
+// flushCSVWriters flushes and closes every group's attendance CSV
+func flushCSVWriters() {
+	groupManager.mu.RLock()
+	defer groupManager.mu.RUnlock()
+	for _, group := range groupManager.groups {
+		group.mu.Lock()
+		if group.CSVWriter != nil {
+			group.CSVWriter.Flush()
+			if err := group.CSVWriter.Error(); err != nil {
+				slog.Error("failed to flush attendance CSV", "group_id", group.ID, "error", err)
+			}
+		}
+		if group.CSVFile != nil {
+			if err := group.CSVFile.Close(); err != nil {
+				slog.Error("failed to close attendance CSV", "group_id", group.ID, "error", err)
+			}
+			group.CSVFile = nil
+			group.CSVWriter = nil
+		}
+		group.mu.Unlock()
+	}
+}
+
+// Handler: GET /healthz
+// The process is up and serving; says nothing about its dependencies.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok"})
+}
+
+// Handler: GET /readyz
+// The server can do useful work: Supabase answers queries. Answers 503
+// with code "unavailable" when it doesn't.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+
+	if err := checkSupabase(ctx); err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusServiceUnavailable, "Supabase is unreachable", err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "ready",
+		"checks": map[string]string{"supabase": "ok"},
+	})
+}
+
+// checkSupabase runs the cheapest query that proves the database is
+// reachable and the key is accepted
+func checkSupabase(ctx context.Context) error {
+	if config.SupabaseURL == "" {
+		return errors.New("SUPABASE_URL not set")
+	}
+	var rows []struct{}
+	return supabaseGetJSON(ctx, from("groups").Select("id").Limit(1).URL(), &rows)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGroupsTable keeps one group row, taking PATCHes and answering GETs
+// with window times formatted the way PostgREST returns a TIMESTAMP. Its
+// attendance has one submission, returned when the query asks for this
+// window's.
+type fakeGroupsTable struct {
+	mu              sync.Mutex
+	row             map[string]interface{}
+	attendanceSince string
+}
+
+func (f *fakeGroupsTable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodPatch && r.URL.Path == "/rest/v1/groups":
+		var update map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&update)
+		for column, value := range update {
+			if s, ok := value.(string); ok && strings.HasPrefix(column, "window_") {
+				value = strings.Replace(s, " ", "T", 1)
+			}
+			f.row[column] = value
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && r.URL.Path == "/rest/v1/groups":
+		rows := []map[string]interface{}{}
+		if query.Get("id") == "eq."+f.row["id"].(string) || query.Get("status") == "eq."+f.row["status"].(string) {
+			rows = append(rows, f.row)
+		}
+		json.NewEncoder(w).Encode(rows)
+	case r.Method == http.MethodGet && r.URL.Path == "/rest/v1/group_attendance":
+		f.attendanceSince = query.Get("submitted_at")
+		rows := []map[string]interface{}{}
+		if f.attendanceSince != "" {
+			rows = append(rows, map[string]interface{}{
+				"status": "Present", "distance": 12.0, "latitude": 12.9716, "longitude": 77.5946,
+				"submitted_at": "2026-01-01T09:01:00",
+				"students":     map[string]string{"student_id": "ST001", "student_name": "Ann"},
+			})
+		}
+		json.NewEncoder(w).Encode(rows)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestOpenWindowSurvivesRestart(t *testing.T) {
+	table := &fakeGroupsTable{row: map[string]interface{}{
+		"id": "g1", "name": "Workshop", "admin_id": "admin-1", "status": "inactive",
+		"location_lat": 12.9716, "location_lon": 77.5946, "threshold_meters": 50.0,
+	}}
+	useSupabase(t, table)
+	config.CSVDir = t.TempDir()
+
+	group := useGroup(t, "g1")
+	group.Name = "Workshop"
+	group.AdminLat, group.AdminLon = 12.9716, 77.5946
+	group.GroupOnly = true
+	group.WindowStartTime = time.Now()
+	group.WindowEndTime = time.Now().Add(2 * time.Second)
+
+	// Shut down, then start again with nothing in memory
+	persistOpenWindows(context.Background())
+	groupManager.mu.Lock()
+	delete(groupManager.groups, "g1")
+	groupManager.mu.Unlock()
+	restoreOpenWindows(context.Background())
+
+	restored, exists := groupManager.GetGroup("g1")
+	if !exists {
+		t.Fatal("window not restored")
+	}
+	t.Cleanup(func() { groupManager.DeleteGroup("g1") })
+
+	restored.mu.RLock()
+	if !restored.WindowActive || !restored.GroupOnly {
+		t.Errorf("restored window active=%v group_only=%v, want both true", restored.WindowActive, restored.GroupOnly)
+	}
+	if restored.AdminLat != 12.9716 || restored.AdminLon != 77.5946 || restored.ThresholdMeters != 50 {
+		t.Errorf("restored location (%v, %v) within %vm, want the group's", restored.AdminLat, restored.AdminLon, restored.ThresholdMeters)
+	}
+	if restored.CSVWriter == nil {
+		t.Error("restored window has no CSV")
+	}
+	if !restored.SubmittedStudents["ST001"] {
+		t.Error("submission made before the restart was forgotten")
+	}
+	restored.mu.RUnlock()
+	if !strings.HasPrefix(table.attendanceSince, "gte.") {
+		t.Errorf("submissions loaded with submitted_at=%q, want only this window's", table.attendanceSince)
+	}
+
+	// The auto-close is armed again
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		restored.mu.RLock()
+		active := restored.WindowActive
+		restored.mu.RUnlock()
+		if !active {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("restored window never closed")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
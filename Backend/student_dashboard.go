@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"time"
+
+	"attendance-system/apierror"
+)
+
+// ActiveWindow is an open attendance window a student can submit to
+type ActiveWindow struct {
+	GroupID          string  `json:"group_id"`
+	GroupName        string  `json:"group_name"`
+	GroupOnly        bool    `json:"group_only"`
+	RemainingSeconds int     `json:"remaining_seconds"`
+	EndsAt           string  `json:"ends_at"`
+	Submitted        bool    `json:"submitted"`
+	Status           string  `json:"status,omitempty"`       // "Present" or "Absent" once submitted
+	SubmittedAt      string  `json:"submitted_at,omitempty"` // Server local time, as in the CSV
+	Distance         float64 `json:"distance,omitempty"`
+}
+
+// UpcomingWindow is a scheduled window that hasn't opened yet
+type UpcomingWindow struct {
+	ScheduledID string  `json:"scheduled_id"`
+	GroupID     string  `json:"group_id"`
+	GroupName   string  `json:"group_name"`
+	GroupOnly   bool    `json:"group_only"`
+	OpensAt     string  `json:"opens_at"`
+	ClosesAt    string  `json:"closes_at"`
+	Recurrence  *string `json:"recurrence,omitempty"`
+}
+
+const maxUpcomingWindows = 20
+
+// studentGroupIDs lists the groups a student (by UUID) belongs to
+func studentGroupIDs(ctx context.Context, studentUUID string) ([]string, error) {
+	var memberships []struct {
+		GroupID string `json:"group_id"`
+	}
+	groupsURL := from("group_students").Eq("student_id", studentUUID).Select("group_id").URL()
+	if err := supabaseGetJSON(ctx, groupsURL, &memberships); err != nil {
+		return nil, err
+	}
+	groupIDs := make([]string, 0, len(memberships))
+	for _, m := range memberships {
+		groupIDs = append(groupIDs, m.GroupID)
+	}
+	return groupIDs, nil
+}
+
+// studentActiveWindows returns every open window the student can access:
+// windows of their own groups plus any window open to all students.
+// Sorted by remaining time, most first.
+func studentActiveWindows(studentID string, groupIDs []string) []ActiveWindow {
+	member := make(map[string]bool, len(groupIDs))
+	for _, id := range groupIDs {
+		member[id] = true
+	}
+
+	windows := []ActiveWindow{}
+	groupManager.mu.RLock()
+	for gID, group := range groupManager.groups {
+		group.mu.RLock()
+		if group.WindowActive && (member[gID] || !group.GroupOnly) {
+			window := ActiveWindow{
+				GroupID:          gID,
+				GroupName:        group.Name,
+				GroupOnly:        group.GroupOnly,
+				RemainingSeconds: remainingSeconds(group),
+				EndsAt:           group.WindowEndTime.UTC().Format(time.RFC3339),
+				Submitted:        group.SubmittedStudents[studentID],
+			}
+			if loc, ok := group.StudentLocations[studentID]; ok {
+				window.Status = loc.Status
+				window.SubmittedAt = loc.Timestamp
+				window.Distance = loc.Distance
+			}
+			windows = append(windows, window)
+		}
+		group.mu.RUnlock()
+	}
+	groupManager.mu.RUnlock()
+
+	sort.Slice(windows, func(i, j int) bool {
+		if windows[i].RemainingSeconds != windows[j].RemainingSeconds {
+			return windows[i].RemainingSeconds > windows[j].RemainingSeconds
+		}
+		return windows[i].GroupID < windows[j].GroupID
+	})
+	return windows
+}
+
+// studentUpcomingWindows lists pending scheduled windows for the student's
+// groups and scheduled windows open to all students
+func studentUpcomingWindows(ctx context.Context, groupIDs []string) ([]UpcomingWindow, error) {
+	audience := []Condition{whereIs("group_only", IsFalse)}
+	if len(groupIDs) > 0 {
+		audience = append([]Condition{whereIn("group_id", groupIDs)}, audience...)
+	}
+
+	var rows []struct {
+		ScheduledWindow
+		Groups *struct {
+			Name string `json:"name"`
+		} `json:"groups"`
+	}
+	upcomingURL := from("scheduled_windows").Eq("status", ScheduledPending).Or(audience...).
+		Select(scheduledWindowSelect + ",groups(name)").Order("opens_at.asc").Limit(maxUpcomingWindows).URL()
+	if err := supabaseGetJSON(ctx, upcomingURL, &rows); err != nil {
+		return nil, err
+	}
+
+	upcoming := make([]UpcomingWindow, 0, len(rows))
+	for _, row := range rows {
+		window := UpcomingWindow{
+			ScheduledID: row.ID,
+			GroupID:     row.GroupID,
+			GroupOnly:   row.GroupOnly,
+			OpensAt:     row.OpensAt,
+			Recurrence:  row.Recurrence,
+		}
+		if row.Groups != nil {
+			window.GroupName = row.Groups.Name
+		}
+		if opensAt, err := time.Parse(time.RFC3339, row.OpensAt); err == nil {
+			window.ClosesAt = opensAt.Add(config.WindowDuration).UTC().Format(time.RFC3339)
+		}
+		upcoming = append(upcoming, window)
+	}
+	return upcoming, nil
+}
+
+// studentUnreadThreads counts unread admin replies across the student's
+// visible conversations
+func studentUnreadThreads(ctx context.Context, studentUUID string) (int, error) {
+	countURL := from("thread_messages").Eq("sender_type", SenderAdmin).Eq("is_read", "false").
+		Select("id,message_threads!inner(id)").Eq("message_threads.student_id", studentUUID).
+		Eq("message_threads.student_deleted", "false").URL()
+	return supabaseCount(ctx, countURL)
+}
+
+// Handler: GET /api/get-student-dashboard?student_id=xxx
+// Everything the student home screen needs: all open windows with their
+// submission state, upcoming scheduled windows and unread counts
+func getStudentDashboardHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	studentID := r.URL.Query().Get("student_id")
+	if studentID == "" {
+		writeError(w, r, http.StatusBadRequest, "student_id is required")
+		return
+	}
+
+	studentUUID := getStudentUUIDByID(r.Context(), studentID)
+	if studentUUID == "" {
+		writeError(w, r, http.StatusNotFound, "Student not found")
+		return
+	}
+
+	groupIDs, err := studentGroupIDs(r.Context(), studentUUID)
+	if err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to load groups", err))
+		return
+	}
+
+	activeWindows := studentActiveWindows(studentID, groupIDs)
+	pending := 0
+	for _, window := range activeWindows {
+		if !window.Submitted {
+			pending++
+		}
+	}
+
+	// The sections below are best effort: a failure leaves that section
+	// empty rather than hiding the open windows
+	upcomingWindows, err := studentUpcomingWindows(r.Context(), groupIDs)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to load upcoming windows", "error", err)
+		upcomingWindows = []UpcomingWindow{}
+	}
+
+	unreadURL := from("message_recipients").Eq("student_id", studentUUID).Eq("is_read", "false").
+		Select("id,broadcast_messages!inner(id)").EmbeddedOr("broadcast_messages", unexpired()...).
+		Is("broadcast_messages.recalled_at", IsNull).URL()
+	unreadMessages, err := supabaseCount(r.Context(), unreadURL)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to count unread messages", "error", err)
+	}
+
+	unreadThreads, err := studentUnreadThreads(r.Context(), studentUUID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to count unread replies", "error", err)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"student_id":       studentID,
+		"active_windows":   activeWindows,
+		"pending_count":    pending,
+		"upcoming_windows": upcomingWindows,
+		"unread_messages":  unreadMessages,
+		"unread_replies":   unreadThreads,
+		"server_time":      time.Now().UTC().Format(time.RFC3339),
+	})
+}
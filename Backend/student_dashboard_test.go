@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// useGroup registers a group with an open window for the rest of the test
+func useGroup(t *testing.T, groupID string) *GroupData {
+	t.Helper()
+	group := &GroupData{
+		ID:                groupID,
+		ThresholdMeters:   100,
+		WindowActive:      true,
+		WindowEndTime:     time.Now().Add(time.Hour),
+		SubmittedStudents: make(map[string]bool),
+		StudentLocations:  make(map[string]StudentLocation),
+	}
+	groupManager.mu.Lock()
+	groupManager.groups[groupID] = group
+	groupManager.mu.Unlock()
+	t.Cleanup(func() {
+		groupManager.mu.Lock()
+		delete(groupManager.groups, groupID)
+		groupManager.mu.Unlock()
+	})
+	return group
+}
+
+func TestStudentActiveWindows(t *testing.T) {
+	now := time.Now()
+	own := useGroup(t, "own")
+	own.GroupOnly = true
+	own.WindowEndTime = now.Add(2 * time.Minute)
+	own.SubmittedStudents["ST001"] = true
+	own.StudentLocations["ST001"] = StudentLocation{Status: "Present", Distance: 12}
+
+	open := useGroup(t, "open")
+	open.WindowEndTime = now.Add(8 * time.Minute)
+
+	other := useGroup(t, "other")
+	other.GroupOnly = true
+	other.WindowEndTime = now.Add(5 * time.Minute)
+
+	closed := useGroup(t, "closed")
+	closed.WindowActive = false
+
+	windows := studentActiveWindows("ST001", []string{"own", "closed"})
+	if len(windows) != 2 || windows[0].GroupID != "open" || windows[1].GroupID != "own" {
+		t.Fatalf("got %+v, want the open window then the student's own group", windows)
+	}
+	if windows[0].Submitted {
+		t.Error("open window reported as submitted")
+	}
+	if got := windows[1]; !got.Submitted || got.Status != "Present" || got.Distance != 12 {
+		t.Errorf("own window = %+v, want the submission", got)
+	}
+}
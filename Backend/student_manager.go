@@ -5,59 +5,45 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+
+	"attendance-system/apierror"
 )
 
 // Handler: POST /api/add-student
 func addStudentHandler(w http.ResponseWriter, r *http.Request) {
-	enableCORS(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var data struct {
 		StudentID   string `json:"student_id"`
 		StudentName string `json:"student_name"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "Invalid JSON")
 		return
 	}
 
 	// Validate required fields
 	if data.StudentID == "" || data.StudentName == "" {
-		http.Error(w, "student_id and student_name are required", http.StatusBadRequest)
+		writeError(w, r, http.StatusBadRequest, "student_id and student_name are required")
 		return
 	}
 
 	// Check if student_id already exists
-	checkURL := fmt.Sprintf("%s/rest/v1/students?student_id=eq.%s&select=id", config.SupabaseURL, data.StudentID)
-	checkReq, _ := http.NewRequest("GET", checkURL, nil)
-	checkReq.Header.Set("apikey", config.SupabaseAnonKey)
-	checkReq.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
+	checkURL := from("students").Eq("student_id", data.StudentID).Select("id").URL()
+	checkReq, _ := newSupabaseRequest(r.Context(), "GET", checkURL, nil)
 
-	checkResp, err := http.DefaultClient.Do(checkReq)
+	checkResp, err := supabaseClient.Do(checkReq)
 	if err == nil {
 		defer checkResp.Body.Close()
 		bodyBytes, _ := io.ReadAll(checkResp.Body)
 		var existing []map[string]interface{}
 		if json.NewDecoder(bytes.NewReader(bodyBytes)).Decode(&existing) == nil && len(existing) > 0 {
-			w.WriteHeader(http.StatusConflict)
-			json.NewEncoder(w).Encode(map[string]string{
-				"error": "Student ID already exists",
-			})
+			writeError(w, r, http.StatusConflict, "Student ID already exists")
 			return
 		}
 	} else {
-		fmt.Printf("DEBUG: Error checking existing student: %v\n", err)
+		slog.WarnContext(r.Context(), "failed to check for an existing student", "student_id", data.StudentID, "error", err)
 	}
 
 	// Create student
@@ -67,34 +53,24 @@ func addStudentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	jsonData, _ := json.Marshal(studentData)
-	fmt.Printf("DEBUG: Adding student - ID: %s, Name: %s\n", data.StudentID, data.StudentName)
-	fmt.Printf("DEBUG: Request body: %s\n", string(jsonData))
-	url := fmt.Sprintf("%s/rest/v1/students", config.SupabaseURL)
-	fmt.Printf("DEBUG: POST URL: %s\n", url)
-	req, _ := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	req.Header.Set("apikey", config.SupabaseAnonKey)
-	req.Header.Set("Authorization", "Bearer "+config.SupabaseAnonKey)
-	req.Header.Set("Content-Type", "application/json")
+	url := from("students").URL()
+	req, _ := newSupabaseRequest(r.Context(), "POST", url, bytes.NewBuffer(jsonData))
 	req.Header.Set("Prefer", "return=representation")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := supabaseClient.Do(req)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Database connection error",
-		})
+		writeError(w, r, http.StatusInternalServerError, "Database connection error")
 		return
 	}
 	defer resp.Body.Close()
 
 	bodyBytes, _ := io.ReadAll(resp.Body)
-	fmt.Printf("DEBUG: Response status: %d\n", resp.StatusCode)
-	fmt.Printf("DEBUG: Response body: %s\n", string(bodyBytes))
+	if resp.StatusCode == http.StatusConflict {
+		writeError(w, r, http.StatusConflict, "A student with this ID already exists")
+		return
+	}
 	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
-		w.WriteHeader(resp.StatusCode)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": fmt.Sprintf("Failed to create student: %s", string(bodyBytes)),
-		})
+		respondError(w, r, apierror.Persistence("Failed to create student", fmt.Errorf("supabase status %d: %s", resp.StatusCode, bodyBytes)))
 		return
 	}
 
@@ -124,10 +100,7 @@ func addStudentHandler(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Failed to parse response",
-		})
+		writeError(w, r, http.StatusInternalServerError, "Failed to parse response")
 		return
 	}
 
@@ -143,10 +116,7 @@ func addStudentHandler(w http.ResponseWriter, r *http.Request) {
 			},
 		})
 	} else {
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]string{
-			"error": "Student created but no data returned",
-		})
+		writeError(w, r, http.StatusInternalServerError, "Student created but no data returned")
 	}
 }
 
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// newSupabaseRequest builds a PostgREST request with the API key headers set.
+// The request ID carried by ctx is forwarded as X-Request-ID so a call can be
+// traced from our logs into Supabase's.
+func newSupabaseRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	key := config.SupabaseServiceKey
+	req.Header.Set("apikey", key)
+	req.Header.Set("Authorization", "Bearer "+key)
+	if id := requestIDFromContext(ctx); id != "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return req, nil
+}
+
+// supabaseGetJSON runs a GET against PostgREST and decodes the JSON response
+func supabaseGetJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := newSupabaseRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := supabaseClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// supabasePatch updates the rows matched by url with the given fields
+func supabasePatch(ctx context.Context, url string, fields map[string]interface{}) error {
+	jsonData, _ := json.Marshal(fields)
+	req, err := newSupabaseRequest(ctx, "PATCH", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	resp, err := supabaseClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+	return nil
+}
+
+// supabaseInsert inserts row into the query's table; prefer, if set, is sent
+// as the Prefer header (e.g. resolution=merge-duplicates for an upsert, with
+// the query's OnConflict naming the unique key to merge on)
+func supabaseInsert(ctx context.Context, query *Query, row map[string]interface{}, prefer string) error {
+	jsonData, _ := json.Marshal(row)
+	req, err := newSupabaseRequest(ctx, "POST", query.URL(), bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	if prefer != "" {
+		req.Header.Set("Prefer", prefer)
+	}
+	resp, err := supabaseClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+	return nil
+}
+
+// supabaseCount returns the number of rows matched by url without fetching them
+func supabaseCount(ctx context.Context, url string) (int, error) {
+	req, err := newSupabaseRequest(ctx, "HEAD", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Prefer", "count=exact")
+	resp, err := supabaseClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	// Content-Range: 0-24/123 (or */0 when nothing matches)
+	contentRange := resp.Header.Get("Content-Range")
+	slash := strings.LastIndex(contentRange, "/")
+	if slash < 0 {
+		return 0, fmt.Errorf("missing count in Content-Range %q", contentRange)
+	}
+	return strconv.Atoi(contentRange[slash+1:])
+}
+
+// supabasePageSize is how many rows supabaseGetPages asks for at a time. It
+// must not exceed the project's max-rows, or every page would look like the last.
+var supabasePageSize = 1000
+
+// supabaseGetPages reads everything query matches a page at a time, ordered by
+// id so the pages neither overlap nor skip rows. query builds a fresh query for
+// each page and page decodes one, returning how many rows it held; a short page
+// is the last.
+func supabaseGetPages(query func() *Query, page func(url string) (int, error)) error {
+	for offset := 0; ; offset += supabasePageSize {
+		n, err := page(query().Order("id.asc").Limit(supabasePageSize).Offset(offset).URL())
+		if err != nil {
+			return err
+		}
+		if n < supabasePageSize {
+			return nil
+		}
+	}
+}
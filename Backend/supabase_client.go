@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// supabaseClient sends every PostgREST call. Layered from the outside in:
+// the circuit breaker fails calls fast during an outage, reads are retried,
+// each attempt gets its own deadline and is recorded for /metrics. Requests
+// carry the caller's context, so a client that goes away cancels its calls.
+var supabaseClient = &http.Client{
+	Transport: breakerTransport{
+		breaker: supabaseBreaker,
+		base:    retryTransport{base: instrumentedTransport{base: http.DefaultTransport}},
+	},
+}
+
+var supabaseBreaker = &circuitBreaker{}
+
+// errSupabaseUnavailable is returned without calling Supabase while the
+// circuit breaker is open. respondError answers it with 503 unavailable.
+var errSupabaseUnavailable = errors.New("supabase unavailable: failing fast after repeated errors")
+
+// retryBackoff is the delay before the first retry; it doubles after that,
+// up to retryMaxBackoff
+const (
+	retryBackoff    = 100 * time.Millisecond
+	retryMaxBackoff = 2 * time.Second
+)
+
+// retryTransport gives every attempt a deadline of config.SupabaseTimeout
+// and retries idempotent reads (GET, HEAD) up to config.SupabaseRetries
+// times when Supabase can't be reached or answers 502, 503 or 504
+type retryTransport struct {
+	base http.RoundTripper
+}
+
+func (t retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attempts := 1
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		attempts += config.SupabaseRetries
+	}
+	for attempt := 1; ; attempt++ {
+		resp, err := t.attempt(req)
+		if attempt >= attempts || req.Context().Err() != nil || !retryable(resp, err) {
+			return resp, err
+		}
+		status := 0
+		if resp != nil {
+			status = resp.StatusCode
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		delay := retryDelay(attempt)
+		supabaseRetries.Inc(supabaseTable(req.URL.Path))
+		slog.DebugContext(req.Context(), "retrying supabase read", "table", supabaseTable(req.URL.Path), "attempt", attempt+1, "delay", delay, "status", status, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// retryDelay is the wait after failed attempt number attempt (from 1).
+// Equal jitter: half the backoff, plus up to the other half at random.
+func retryDelay(attempt int) time.Duration {
+	backoff := retryBackoff
+	for i := 1; i < attempt && backoff < retryMaxBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, retryMaxBackoff)
+	return backoff/2 + rand.N(backoff/2)
+}
+
+// attempt sends req once, cancelling it after config.SupabaseTimeout. The
+// deadline also covers reading the body, so it is released when the body
+// is closed.
+func (t retryTransport) attempt(req *http.Request) (*http.Response, error) {
+	if config.SupabaseTimeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), config.SupabaseTimeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// breakerTransport refuses calls while its breaker is open
+type breakerTransport struct {
+	breaker *circuitBreaker
+	base    http.RoundTripper
+}
+
+func (t breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.breaker.allow() {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, errSupabaseUnavailable
+	}
+	resp, err := t.base.RoundTrip(req)
+	switch {
+	case err != nil && req.Context().Err() != nil:
+		// The caller gave up; that says nothing about Supabase
+		t.breaker.release()
+	case err != nil || resp.StatusCode >= 500:
+		t.breaker.failure(req.Context())
+	default:
+		t.breaker.success(req.Context())
+	}
+	return resp, err
+}
+
+// circuitBreaker opens after config.SupabaseBreakerThreshold failed calls
+// in a row (0 disables it). While open, calls fail at once; after
+// config.SupabaseBreakerCooldown one call is let through, and its outcome
+// closes the breaker or starts another cooldown.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	failures int       // Consecutive failed calls
+	openedAt time.Time // When the breaker last opened or a trial call failed
+	trial    bool      // A trial call is in flight
+}
+
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.openLocked() {
+		return true
+	}
+	if b.trial || time.Since(b.openedAt) < config.SupabaseBreakerCooldown {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+func (b *circuitBreaker) success(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.openLocked() {
+		slog.InfoContext(ctx, "supabase circuit closed; calls resume")
+	}
+	b.failures = 0
+	b.trial = false
+}
+
+func (b *circuitBreaker) failure(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.trial = false
+	if b.openLocked() {
+		if b.failures == config.SupabaseBreakerThreshold {
+			slog.WarnContext(ctx, "supabase circuit open; failing calls fast", "failures", b.failures, "cooldown", config.SupabaseBreakerCooldown)
+		}
+		b.openedAt = time.Now()
+	}
+}
+
+// release ends a call without an outcome
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// open reports whether calls are being refused or only a trial is allowed
+func (b *circuitBreaker) open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.openLocked()
+}
+
+func (b *circuitBreaker) openLocked() bool {
+	return config.SupabaseBreakerThreshold > 0 && b.failures >= config.SupabaseBreakerThreshold
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryTransportRetriesReadsOnly(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+	saved := config
+	config.SupabaseRetries = 2
+	config.SupabaseTimeout = time.Second
+	t.Cleanup(func() { config = saved })
+
+	client := &http.Client{Transport: retryTransport{base: http.DefaultTransport}}
+	resp, err := client.Get(srv.URL + "/rest/v1/students")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Errorf("GET: status %d after %d calls, want 200 after 3", resp.StatusCode, calls.Load())
+	}
+
+	// Writes are never repeated
+	calls.Store(0)
+	resp, err = client.Post(srv.URL+"/rest/v1/students", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Errorf("POST: status %d after %d calls, want 503 after 1", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestRetryDelayIsCapped(t *testing.T) {
+	for _, attempt := range []int{1, 2, 5, 10, 63, 64, 65, 1000} {
+		backoff := min(retryBackoff<<min(attempt-1, 10), retryMaxBackoff)
+		if delay := retryDelay(attempt); delay < backoff/2 || delay >= backoff {
+			t.Errorf("retryDelay(%d) = %s, want between %s and %s", attempt, delay, backoff/2, backoff)
+		}
+	}
+}
+
+func TestBreakerTransport(t *testing.T) {
+	var calls atomic.Int32
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer srv.Close()
+	saved := config
+	config.SupabaseBreakerThreshold = 2
+	config.SupabaseBreakerCooldown = 50 * time.Millisecond
+	t.Cleanup(func() { config = saved })
+
+	breaker := &circuitBreaker{}
+	client := &http.Client{Transport: breakerTransport{breaker: breaker, base: http.DefaultTransport}}
+	get := func() error {
+		resp, err := client.Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	get()
+	get()
+	if !breaker.open() {
+		t.Fatal("breaker still closed after two failed calls")
+	}
+	if err := get(); !errors.Is(err, errSupabaseUnavailable) || calls.Load() != 2 {
+		t.Fatalf("open breaker: got %v after %d calls, want errSupabaseUnavailable without calling", err, calls.Load())
+	}
+
+	// After the cooldown one trial call goes through and closes the breaker
+	time.Sleep(config.SupabaseBreakerCooldown)
+	status.Store(http.StatusOK)
+	if err := get(); err != nil {
+		t.Fatal(err)
+	}
+	if breaker.open() || calls.Load() != 3 {
+		t.Errorf("after a good trial: open %v, %d calls", breaker.open(), calls.Load())
+	}
+}
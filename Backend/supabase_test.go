@@ -0,0 +1,19 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// useSupabase points the Supabase calls at handler for the rest of the test
+func useSupabase(t *testing.T, handler http.Handler) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	saved := config
+	config.SupabaseURL = srv.URL
+	t.Cleanup(func() {
+		srv.Close()
+		config = saved
+	})
+}
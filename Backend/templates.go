@@ -0,0 +1,563 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"attendance-system/apierror"
+)
+
+// Placeholders filled in per recipient when a broadcast is delivered
+const (
+	PlaceholderStudentName       = "{student_name}"
+	PlaceholderStudentID         = "{student_id}"
+	PlaceholderGroup             = "{group}"
+	PlaceholderEndTime           = "{end_time}"
+	PlaceholderAttendancePercent = "{attendance_percent}"
+)
+
+var knownPlaceholders = map[string]bool{
+	PlaceholderStudentName:       true,
+	PlaceholderStudentID:         true,
+	PlaceholderGroup:             true,
+	PlaceholderEndTime:           true,
+	PlaceholderAttendancePercent: true,
+}
+
+var placeholderPattern = regexp.MustCompile(`\{[a-z_]+\}`)
+
+// MessageTemplate is a reusable announcement; Shared templates are visible
+// to every admin
+type MessageTemplate struct {
+	ID        string `json:"id"`
+	AdminID   string `json:"admin_id"`
+	Name      string `json:"name"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	Shared    bool   `json:"shared"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// hasPlaceholders reports whether text needs per-recipient rendering
+func hasPlaceholders(text string) bool {
+	for _, p := range placeholderPattern.FindAllString(text, -1) {
+		if knownPlaceholders[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPlaceholders rejects placeholders we don't know how to fill
+func checkPlaceholders(texts ...string) error {
+	for _, text := range texts {
+		for _, p := range placeholderPattern.FindAllString(text, -1) {
+			if !knownPlaceholders[p] {
+				return fmt.Errorf("unknown placeholder %s", p)
+			}
+		}
+	}
+	return nil
+}
+
+// TemplateValues are the placeholder values for one recipient
+type TemplateValues struct {
+	StudentName       string `json:"student_name"`
+	StudentID         string `json:"student_id"`
+	Group             string `json:"group"`
+	EndTime           string `json:"end_time"`
+	AttendancePercent string `json:"attendance_percent"`
+}
+
+func (v TemplateValues) render(text string) string {
+	return strings.NewReplacer(
+		PlaceholderStudentName, v.StudentName,
+		PlaceholderStudentID, v.StudentID,
+		PlaceholderGroup, v.Group,
+		PlaceholderEndTime, v.EndTime,
+		PlaceholderAttendancePercent, v.AttendancePercent,
+	).Replace(text)
+}
+
+// templateRenderer renders a broadcast for many recipients, loading the
+// group and attendance data once
+type templateRenderer struct {
+	title    string
+	message  string
+	group    string
+	endTime  string
+	rates    map[string]*AttendanceRate
+	students map[string]templateStudent // UUID -> student
+}
+
+type templateStudent struct {
+	ID          string `json:"id"`
+	StudentID   string `json:"student_id"`
+	StudentName string `json:"student_name"`
+}
+
+// groupTemplateContext returns a group's name and window end time. The live
+// window wins over the database, which only has the last one.
+func groupTemplateContext(ctx context.Context, groupID string) (string, string, error) {
+	if groupID == "" {
+		return "", "-", nil
+	}
+	if group, exists := groupManager.GetGroup(groupID); exists {
+		group.mu.RLock()
+		name, active, endTime := group.Name, group.WindowActive, group.WindowEndTime
+		group.mu.RUnlock()
+		if active && name != "" {
+			return name, endTime.Format("15:04"), nil
+		}
+	}
+
+	var groups []struct {
+		Name          string  `json:"name"`
+		WindowEndTime *string `json:"window_end_time"`
+	}
+	groupURL := from("groups").Eq("id", groupID).Select("name,window_end_time").URL()
+	if err := supabaseGetJSON(ctx, groupURL, &groups); err != nil {
+		return "", "", err
+	}
+	if len(groups) == 0 {
+		return "", "", errGroupNotFound
+	}
+
+	endTime := "-"
+	if groups[0].WindowEndTime != nil {
+		raw := *groups[0].WindowEndTime
+		if t, err := time.Parse(time.RFC3339, raw); err == nil {
+			endTime = t.Format("15:04")
+		} else if t, err := time.Parse("2006-01-02T15:04:05", strings.Split(raw, "+")[0]); err == nil {
+			endTime = t.Format("15:04")
+		}
+	}
+	return groups[0].Name, endTime, nil
+}
+
+func newTemplateRenderer(ctx context.Context, title, message, groupID string) (*templateRenderer, error) {
+	r := &templateRenderer{title: title, message: message, students: make(map[string]templateStudent)}
+
+	var err error
+	if r.group, r.endTime, err = groupTemplateContext(ctx, groupID); err != nil {
+		return nil, err
+	}
+	if strings.Contains(title+message, PlaceholderAttendancePercent) {
+		if r.rates, err = attendanceRates(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// loadStudents fetches names for a batch of recipients
+func (r *templateRenderer) loadStudents(ctx context.Context, studentUUIDs []string) error {
+	var students []templateStudent
+	studentsURL := from("students").In("id", studentUUIDs).Select("id,student_id,student_name").URL()
+	if err := supabaseGetJSON(ctx, studentsURL, &students); err != nil {
+		return err
+	}
+	for _, s := range students {
+		r.students[s.ID] = s
+	}
+	return nil
+}
+
+func (r *templateRenderer) values(studentUUID string) TemplateValues {
+	student := r.students[studentUUID]
+	percent := "N/A"
+	if rate, exists := r.rates[studentUUID]; exists {
+		percent = fmt.Sprintf("%.0f%%", rate.Percent)
+	}
+	return TemplateValues{
+		StudentName:       student.StudentName,
+		StudentID:         student.StudentID,
+		Group:             r.group,
+		EndTime:           r.endTime,
+		AttendancePercent: percent,
+	}
+}
+
+// Render returns the title and message as one student will see them
+func (r *templateRenderer) Render(studentUUID string) RenderedMessage {
+	values := r.values(studentUUID)
+	return RenderedMessage{Title: values.render(r.title), Message: values.render(r.message)}
+}
+
+// RenderedMessage is a broadcast personalised for one recipient
+type RenderedMessage struct {
+	Title   string `json:"title"`
+	Message string `json:"message"`
+}
+
+// visibleTemplates is the or= matching templates the admin owns or that are shared
+func visibleTemplates(adminID string) []Condition {
+	return []Condition{where("admin_id", OpEq, adminID), where("shared", OpEq, "true")}
+}
+
+// fetchTemplate loads a template the admin owns or that is shared
+func fetchTemplate(ctx context.Context, adminID, templateID string) (*MessageTemplate, error) {
+	var templates []MessageTemplate
+	templateURL := from("message_templates").Eq("id", templateID).Or(visibleTemplates(adminID)...).Select("*").URL()
+	if err := supabaseGetJSON(ctx, templateURL, &templates); err != nil {
+		return nil, err
+	}
+	if len(templates) == 0 {
+		return nil, nil
+	}
+	return &templates[0], nil
+}
+
+// Handler: POST /api/create-template
+func createTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		AdminID string `json:"admin_id"`
+		Name    string `json:"name"`
+		Title   string `json:"title"`
+		Body    string `json:"body"`
+		Shared  bool   `json:"shared"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if data.AdminID == "" || data.Name == "" || data.Title == "" || data.Body == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id, name, title and body are required")
+		return
+	}
+	if err := checkPlaceholders(data.Title, data.Body); err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	templateID, status, err := insertReturning(r.Context(), "message_templates", map[string]interface{}{
+		"admin_id": data.AdminID,
+		"name":     data.Name,
+		"title":    data.Title,
+		"body":     data.Body,
+		"shared":   data.Shared,
+	})
+	if status == http.StatusConflict {
+		writeError(w, r, http.StatusConflict, "A template with this name already exists")
+		return
+	}
+	if err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to create template", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"template_id": templateID,
+	})
+}
+
+// Handler: POST /api/update-template (owner only; omitted fields are kept)
+func updateTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		AdminID    string  `json:"admin_id"`
+		TemplateID string  `json:"template_id"`
+		Name       *string `json:"name"`
+		Title      *string `json:"title"`
+		Body       *string `json:"body"`
+		Shared     *bool   `json:"shared"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if data.AdminID == "" || data.TemplateID == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id and template_id are required")
+		return
+	}
+
+	fields := map[string]interface{}{"updated_at": time.Now().UTC().Format(time.RFC3339)}
+	for key, value := range map[string]*string{"name": data.Name, "title": data.Title, "body": data.Body} {
+		if value == nil {
+			continue
+		}
+		if strings.TrimSpace(*value) == "" {
+			writeError(w, r, http.StatusBadRequest, key+" cannot be empty")
+			return
+		}
+		if err := checkPlaceholders(*value); err != nil {
+			writeError(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		fields[key] = *value
+	}
+	if data.Shared != nil {
+		fields["shared"] = *data.Shared
+	}
+
+	var existing []MessageTemplate
+	existingURL := from("message_templates").Eq("id", data.TemplateID).Eq("admin_id", data.AdminID).Select("id").URL()
+	if err := supabaseGetJSON(r.Context(), existingURL, &existing); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if len(existing) == 0 {
+		writeError(w, r, http.StatusNotFound, "Template not found")
+		return
+	}
+
+	updateURL := from("message_templates").Eq("id", data.TemplateID).Eq("admin_id", data.AdminID).URL()
+	if err := supabasePatch(r.Context(), updateURL, fields); err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to update template", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
+// Handler: GET /api/get-templates?admin_id=xxx (own and shared templates)
+func getTemplatesHandler(w http.ResponseWriter, r *http.Request) {
+	adminID := r.URL.Query().Get("admin_id")
+	if adminID == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id is required")
+		return
+	}
+
+	var templates []MessageTemplate
+	templatesURL := from("message_templates").Or(visibleTemplates(adminID)...).Select("*").Order("name.asc").URL()
+	if err := supabaseGetJSON(r.Context(), templatesURL, &templates); err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to fetch templates", err))
+		return
+	}
+	if templates == nil {
+		templates = []MessageTemplate{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"templates": templates,
+		"count":     len(templates),
+		"placeholders": []string{
+			PlaceholderStudentName, PlaceholderStudentID, PlaceholderGroup,
+			PlaceholderEndTime, PlaceholderAttendancePercent,
+		},
+	})
+}
+
+// Handler: POST /api/delete-template (owner only)
+func deleteTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		AdminID    string `json:"admin_id"`
+		TemplateID string `json:"template_id"`
+	}
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid JSON")
+			return
+		}
+	} else {
+		data.AdminID = r.URL.Query().Get("admin_id")
+		data.TemplateID = r.URL.Query().Get("template_id")
+	}
+
+	if data.AdminID == "" || data.TemplateID == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id and template_id are required")
+		return
+	}
+
+	deleteURL := from("message_templates").Eq("id", data.TemplateID).Eq("admin_id", data.AdminID).URL()
+	req, _ := newSupabaseRequest(r.Context(), "DELETE", deleteURL, nil)
+	req.Header.Set("Prefer", "return=representation")
+	resp, err := supabaseClient.Do(req)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to delete template")
+		return
+	}
+	defer resp.Body.Close()
+
+	var deleted []MessageTemplate
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&deleted) != nil {
+		writeError(w, r, http.StatusInternalServerError, "Failed to delete template")
+		return
+	}
+	if len(deleted) == 0 {
+		writeError(w, r, http.StatusNotFound, "Template not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Template deleted successfully"})
+}
+
+// Handler: POST /api/preview-template
+// Renders a stored template (template_id) or ad-hoc title/body for one student
+func previewTemplateHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		AdminID    string `json:"admin_id"`
+		TemplateID string `json:"template_id"`
+		Title      string `json:"title"`
+		Body       string `json:"body"`
+		StudentID  string `json:"student_id"` // Roll number
+		GroupID    string `json:"group_id"`   // Fills {group} and {end_time}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if data.AdminID == "" || data.StudentID == "" || (data.TemplateID == "" && data.Body == "") {
+		writeError(w, r, http.StatusBadRequest, "admin_id, student_id and template_id or body are required")
+		return
+	}
+
+	if data.TemplateID != "" {
+		template, err := fetchTemplate(r.Context(), data.AdminID, data.TemplateID)
+		if err != nil {
+			respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Database error", err))
+			return
+		}
+		if template == nil {
+			writeError(w, r, http.StatusNotFound, "Template not found")
+			return
+		}
+		data.Title, data.Body = template.Title, template.Body
+	}
+	if err := checkPlaceholders(data.Title, data.Body); err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	studentUUID := getStudentUUIDByID(r.Context(), data.StudentID)
+	if studentUUID == "" {
+		writeError(w, r, http.StatusNotFound, "Student not found")
+		return
+	}
+
+	renderer, err := newTemplateRenderer(r.Context(), data.Title, data.Body, data.GroupID)
+	if err == nil {
+		err = renderer.loadStudents(r.Context(), []string{studentUUID})
+	}
+	if errors.Is(err, errGroupNotFound) {
+		writeError(w, r, http.StatusNotFound, "Group not found")
+		return
+	}
+	if err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to load template values", err))
+		return
+	}
+
+	rendered := renderer.Render(studentUUID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"title":   rendered.Title,
+		"message": rendered.Message,
+		"values":  renderer.values(studentUUID),
+	})
+}
+
+// rerenderRecipients refreshes each recipient's text after a message edit
+func rerenderRecipients(ctx context.Context, messageID, title, message string) error {
+	var messages []struct {
+		Personalized    bool    `json:"personalized"`
+		TemplateGroupID *string `json:"template_group_id"`
+	}
+	messageURL := from("broadcast_messages").Eq("id", messageID).Select("personalized,template_group_id").URL()
+	if err := supabaseGetJSON(ctx, messageURL, &messages); err != nil || len(messages) == 0 {
+		return err
+	}
+
+	personalized := hasPlaceholders(title + message)
+	if !personalized {
+		if !messages[0].Personalized {
+			return nil
+		}
+		recipientsURL := from("message_recipients").Eq("message_id", messageID).URL()
+		if err := supabasePatch(ctx, recipientsURL, map[string]interface{}{"rendered_title": nil, "rendered_message": nil}); err != nil {
+			return err
+		}
+		return supabasePatch(ctx, from("broadcast_messages").Eq("id", messageID).URL(),
+			map[string]interface{}{"personalized": false})
+	}
+
+	groupID := ""
+	if messages[0].TemplateGroupID != nil {
+		groupID = *messages[0].TemplateGroupID
+	}
+	renderer, err := newTemplateRenderer(ctx, title, message, groupID)
+	if err != nil {
+		return err
+	}
+	receipts, err := fetchMessageReceipts(ctx, messageID, false)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(receipts); start += recipientChunkSize {
+		end := start + recipientChunkSize
+		if end > len(receipts) {
+			end = len(receipts)
+		}
+		chunk := make([]string, 0, end-start)
+		for _, receipt := range receipts[start:end] {
+			chunk = append(chunk, receipt.StudentUUID)
+		}
+		if err := renderer.loadStudents(ctx, chunk); err != nil {
+			return err
+		}
+		rendered := make(map[string]RenderedMessage, len(chunk))
+		for _, studentUUID := range chunk {
+			rendered[studentUUID] = renderer.Render(studentUUID)
+		}
+		if err := upsertRenderedRecipients(ctx, messageID, rendered); err != nil {
+			return err
+		}
+	}
+
+	if !messages[0].Personalized {
+		return supabasePatch(ctx, from("broadcast_messages").Eq("id", messageID).URL(),
+			map[string]interface{}{"personalized": true})
+	}
+	return nil
+}
+
+// upsertRenderedRecipients overwrites the rendered text of existing recipient rows
+func upsertRenderedRecipients(ctx context.Context, messageID string, rendered map[string]RenderedMessage) error {
+	rows := make([]map[string]interface{}, 0, len(rendered))
+	for studentUUID, content := range rendered {
+		rows = append(rows, map[string]interface{}{
+			"message_id":       messageID,
+			"student_id":       studentUUID,
+			"rendered_title":   content.Title,
+			"rendered_message": content.Message,
+		})
+	}
+
+	jsonData, _ := json.Marshal(rows)
+	upsertURL := from("message_recipients").OnConflict("message_id", "student_id").URL()
+	req, err := newSupabaseRequest(ctx, "POST", upsertURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Prefer", "resolution=merge-duplicates")
+
+	resp, err := supabaseClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTemplateValuesRender(t *testing.T) {
+	values := TemplateValues{
+		StudentName:       "Ann",
+		StudentID:         "ST001",
+		Group:             "CS101",
+		EndTime:           "10:30",
+		AttendancePercent: "87",
+	}
+	got := values.render("Hi {student_name} ({student_id}), {group} closes at {end_time}. You're at {attendance_percent}%. {unknown}")
+	want := "Hi Ann (ST001), CS101 closes at 10:30. You're at 87%. {unknown}"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCheckPlaceholders(t *testing.T) {
+	if err := checkPlaceholders("Hi {student_name}", "Closes at {end_time}", "no placeholders"); err != nil {
+		t.Errorf("known placeholders rejected: %v", err)
+	}
+	if err := checkPlaceholders("fine", "Hi {first_name}"); err == nil {
+		t.Error("unknown placeholder accepted")
+	}
+}
+
+func TestHasPlaceholders(t *testing.T) {
+	tests := map[string]bool{
+		"Hello {student_name}": true,
+		"Hello {someone}":      false,
+		"Hello everyone":       false,
+	}
+	for text, want := range tests {
+		if got := hasPlaceholders(text); got != want {
+			t.Errorf("hasPlaceholders(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestPreviewTemplateGroupErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		groups http.HandlerFunc
+		want   int
+	}{
+		{"found", func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode([]map[string]string{{"name": "Workshop"}})
+		}, http.StatusOK},
+		{"missing group", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("[]"))
+		}, http.StatusNotFound},
+		{"database error", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSupabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/rest/v1/students":
+					json.NewEncoder(w).Encode([]map[string]string{{"id": "u1", "student_id": "ST001", "student_name": "Ann"}})
+				case "/rest/v1/groups":
+					tt.groups(w, r)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			config.SupabaseBreakerThreshold = 0
+
+			body := `{"admin_id": "admin-1", "student_id": "ST001", "group_id": "g1", "body": "Hi {student_name}, {group} is open"}`
+			rec := httptest.NewRecorder()
+			previewTemplateHandler(rec, httptest.NewRequest(http.MethodPost, "/api/preview-template", strings.NewReader(body)))
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
+
+func TestBroadcastTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates http.HandlerFunc
+		want      int
+	}{
+		{"missing template", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("[]"))
+		}, http.StatusBadRequest},
+		{"database error", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useSupabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/rest/v1/message_templates" {
+					t.Errorf("unexpected request to %s", r.URL.Path)
+				}
+				tt.templates(w, r)
+			}))
+			config.SupabaseBreakerThreshold = 0
+
+			body := `{"admin_id": "admin-1", "send_to_all": true, "template_id": "t1"}`
+			rec := httptest.NewRecorder()
+			sendBroadcastMessageHandler(rec, httptest.NewRequest(http.MethodPost, "/api/send-broadcast-message", strings.NewReader(body)))
+			if rec.Code != tt.want {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
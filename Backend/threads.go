@@ -0,0 +1,654 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"attendance-system/apierror"
+)
+
+// Thread participants
+const (
+	SenderStudent = "student"
+	SenderAdmin   = "admin"
+)
+
+const maxThreadMessageLength = 2000
+
+// MessageThread is a conversation between one student and one admin, either
+// a reply to a broadcast or opened by the student with a group's admin
+type MessageThread struct {
+	ID                 string  `json:"id"`
+	StudentID          string  `json:"student_id"` // students.id (UUID)
+	AdminID            string  `json:"admin_id"`
+	GroupID            *string `json:"group_id"`
+	BroadcastMessageID *string `json:"broadcast_message_id"`
+	Subject            string  `json:"subject"`
+	LastMessageAt      string  `json:"last_message_at"`
+	StudentDeleted     bool    `json:"student_deleted"`
+	AdminDeleted       bool    `json:"admin_deleted"`
+	CreatedAt          string  `json:"created_at"`
+	Students           struct {
+		StudentID   string `json:"student_id"`
+		StudentName string `json:"student_name"`
+	} `json:"students"`
+}
+
+// ThreadMessage is one message inside a thread
+type ThreadMessage struct {
+	ID         string  `json:"id"`
+	ThreadID   string  `json:"thread_id"`
+	SenderType string  `json:"sender_type"`
+	SenderID   string  `json:"sender_id"`
+	Body       string  `json:"body"`
+	IsRead     bool    `json:"is_read"`
+	ReadAt     *string `json:"read_at"`
+	CreatedAt  string  `json:"created_at"`
+}
+
+const threadSelect = "id,student_id,admin_id,group_id,broadcast_message_id,subject,last_message_at,student_deleted,admin_deleted,created_at,students(student_id,student_name)"
+
+var errThreadNotFound = errors.New("thread not found")
+
+func fetchThreads(ctx context.Context, threadsURL string) ([]MessageThread, error) {
+	var threads []MessageThread
+	if err := supabaseGetJSON(ctx, threadsURL, &threads); err != nil {
+		return nil, err
+	}
+	return threads, nil
+}
+
+// threadParticipant loads a thread and works out which side the caller is on.
+// Exactly one of adminID and studentID (roll number) is expected.
+func threadParticipant(ctx context.Context, threadID, adminID, studentID string) (*MessageThread, string, string, error) {
+	threads, err := fetchThreads(ctx, from("message_threads").Eq("id", threadID).Select(threadSelect).URL())
+	if err != nil {
+		return nil, "", "", err
+	}
+	if len(threads) == 0 {
+		return nil, "", "", errThreadNotFound
+	}
+	thread := &threads[0]
+
+	switch {
+	case adminID != "" && thread.AdminID == adminID && !thread.AdminDeleted:
+		return thread, SenderAdmin, adminID, nil
+	case studentID != "" && thread.Students.StudentID == studentID && !thread.StudentDeleted:
+		return thread, SenderStudent, thread.StudentID, nil
+	}
+	return nil, "", "", errThreadNotFound
+}
+
+// postThreadMessage stores a message, bumps the thread and notifies the other side
+func postThreadMessage(ctx context.Context, thread *MessageThread, senderType, senderID, body string) (string, error) {
+	messageID, _, err := insertReturning(ctx, "thread_messages", map[string]interface{}{
+		"thread_id":   thread.ID,
+		"sender_type": senderType,
+		"sender_id":   senderID,
+		"body":        body,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// A new message brings the thread back for a recipient who deleted it
+	fields := map[string]interface{}{"last_message_at": time.Now().UTC().Format(time.RFC3339)}
+	if senderType == SenderStudent {
+		fields["admin_deleted"] = false
+	} else {
+		fields["student_deleted"] = false
+	}
+	if err := supabasePatch(ctx, from("message_threads").Eq("id", thread.ID).URL(), fields); err != nil {
+		slog.ErrorContext(ctx, "failed to update thread", "thread_id", thread.ID, "error", err)
+	}
+
+	event := map[string]interface{}{
+		"thread_id":   thread.ID,
+		"message_id":  messageID,
+		"sender_type": senderType,
+		"subject":     thread.Subject,
+		"body":        body,
+	}
+	notification := Notification{
+		Title: thread.Subject,
+		Body:  body,
+		Data: map[string]string{
+			"type":      EventThreadMessage,
+			"thread_id": thread.ID,
+		},
+	}
+	if senderType == SenderStudent {
+		notification.Title = thread.Students.StudentName + ": " + thread.Subject
+		eventHub.Publish(EventThreadMessage, event, adminTopic(thread.AdminID))
+		go notifyUsers(context.WithoutCancel(ctx), "admin", []string{thread.AdminID}, notification)
+	} else {
+		eventHub.Publish(EventThreadMessage, event, studentTopic(thread.Students.StudentID))
+		go notifyStudents(context.WithoutCancel(ctx), []string{thread.StudentID}, notification)
+	}
+	return messageID, nil
+}
+
+// findOrCreateThread returns the student's thread for a broadcast, creating it
+// on the first reply
+func findOrCreateThread(ctx context.Context, studentUUID string, broadcast *SentMessage) (*MessageThread, error) {
+	lookupURL := from("message_threads").Eq("student_id", studentUUID).Eq("broadcast_message_id", broadcast.ID).
+		Select(threadSelect).URL()
+	threads, err := fetchThreads(ctx, lookupURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(threads) > 0 {
+		return &threads[0], nil
+	}
+
+	row := map[string]interface{}{
+		"student_id":           studentUUID,
+		"admin_id":             broadcast.AdminID,
+		"broadcast_message_id": broadcast.ID,
+		"subject":              "Re: " + broadcast.Title,
+	}
+	if broadcast.GroupID != nil {
+		row["group_id"] = *broadcast.GroupID
+	}
+	if _, status, err := insertReturning(ctx, "message_threads", row); err != nil && status != http.StatusConflict {
+		return nil, err
+	}
+
+	// Re-read so a concurrent first reply lands in the same thread
+	threads, err = fetchThreads(ctx, lookupURL)
+	if err != nil {
+		return nil, err
+	}
+	if len(threads) == 0 {
+		return nil, errThreadNotFound
+	}
+	return &threads[0], nil
+}
+
+func validThreadBody(body string) (string, string) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", "body is required"
+	}
+	if len(body) > maxThreadMessageLength {
+		return "", fmt.Sprintf("body must be at most %d characters", maxThreadMessageLength)
+	}
+	return body, ""
+}
+
+func writeThreadError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errThreadNotFound) {
+		writeError(w, r, http.StatusNotFound, "Thread not found")
+		return
+	}
+	respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Database error", err))
+}
+
+// unreadThreadCounts counts unread messages per thread sent by the other side
+func unreadThreadCounts(ctx context.Context, threadIDs []string, fromSender string) (map[string]int, error) {
+	counts := make(map[string]int)
+	if len(threadIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		ThreadID string `json:"thread_id"`
+	}
+	unreadURL := from("thread_messages").In("thread_id", threadIDs).Eq("sender_type", fromSender).Eq("is_read", "false").
+		Select("thread_id").URL()
+	if err := supabaseGetJSON(ctx, unreadURL, &rows); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.ThreadID]++
+	}
+	return counts, nil
+}
+
+// Handler: POST /api/reply-message
+// A student replies to a broadcast they received
+func replyMessageHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		StudentID string `json:"student_id"`
+		MessageID string `json:"message_id"`
+		Body      string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	body, problem := validThreadBody(data.Body)
+	if data.StudentID == "" || data.MessageID == "" {
+		problem = "student_id and message_id are required"
+	}
+	if problem != "" {
+		writeError(w, r, http.StatusBadRequest, problem)
+		return
+	}
+
+	studentUUID := getStudentUUIDByID(r.Context(), data.StudentID)
+	if studentUUID == "" {
+		writeError(w, r, http.StatusNotFound, "Student not found")
+		return
+	}
+
+	// Only recipients can reply
+	var recipients []struct {
+		MessageID         string      `json:"message_id"`
+		BroadcastMessages SentMessage `json:"broadcast_messages"`
+	}
+	recipientURL := from("message_recipients").Eq("message_id", data.MessageID).Eq("student_id", studentUUID).
+		Select("message_id,broadcast_messages!inner("+sentMessageSelect+")").Is("broadcast_messages.recalled_at", IsNull).URL()
+	if err := supabaseGetJSON(r.Context(), recipientURL, &recipients); err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+	if len(recipients) == 0 {
+		writeError(w, r, http.StatusNotFound, "Message not found")
+		return
+	}
+
+	thread, err := findOrCreateThread(r.Context(), studentUUID, &recipients[0].BroadcastMessages)
+	if err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+
+	messageID, err := postThreadMessage(r.Context(), thread, SenderStudent, studentUUID, body)
+	if err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "student replied to message", "student_id", data.StudentID, "message_id", data.MessageID, "thread_id", thread.ID)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"thread_id":  thread.ID,
+		"message_id": messageID,
+	})
+}
+
+// Handler: POST /api/open-thread
+// A student starts a conversation with the admin of a group they belong to
+func openThreadHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		StudentID string `json:"student_id"`
+		GroupID   string `json:"group_id"`
+		Subject   string `json:"subject"`
+		Body      string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	body, problem := validThreadBody(data.Body)
+	if data.StudentID == "" || data.GroupID == "" {
+		problem = "student_id and group_id are required"
+	}
+	if problem != "" {
+		writeError(w, r, http.StatusBadRequest, problem)
+		return
+	}
+
+	studentUUID := getStudentUUIDByID(r.Context(), data.StudentID)
+	if studentUUID == "" {
+		writeError(w, r, http.StatusNotFound, "Student not found")
+		return
+	}
+
+	var memberships []struct {
+		Groups struct {
+			ID      string `json:"id"`
+			Name    string `json:"name"`
+			AdminID string `json:"admin_id"`
+		} `json:"groups"`
+	}
+	membershipURL := from("group_students").Eq("group_id", data.GroupID).Eq("student_id", studentUUID).
+		Select("groups(id,name,admin_id)").URL()
+	if err := supabaseGetJSON(r.Context(), membershipURL, &memberships); err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+	if len(memberships) == 0 {
+		writeError(w, r, http.StatusForbidden, "Student is not a member of this group")
+		return
+	}
+	group := memberships[0].Groups
+
+	subject := strings.TrimSpace(data.Subject)
+	if subject == "" {
+		subject = group.Name
+	}
+
+	threadID, _, err := insertReturning(r.Context(), "message_threads", map[string]interface{}{
+		"student_id": studentUUID,
+		"admin_id":   group.AdminID,
+		"group_id":   group.ID,
+		"subject":    subject,
+	})
+	if err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+
+	thread, _, _, err := threadParticipant(r.Context(), threadID, "", data.StudentID)
+	if err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+	messageID, err := postThreadMessage(r.Context(), thread, SenderStudent, studentUUID, body)
+	if err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "student opened thread", "student_id", data.StudentID, "thread_id", threadID, "group", group.Name)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"thread_id":  threadID,
+		"message_id": messageID,
+	})
+}
+
+// Handler: GET /api/get-admin-inbox?admin_id=xxx&message_id=xxx
+// Lists an admin's threads with unread counts, grouped by the broadcast they reply to
+func getAdminInboxHandler(w http.ResponseWriter, r *http.Request) {
+	adminID := r.URL.Query().Get("admin_id")
+	w.Header().Set("Content-Type", "application/json")
+	if adminID == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id is required")
+		return
+	}
+
+	threadsQuery := from("message_threads").Eq("admin_id", adminID).Eq("admin_deleted", "false").
+		Select(threadSelect).Order("last_message_at.desc")
+	if messageID := r.URL.Query().Get("message_id"); messageID != "" {
+		threadsQuery.Eq("broadcast_message_id", messageID)
+	}
+	threads, err := fetchThreads(r.Context(), threadsQuery.URL())
+	if err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+
+	threadIDs := make([]string, 0, len(threads))
+	for _, thread := range threads {
+		threadIDs = append(threadIDs, thread.ID)
+	}
+	unread, err := unreadThreadCounts(r.Context(), threadIDs, SenderStudent)
+	if err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+
+	// Summaries per broadcast; direct threads are keyed by ""
+	type broadcastSummary struct {
+		BroadcastMessageID *string `json:"broadcast_message_id"`
+		Threads            int     `json:"threads"`
+		Unread             int     `json:"unread"`
+	}
+	summaries := make(map[string]*broadcastSummary)
+	var order []string
+
+	totalUnread := 0
+	items := make([]map[string]interface{}, 0, len(threads))
+	for _, thread := range threads {
+		totalUnread += unread[thread.ID]
+		items = append(items, map[string]interface{}{
+			"id":                   thread.ID,
+			"subject":              thread.Subject,
+			"student_id":           thread.Students.StudentID,
+			"student_name":         thread.Students.StudentName,
+			"group_id":             thread.GroupID,
+			"broadcast_message_id": thread.BroadcastMessageID,
+			"last_message_at":      thread.LastMessageAt,
+			"unread":               unread[thread.ID],
+		})
+
+		key := ""
+		if thread.BroadcastMessageID != nil {
+			key = *thread.BroadcastMessageID
+		}
+		summary, exists := summaries[key]
+		if !exists {
+			summary = &broadcastSummary{BroadcastMessageID: thread.BroadcastMessageID}
+			summaries[key] = summary
+			order = append(order, key)
+		}
+		summary.Threads++
+		summary.Unread += unread[thread.ID]
+	}
+
+	broadcasts := make([]*broadcastSummary, 0, len(order))
+	for _, key := range order {
+		broadcasts = append(broadcasts, summaries[key])
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"threads":      items,
+		"broadcasts":   broadcasts,
+		"count":        len(items),
+		"total_unread": totalUnread,
+	})
+}
+
+// Handler: GET /api/get-student-threads?student_id=xxx
+func getStudentThreadsHandler(w http.ResponseWriter, r *http.Request) {
+	studentID := r.URL.Query().Get("student_id")
+	w.Header().Set("Content-Type", "application/json")
+	if studentID == "" {
+		writeError(w, r, http.StatusBadRequest, "student_id is required")
+		return
+	}
+
+	studentUUID := getStudentUUIDByID(r.Context(), studentID)
+	if studentUUID == "" {
+		writeError(w, r, http.StatusNotFound, "Student not found")
+		return
+	}
+
+	threads, err := fetchThreads(r.Context(), from("message_threads").Eq("student_id", studentUUID).Eq("student_deleted", "false").
+		Select(threadSelect).Order("last_message_at.desc").URL())
+	if err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+
+	threadIDs := make([]string, 0, len(threads))
+	for _, thread := range threads {
+		threadIDs = append(threadIDs, thread.ID)
+	}
+	unread, err := unreadThreadCounts(r.Context(), threadIDs, SenderAdmin)
+	if err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+
+	totalUnread := 0
+	items := make([]map[string]interface{}, 0, len(threads))
+	for _, thread := range threads {
+		totalUnread += unread[thread.ID]
+		items = append(items, map[string]interface{}{
+			"id":                   thread.ID,
+			"subject":              thread.Subject,
+			"group_id":             thread.GroupID,
+			"broadcast_message_id": thread.BroadcastMessageID,
+			"last_message_at":      thread.LastMessageAt,
+			"unread":               unread[thread.ID],
+		})
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"threads":      items,
+		"count":        len(items),
+		"total_unread": totalUnread,
+	})
+}
+
+// Handler: GET /api/get-thread?thread_id=xxx&admin_id=xxx (or &student_id=xxx)
+func getThreadHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	threadID := query.Get("thread_id")
+	if threadID == "" || (query.Get("admin_id") == "") == (query.Get("student_id") == "") {
+		writeError(w, r, http.StatusBadRequest, "thread_id and one of admin_id or student_id are required")
+		return
+	}
+
+	thread, _, _, err := threadParticipant(r.Context(), threadID, query.Get("admin_id"), query.Get("student_id"))
+	if err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+
+	var messages []ThreadMessage
+	messagesURL := from("thread_messages").Eq("thread_id", threadID).Select("*").Order("created_at.asc").URL()
+	if err := supabaseGetJSON(r.Context(), messagesURL, &messages); err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+	if messages == nil {
+		messages = []ThreadMessage{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"thread":   thread,
+		"messages": messages,
+		"count":    len(messages),
+	})
+}
+
+// Handler: POST /api/reply-thread
+// Either participant adds a message to an existing thread
+func replyThreadHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		ThreadID  string `json:"thread_id"`
+		AdminID   string `json:"admin_id"`
+		StudentID string `json:"student_id"`
+		Body      string `json:"body"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	body, problem := validThreadBody(data.Body)
+	if data.ThreadID == "" || (data.AdminID == "") == (data.StudentID == "") {
+		problem = "thread_id and one of admin_id or student_id are required"
+	}
+	if problem != "" {
+		writeError(w, r, http.StatusBadRequest, problem)
+		return
+	}
+
+	thread, senderType, senderID, err := threadParticipant(r.Context(), data.ThreadID, data.AdminID, data.StudentID)
+	if err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+
+	messageID, err := postThreadMessage(r.Context(), thread, senderType, senderID, body)
+	if err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"thread_id":  thread.ID,
+		"message_id": messageID,
+	})
+}
+
+// Handler: POST /api/mark-thread-read
+// Marks everything the other participant sent in a thread as read
+func markThreadReadHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		ThreadID  string `json:"thread_id"`
+		AdminID   string `json:"admin_id"`
+		StudentID string `json:"student_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	if data.ThreadID == "" || (data.AdminID == "") == (data.StudentID == "") {
+		writeError(w, r, http.StatusBadRequest, "thread_id and one of admin_id or student_id are required")
+		return
+	}
+
+	_, readerType, _, err := threadParticipant(r.Context(), data.ThreadID, data.AdminID, data.StudentID)
+	if err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+
+	senderType := SenderStudent
+	if readerType == SenderStudent {
+		senderType = SenderAdmin
+	}
+	updateURL := from("thread_messages").Eq("thread_id", data.ThreadID).Eq("sender_type", senderType).
+		Eq("is_read", "false").URL()
+	if err := supabasePatch(r.Context(), updateURL, map[string]interface{}{
+		"is_read": true,
+		"read_at": time.Now().UTC().Format(time.RFC3339),
+	}); err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// Handler: POST /api/delete-thread (removes the thread for the caller only)
+func deleteThreadHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		ThreadID  string `json:"thread_id"`
+		AdminID   string `json:"admin_id"`
+		StudentID string `json:"student_id"`
+	}
+
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid JSON")
+			return
+		}
+	} else {
+		data.ThreadID = r.URL.Query().Get("thread_id")
+		data.AdminID = r.URL.Query().Get("admin_id")
+		data.StudentID = r.URL.Query().Get("student_id")
+	}
+
+	if data.ThreadID == "" || (data.AdminID == "") == (data.StudentID == "") {
+		writeError(w, r, http.StatusBadRequest, "thread_id and one of admin_id or student_id are required")
+		return
+	}
+
+	_, participant, _, err := threadParticipant(r.Context(), data.ThreadID, data.AdminID, data.StudentID)
+	if err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+
+	// The other participant keeps their copy
+	updateURL := from("message_threads").Eq("id", data.ThreadID).URL()
+	if err := supabasePatch(r.Context(), updateURL, map[string]interface{}{participant + "_deleted": true}); err != nil {
+		writeThreadError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Thread deleted successfully"})
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestThreadParticipant(t *testing.T) {
+	useSupabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		threads := []map[string]interface{}{}
+		if r.URL.Query().Get("id") == "eq.t1" {
+			threads = append(threads, map[string]interface{}{
+				"id": "t1", "student_id": "uuid-1", "admin_id": "admin-1", "subject": "Re: Hi",
+				"admin_deleted": true,
+				"students":      map[string]string{"student_id": "ST001", "student_name": "Ann"},
+			})
+		}
+		json.NewEncoder(w).Encode(threads)
+	}))
+
+	thread, side, senderID, err := threadParticipant(context.Background(), "t1", "", "ST001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thread.ID != "t1" || side != SenderStudent || senderID != "uuid-1" {
+		t.Errorf("student: got (%s, %s, %s), want (t1, student, uuid-1)", thread.ID, side, senderID)
+	}
+
+	tests := []struct {
+		name, threadID, adminID, studentID string
+	}{
+		{"admin who deleted the thread", "t1", "admin-1", ""},
+		{"another student", "t1", "", "ST002"},
+		{"another admin", "t1", "admin-2", ""},
+		{"unknown thread", "t2", "admin-1", ""},
+	}
+	for _, tt := range tests {
+		if _, _, _, err := threadParticipant(context.Background(), tt.threadID, tt.adminID, tt.studentID); !errors.Is(err, errThreadNotFound) {
+			t.Errorf("%s: got %v, want errThreadNotFound", tt.name, err)
+		}
+	}
+}
+
+func TestValidThreadBody(t *testing.T) {
+	if body, msg := validThreadBody("  hello \n"); body != "hello" || msg != "" {
+		t.Errorf("got (%q, %q), want (\"hello\", \"\")", body, msg)
+	}
+	if _, msg := validThreadBody("   "); msg == "" {
+		t.Error("blank body accepted")
+	}
+	if _, msg := validThreadBody(strings.Repeat("a", maxThreadMessageLength+1)); msg == "" {
+		t.Error("over-long body accepted")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Window lifecycle messages are ordinary broadcasts from the group's admin,
+// so they show up in the inbox and go out as push notifications through the
+// delivery queue. Idempotency keys tie each one to a single window.
+
+// announceWindowOpened messages every student eligible for a newly opened window
+func announceWindowOpened(ctx context.Context, groupID, groupName, adminID string, groupOnly bool, start, end time.Time) {
+	if groupID == "default" {
+		return
+	}
+	if adminID == "" {
+		// No admin to send as; fall back to a plain push
+		notifyWindowOpened(ctx, groupID, groupName, groupOnly, end)
+		return
+	}
+
+	audience := BroadcastAudience{SendToAll: true}
+	if groupOnly {
+		audience = BroadcastAudience{GroupIDs: []string{groupID}}
+	}
+	queueWindowMessage(ctx, BroadcastRequest{
+		AdminID:         adminID,
+		Title:           "Attendance window open",
+		Message:         fmt.Sprintf("Attendance for %s is open until %s.", PlaceholderGroup, PlaceholderEndTime),
+		Audience:        audience,
+		IdempotencyKey:  fmt.Sprintf("window-open:%s:%d", groupID, start.Unix()),
+		Priority:        PriorityUrgent,
+		ExpiresAt:       &end,
+		TemplateGroupID: groupID,
+	})
+}
+
+// scheduleWindowReminder reminds students who haven't submitted shortly
+// before the window auto-closes. Restored windows schedule it again: one
+// already due goes out at once, and its idempotency key stops a reminder
+// sent before the restart from going out twice.
+func scheduleWindowReminder(groupID string, start, end time.Time) {
+	lead := time.Duration(config.WindowReminderMinutes) * time.Minute
+	if groupID == "default" || lead <= 0 || lead >= end.Sub(start) {
+		return
+	}
+	time.AfterFunc(time.Until(end.Add(-lead)), func() {
+		sendWindowReminder(groupID, start, end)
+	})
+}
+
+func sendWindowReminder(groupID string, start, end time.Time) {
+	group, exists := groupManager.GetGroup(groupID)
+	if !exists {
+		return
+	}
+
+	group.mu.RLock()
+	sameWindow := group.WindowActive && group.WindowStartTime.Equal(start)
+	adminID, groupOnly := group.AdminID, group.GroupOnly
+	submitted := make(map[string]bool, len(group.SubmittedStudents))
+	for studentID := range group.SubmittedStudents {
+		submitted[studentID] = true
+	}
+	group.mu.RUnlock()
+
+	// Closed early or restarted since this reminder was scheduled
+	if !sameWindow || adminID == "" {
+		return
+	}
+
+	students, err := eligibleStudents(context.Background(), groupID, groupOnly)
+	if err != nil {
+		slog.Error("failed to load students for window reminder", "group_id", groupID, "error", err)
+		return
+	}
+	pending := make([]string, 0, len(students))
+	for _, student := range students {
+		if student.StudentID != "" && !submitted[student.StudentID] {
+			pending = append(pending, student.StudentID)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	slog.Info("sending window reminder", "group_id", groupID, "students", len(pending), "closes_at", end.Format("15:04"))
+	queueWindowMessage(context.Background(), BroadcastRequest{
+		AdminID:         adminID,
+		Title:           "Attendance closing soon",
+		Message:         fmt.Sprintf("Attendance for %s closes at %s and you haven't submitted yet.", PlaceholderGroup, PlaceholderEndTime),
+		Audience:        BroadcastAudience{StudentIDs: pending},
+		IdempotencyKey:  fmt.Sprintf("window-reminder:%s:%d", groupID, start.Unix()),
+		Priority:        PriorityUrgent,
+		ExpiresAt:       &end,
+		TemplateGroupID: groupID,
+	})
+}
+
+// confirmAttendance tells a student how their submission was recorded
+func confirmAttendance(ctx context.Context, groupID, adminID, studentID, status string, distance float64, start time.Time) {
+	if groupID == "default" || adminID == "" {
+		return
+	}
+	queueWindowMessage(ctx, BroadcastRequest{
+		AdminID:         adminID,
+		Title:           "Attendance recorded",
+		Message:         fmt.Sprintf("You were marked %s for %s (%.0fm from the venue).", status, PlaceholderGroup, distance),
+		Audience:        BroadcastAudience{StudentIDs: []string{studentID}},
+		IdempotencyKey:  fmt.Sprintf("attendance:%s:%s:%d", groupID, studentID, start.Unix()),
+		TemplateGroupID: groupID,
+	})
+}
+
+func queueWindowMessage(ctx context.Context, b BroadcastRequest) {
+	job, duplicate, err := enqueueBroadcast(ctx, b)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to queue window message", "title", b.Title, "error", err)
+		return
+	}
+	if !duplicate {
+		slog.DebugContext(ctx, "queued window message", "title", b.Title, "message_id", job.MessageID)
+	}
+}
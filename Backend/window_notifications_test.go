@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeWindowMessages serves a roster of four students, three of them in
+// group g1, and records the broadcasts stored for window messages
+type fakeWindowMessages struct {
+	mu       sync.Mutex
+	messages []map[string]interface{}
+}
+
+func (f *fakeWindowMessages) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	student := func(n int) map[string]string {
+		return map[string]string{"id": fmt.Sprintf("u%d", n), "student_id": fmt.Sprintf("ST00%d", n), "student_name": "Student"}
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/rest/v1/group_students":
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"students": student(1)}, {"students": student(2)}, {"students": student(3)},
+		})
+	case r.Method == http.MethodGet && r.URL.Path == "/rest/v1/students":
+		json.NewEncoder(w).Encode([]map[string]string{student(1), student(2), student(3), student(4)})
+	case r.Method == http.MethodPost && r.URL.Path == "/rest/v1/broadcast_messages":
+		var message map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&message)
+		f.messages = append(f.messages, message)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode([]map[string]string{{"id": fmt.Sprintf("wm%d", len(f.messages))}})
+	case r.Method == http.MethodPost && r.URL.Path == "/rest/v1/broadcast_jobs":
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode([]map[string]string{{"id": fmt.Sprintf("wj%d-%d", len(f.messages), time.Now().UnixNano())}})
+	case r.Method == http.MethodGet:
+		w.Write([]byte("[]"))
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// sent returns the stored broadcasts' audiences and idempotency keys
+func (f *fakeWindowMessages) sent() (audiences []BroadcastAudience, keys []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, message := range f.messages {
+		raw, _ := json.Marshal(message["audience"])
+		var audience BroadcastAudience
+		json.Unmarshal(raw, &audience)
+		audiences = append(audiences, audience)
+		key, _ := message["idempotency_key"].(string)
+		keys = append(keys, key)
+	}
+	return audiences, keys
+}
+
+func TestAnnounceWindowOpenedAudience(t *testing.T) {
+	start := time.Date(2026, time.January, 5, 9, 0, 0, 0, time.UTC)
+	for _, groupOnly := range []bool{true, false} {
+		t.Run(fmt.Sprintf("group_only=%v", groupOnly), func(t *testing.T) {
+			fake := &fakeWindowMessages{}
+			useSupabase(t, fake)
+
+			announceWindowOpened(context.Background(), "g1", "Workshop", "admin-1", groupOnly, start, start.Add(10*time.Minute))
+
+			want := BroadcastAudience{SendToAll: true}
+			if groupOnly {
+				want = BroadcastAudience{GroupIDs: []string{"g1"}}
+			}
+			audiences, keys := fake.sent()
+			if len(audiences) != 1 || !reflect.DeepEqual(audiences[0], want) {
+				t.Fatalf("announced to %+v, want %+v", audiences, want)
+			}
+			if keys[0] != fmt.Sprintf("window-open:g1:%d", start.Unix()) {
+				t.Errorf("idempotency key %q isn't tied to the window", keys[0])
+			}
+		})
+	}
+}
+
+func TestWindowReminderSkipsSubmitted(t *testing.T) {
+	tests := []struct {
+		groupOnly bool
+		want      []string
+	}{
+		{true, []string{"ST001", "ST003"}},
+		{false, []string{"ST001", "ST003", "ST004"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("group_only=%v", tt.groupOnly), func(t *testing.T) {
+			fake := &fakeWindowMessages{}
+			useSupabase(t, fake)
+			start := time.Now().Add(-7 * time.Minute).Truncate(time.Second)
+			group := useGroup(t, "g1")
+			group.AdminID, group.GroupOnly = "admin-1", tt.groupOnly
+			group.WindowStartTime, group.WindowEndTime = start, start.Add(10*time.Minute)
+			group.SubmittedStudents["ST002"] = true
+
+			sendWindowReminder("g1", start, start.Add(10*time.Minute))
+
+			audiences, keys := fake.sent()
+			if len(audiences) != 1 || !reflect.DeepEqual(audiences[0].StudentIDs, tt.want) {
+				t.Fatalf("reminded %+v, want students %v", audiences, tt.want)
+			}
+			if keys[0] != fmt.Sprintf("window-reminder:g1:%d", start.Unix()) {
+				t.Errorf("idempotency key %q isn't tied to the window", keys[0])
+			}
+
+			// A reminder for an earlier window of the group is dropped
+			sendWindowReminder("g1", start.Add(-time.Hour), start.Add(-50*time.Minute))
+			if audiences, _ := fake.sent(); len(audiences) != 1 {
+				t.Errorf("reminder for a closed window sent: %+v", audiences[1:])
+			}
+		})
+	}
+}
+
+func TestConfirmAttendance(t *testing.T) {
+	fake := &fakeWindowMessages{}
+	useSupabase(t, fake)
+	start := time.Date(2026, time.January, 5, 9, 0, 0, 0, time.UTC)
+
+	confirmAttendance(context.Background(), "g1", "admin-1", "ST002", "Present", 12, start)
+
+	audiences, keys := fake.sent()
+	if len(audiences) != 1 || !reflect.DeepEqual(audiences[0], BroadcastAudience{StudentIDs: []string{"ST002"}}) {
+		t.Fatalf("confirmed to %+v, want ST002 alone", audiences)
+	}
+	if keys[0] != fmt.Sprintf("attendance:g1:ST002:%d", start.Unix()) {
+		t.Errorf("idempotency key %q isn't tied to the submission", keys[0])
+	}
+}
+
+func TestRestoredWindowReminds(t *testing.T) {
+	fake := &fakeWindowMessages{}
+	useSupabase(t, fake)
+	config.CSVDir = t.TempDir()
+	config.WindowDuration = 10 * time.Minute
+	config.WindowReminderMinutes = 3
+	group := useGroup(t, "g1")
+	group.Name, group.AdminID = "Workshop", "admin-1"
+	group.AdminLat, group.AdminLon = 12.9716, 77.5946
+	group.WindowActive = false
+
+	// The server went down before the reminder was due and came back after;
+	// PostgREST returns the saved times with a T
+	start := time.Now().Add(-8 * time.Minute)
+	startText := start.Format("2006-01-02T15:04:05")
+	endText := start.Add(10 * time.Minute).Format("2006-01-02T15:04:05")
+	if err := restoreWindow(context.Background(), savedWindow{ID: "g1", Status: "active", GroupOnly: true,
+		WindowStartTime: &startText, WindowEndTime: &endText}); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		audiences, _ := fake.sent()
+		if len(audiences) > 0 {
+			if !reflect.DeepEqual(audiences[0].StudentIDs, []string{"ST001", "ST002", "ST003"}) {
+				t.Errorf("reminded %+v, want the group's students", audiences[0])
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("restored window never sent its reminder")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"attendance-system/apierror"
+)
+
+// ScheduledOpened marks a one-off scheduled window that has been opened;
+// pending and cancelled share the scheduled message states
+const ScheduledOpened = "opened"
+
+// ScheduledWindow opens a group's attendance window at a set time,
+// optionally repeating
+type ScheduledWindow struct {
+	ID           string  `json:"id"`
+	AdminID      string  `json:"admin_id"`
+	GroupID      string  `json:"group_id"`
+	GroupOnly    bool    `json:"group_only"`
+	OpensAt      string  `json:"opens_at"`
+	Recurrence   *string `json:"recurrence"`
+	Status       string  `json:"status"`
+	OpenCount    int     `json:"open_count"`
+	LastOpenedAt *string `json:"last_opened_at"`
+	CreatedAt    string  `json:"created_at,omitempty"`
+}
+
+const scheduledWindowSelect = "id,admin_id,group_id,group_only,opens_at,recurrence,status,open_count,last_opened_at,created_at"
+
+var (
+	errGroupNotFound   = errors.New("group not found")
+	errNoGroupLocation = errors.New("group has no saved location")
+)
+
+// loadGroupLocation fills in the group's name, admin and location from the
+// database when the in-memory group doesn't have them yet
+func loadGroupLocation(ctx context.Context, groupID string) error {
+	group := groupManager.GetOrCreateGroup(groupID)
+	group.mu.RLock()
+	loaded := group.AdminLat != 0 && group.AdminLon != 0 && group.Name != ""
+	group.mu.RUnlock()
+	if loaded {
+		return nil
+	}
+
+	var groups []struct {
+		Name            string   `json:"name"`
+		AdminID         string   `json:"admin_id"`
+		LocationLat     *float64 `json:"location_lat"`
+		LocationLon     *float64 `json:"location_lon"`
+		ThresholdMeters *float64 `json:"threshold_meters"`
+	}
+	groupURL := from("groups").Eq("id", groupID).Select("name,admin_id,location_lat,location_lon,threshold_meters").URL()
+	if err := supabaseGetJSON(ctx, groupURL, &groups); err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		return errGroupNotFound
+	}
+	g := groups[0]
+
+	group.mu.Lock()
+	defer group.mu.Unlock()
+	group.Name = g.Name
+	group.AdminID = g.AdminID
+	if group.AdminLat == 0 || group.AdminLon == 0 {
+		if g.LocationLat == nil || g.LocationLon == nil || *g.LocationLat == 0 || *g.LocationLon == 0 {
+			return errNoGroupLocation
+		}
+		group.AdminLat = *g.LocationLat
+		group.AdminLon = *g.LocationLon
+		if g.ThresholdMeters != nil {
+			group.ThresholdMeters = *g.ThresholdMeters
+		}
+	}
+	return nil
+}
+
+// openDueWindows opens scheduled windows whose time has come; called from
+// the scheduler loop
+func openDueWindows(ctx context.Context) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	dueURL := from("scheduled_windows").Eq("status", ScheduledPending).Filter("opens_at", OpLte, now).
+		Select(scheduledWindowSelect).Order("opens_at.asc").URL()
+	var due []ScheduledWindow
+	if err := supabaseGetJSON(ctx, dueURL, &due); err != nil {
+		slog.ErrorContext(ctx, "scheduler: failed to load due windows", "error", err)
+		return
+	}
+
+	for _, sw := range due {
+		openScheduledWindow(ctx, sw)
+	}
+}
+
+// openScheduledWindow opens one occurrence and advances the schedule.
+// Occurrences that are more than a window long overdue (server was down) or
+// that find the window already open are skipped rather than opened late.
+func openScheduledWindow(ctx context.Context, sw ScheduledWindow) {
+	opensAt, err := time.Parse(time.RFC3339, sw.OpensAt)
+	if err != nil {
+		slog.ErrorContext(ctx, "scheduler: invalid opens_at", "window_id", sw.ID, "opens_at", sw.OpensAt, "error", err)
+		return
+	}
+
+	update := map[string]interface{}{}
+	if time.Since(opensAt) > config.WindowDuration {
+		slog.WarnContext(ctx, "scheduler: skipping missed window", "window_id", sw.ID, "group_id", sw.GroupID, "opens_at", sw.OpensAt)
+	} else if err := loadGroupLocation(ctx, sw.GroupID); err != nil {
+		slog.ErrorContext(ctx, "scheduler: can't open window", "window_id", sw.ID, "group_id", sw.GroupID, "error", err)
+	} else {
+		group, _ := groupManager.GetGroup(sw.GroupID)
+		group.mu.RLock()
+		alreadyOpen := group.WindowActive
+		group.mu.RUnlock()
+
+		if alreadyOpen {
+			slog.InfoContext(ctx, "scheduler: window already open, skipping", "window_id", sw.ID, "group_id", sw.GroupID)
+		} else if err := openWindow(ctx, sw.GroupID, sw.GroupOnly); err != nil {
+			slog.ErrorContext(ctx, "scheduler: can't open window", "window_id", sw.ID, "group_id", sw.GroupID, "error", err)
+		} else {
+			update["open_count"] = sw.OpenCount + 1
+			update["last_opened_at"] = time.Now().UTC().Format(time.RFC3339)
+			slog.InfoContext(ctx, "scheduler: opened scheduled window", "window_id", sw.ID, "group_id", sw.GroupID)
+		}
+	}
+
+	if sw.Recurrence != nil && *sw.Recurrence != "" {
+		rec, err := parseRecurrence(*sw.Recurrence)
+		if err != nil {
+			slog.ErrorContext(ctx, "scheduler: invalid recurrence, stopping it", "window_id", sw.ID, "error", err)
+			update["status"] = ScheduledOpened
+		} else {
+			next := rec.Next(opensAt)
+			for !next.After(time.Now()) {
+				next = rec.Next(next)
+			}
+			update["opens_at"] = next.UTC().Format(time.RFC3339)
+		}
+	} else {
+		update["status"] = ScheduledOpened
+	}
+
+	// Only advance a row that is still pending, so a window cancelled in the
+	// meantime stays cancelled
+	updateURL := from("scheduled_windows").Eq("id", sw.ID).Eq("status", ScheduledPending).URL()
+	if err := supabasePatch(ctx, updateURL, update); err != nil {
+		slog.ErrorContext(ctx, "scheduler: failed to update scheduled window", "window_id", sw.ID, "error", err)
+	}
+}
+
+// Handler: POST /api/schedule-window
+// Opens the group's attendance window at opens_at and/or on a recurrence
+func scheduleWindowHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		AdminID    string `json:"admin_id"`
+		GroupID    string `json:"group_id"`
+		GroupOnly  bool   `json:"group_only"`
+		OpensAt    string `json:"opens_at"`   // RFC3339; optional for recurring windows
+		Recurrence string `json:"recurrence"` // Optional, e.g. "every monday 09:00"
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if data.AdminID == "" || data.GroupID == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id and group_id are required")
+		return
+	}
+	if data.OpensAt == "" && data.Recurrence == "" {
+		writeError(w, r, http.StatusBadRequest, "opens_at or recurrence is required")
+		return
+	}
+
+	var groups []struct {
+		LocationLat *float64 `json:"location_lat"`
+		LocationLon *float64 `json:"location_lon"`
+	}
+	groupURL := from("groups").Eq("id", data.GroupID).Eq("admin_id", data.AdminID).Select("location_lat,location_lon").URL()
+	if err := supabaseGetJSON(r.Context(), groupURL, &groups); err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Database error", err))
+		return
+	}
+	if len(groups) == 0 {
+		writeError(w, r, http.StatusNotFound, "Group not found")
+		return
+	}
+	if groups[0].LocationLat == nil || groups[0].LocationLon == nil {
+		writeError(w, r, http.StatusBadRequest, "Set the group's location before scheduling a window")
+		return
+	}
+
+	var rec *Recurrence
+	if data.Recurrence != "" {
+		var err error
+		if rec, err = parseRecurrence(data.Recurrence); err != nil {
+			writeError(w, r, http.StatusBadRequest, "Invalid recurrence: "+err.Error())
+			return
+		}
+	}
+
+	var opensAt time.Time
+	if data.OpensAt != "" {
+		var err error
+		if opensAt, err = time.Parse(time.RFC3339, data.OpensAt); err != nil {
+			writeError(w, r, http.StatusBadRequest, "opens_at must be an RFC3339 timestamp")
+			return
+		}
+		if opensAt.Before(time.Now().Add(-time.Minute)) {
+			writeError(w, r, http.StatusBadRequest, "opens_at must be in the future")
+			return
+		}
+	} else {
+		opensAt = rec.Next(time.Now())
+	}
+
+	row := map[string]interface{}{
+		"admin_id":   data.AdminID,
+		"group_id":   data.GroupID,
+		"group_only": data.GroupOnly,
+		"opens_at":   opensAt.UTC().Format(time.RFC3339),
+		"status":     ScheduledPending,
+	}
+	if data.Recurrence != "" {
+		row["recurrence"] = data.Recurrence
+	}
+
+	scheduledID, _, err := insertReturning(r.Context(), "scheduled_windows", row)
+	if err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to schedule window", err))
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"message":      "Window scheduled",
+		"scheduled_id": scheduledID,
+		"opens_at":     opensAt.UTC().Format(time.RFC3339),
+		"closes_at":    opensAt.Add(config.WindowDuration).UTC().Format(time.RFC3339),
+		"recurrence":   data.Recurrence,
+	})
+}
+
+// Handler: POST /api/cancel-scheduled-window
+func cancelScheduledWindowHandler(w http.ResponseWriter, r *http.Request) {
+	var data struct {
+		AdminID     string `json:"admin_id"`
+		ScheduledID string `json:"scheduled_id"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		writeError(w, r, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if data.AdminID == "" || data.ScheduledID == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id and scheduled_id are required")
+		return
+	}
+
+	var pending []ScheduledWindow
+	pendingURL := from("scheduled_windows").Eq("id", data.ScheduledID).Eq("admin_id", data.AdminID).
+		Eq("status", ScheduledPending).Select("id").URL()
+	if err := supabaseGetJSON(r.Context(), pendingURL, &pending); err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Database error", err))
+		return
+	}
+	if len(pending) == 0 {
+		writeError(w, r, http.StatusNotFound, "No pending scheduled window found")
+		return
+	}
+
+	if err := supabasePatch(r.Context(), pendingURL, map[string]interface{}{"status": ScheduledCancelled}); err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to cancel scheduled window", err))
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"message": "Scheduled window cancelled",
+	})
+}
+
+// Handler: GET /api/get-scheduled-windows?admin_id=xxx[&group_id=xxx]
+func getScheduledWindowsHandler(w http.ResponseWriter, r *http.Request) {
+	adminID := r.URL.Query().Get("admin_id")
+	w.Header().Set("Content-Type", "application/json")
+	if adminID == "" {
+		writeError(w, r, http.StatusBadRequest, "admin_id is required")
+		return
+	}
+
+	listQuery := from("scheduled_windows").Eq("admin_id", adminID).Eq("status", ScheduledPending).
+		Select(scheduledWindowSelect).Order("opens_at.asc")
+	if groupID := r.URL.Query().Get("group_id"); groupID != "" {
+		listQuery.Eq("group_id", groupID)
+	}
+	windows := []ScheduledWindow{}
+	if err := supabaseGetJSON(r.Context(), listQuery.URL(), &windows); err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to fetch scheduled windows", err))
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"scheduled_windows": windows,
+		"count":             len(windows),
+	})
+}
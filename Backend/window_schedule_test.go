@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeScheduledWindows records the updates made to scheduled_windows and
+// the last scheduled_windows read; every other read finds nothing and
+// every other write succeeds
+type fakeScheduledWindows struct {
+	mu      sync.Mutex
+	rows    []map[string]interface{}
+	read    url.Values
+	updates []scheduledWindowUpdate
+}
+
+type scheduledWindowUpdate struct {
+	query  url.Values
+	fields map[string]interface{}
+}
+
+func (f *fakeScheduledWindows) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case r.Method == http.MethodPatch && r.URL.Path == "/rest/v1/scheduled_windows":
+		var fields map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&fields)
+		f.updates = append(f.updates, scheduledWindowUpdate{r.URL.Query(), fields})
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && r.URL.Path == "/rest/v1/scheduled_windows":
+		f.read = r.URL.Query()
+		json.NewEncoder(w).Encode(f.rows)
+	case r.Method == http.MethodGet:
+		w.Write([]byte("[]"))
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeScheduledWindows) lastUpdate(t *testing.T) scheduledWindowUpdate {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.updates) != 1 {
+		t.Fatalf("%d scheduled window updates, want 1", len(f.updates))
+	}
+	return f.updates[0]
+}
+
+func TestOpenScheduledWindow(t *testing.T) {
+	every := "every day 08:00"
+	tests := []struct {
+		name       string
+		opensAgo   time.Duration
+		recurrence *string
+		wantOpen   bool
+		wantStatus interface{}
+	}{
+		{"one-off due", 0, nil, true, ScheduledOpened},
+		{"recurring due", 0, &every, true, nil},
+		{"one-off missed", 30 * time.Minute, nil, false, ScheduledOpened},
+		{"recurring missed", 3 * 24 * time.Hour, &every, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeScheduledWindows{}
+			useSupabase(t, fake)
+			config.CSVDir = t.TempDir()
+			config.WindowDuration = 10 * time.Minute
+			group := useGroup(t, "g1")
+			group.Name, group.AdminID = "Workshop", "admin-1"
+			group.AdminLat, group.AdminLon = 12.9716, 77.5946
+			group.WindowActive = false
+
+			opensAt := time.Now().Add(-tt.opensAgo).UTC().Truncate(time.Second)
+			openScheduledWindow(context.Background(), ScheduledWindow{
+				ID: "sw1", GroupID: "g1", OpensAt: opensAt.Format(time.RFC3339),
+				Recurrence: tt.recurrence, Status: ScheduledPending, OpenCount: 2,
+			})
+
+			group.mu.RLock()
+			opened := group.WindowActive
+			group.mu.RUnlock()
+			if opened != tt.wantOpen {
+				t.Errorf("window open %v, want %v", opened, tt.wantOpen)
+			}
+
+			update := fake.lastUpdate(t)
+			if update.query.Get("id") != "eq.sw1" || update.query.Get("status") != "eq."+ScheduledPending {
+				t.Errorf("update filtered by %v, want id=eq.sw1 and status=eq.pending", update.query)
+			}
+			if update.fields["status"] != tt.wantStatus {
+				t.Errorf("status set to %v, want %v", update.fields["status"], tt.wantStatus)
+			}
+			if count, counted := update.fields["open_count"]; counted != tt.wantOpen || (counted && count != 3.0) {
+				t.Errorf("open_count set to %v, want 3 only when opened", count)
+			}
+			if tt.recurrence != nil {
+				next, err := time.Parse(time.RFC3339, update.fields["opens_at"].(string))
+				if err != nil || !next.After(time.Now()) || next.Sub(time.Now()) > 24*time.Hour {
+					t.Errorf("next opens_at %v, want the next occurrence after now", update.fields["opens_at"])
+				}
+			} else if _, advanced := update.fields["opens_at"]; advanced {
+				t.Error("one-off window was given another occurrence")
+			}
+		})
+	}
+}
+
+func TestOpenScheduledWindowSkipsOpenWindow(t *testing.T) {
+	fake := &fakeScheduledWindows{}
+	useSupabase(t, fake)
+	group := useGroup(t, "g1")
+	group.Name, group.AdminID = "Workshop", "admin-1"
+	group.AdminLat, group.AdminLon = 12.9716, 77.5946
+	started := time.Now().Add(-time.Minute)
+	group.WindowStartTime = started
+
+	openScheduledWindow(context.Background(), ScheduledWindow{
+		ID: "sw1", GroupID: "g1", OpensAt: time.Now().UTC().Format(time.RFC3339), Status: ScheduledPending,
+	})
+
+	if !group.WindowStartTime.Equal(started) {
+		t.Error("the open window was restarted")
+	}
+	if update := fake.lastUpdate(t); update.fields["status"] != ScheduledOpened || update.fields["open_count"] != nil {
+		t.Errorf("update %v, want the occurrence marked opened without counting it", update.fields)
+	}
+}
+
+func TestStudentUpcomingWindows(t *testing.T) {
+	fake := &fakeScheduledWindows{rows: []map[string]interface{}{{
+		"id": "sw1", "group_id": "g1", "group_only": true, "opens_at": "2026-01-05T09:00:00Z",
+		"recurrence": "every weekday 09:00", "status": ScheduledPending, "groups": map[string]string{"name": "Workshop"},
+	}}}
+	useSupabase(t, fake)
+	config.WindowDuration = 10 * time.Minute
+
+	upcoming, err := studentUpcomingWindows(context.Background(), []string{"g1", "g2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for param, want := range map[string]string{
+		"status": "eq." + ScheduledPending,
+		"or":     `(group_id.in.("g1","g2"),group_only.is.false)`,
+		"select": scheduledWindowSelect + ",groups(name)",
+		"order":  "opens_at.asc",
+	} {
+		if got := fake.read.Get(param); got != want {
+			t.Errorf("%s=%q, want %q", param, got, want)
+		}
+	}
+	if len(upcoming) != 1 {
+		t.Fatalf("%d upcoming windows, want 1", len(upcoming))
+	}
+	window := upcoming[0]
+	if window.ScheduledID != "sw1" || window.GroupName != "Workshop" || !window.GroupOnly || window.ClosesAt != "2026-01-05T09:10:00Z" {
+		t.Errorf("got %+v, want sw1 for Workshop closing at 09:10", window)
+	}
+
+	// A student in no group only sees windows open to everyone
+	if _, err := studentUpcomingWindows(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := fake.read.Get("or"); got != "(group_only.is.false)" {
+		t.Errorf("or=%q without groups, want (group_only.is.false)", got)
+	}
+}
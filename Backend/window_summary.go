@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"attendance-system/apierror"
+)
+
+const defaultHistogramBucket = 60 // seconds
+
+// Distance bands as multiples of the group's threshold
+var distanceBands = []float64{0.5, 1, 2, 5}
+
+// submissionLayouts are the timestamp formats found in StudentLocation:
+// server local time for live submissions, PostgREST output once restored
+var submissionLayouts = []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05.999999", "2006-01-02T15:04:05"}
+
+// PendingStudent is a roster student who hasn't submitted yet
+type PendingStudent struct {
+	StudentID   string `json:"student_id"`
+	StudentName string `json:"student_name"`
+}
+
+// HistogramBucket counts submissions in [start, start+bucket) seconds after
+// the window opened
+type HistogramBucket struct {
+	StartSeconds int `json:"start_seconds"`
+	EndSeconds   int `json:"end_seconds"`
+	Count        int `json:"count"`
+}
+
+// DistanceBucket counts submissions within [min, max) meters; Max is nil
+// for the open-ended last band
+type DistanceBucket struct {
+	MinMeters float64  `json:"min_meters"`
+	MaxMeters *float64 `json:"max_meters"`
+	Count     int      `json:"count"`
+}
+
+func parseSubmissionTime(ts string) (time.Time, bool) {
+	for _, layout := range submissionLayouts {
+		if t, err := time.ParseInLocation(layout, ts, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// submissionHistogram buckets submission times relative to the window
+// start; late restored records land in the last bucket
+func submissionHistogram(locations []StudentLocation, start, end time.Time, bucketSeconds int) []HistogramBucket {
+	times := make([]time.Time, 0, len(locations))
+	for _, loc := range locations {
+		if t, ok := parseSubmissionTime(loc.Timestamp); ok {
+			times = append(times, t)
+		}
+	}
+	if start.IsZero() {
+		// Restored groups may not know their start; use the first submission
+		for _, t := range times {
+			if start.IsZero() || t.Before(start) {
+				start = t
+			}
+		}
+	}
+
+	// The window's own length: it may have been opened under another
+	// window_duration, or closed early
+	total := int(end.Sub(start).Seconds())
+	if start.IsZero() || end.IsZero() || total <= 0 {
+		total = int(config.WindowDuration.Seconds())
+	}
+	buckets := make([]HistogramBucket, 0, (total+bucketSeconds-1)/bucketSeconds)
+	for offset := 0; offset < total; offset += bucketSeconds {
+		end := offset + bucketSeconds
+		if end > total {
+			end = total
+		}
+		buckets = append(buckets, HistogramBucket{StartSeconds: offset, EndSeconds: end})
+	}
+	for _, t := range times {
+		i := int(t.Sub(start).Seconds()) / bucketSeconds
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(buckets) {
+			i = len(buckets) - 1
+		}
+		buckets[i].Count++
+	}
+	return buckets
+}
+
+// distanceDistribution bands distances by multiples of the threshold and
+// summarises them
+func distanceDistribution(locations []StudentLocation, threshold float64) map[string]interface{} {
+	if threshold <= 0 {
+		threshold = 100
+	}
+
+	buckets := make([]DistanceBucket, 0, len(distanceBands)+1)
+	lower := 0.0
+	for _, band := range distanceBands {
+		upper := band * threshold
+		buckets = append(buckets, DistanceBucket{MinMeters: lower, MaxMeters: &upper})
+		lower = upper
+	}
+	buckets = append(buckets, DistanceBucket{MinMeters: lower})
+
+	distances := make([]float64, 0, len(locations))
+	for _, loc := range locations {
+		distances = append(distances, loc.Distance)
+		for i := range buckets {
+			if buckets[i].MaxMeters == nil || loc.Distance < *buckets[i].MaxMeters {
+				buckets[i].Count++
+				break
+			}
+		}
+	}
+
+	summary := map[string]interface{}{
+		"threshold_meters": threshold,
+		"buckets":          buckets,
+	}
+	if len(distances) > 0 {
+		sort.Float64s(distances)
+		sum := 0.0
+		for _, d := range distances {
+			sum += d
+		}
+		median := distances[len(distances)/2]
+		if len(distances)%2 == 0 {
+			median = (distances[len(distances)/2-1] + median) / 2
+		}
+		summary["min_meters"] = math.Round(distances[0])
+		summary["max_meters"] = math.Round(distances[len(distances)-1])
+		summary["mean_meters"] = math.Round(sum / float64(len(distances)))
+		summary["median_meters"] = math.Round(median)
+	}
+	return summary
+}
+
+// restoreLastWindow brings back a closed group's last window after a
+// restart: its saved start and end, and only the submissions made since it
+// opened. A group that has never run a window has nothing to restore.
+func restoreLastWindow(ctx context.Context, group *GroupData) error {
+	var saved []savedWindow
+	groupURL := from("groups").Eq("id", group.ID).Select(savedWindowSelect).URL()
+	if err := supabaseGetJSON(ctx, groupURL, &saved); err != nil {
+		return err
+	}
+	if len(saved) == 0 || saved[0].WindowStartTime == nil {
+		return nil
+	}
+	start, end, err := saved[0].times()
+	if err != nil {
+		return err
+	}
+	restoreStudentLocations(ctx, group, start)
+
+	group.mu.Lock()
+	defer group.mu.Unlock()
+	if group.WindowStartTime.IsZero() {
+		group.WindowStartTime, group.WindowEndTime = start, end
+	}
+	return nil
+}
+
+// Handler: GET /api/get-window-summary?group_id=xxx[&bucket_seconds=60]
+// One call for the admin's live view of a window: roster and submission
+// counts, who is still pending, when submissions came in and how far away
+func getWindowSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	groupID := getGroupID(r)
+	if groupID == "" || groupID == "default" {
+		writeError(w, r, http.StatusBadRequest, "group_id is required")
+		return
+	}
+
+	bucketSeconds := defaultHistogramBucket
+	if raw := r.URL.Query().Get("bucket_seconds"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 10 || n > int(config.WindowDuration.Seconds()) {
+			writeError(w, r, http.StatusBadRequest, fmt.Sprintf("bucket_seconds must be between 10 and %d", int(config.WindowDuration.Seconds())))
+			return
+		}
+		bucketSeconds = n
+	}
+
+	// Make sure name, admin and threshold are known; a missing location is
+	// fine here, the summary still reports the roster
+	switch err := loadGroupLocation(r.Context(), groupID); err {
+	case nil, errNoGroupLocation:
+	case errGroupNotFound:
+		writeError(w, r, http.StatusNotFound, "Group not found")
+		return
+	default:
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Database error", err))
+		return
+	}
+	group := groupManager.GetOrCreateGroup(groupID)
+
+	// After a restart the last window's submissions only exist in the
+	// database; an open window with no submissions yet has nothing to restore
+	group.mu.RLock()
+	restore := len(group.StudentLocations) == 0 && !group.WindowActive
+	group.mu.RUnlock()
+	if restore {
+		if err := restoreLastWindow(r.Context(), group); err != nil {
+			respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Database error", err))
+			return
+		}
+	}
+
+	// Snapshot the group so no lock is held during the roster query
+	group.mu.RLock()
+	name, groupOnly, active := group.Name, group.GroupOnly, group.WindowActive
+	start, end, threshold := group.WindowStartTime, group.WindowEndTime, group.ThresholdMeters
+	remaining := 0
+	if active {
+		remaining = remainingSeconds(group)
+	}
+	submitted := make(map[string]bool, len(group.SubmittedStudents))
+	for studentID := range group.SubmittedStudents {
+		submitted[studentID] = true
+	}
+	locations := make([]StudentLocation, 0, len(group.StudentLocations))
+	for _, loc := range group.StudentLocations {
+		locations = append(locations, loc)
+	}
+	group.mu.RUnlock()
+
+	roster, err := eligibleStudents(r.Context(), groupID, groupOnly)
+	if err != nil {
+		respondError(w, r, apierror.Wrap(http.StatusInternalServerError, "Failed to load roster", err))
+		return
+	}
+
+	pending := []PendingStudent{}
+	for _, student := range roster {
+		if student.StudentID != "" && !submitted[student.StudentID] {
+			pending = append(pending, PendingStudent{StudentID: student.StudentID, StudentName: student.StudentName})
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		if pending[i].StudentName != pending[j].StudentName {
+			return pending[i].StudentName < pending[j].StudentName
+		}
+		return pending[i].StudentID < pending[j].StudentID
+	})
+
+	present, absent := 0, 0
+	for _, loc := range locations {
+		if loc.Status == "Present" {
+			present++
+		} else {
+			absent++
+		}
+	}
+
+	response := map[string]interface{}{
+		"group_id":          groupID,
+		"group_name":        name,
+		"group_only":        groupOnly,
+		"window_active":     active,
+		"remaining_seconds": remaining,
+		"roster_size":       len(roster),
+		"submitted_count":   len(submitted),
+		"present_count":     present,
+		"absent_count":      absent,
+		"pending_count":     len(pending),
+		"pending_students":  pending,
+		"submission_histogram": map[string]interface{}{
+			"bucket_seconds": bucketSeconds,
+			"buckets":        submissionHistogram(locations, start, end, bucketSeconds),
+		},
+		"distance_distribution": distanceDistribution(locations, threshold),
+	}
+	if !start.IsZero() {
+		response["window_start"] = start.UTC().Format(time.RFC3339)
+		response["window_end"] = end.UTC().Format(time.RFC3339)
+	}
+	json.NewEncoder(w).Encode(response)
+}
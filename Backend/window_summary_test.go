@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDistanceDistribution(t *testing.T) {
+	locations := []StudentLocation{{Distance: 10}, {Distance: 60}, {Distance: 99}, {Distance: 100}, {Distance: 700}}
+	summary := distanceDistribution(locations, 100)
+
+	buckets := summary["buckets"].([]DistanceBucket)
+	want := []int{1, 2, 1, 0, 1} // <50, <100, <200, <500, 500+
+	if len(buckets) != len(want) {
+		t.Fatalf("got %d buckets, want %d", len(buckets), len(want))
+	}
+	for i, b := range buckets {
+		if b.Count != want[i] {
+			t.Errorf("bucket %d [%v, %v): count %d, want %d", i, b.MinMeters, b.MaxMeters, b.Count, want[i])
+		}
+	}
+	if buckets[len(buckets)-1].MaxMeters != nil {
+		t.Error("last band should be open-ended")
+	}
+	if summary["median_meters"] != 99.0 || summary["min_meters"] != 10.0 || summary["max_meters"] != 700.0 {
+		t.Errorf("got summary %v", summary)
+	}
+}
+
+func TestSubmissionHistogram(t *testing.T) {
+	saved := config
+	config.WindowDuration = 10 * time.Minute
+	t.Cleanup(func() { config = saved })
+
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.Local)
+	at := func(d time.Duration) StudentLocation {
+		return StudentLocation{Timestamp: start.Add(d).Format("2006-01-02 15:04:05")}
+	}
+	locations := []StudentLocation{at(5 * time.Second), at(59 * time.Second), at(61 * time.Second), at(time.Hour), {Timestamp: "garbage"}}
+
+	buckets := submissionHistogram(locations, start, start.Add(10*time.Minute), 60)
+	if len(buckets) != 10 {
+		t.Fatalf("got %d buckets, want one per minute of the window", len(buckets))
+	}
+	if buckets[0].Count != 2 || buckets[1].Count != 1 {
+		t.Errorf("first buckets %+v, %+v, want counts 2 and 1", buckets[0], buckets[1])
+	}
+	// Submissions after the window count in the last bucket
+	if last := buckets[len(buckets)-1]; last.Count != 1 || last.EndSeconds != 600 {
+		t.Errorf("last bucket %+v", last)
+	}
+
+	// A window shorter than window_duration is sized by its own times
+	if buckets := submissionHistogram(locations, start, start.Add(5*time.Minute), 60); len(buckets) != 5 {
+		t.Errorf("got %d buckets for a five-minute window, want 5", len(buckets))
+	}
+}
+
+func TestWindowSummaryRestoresLastWindow(t *testing.T) {
+	var mu sync.Mutex
+	var attendanceQuery url.Values
+	useSupabase(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rest/v1/groups":
+			json.NewEncoder(w).Encode([]map[string]interface{}{{
+				"id": "g1", "name": "Workshop", "admin_id": "admin-1", "status": "closed", "group_only": true,
+				"location_lat": 12.9716, "location_lon": 77.5946, "threshold_meters": 50,
+				"window_start_time": "2026-01-05T09:00:00", "window_end_time": "2026-01-05T09:05:00",
+			}})
+		case "/rest/v1/group_attendance":
+			mu.Lock()
+			attendanceQuery = r.URL.Query()
+			mu.Unlock()
+			json.NewEncoder(w).Encode([]map[string]interface{}{{
+				"status": "Present", "distance": 12, "submitted_at": "2026-01-05T09:01:30",
+				"students": map[string]string{"student_id": "ST001", "student_name": "Ann"},
+			}})
+		default:
+			w.Write([]byte("[]"))
+		}
+	}))
+	config.WindowDuration = 10 * time.Minute
+	// The server restarted since the window closed
+	t.Cleanup(func() { groupManager.DeleteGroup("g1") })
+
+	rec := httptest.NewRecorder()
+	getWindowSummaryHandler(rec, httptest.NewRequest(http.MethodGet, "/api/get-window-summary?group_id=g1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+
+	if got := attendanceQuery.Get("submitted_at"); got != "gte.2026-01-05 09:00:00" {
+		t.Errorf("submitted_at=%q, want only the last window's submissions", got)
+	}
+	var summary struct {
+		SubmittedCount int    `json:"submitted_count"`
+		WindowEnd      string `json:"window_end"`
+		Histogram      struct {
+			Buckets []HistogramBucket `json:"buckets"`
+		} `json:"submission_histogram"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.SubmittedCount != 1 || summary.WindowEnd == "" {
+		t.Errorf("got %+v, want the restored submission and window times", summary)
+	}
+	if buckets := summary.Histogram.Buckets; len(buckets) != 5 || buckets[1].Count != 1 {
+		t.Errorf("histogram %+v, want five minutes with the submission in the second", buckets)
+	}
+}
@@ -1,18 +1,18 @@
 -- Fix Add Student Feature
 -- Run this in Supabase SQL Editor
 
--- 1. Disable RLS (Row Level Security) for students table
--- This allows the backend to insert students
-ALTER TABLE students DISABLE ROW LEVEL SECURITY;
+-- 1. Don't disable RLS: the backend inserts with SUPABASE_SERVICE_KEY,
+-- which bypasses it. If inserts fail with "Forbidden" or "permission
+-- denied", set SUPABASE_SERVICE_KEY in Backend/.env and restart the server.
 
--- 2. Verify RLS is disabled
+-- 2. Check RLS is enabled (Backend/SCHEMA_RLS.sql turns it on)
 SELECT 
     tablename,
     rowsecurity as rls_enabled
 FROM pg_tables 
 WHERE schemaname = 'public' 
 AND tablename = 'students';
--- Should show: rls_enabled = false
+-- Should show: rls_enabled = true
 
 -- 3. Test insert to verify permissions work
 INSERT INTO students (student_id, student_name) 
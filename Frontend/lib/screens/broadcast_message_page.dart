@@ -100,7 +100,7 @@ class _BroadcastMessagePageState extends State<BroadcastMessagePage> {
         }),
       );
 
-      if (response.statusCode == 200) {
+      if (response.statusCode == 200 || response.statusCode == 202) {
         if (response.body.isNotEmpty) {
           try {
             final data = json.decode(response.body);
@@ -22,9 +22,8 @@ FROM pg_tables
 WHERE schemaname = 'public' 
 AND tablename = 'students';
 
--- 4. If RLS is enabled, disable it (for development)
--- Uncomment the line below if RLS is enabled:
--- ALTER TABLE students DISABLE ROW LEVEL SECURITY;
+-- 4. RLS should stay enabled; the backend reads and writes with
+-- SUPABASE_SERVICE_KEY, which bypasses it (see Backend/SCHEMA_RLS.sql)
 
 -- 5. Test insert (optional - to verify permissions)
 -- Uncomment to test: